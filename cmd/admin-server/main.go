@@ -0,0 +1,16 @@
+// Command admin-server serves the admin API: queue and consumer-group
+// statistics, per-domain crawl state, URL records, and pausing and resuming
+// the crawl or single domains.
+//
+// It is the same as nimbus admin.
+package main
+
+import (
+	"os"
+
+	"github.com/theognis1002/nimbus-crawler/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.Main(append([]string{"admin"}, os.Args[1:]...)))
+}
@@ -0,0 +1,16 @@
+// Command api-server serves the crawl API: batches of URLs submitted for
+// crawling with their progress, synchronous fetches of single pages, and
+// reads of crawled pages, their content and links.
+//
+// It is the same as nimbus api.
+package main
+
+import (
+	"os"
+
+	"github.com/theognis1002/nimbus-crawler/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.Main(append([]string{"api"}, os.Args[1:]...)))
+}
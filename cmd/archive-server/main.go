@@ -0,0 +1,17 @@
+// Command archive-server serves stored pages for browsing, Wayback Machine
+// style: captures of a URL by timestamp with their links rewritten to stay
+// inside the archive, a calendar of each URL's captures, and a domain
+// browser.
+//
+// It is the same as nimbus archive.
+package main
+
+import (
+	"os"
+
+	"github.com/theognis1002/nimbus-crawler/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.Main(append([]string{"archive"}, os.Args[1:]...)))
+}
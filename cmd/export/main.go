@@ -0,0 +1,18 @@
+// Command export writes the stored pages matching a filter to sharded
+// JSONL.gz, CSV or Parquet files, locally or in object storage. Each record
+// carries the page's id, URL, final URL, title, text, fetch time and content
+// hash. Rerunning an interrupted export with the same flags resumes it; a
+// finished export can be rebuilt identically into a new location.
+//
+// It is the same as nimbus export.
+package main
+
+import (
+	"os"
+
+	"github.com/theognis1002/nimbus-crawler/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.Main(append([]string{"export"}, os.Args[1:]...)))
+}
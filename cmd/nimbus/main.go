@@ -0,0 +1,14 @@
+// Command nimbus runs every part of the crawler as a subcommand: crawl,
+// parse, seed, migrate, dlq, export, status and the servers. Run nimbus -h
+// for the list.
+package main
+
+import (
+	"os"
+
+	"github.com/theognis1002/nimbus-crawler/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.Main(os.Args[1:]))
+}
@@ -0,0 +1,16 @@
+// Command recompress rewrites stored HTML and text objects in place with the
+// configured storage compression. Objects already stored that way are left
+// alone, so it can be rerun safely after an interruption.
+//
+// It is the same as nimbus recompress.
+package main
+
+import (
+	"os"
+
+	"github.com/theognis1002/nimbus-crawler/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.Main(append([]string{"recompress"}, os.Args[1:]...)))
+}
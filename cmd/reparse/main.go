@@ -0,0 +1,17 @@
+// Command reparse republishes parse messages for URLs whose HTML is already
+// stored, so changes to text and link extraction reach pages crawled before
+// them without recrawling. Messages are published at a fixed rate and held
+// back while the parse queue is busy, leaving room for live parsing.
+//
+// It is the same as nimbus reparse.
+package main
+
+import (
+	"os"
+
+	"github.com/theognis1002/nimbus-crawler/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.Main(append([]string{"reparse"}, os.Args[1:]...)))
+}
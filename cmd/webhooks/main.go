@@ -0,0 +1,15 @@
+// Command webhooks delivers crawl lifecycle events from the event stream to
+// the configured webhook endpoints.
+//
+// It is the same as nimbus webhooks.
+package main
+
+import (
+	"os"
+
+	"github.com/theognis1002/nimbus-crawler/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.Main(append([]string{"webhooks"}, os.Args[1:]...)))
+}
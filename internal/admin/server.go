@@ -0,0 +1,253 @@
+// Package admin serves a JSON API for inspecting and controlling a crawl.
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/theognis1002/nimbus-crawler/internal/cache"
+	"github.com/theognis1002/nimbus-crawler/internal/database/models"
+	"github.com/theognis1002/nimbus-crawler/internal/queue"
+)
+
+// Tokens authenticate API requests, sent as "Authorization: Bearer <token>".
+// Admin may do anything; Read may only use GET endpoints. An empty token
+// is never accepted.
+type Tokens struct {
+	Admin string
+	Read  string
+}
+
+// Server serves the admin API:
+//
+//	GET  /api/queues  stream lengths, consumer groups and their consumers
+//	GET  /api/pause  whether the crawl is paused, and the paused domains
+//	POST /api/pause, /api/resume  pause or resume the whole crawl
+//	GET  /api/domains/{domain}  crawl state and URL counts of a domain
+//	POST /api/domains/{domain}/pause, .../resume  pause or resume a domain
+//	GET  /api/urls/{id}, /api/urls?url=  a URL's record
+type Server struct {
+	store   Store
+	rdb     *redis.Client
+	pause   *cache.PauseFlags
+	streams []string
+	tokens  Tokens
+	logger  *slog.Logger
+	mux     *http.ServeMux
+}
+
+// NewServer returns a server reading records from store and flags and
+// streams from rdb. streams lists the queue streams to report; with nil,
+// /api/queues answers 501 as the queue backend is not Redis.
+func NewServer(store Store, rdb *redis.Client, streams []string, tokens Tokens, logger *slog.Logger) *Server {
+	s := &Server{
+		store:   store,
+		rdb:     rdb,
+		pause:   cache.NewPauseFlags(rdb),
+		streams: streams,
+		tokens:  tokens,
+		logger:  logger,
+		mux:     http.NewServeMux(),
+	}
+	s.mux.HandleFunc("GET /api/queues", s.handleQueues)
+	s.mux.HandleFunc("GET /api/pause", s.handlePauseState)
+	s.mux.HandleFunc("POST /api/pause", s.handleSetPaused("", true))
+	s.mux.HandleFunc("POST /api/resume", s.handleSetPaused("", false))
+	s.mux.HandleFunc("GET /api/domains/{domain}", s.handleDomain)
+	s.mux.HandleFunc("POST /api/domains/{domain}/pause", s.handleSetPaused("domain", true))
+	s.mux.HandleFunc("POST /api/domains/{domain}/resume", s.handleSetPaused("domain", false))
+	s.mux.HandleFunc("GET /api/urls", s.handleURLByURL)
+	s.mux.HandleFunc("GET /api/urls/{id}", s.handleURLByID)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	switch {
+	case ok && tokenMatches(token, s.tokens.Admin):
+	case ok && tokenMatches(token, s.tokens.Read):
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeError(w, http.StatusForbidden, "read-only token")
+			return
+		}
+	default:
+		w.Header().Set("WWW-Authenticate", `Bearer realm="nimbus-admin"`)
+		writeError(w, http.StatusUnauthorized, "missing or invalid token")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func tokenMatches(got, want string) bool {
+	return want != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+func (s *Server) handleQueues(w http.ResponseWriter, r *http.Request) {
+	if s.streams == nil {
+		writeError(w, http.StatusNotImplemented, "queue inspection needs the redis queue backend")
+		return
+	}
+	stats, err := queue.InspectStreams(r.Context(), s.rdb, s.streams)
+	if err != nil {
+		s.internalError(w, "failed to inspect streams", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+type pauseState struct {
+	Paused        bool     `json:"paused"`
+	PausedDomains []string `json:"paused_domains"`
+}
+
+func (s *Server) handlePauseState(w http.ResponseWriter, r *http.Request) {
+	global, domains, err := s.pause.State(r.Context())
+	if err != nil {
+		s.internalError(w, "failed to read pause flags", err)
+		return
+	}
+	if domains == nil {
+		domains = []string{}
+	}
+	writeJSON(w, http.StatusOK, pauseState{Paused: global, PausedDomains: domains})
+}
+
+// handleSetPaused pauses or resumes the whole crawl, or the domain named by
+// the path value param when it is set.
+func (s *Server) handleSetPaused(param string, paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var domain string
+		if param != "" {
+			domain = strings.ToLower(r.PathValue(param))
+		}
+		if err := s.pause.SetPaused(r.Context(), domain, paused); err != nil {
+			s.internalError(w, "failed to set pause flag", err)
+			return
+		}
+		s.logger.Info("pause flag set", "domain", domain, "paused", paused)
+		s.handlePauseState(w, r)
+	}
+}
+
+type domainResponse struct {
+	Domain        string                     `json:"domain"`
+	Known         bool                       `json:"known"`
+	Paused        bool                       `json:"paused"`
+	CrawlDelayMs  int                        `json:"crawl_delay_ms"`
+	LastCrawlTime *time.Time                 `json:"last_crawl_time"`
+	RobotsTxt     *string                    `json:"robots_txt"`
+	CreatedAt     *time.Time                 `json:"created_at,omitempty"`
+	URLCounts     map[models.URLStatus]int64 `json:"url_counts"`
+}
+
+// handleDomain reports a domain's crawl state. Domains with URLs that have
+// not been crawled yet have no domains row and are reported with Known
+// false.
+func (s *Server) handleDomain(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	domain := strings.ToLower(r.PathValue("domain"))
+	rec, err := s.store.Domain(ctx, domain)
+	if err != nil {
+		s.internalError(w, "failed to get domain", err)
+		return
+	}
+	counts, err := s.store.URLCounts(ctx, domain)
+	if err != nil {
+		s.internalError(w, "failed to count urls", err)
+		return
+	}
+	if rec == nil && len(counts) == 0 {
+		writeError(w, http.StatusNotFound, "unknown domain")
+		return
+	}
+	paused, err := s.pause.Paused(ctx, domain)
+	if err != nil {
+		s.internalError(w, "failed to read pause flags", err)
+		return
+	}
+
+	resp := domainResponse{Domain: domain, Paused: paused, URLCounts: counts}
+	if rec != nil {
+		resp.Known = true
+		resp.CrawlDelayMs = rec.CrawlDelayMs
+		resp.LastCrawlTime = rec.LastCrawlTime
+		resp.RobotsTxt = rec.RobotsTxt
+		resp.CreatedAt = &rec.CreatedAt
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+type urlResponse struct {
+	ID            string     `json:"id"`
+	URL           string     `json:"url"`
+	Domain        string     `json:"domain"`
+	Status        string     `json:"status"`
+	Depth         int        `json:"depth"`
+	RetryCount    int        `json:"retry_count"`
+	S3HTMLLink    *string    `json:"s3_html_link"`
+	S3TextLink    *string    `json:"s3_text_link"`
+	ContentHash   *string    `json:"content_hash"`
+	LastCrawlTime *time.Time `json:"last_crawl_time"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (s *Server) handleURLByID(w http.ResponseWriter, r *http.Request) {
+	rec, err := s.store.URLByID(r.Context(), r.PathValue("id"))
+	s.writeURL(w, rec, err)
+}
+
+func (s *Server) handleURLByURL(w http.ResponseWriter, r *http.Request) {
+	rawURL := r.URL.Query().Get("url")
+	if rawURL == "" {
+		writeError(w, http.StatusBadRequest, "url parameter required")
+		return
+	}
+	rec, err := s.store.URLByURL(r.Context(), rawURL)
+	s.writeURL(w, rec, err)
+}
+
+func (s *Server) writeURL(w http.ResponseWriter, rec *models.URLRecord, err error) {
+	if err != nil {
+		s.internalError(w, "failed to get url", err)
+		return
+	}
+	if rec == nil {
+		writeError(w, http.StatusNotFound, "unknown url")
+		return
+	}
+	writeJSON(w, http.StatusOK, urlResponse{
+		ID:            rec.ID,
+		URL:           rec.URL,
+		Domain:        rec.Domain,
+		Status:        rec.Status,
+		Depth:         rec.Depth,
+		RetryCount:    rec.RetryCount,
+		S3HTMLLink:    rec.S3HTMLLink,
+		S3TextLink:    rec.S3TextLink,
+		ContentHash:   rec.ContentHash,
+		LastCrawlTime: rec.LastCrawlTime,
+		CreatedAt:     rec.CreatedAt,
+		UpdatedAt:     rec.UpdatedAt,
+	})
+}
+
+func (s *Server) internalError(w http.ResponseWriter, msg string, err error) {
+	s.logger.Error(msg, "error", err)
+	writeError(w, http.StatusInternalServerError, "internal error")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,195 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/theognis1002/nimbus-crawler/internal/database/models"
+	"github.com/theognis1002/nimbus-crawler/internal/queue"
+)
+
+type fakeStore struct{}
+
+func (fakeStore) Domain(ctx context.Context, domain string) (*models.DomainRecord, error) {
+	if domain != "example.com" {
+		return nil, nil
+	}
+	robots := "User-agent: *\nDisallow: /private"
+	return &models.DomainRecord{Domain: domain, RobotsTxt: &robots, CrawlDelayMs: 1500, CreatedAt: time.Now()}, nil
+}
+
+func (fakeStore) URLCounts(ctx context.Context, domain string) (map[models.URLStatus]int64, error) {
+	if domain != "example.com" {
+		return map[models.URLStatus]int64{}, nil
+	}
+	return map[models.URLStatus]int64{models.StatusParsed: 3, models.StatusPending: 2}, nil
+}
+
+func (fakeStore) URLByID(ctx context.Context, id string) (*models.URLRecord, error) {
+	if id != "u1" {
+		return nil, nil
+	}
+	return &models.URLRecord{ID: id, URL: "https://example.com/", Domain: "example.com", Status: "parsed"}, nil
+}
+
+func (f fakeStore) URLByURL(ctx context.Context, rawURL string) (*models.URLRecord, error) {
+	if rawURL != "https://example.com/" {
+		return nil, nil
+	}
+	return f.URLByID(ctx, "u1")
+}
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	ctx := context.Background()
+	if err := rdb.XGroupCreateMkStream(ctx, queue.ParseStream, queue.ParserGroup, "0").Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rdb.XAdd(ctx, &redis.XAddArgs{Stream: queue.ParseStream, Values: map[string]any{"data": "{}"}}).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{Group: queue.ParserGroup, Consumer: "parser-1", Streams: []string{queue.ParseStream, ">"}, Count: 1}).Err(); err != nil {
+		t.Fatal(err)
+	}
+	tokens := Tokens{Admin: "admin-secret", Read: "read-secret"}
+	return NewServer(fakeStore{}, rdb, []string{queue.ParseStream, queue.ParseDLQ}, tokens, slog.New(slog.DiscardHandler))
+}
+
+func do(srv *Server, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServerAuth(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		method     string
+		token      string
+		wantStatus int
+	}{
+		{name: "no token", method: http.MethodGet, wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", method: http.MethodGet, token: "nope", wantStatus: http.StatusUnauthorized},
+		{name: "read token reads", method: http.MethodGet, token: "read-secret", wantStatus: http.StatusOK},
+		{name: "read token cannot write", method: http.MethodPost, token: "read-secret", wantStatus: http.StatusForbidden},
+		{name: "admin token reads", method: http.MethodGet, token: "admin-secret", wantStatus: http.StatusOK},
+		{name: "admin token writes", method: http.MethodPost, token: "admin-secret", wantStatus: http.StatusOK},
+	}
+
+	srv := newTestServer(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			path := "/api/pause"
+			if tt.method == http.MethodPost {
+				path = "/api/domains/auth.example/pause"
+			}
+			if rec := do(srv, tt.method, path, tt.token); rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
+
+func TestServerEmptyTokenRejected(t *testing.T) {
+	t.Parallel()
+
+	srv := NewServer(fakeStore{}, nil, nil, Tokens{Admin: "admin-secret"}, slog.New(slog.DiscardHandler))
+	req := httptest.NewRequest(http.MethodGet, "/api/pause", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestServerPauseAndResume(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer(t)
+	steps := []struct {
+		method, path string
+		want         pauseState
+	}{
+		{http.MethodPost, "/api/domains/Example.com/pause", pauseState{PausedDomains: []string{"example.com"}}},
+		{http.MethodPost, "/api/pause", pauseState{Paused: true, PausedDomains: []string{"example.com"}}},
+		{http.MethodPost, "/api/resume", pauseState{PausedDomains: []string{"example.com"}}},
+		{http.MethodPost, "/api/domains/example.com/resume", pauseState{PausedDomains: []string{}}},
+	}
+	for _, step := range steps {
+		rec := do(srv, step.method, step.path, "admin-secret")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s %s: status = %d: %s", step.method, step.path, rec.Code, rec.Body)
+		}
+		var got pauseState
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Paused != step.want.Paused || strings.Join(got.PausedDomains, ",") != strings.Join(step.want.PausedDomains, ",") {
+			t.Errorf("%s %s: state = %+v, want %+v", step.method, step.path, got, step.want)
+		}
+	}
+}
+
+func TestServerEndpoints(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "queues", path: "/api/queues", wantStatus: 200, wantBody: `"name":"parser-1","pending":1`},
+		{name: "missing stream", path: "/api/queues", wantStatus: 200, wantBody: `{"stream":"stream:parse:dlq","length":0,"groups":[]}`},
+		{name: "domain", path: "/api/domains/example.com", wantStatus: 200, wantBody: `"url_counts":{"parsed":3,"pending":2}`},
+		{name: "domain robots", path: "/api/domains/example.com", wantStatus: 200, wantBody: `"crawl_delay_ms":1500`},
+		{name: "unknown domain", path: "/api/domains/missing.example", wantStatus: 404},
+		{name: "url by id", path: "/api/urls/u1", wantStatus: 200, wantBody: `"url":"https://example.com/"`},
+		{name: "url by url", path: "/api/urls?url=https://example.com/", wantStatus: 200, wantBody: `"id":"u1"`},
+		{name: "unknown url", path: "/api/urls/u2", wantStatus: 404},
+		{name: "url parameter required", path: "/api/urls", wantStatus: 400},
+	}
+
+	srv := newTestServer(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			rec := do(srv, http.MethodGet, tt.path, "read-secret")
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", rec.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestServerQueuesNeedRedisBackend(t *testing.T) {
+	t.Parallel()
+
+	srv := NewServer(fakeStore{}, nil, nil, Tokens{Read: "read-secret"}, slog.New(slog.DiscardHandler))
+	if rec := do(srv, http.MethodGet, "/api/queues", "read-secret"); rec.Code != http.StatusNotImplemented {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotImplemented)
+	}
+}
@@ -0,0 +1,59 @@
+package admin
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/theognis1002/nimbus-crawler/internal/database/models"
+)
+
+// Store looks up crawl records. Lookups of records that do not exist return
+// nil and no error.
+type Store interface {
+	Domain(ctx context.Context, domain string) (*models.DomainRecord, error)
+	// URLCounts returns the number of URLs of domain in each status.
+	URLCounts(ctx context.Context, domain string) (map[models.URLStatus]int64, error)
+	URLByID(ctx context.Context, id string) (*models.URLRecord, error)
+	URLByURL(ctx context.Context, rawURL string) (*models.URLRecord, error)
+}
+
+// PostgresStore reads the domains and urls tables.
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresStore(pool *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{pool: pool}
+}
+
+func (s *PostgresStore) Domain(ctx context.Context, domain string) (*models.DomainRecord, error) {
+	return notFoundAsNil(models.GetDomain(ctx, s.pool, domain))
+}
+
+func (s *PostgresStore) URLCounts(ctx context.Context, domain string) (map[models.URLStatus]int64, error) {
+	return models.CountURLsByStatus(ctx, s.pool, domain)
+}
+
+func (s *PostgresStore) URLByID(ctx context.Context, id string) (*models.URLRecord, error) {
+	// IDs are UUIDs; anything else cannot match and would fail the query.
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, nil
+	}
+	return notFoundAsNil(models.GetURLByID(ctx, s.pool, id))
+}
+
+func (s *PostgresStore) URLByURL(ctx context.Context, rawURL string) (*models.URLRecord, error) {
+	return notFoundAsNil(models.GetURLByURL(ctx, s.pool, rawURL))
+}
+
+func notFoundAsNil[T any](v *T, err error) (*T, error) {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return v, err
+}
+
+var _ Store = (*PostgresStore)(nil)
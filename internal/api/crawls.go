@@ -0,0 +1,301 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/theognis1002/nimbus-crawler/internal/database/models"
+	"github.com/theognis1002/nimbus-crawler/internal/queue"
+	"github.com/theognis1002/nimbus-crawler/internal/robots"
+)
+
+const (
+	// maxBatchURLs is the most URLs one crawl request may submit.
+	maxBatchURLs = 1000
+	// maxRequestBytes caps request bodies.
+	maxRequestBytes = 1 << 20
+)
+
+// Store records crawl requests and the URLs they submit, and reads crawled
+// pages. Lookups of records that do not exist return nil and no error.
+type Store interface {
+	// CreateRequest records req and its urls, setting req's ID.
+	CreateRequest(ctx context.Context, req *models.CrawlRequest, urls []string) error
+	// InsertURLs inserts urls at depth 0 with their domains and returns the
+	// ones that were not known before.
+	InsertURLs(ctx context.Context, urls, domains []string) ([]string, error)
+	SetEnqueued(ctx context.Context, id string, n int) error
+	// Request returns a request and its URL counts by status, or nil if
+	// there is no such request.
+	Request(ctx context.Context, id string) (*models.CrawlRequest, map[models.URLStatus]int64, error)
+
+	// Pages pages through the pages matching f in id order.
+	Pages(ctx context.Context, f models.URLFilter, after string, limit int) ([]models.URLRecord, error)
+	Page(ctx context.Context, id string) (*models.URLRecord, error)
+	// Changes pages through pages whose content changed after since; see
+	// models.ListChangedURLs.
+	Changes(ctx context.Context, since time.Time, afterID string, limit int) ([]models.URLRecord, error)
+	// Outlinks and Inlinks page through links in URL order.
+	Outlinks(ctx context.Context, id, after string, limit int) ([]models.Link, error)
+	Inlinks(ctx context.Context, rawURL, after string, limit int) ([]models.Link, error)
+	// Search returns the pages matching a full-text search, best first.
+	Search(ctx context.Context, q models.SearchQuery) ([]models.SearchResult, error)
+}
+
+// PostgresStore keeps crawl requests in the crawl_requests tables and reads
+// pages from the urls, links and page_search tables.
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresStore(pool *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{pool: pool}
+}
+
+func (s *PostgresStore) CreateRequest(ctx context.Context, req *models.CrawlRequest, urls []string) error {
+	return models.InsertCrawlRequest(ctx, s.pool, req, urls)
+}
+
+func (s *PostgresStore) InsertURLs(ctx context.Context, urls, domains []string) ([]string, error) {
+	seen := make(map[string]bool)
+	for _, d := range domains {
+		if seen[d] {
+			continue
+		}
+		seen[d] = true
+		if err := models.UpsertDomain(ctx, s.pool, d, robots.DefaultCrawlDelayMs); err != nil {
+			return nil, err
+		}
+	}
+	inserted, err := models.BulkInsertURLs(ctx, s.pool, urls, domains, 0)
+	out := make([]string, len(inserted))
+	for i, u := range inserted {
+		out[i] = u.URL
+	}
+	return out, err
+}
+
+func (s *PostgresStore) SetEnqueued(ctx context.Context, id string, n int) error {
+	return models.SetCrawlRequestEnqueued(ctx, s.pool, id, n)
+}
+
+func (s *PostgresStore) Request(ctx context.Context, id string) (*models.CrawlRequest, map[models.URLStatus]int64, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, nil, nil
+	}
+	req, counts, err := models.GetCrawlRequest(ctx, s.pool, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, nil
+	}
+	return req, counts, err
+}
+
+func (s *PostgresStore) Pages(ctx context.Context, f models.URLFilter, after string, limit int) ([]models.URLRecord, error) {
+	return models.ListURLs(ctx, s.pool, f, after, limit)
+}
+
+func (s *PostgresStore) Page(ctx context.Context, id string) (*models.URLRecord, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, nil
+	}
+	rec, err := models.GetURLByID(ctx, s.pool, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return rec, err
+}
+
+func (s *PostgresStore) Changes(ctx context.Context, since time.Time, afterID string, limit int) ([]models.URLRecord, error) {
+	return models.ListChangedURLs(ctx, s.pool, since, afterID, limit)
+}
+
+func (s *PostgresStore) Outlinks(ctx context.Context, id, after string, limit int) ([]models.Link, error) {
+	return models.ListOutlinks(ctx, s.pool, id, after, limit)
+}
+
+func (s *PostgresStore) Inlinks(ctx context.Context, rawURL, after string, limit int) ([]models.Link, error) {
+	return models.ListInlinks(ctx, s.pool, rawURL, after, limit)
+}
+
+func (s *PostgresStore) Search(ctx context.Context, q models.SearchQuery) ([]models.SearchResult, error) {
+	return models.SearchPages(ctx, s.pool, q)
+}
+
+var _ Store = (*PostgresStore)(nil)
+
+type submitRequest struct {
+	URLs []string `json:"urls"`
+	// Priority is the frontier lane; nil means the highest, as for seeds.
+	Priority *int `json:"priority"`
+	// MaxDepth is how many links deep to follow; 0 means the crawler's
+	// max_depth.
+	MaxDepth int    `json:"max_depth"`
+	Scope    string `json:"scope"`
+}
+
+type rejectedURL struct {
+	URL   string `json:"url"`
+	Error string `json:"error"`
+}
+
+type submitResponse struct {
+	ID string `json:"id"`
+	// URLs counts the accepted URLs and Enqueued the ones that were new
+	// and queued; URLs known before keep their current state.
+	URLs     int           `json:"urls"`
+	Enqueued int           `json:"enqueued"`
+	Rejected []rejectedURL `json:"rejected"`
+}
+
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var body submitRequest
+	if !decode(w, r, &body) {
+		return
+	}
+	switch {
+	case len(body.URLs) == 0:
+		writeError(w, http.StatusBadRequest, "urls required")
+		return
+	case len(body.URLs) > maxBatchURLs:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("at most %d urls per request", maxBatchURLs))
+		return
+	case body.MaxDepth < 0 || body.MaxDepth > s.maxDepth:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("max_depth must be between 0 and %d", s.maxDepth))
+		return
+	}
+	switch body.Scope {
+	case queue.ScopeAll, queue.ScopePage, queue.ScopeHost, queue.ScopeDomain:
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown scope %q", body.Scope))
+		return
+	}
+	priority := queue.HighestPriority
+	if body.Priority != nil {
+		if *body.Priority < 0 || *body.Priority > queue.HighestPriority {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("priority must be between 0 and %d", queue.HighestPriority))
+			return
+		}
+		priority = *body.Priority
+	}
+
+	resp := submitResponse{Rejected: []rejectedURL{}}
+	var urls, domains []string
+	seen := make(map[string]bool, len(body.URLs))
+	for _, raw := range body.URLs {
+		domain, err := crawlableDomain(raw)
+		if err != nil {
+			resp.Rejected = append(resp.Rejected, rejectedURL{URL: raw, Error: err.Error()})
+			continue
+		}
+		if seen[raw] {
+			continue
+		}
+		seen[raw] = true
+		urls = append(urls, raw)
+		domains = append(domains, domain)
+	}
+	if len(urls) == 0 {
+		writeJSON(w, http.StatusBadRequest, resp)
+		return
+	}
+
+	ctx := r.Context()
+	req := &models.CrawlRequest{Priority: priority, MaxDepth: body.MaxDepth, Scope: body.Scope}
+	if err := s.store.CreateRequest(ctx, req, urls); err != nil {
+		s.internalError(w, "failed to record crawl request", err)
+		return
+	}
+	resp.ID = req.ID
+	resp.URLs = len(urls)
+
+	inserted, err := s.store.InsertURLs(ctx, urls, domains)
+	if err != nil && len(inserted) == 0 {
+		s.internalError(w, "failed to insert urls", err)
+		return
+	}
+	if err != nil {
+		s.logger.Error("bulk insert partially failed", "request_id", req.ID, "error", err, "inserted", len(inserted))
+	}
+	if len(inserted) > 0 {
+		msgs := make([]queue.URLMessage, len(inserted))
+		for i, u := range inserted {
+			msgs[i] = queue.URLMessage{
+				URL:      u,
+				Priority: priority,
+				JobID:    req.ID,
+				Scope:    body.Scope,
+				MaxDepth: body.MaxDepth,
+			}
+		}
+		if err := s.publisher.PublishURLBatch(ctx, msgs); err != nil {
+			s.internalError(w, "failed to publish urls", err)
+			return
+		}
+	}
+	resp.Enqueued = len(inserted)
+	if err := s.store.SetEnqueued(ctx, req.ID, resp.Enqueued); err != nil {
+		s.logger.Warn("failed to record enqueued count", "request_id", req.ID, "error", err)
+	}
+
+	s.logger.Info("crawl request submitted", "request_id", req.ID, "urls", resp.URLs, "enqueued", resp.Enqueued)
+	writeJSON(w, http.StatusAccepted, resp)
+}
+
+// crawlableDomain returns the domain of rawURL, or why it cannot be crawled.
+func crawlableDomain(rawURL string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", errors.New("invalid url")
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", fmt.Errorf("unsupported scheme %q", parsed.Scheme)
+	}
+	if parsed.Hostname() == "" {
+		return "", errors.New("no host")
+	}
+	return parsed.Hostname(), nil
+}
+
+type progressResponse struct {
+	ID        string                     `json:"id"`
+	Priority  int                        `json:"priority"`
+	MaxDepth  int                        `json:"max_depth"`
+	Scope     string                     `json:"scope"`
+	CreatedAt time.Time                  `json:"created_at"`
+	URLs      int                        `json:"urls"`
+	Enqueued  int                        `json:"enqueued"`
+	Status    map[models.URLStatus]int64 `json:"status"`
+	// Done is set once every submitted URL is parsed, failed or skipped.
+	Done bool `json:"done"`
+}
+
+func (s *Server) handleProgress(w http.ResponseWriter, r *http.Request) {
+	req, counts, err := s.store.Request(r.Context(), r.PathValue("id"))
+	if err != nil {
+		s.internalError(w, "failed to get crawl request", err)
+		return
+	}
+	if req == nil {
+		writeError(w, http.StatusNotFound, "unknown crawl request")
+		return
+	}
+	finished := counts[models.StatusParsed] + counts[models.StatusFailed] + counts[models.StatusSkipped]
+	writeJSON(w, http.StatusOK, progressResponse{
+		ID:        req.ID,
+		Priority:  req.Priority,
+		MaxDepth:  req.MaxDepth,
+		Scope:     req.Scope,
+		CreatedAt: req.CreatedAt,
+		URLs:      req.URLs,
+		Enqueued:  req.Enqueued,
+		Status:    counts,
+		Done:      finished == int64(req.URLs),
+	})
+}
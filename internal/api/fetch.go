@@ -0,0 +1,164 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/theognis1002/nimbus-crawler/internal/cache"
+	"github.com/theognis1002/nimbus-crawler/internal/crawler"
+	"github.com/theognis1002/nimbus-crawler/internal/parser"
+	"github.com/theognis1002/nimbus-crawler/internal/robots"
+)
+
+// fetchTimeout bounds a synchronous fetch, including the wait for the
+// domain's rate limit.
+const fetchTimeout = 60 * time.Second
+
+// PageFetcher fetches and parses single pages on demand, the way crawlers
+// and parsers do, without storing anything.
+type PageFetcher struct {
+	fetcher     *crawler.Fetcher
+	robotsCheck *robots.Checker
+	rateLimiter *cache.RateLimiter
+}
+
+// NewPageFetcher returns a fetcher that checks robots.txt with robotsCheck,
+// unless it is nil, and waits for the domain's slot in rateLimiter.
+func NewPageFetcher(fetcher *crawler.Fetcher, robotsCheck *robots.Checker, rateLimiter *cache.RateLimiter) *PageFetcher {
+	return &PageFetcher{fetcher: fetcher, robotsCheck: robotsCheck, rateLimiter: rateLimiter}
+}
+
+// Page is the result of fetching one URL. A page disallowed by robots.txt is
+// not fetched, and only pages fetched with status 200 are parsed.
+type Page struct {
+	URL        string    `json:"url"`
+	Allowed    bool      `json:"allowed"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Metadata   *Metadata `json:"metadata,omitempty"`
+	Text       string    `json:"text,omitempty"`
+	Links      []string  `json:"links,omitempty"`
+}
+
+// Metadata describes a fetched response.
+type Metadata struct {
+	// FinalURL is the URL fetched after following redirects.
+	FinalURL        string    `json:"final_url"`
+	Title           string    `json:"title,omitempty"`
+	Language        string    `json:"language,omitempty"`
+	ContentType     string    `json:"content_type,omitempty"`
+	ContentLanguage string    `json:"content_language,omitempty"`
+	LastModified    string    `json:"last_modified,omitempty"`
+	ContentHash     string    `json:"content_hash,omitempty"`
+	Bytes           int64     `json:"bytes"`
+	FetchedAt       time.Time `json:"fetched_at"`
+	ElapsedMs       int64     `json:"elapsed_ms"`
+}
+
+// FetchPage fetches and parses rawURL.
+func (f *PageFetcher) FetchPage(ctx context.Context, rawURL string) (*Page, error) {
+	domain, err := crawlableDomain(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	page := &Page{URL: rawURL, Allowed: true}
+
+	crawlDelay := robots.DefaultCrawlDelayMs
+	if f.robotsCheck != nil {
+		allowed, delay, err := f.robotsCheck.IsAllowed(ctx, rawURL, domain)
+		if err != nil {
+			return nil, fmt.Errorf("checking robots.txt: %w", err)
+		}
+		if !allowed {
+			page.Allowed = false
+			return page, nil
+		}
+		crawlDelay = delay
+	}
+	if err := f.rateLimiter.WaitForAllow(ctx, domain, crawlDelay); err != nil {
+		return nil, fmt.Errorf("waiting for rate limit: %w", err)
+	}
+
+	body, status, err := f.fetcher.FetchStream(ctx, rawURL)
+	if body != nil {
+		defer body.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	page.StatusCode = status
+	resp := body.Response
+	page.Metadata = &Metadata{
+		FinalURL:        resp.Request.URL.String(),
+		ContentType:     resp.Header.Get("Content-Type"),
+		ContentLanguage: resp.Header.Get("Content-Language"),
+		LastModified:    resp.Header.Get("Last-Modified"),
+		FetchedAt:       body.FetchedAt,
+		ElapsedMs:       body.Elapsed.Milliseconds(),
+	}
+	if status != http.StatusOK {
+		return page, nil
+	}
+
+	// Hash the body as it is parsed, as the crawler does when storing it.
+	hash := sha256.New()
+	counter := &countingWriter{w: hash}
+	var text strings.Builder
+	doc, err := parser.ExtractDocument(io.TeeReader(body, counter), page.Metadata.FinalURL, &text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", rawURL, err)
+	}
+	page.Metadata.Title = doc.Title
+	page.Metadata.Language = doc.Language
+	page.Metadata.ContentHash = hex.EncodeToString(hash.Sum(nil))
+	page.Metadata.Bytes = counter.n
+	page.Text = text.String()
+	page.Links = doc.Links
+	return page, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+type fetchRequest struct {
+	URL string `json:"url"`
+}
+
+func (s *Server) handleFetch(w http.ResponseWriter, r *http.Request) {
+	var body fetchRequest
+	if !decode(w, r, &body) {
+		return
+	}
+	if _, err := crawlableDomain(body.URL); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), fetchTimeout)
+	defer cancel()
+	page, err := s.fetcher.FetchPage(ctx, body.URL)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			writeError(w, http.StatusGatewayTimeout, err.Error())
+			return
+		}
+		s.logger.Warn("on-demand fetch failed", "url", body.URL, "error", err)
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
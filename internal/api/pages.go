@@ -0,0 +1,321 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/theognis1002/nimbus-crawler/internal/database/models"
+	"github.com/theognis1002/nimbus-crawler/internal/storage"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+type pageResponse struct {
+	ID               string     `json:"id"`
+	URL              string     `json:"url"`
+	Domain           string     `json:"domain"`
+	Status           string     `json:"status"`
+	Depth            int        `json:"depth"`
+	Title            *string    `json:"title"`
+	Language         *string    `json:"language"`
+	ContentHash      *string    `json:"content_hash"`
+	LastCrawlTime    *time.Time `json:"last_crawl_time"`
+	ContentChangedAt *time.Time `json:"content_changed_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	// HTMLURL and TextURL are the API paths of the stored HTML and text,
+	// when there are any.
+	HTMLURL string `json:"html_url,omitempty"`
+	TextURL string `json:"text_url,omitempty"`
+}
+
+func newPageResponse(rec *models.URLRecord) pageResponse {
+	p := pageResponse{
+		ID:               rec.ID,
+		URL:              rec.URL,
+		Domain:           rec.Domain,
+		Status:           rec.Status,
+		Depth:            rec.Depth,
+		Title:            rec.Title,
+		Language:         rec.Language,
+		ContentHash:      rec.ContentHash,
+		LastCrawlTime:    rec.LastCrawlTime,
+		ContentChangedAt: rec.ContentChangedAt,
+		UpdatedAt:        rec.UpdatedAt,
+	}
+	if rec.S3HTMLLink != nil {
+		p.HTMLURL = "/api/pages/" + rec.ID + "/html"
+	}
+	if rec.S3TextLink != nil {
+		p.TextURL = "/api/pages/" + rec.ID + "/text"
+	}
+	return p
+}
+
+type pageList struct {
+	Pages []pageResponse `json:"pages"`
+	// NextCursor fetches the next page of results; it is empty after the
+	// last.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+func newPageList(records []models.URLRecord, limit int, cursor func(models.URLRecord) string) pageList {
+	list := pageList{Pages: make([]pageResponse, len(records))}
+	for i := range records {
+		list.Pages[i] = newPageResponse(&records[i])
+	}
+	if len(records) == limit {
+		list.NextCursor = cursor(records[len(records)-1])
+	}
+	return list
+}
+
+// handlePages lists pages with stored HTML, filtered by domain, status (a
+// comma-separated list), last crawl time (since, until) and language.
+func (s *Server) handlePages(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, after, ok := pageParams(w, q.Get("limit"), q.Get("cursor"))
+	if !ok {
+		return
+	}
+	if after != "" {
+		if _, err := uuid.Parse(after); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+	}
+	f := models.URLFilter{Domain: q.Get("domain"), Language: q.Get("language")}
+	if statuses := q.Get("status"); statuses != "" {
+		for _, st := range strings.Split(statuses, ",") {
+			f.Statuses = append(f.Statuses, models.URLStatus(strings.TrimSpace(st)))
+		}
+	}
+	var err error
+	if f.CrawledSince, err = parseTime(q.Get("since")); err != nil {
+		writeError(w, http.StatusBadRequest, "since: "+err.Error())
+		return
+	}
+	if f.CrawledUntil, err = parseTime(q.Get("until")); err != nil {
+		writeError(w, http.StatusBadRequest, "until: "+err.Error())
+		return
+	}
+
+	records, err := s.store.Pages(r.Context(), f, after, limit)
+	if err != nil {
+		s.internalError(w, "failed to list pages", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newPageList(records, limit, func(rec models.URLRecord) string {
+		return encodeCursor(rec.ID)
+	}))
+}
+
+// handleChanges lists pages whose content changed after since, oldest
+// change first. Clients keep the last next_cursor to resume from.
+func (s *Server) handleChanges(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, cursor, ok := pageParams(w, q.Get("limit"), q.Get("cursor"))
+	if !ok {
+		return
+	}
+	var since time.Time
+	var afterID string
+	if cursor != "" {
+		ts, id, found := strings.Cut(cursor, "|")
+		var err error
+		since, err = time.Parse(time.RFC3339Nano, ts)
+		if _, idErr := uuid.Parse(id); !found || err != nil || idErr != nil {
+			writeError(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+		afterID = id
+	} else {
+		var err error
+		if since, err = parseTime(q.Get("since")); err != nil || since.IsZero() {
+			writeError(w, http.StatusBadRequest, "since or cursor required")
+			return
+		}
+	}
+
+	records, err := s.store.Changes(r.Context(), since, afterID, limit)
+	if err != nil {
+		s.internalError(w, "failed to list changes", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newPageList(records, limit, func(rec models.URLRecord) string {
+		return encodeCursor(rec.ContentChangedAt.UTC().Format(time.RFC3339Nano) + "|" + rec.ID)
+	}))
+}
+
+func (s *Server) handlePage(w http.ResponseWriter, r *http.Request) {
+	rec, ok := s.page(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, newPageResponse(rec))
+}
+
+// handleContent streams a page's stored HTML or text.
+func (s *Server) handleContent(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rec, ok := s.page(w, r)
+		if !ok {
+			return
+		}
+		link := rec.S3HTMLLink
+		if kind == "text" {
+			link = rec.S3TextLink
+		}
+		if link == nil {
+			writeError(w, http.StatusNotFound, "no stored "+kind)
+			return
+		}
+		bucket, key, ok := storage.SplitLink(*link)
+		if !ok {
+			s.internalError(w, "invalid stored link", fmt.Errorf("link %q of %s", *link, rec.ID))
+			return
+		}
+		obj, info, err := s.objects.Get(r.Context(), bucket, key)
+		if errors.Is(err, storage.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "stored "+kind+" not found")
+			return
+		}
+		if err != nil {
+			s.internalError(w, "failed to read stored "+kind, err)
+			return
+		}
+		defer obj.Close()
+
+		contentType := info.ContentType
+		if contentType == "" {
+			contentType = map[string]string{"html": "text/html", "text": "text/plain"}[kind]
+		}
+		w.Header().Set("Content-Type", contentType)
+		if info.Size >= 0 {
+			w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+		}
+		if _, err := io.Copy(w, obj); err != nil {
+			s.logger.Warn("failed to stream stored "+kind, "id", rec.ID, "error", err)
+		}
+	}
+}
+
+type linkResponse struct {
+	URL string  `json:"url"`
+	ID  *string `json:"id"`
+}
+
+type linkList struct {
+	Links      []linkResponse `json:"links"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// handleLinks lists the links found on a page (direction=out, the default)
+// or the pages linking to it (direction=in). Links are only known when the
+// parser records them.
+func (s *Server) handleLinks(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, after, ok := pageParams(w, q.Get("limit"), q.Get("cursor"))
+	if !ok {
+		return
+	}
+	rec, ok := s.page(w, r)
+	if !ok {
+		return
+	}
+
+	var links []models.Link
+	var err error
+	switch q.Get("direction") {
+	case "", "out":
+		links, err = s.store.Outlinks(r.Context(), rec.ID, after, limit)
+	case "in":
+		links, err = s.store.Inlinks(r.Context(), rec.URL, after, limit)
+	default:
+		writeError(w, http.StatusBadRequest, "direction must be in or out")
+		return
+	}
+	if err != nil {
+		s.internalError(w, "failed to list links", err)
+		return
+	}
+
+	list := linkList{Links: make([]linkResponse, len(links))}
+	for i, l := range links {
+		list.Links[i] = linkResponse{URL: l.URL, ID: l.ID}
+	}
+	if len(links) == limit {
+		list.NextCursor = encodeCursor(links[len(links)-1].URL)
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// page looks up the page named by the request's id, answering 404 if there
+// is none.
+func (s *Server) page(w http.ResponseWriter, r *http.Request) (*models.URLRecord, bool) {
+	rec, err := s.store.Page(r.Context(), r.PathValue("id"))
+	if err != nil {
+		s.internalError(w, "failed to get page", err)
+		return nil, false
+	}
+	if rec == nil {
+		writeError(w, http.StatusNotFound, "unknown page")
+		return nil, false
+	}
+	return rec, true
+}
+
+// pageParams parses a limit and a cursor, answering 400 if either is
+// invalid.
+func pageParams(w http.ResponseWriter, rawLimit, rawCursor string) (limit int, cursor string, ok bool) {
+	limit = defaultPageLimit
+	if rawLimit != "" {
+		n, err := strconv.Atoi(rawLimit)
+		if err != nil || n < 1 || n > maxPageLimit {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxPageLimit))
+			return 0, "", false
+		}
+		limit = n
+	}
+	if rawCursor != "" {
+		var err error
+		if cursor, err = decodeCursor(rawCursor); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid cursor")
+			return 0, "", false
+		}
+	}
+	return limit, cursor, true
+}
+
+// Cursors are opaque to clients.
+func encodeCursor(s string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+func decodeCursor(s string) (string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	return string(b), err
+}
+
+// parseTime parses an RFC 3339 time or a date; "" is the zero time.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errors.New("want an RFC 3339 time or YYYY-MM-DD date")
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+	"github.com/theognis1002/nimbus-crawler/internal/database/models"
+	"github.com/theognis1002/nimbus-crawler/internal/storage"
+)
+
+const (
+	pageA = "00000000-0000-0000-0000-00000000000a"
+	pageB = "00000000-0000-0000-0000-00000000000b"
+)
+
+var changedAt = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func strPtr(s string) *string { return &s }
+
+// testPages are two parsed pages; a links to b.
+func testPages() []models.URLRecord {
+	return []models.URLRecord{
+		{ID: pageA, URL: "https://example.com/a", Domain: "example.com", Status: "parsed", Language: strPtr("en"), Title: strPtr("A"),
+			S3HTMLLink: strPtr("nimbus-html/a.html"), S3TextLink: strPtr("nimbus-text/a.txt"), ContentChangedAt: &changedAt},
+		{ID: pageB, URL: "https://example.com/b", Domain: "example.com", Status: "parsed", Language: strPtr("de"),
+			S3HTMLLink: strPtr("nimbus-html/b.html"), ContentChangedAt: &changedAt},
+	}
+}
+
+func newTestObjects(t *testing.T) storage.ObjectStore {
+	t.Helper()
+	store := storage.NewMemoryStore()
+	for key, body := range map[string]string{"a.html": "<html>a</html>", "a.txt": "text of a"} {
+		bucket := "nimbus-html"
+		if strings.HasSuffix(key, ".txt") {
+			bucket = "nimbus-text"
+		}
+		if err := store.Put(context.Background(), bucket, key, strings.NewReader(body), int64(len(body)), storage.PutOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func (f *fakeStore) Pages(ctx context.Context, filter models.URLFilter, after string, limit int) ([]models.URLRecord, error) {
+	var out []models.URLRecord
+	for _, p := range testPages() {
+		if p.ID > after && (filter.Language == "" || *p.Language == filter.Language) && len(out) < limit {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeStore) Page(ctx context.Context, id string) (*models.URLRecord, error) {
+	for _, p := range testPages() {
+		if p.ID == id {
+			return &p, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeStore) Changes(ctx context.Context, since time.Time, afterID string, limit int) ([]models.URLRecord, error) {
+	var out []models.URLRecord
+	for _, p := range testPages() {
+		after := p.ContentChangedAt.After(since) || (p.ContentChangedAt.Equal(since) && afterID != "" && p.ID > afterID)
+		if after && len(out) < limit {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeStore) Outlinks(ctx context.Context, id, after string, limit int) ([]models.Link, error) {
+	if id != pageA {
+		return nil, nil
+	}
+	return []models.Link{{URL: "https://example.com/b", ID: strPtr(pageB)}, {URL: "https://other.example/"}}, nil
+}
+
+func (f *fakeStore) Inlinks(ctx context.Context, rawURL, after string, limit int) ([]models.Link, error) {
+	if rawURL != "https://example.com/b" {
+		return nil, nil
+	}
+	return []models.Link{{URL: "https://example.com/a", ID: strPtr(pageA)}}, nil
+}
+
+func TestPages(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "page", path: "/api/pages/" + pageA, wantStatus: 200, wantBody: `"title":"A","language":"en"`},
+		{name: "content urls", path: "/api/pages/" + pageA, wantStatus: 200, wantBody: `"html_url":"/api/pages/` + pageA + `/html","text_url":"/api/pages/` + pageA + `/text"`},
+		{name: "unknown page", path: "/api/pages/" + pageB + "0", wantStatus: 404},
+		{name: "html", path: "/api/pages/" + pageA + "/html", wantStatus: 200, wantBody: "<html>a</html>"},
+		{name: "text", path: "/api/pages/" + pageA + "/text", wantStatus: 200, wantBody: "text of a"},
+		{name: "missing stored html", path: "/api/pages/" + pageB + "/html", wantStatus: 404},
+		{name: "no text", path: "/api/pages/" + pageB + "/text", wantStatus: 404, wantBody: "no stored text"},
+		{name: "outlinks", path: "/api/pages/" + pageA + "/links", wantStatus: 200, wantBody: `{"url":"https://other.example/","id":null}`},
+		{name: "inlinks", path: "/api/pages/" + pageB + "/links?direction=in", wantStatus: 200, wantBody: `{"url":"https://example.com/a","id":"` + pageA + `"}`},
+		{name: "bad direction", path: "/api/pages/" + pageA + "/links?direction=up", wantStatus: 400},
+		{name: "language filter", path: "/api/pages?language=de", wantStatus: 200, wantBody: `"id":"` + pageB + `"`},
+		{name: "bad since", path: "/api/pages?since=yesterday", wantStatus: 400},
+		{name: "bad limit", path: "/api/pages?limit=0", wantStatus: 400},
+		{name: "bad cursor", path: "/api/pages?cursor=" + encodeCursor("not-a-uuid"), wantStatus: 400},
+		{name: "changes need since", path: "/api/changes", wantStatus: 400},
+	}
+
+	srv, _ := newTestServer(t, config.APIConfig{Insecure: true})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			rec := do(srv, http.MethodGet, tt.path, "")
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", rec.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+// collect follows next_cursor from path to the end and returns the page ids
+// seen.
+func collect(t *testing.T, srv *Server, path string) []string {
+	t.Helper()
+	var ids []string
+	for range 10 {
+		rec := do(srv, http.MethodGet, path, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: status = %d: %s", path, rec.Code, rec.Body)
+		}
+		var list pageList
+		if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range list.Pages {
+			ids = append(ids, p.ID)
+		}
+		if list.NextCursor == "" {
+			return ids
+		}
+		base, _, _ := strings.Cut(path, "?")
+		path = base + "?limit=1&cursor=" + list.NextCursor
+	}
+	t.Fatalf("GET %s: cursor never ran out", path)
+	return nil
+}
+
+func TestPagination(t *testing.T) {
+	t.Parallel()
+
+	srv, _ := newTestServer(t, config.APIConfig{Insecure: true})
+	want := []string{pageA, pageB}
+	if got := collect(t, srv, "/api/pages?limit=1"); !slices.Equal(got, want) {
+		t.Errorf("pages = %v, want %v", got, want)
+	}
+	since := changedAt.Add(-time.Second).Format(time.RFC3339)
+	if got := collect(t, srv, "/api/changes?limit=1&since="+since); !slices.Equal(got, want) {
+		t.Errorf("changes = %v, want %v", got, want)
+	}
+	if got := collect(t, srv, "/api/changes?since="+changedAt.Format(time.RFC3339)); len(got) != 0 {
+		t.Errorf("changes after the last change = %v, want none", got)
+	}
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/theognis1002/nimbus-crawler/internal/database/models"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	// maxSearchOffset bounds how deep clients may page, since every page
+	// ranks all the matches before it.
+	maxSearchOffset = 1000
+)
+
+type searchResult struct {
+	ID            string     `json:"id"`
+	URL           string     `json:"url"`
+	Title         *string    `json:"title"`
+	LastCrawlTime *time.Time `json:"last_crawl_time"`
+	Rank          float32    `json:"rank"`
+	// Snippet is HTML-escaped text around the matches, which are wrapped in
+	// <b> tags.
+	Snippet string `json:"snippet"`
+}
+
+type searchResponse struct {
+	Results []searchResult `json:"results"`
+	// NextOffset fetches the next page of results; it is absent after the
+	// last.
+	NextOffset *int `json:"next_offset,omitempty"`
+}
+
+// handleSearch ranks indexed pages against q, in websearch syntax, filtered
+// by domain, last crawl time (since, until) and language.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := models.SearchQuery{
+		Query:    q.Get("q"),
+		Language: q.Get("language"),
+		Domain:   q.Get("domain"),
+		Limit:    defaultSearchLimit,
+	}
+	if query.Query == "" {
+		writeError(w, http.StatusBadRequest, "q required")
+		return
+	}
+	if raw := q.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxSearchLimit))
+			return
+		}
+		query.Limit = n
+	}
+	if raw := q.Get("offset"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 || n > maxSearchOffset {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("offset must be between 0 and %d", maxSearchOffset))
+			return
+		}
+		query.Offset = n
+	}
+	var err error
+	if query.Since, err = parseTime(q.Get("since")); err != nil {
+		writeError(w, http.StatusBadRequest, "since: "+err.Error())
+		return
+	}
+	if query.Until, err = parseTime(q.Get("until")); err != nil {
+		writeError(w, http.StatusBadRequest, "until: "+err.Error())
+		return
+	}
+
+	results, err := s.store.Search(r.Context(), query)
+	if err != nil {
+		s.internalError(w, "failed to search pages", err)
+		return
+	}
+	resp := searchResponse{Results: make([]searchResult, len(results))}
+	for i, res := range results {
+		resp.Results[i] = searchResult{
+			ID:            res.ID,
+			URL:           res.URL,
+			Title:         res.Title,
+			LastCrawlTime: res.LastCrawlTime,
+			Rank:          res.Rank,
+			Snippet:       res.Snippet,
+		}
+	}
+	if next := query.Offset + query.Limit; len(results) == query.Limit && next <= maxSearchOffset {
+		resp.NextOffset = &next
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
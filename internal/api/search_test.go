@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+	"github.com/theognis1002/nimbus-crawler/internal/database/models"
+)
+
+// Search matches testPages whose URL contains the query.
+func (f *fakeStore) Search(ctx context.Context, q models.SearchQuery) ([]models.SearchResult, error) {
+	var results []models.SearchResult
+	for _, p := range testPages() {
+		if !strings.Contains(p.URL, q.Query) || (q.Domain != "" && p.Domain != q.Domain) || (q.Language != "" && *p.Language != q.Language) {
+			continue
+		}
+		results = append(results, models.SearchResult{ID: p.ID, URL: p.URL, Title: p.Title, Rank: 0.5, Snippet: "<b>" + q.Query + "</b>"})
+	}
+	results = results[min(q.Offset, len(results)):]
+	return results[:min(q.Limit, len(results))], nil
+}
+
+func TestSearch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "first page", path: "/api/search?q=example&limit=1", wantStatus: 200,
+			wantBody: `{"results":[{"id":"` + pageA + `","url":"https://example.com/a","title":"A","last_crawl_time":null,"rank":0.5,"snippet":"\u003cb\u003eexample\u003c/b\u003e"}],"next_offset":1}`},
+		{name: "next page", path: "/api/search?q=example&limit=1&offset=1", wantStatus: 200, wantBody: `"id":"` + pageB + `"`},
+		{name: "last page", path: "/api/search?q=example&limit=1&offset=2", wantStatus: 200, wantBody: `{"results":[]}`},
+		{name: "language", path: "/api/search?q=example&language=de", wantStatus: 200, wantBody: `{"results":[{"id":"` + pageB + `"`},
+		{name: "domain", path: "/api/search?q=example&domain=other.example", wantStatus: 200, wantBody: `{"results":[]}`},
+		{name: "no query", path: "/api/search", wantStatus: 400, wantBody: "q required"},
+		{name: "bad limit", path: "/api/search?q=a&limit=101", wantStatus: 400},
+		{name: "bad offset", path: "/api/search?q=a&offset=-1", wantStatus: 400},
+		{name: "bad until", path: "/api/search?q=a&until=soon", wantStatus: 400},
+	}
+
+	srv, _ := newTestServer(t, config.APIConfig{Insecure: true})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			rec := do(srv, http.MethodGet, tt.path, "")
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", rec.Body, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+// Package api serves the HTTP API other services use to crawl on demand and
+// to read crawled pages.
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+	"github.com/theognis1002/nimbus-crawler/internal/queue"
+	"github.com/theognis1002/nimbus-crawler/internal/storage"
+)
+
+// Server serves the crawl API:
+//
+//	POST /api/crawls  enqueue a batch of URLs; returns the request's ID
+//	GET  /api/crawls/{id}  the progress of a request
+//	POST /api/fetch  fetch and parse one URL now and return the result
+//
+// and the read API over crawled pages:
+//
+//	GET /api/pages  pages by domain, status, crawl time and language
+//	GET /api/pages/{id}  a page's metadata
+//	GET /api/pages/{id}/html, .../text  the page as stored
+//	GET /api/pages/{id}/links  its outlinks, or inlinks with direction=in
+//	GET /api/changes  pages whose content changed since a time
+//	GET /api/search  pages ranked by a full-text search of their text
+//
+// Lists are paged with the next_cursor they return, search results with an
+// offset. Requests must carry cfg.Token as a bearer token unless cfg.Insecure
+// is set; without either every request is refused.
+type Server struct {
+	cfg       config.APIConfig
+	maxDepth  int
+	store     Store
+	objects   storage.ObjectStore
+	publisher queue.Publisher
+	fetcher   *PageFetcher
+	logger    *slog.Logger
+	mux       *http.ServeMux
+}
+
+// NewServer returns a server that keeps requests and reads pages in store,
+// reads stored HTML and text from objects, queues URLs with publisher and
+// fetches pages on demand with fetcher. maxDepth caps the depth a request
+// may ask for.
+func NewServer(cfg config.APIConfig, maxDepth int, store Store, objects storage.ObjectStore, publisher queue.Publisher, fetcher *PageFetcher, logger *slog.Logger) *Server {
+	s := &Server{
+		cfg:       cfg,
+		maxDepth:  maxDepth,
+		store:     store,
+		objects:   objects,
+		publisher: publisher,
+		fetcher:   fetcher,
+		logger:    logger,
+		mux:       http.NewServeMux(),
+	}
+	s.mux.HandleFunc("POST /api/crawls", s.handleSubmit)
+	s.mux.HandleFunc("GET /api/crawls/{id}", s.handleProgress)
+	s.mux.HandleFunc("POST /api/fetch", s.handleFetch)
+	s.mux.HandleFunc("GET /api/pages", s.handlePages)
+	s.mux.HandleFunc("GET /api/pages/{id}", s.handlePage)
+	s.mux.HandleFunc("GET /api/pages/{id}/html", s.handleContent("html"))
+	s.mux.HandleFunc("GET /api/pages/{id}/text", s.handleContent("text"))
+	s.mux.HandleFunc("GET /api/pages/{id}/links", s.handleLinks)
+	s.mux.HandleFunc("GET /api/changes", s.handleChanges)
+	s.mux.HandleFunc("GET /api/search", s.handleSearch)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.cfg.Insecure {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || s.cfg.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="nimbus-api"`)
+			writeError(w, http.StatusUnauthorized, "missing or invalid token")
+			return
+		}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// decode reads a JSON request body of at most maxRequestBytes into v.
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func (s *Server) internalError(w http.ResponseWriter, msg string, err error) {
+	s.logger.Error(msg, "error", err)
+	writeError(w, http.StatusInternalServerError, "internal error")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,267 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/theognis1002/nimbus-crawler/internal/cache"
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+	"github.com/theognis1002/nimbus-crawler/internal/crawler"
+	"github.com/theognis1002/nimbus-crawler/internal/database/models"
+	"github.com/theognis1002/nimbus-crawler/internal/queue"
+	"github.com/theognis1002/nimbus-crawler/internal/robots"
+)
+
+// fakeStore knows "https://known.example/" already.
+type fakeStore struct {
+	mu       sync.Mutex
+	requests map[string]*models.CrawlRequest
+}
+
+func (f *fakeStore) CreateRequest(ctx context.Context, req *models.CrawlRequest, urls []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	req.ID = "req-1"
+	req.URLs = len(urls)
+	req.CreatedAt = time.Now()
+	f.requests[req.ID] = req
+	return nil
+}
+
+func (f *fakeStore) InsertURLs(ctx context.Context, urls, domains []string) ([]string, error) {
+	var inserted []string
+	for _, u := range urls {
+		if u != "https://known.example/" {
+			inserted = append(inserted, u)
+		}
+	}
+	return inserted, nil
+}
+
+func (f *fakeStore) SetEnqueued(ctx context.Context, id string, n int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests[id].Enqueued = n
+	return nil
+}
+
+func (f *fakeStore) Request(ctx context.Context, id string) (*models.CrawlRequest, map[models.URLStatus]int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	req, ok := f.requests[id]
+	if !ok {
+		return nil, nil, nil
+	}
+	return req, map[models.URLStatus]int64{models.StatusParsed: 1, models.StatusPending: int64(req.URLs - 1)}, nil
+}
+
+type fakePublisher struct {
+	mu   sync.Mutex
+	msgs []queue.URLMessage
+}
+
+func (p *fakePublisher) PublishURL(ctx context.Context, msg queue.URLMessage) error {
+	return p.PublishURLBatch(ctx, []queue.URLMessage{msg})
+}
+
+func (p *fakePublisher) PublishURLBatch(ctx context.Context, msgs []queue.URLMessage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.msgs = append(p.msgs, msgs...)
+	return nil
+}
+
+func (p *fakePublisher) PublishParse(ctx context.Context, msg queue.ParseMessage) error { return nil }
+func (p *fakePublisher) FrontierLen(ctx context.Context) (int64, error)                 { return 0, nil }
+func (p *fakePublisher) ParseLen(ctx context.Context) (int64, error)                    { return 0, nil }
+func (p *fakePublisher) Close()                                                         {}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// site serves example.com: "/" links to "/a" and "/private", "/moved"
+// redirects to "/", and anything else is a 404.
+func site(r *http.Request) (*http.Response, error) {
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": {"text/html"}}, Request: r}
+	switch r.URL.Path {
+	case "/":
+		resp.Header.Set("Content-Language", "en")
+		resp.Body = io.NopCloser(strings.NewReader(`<html><head><title>Home</title></head><body><p>Hello there</p><a href="/a"></a><a href="/private"></a></body></html>`))
+	case "/moved":
+		resp.StatusCode = http.StatusMovedPermanently
+		resp.Header.Set("Location", "/")
+		resp.Body = http.NoBody
+	default:
+		resp.StatusCode = http.StatusNotFound
+		resp.Body = io.NopCloser(strings.NewReader("not found"))
+	}
+	return resp, nil
+}
+
+func newTestServer(t *testing.T, cfg config.APIConfig) (*Server, *fakePublisher) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	// Cached robots.txt, so the checker never needs Postgres.
+	mr.HSet("robots:example.com", "body", "User-agent: *\nDisallow: /private\n", "delay", "1")
+
+	logger := slog.New(slog.DiscardHandler)
+	fetcher := crawler.NewReplayFetcher(roundTripFunc(site), 5, 3, logger)
+	pages := NewPageFetcher(fetcher, robots.NewChecker(nil, rdb, logger), cache.NewRateLimiter(rdb))
+	publisher := &fakePublisher{}
+	store := &fakeStore{requests: make(map[string]*models.CrawlRequest)}
+	objects := newTestObjects(t)
+	return NewServer(cfg, 3, store, objects, publisher, pages, logger), publisher
+}
+
+func do(srv *Server, method, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rec
+}
+
+func TestSubmit(t *testing.T) {
+	t.Parallel()
+
+	srv, publisher := newTestServer(t, config.APIConfig{Insecure: true})
+	rec := do(srv, http.MethodPost, "/api/crawls",
+		`{"urls":["https://example.com/","https://known.example/","ftp://example.com/","https://example.com/"],"priority":1,"max_depth":2,"scope":"host"}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp submitResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != "req-1" || resp.URLs != 2 || resp.Enqueued != 1 || len(resp.Rejected) != 1 {
+		t.Errorf("response = %+v, want 2 urls, 1 enqueued, 1 rejected", resp)
+	}
+
+	want := queue.URLMessage{URL: "https://example.com/", Priority: 1, JobID: "req-1", Scope: queue.ScopeHost, MaxDepth: 2}
+	if len(publisher.msgs) != 1 || publisher.msgs[0] != want {
+		t.Errorf("published %+v, want [%+v]", publisher.msgs, want)
+	}
+
+	rec = do(srv, http.MethodGet, "/api/crawls/req-1", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("progress status = %d: %s", rec.Code, rec.Body)
+	}
+	for _, want := range []string{`"status":{"parsed":1,"pending":1}`, `"enqueued":1`, `"done":false`} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("progress = %s, want it to contain %s", rec.Body, want)
+		}
+	}
+	if rec := do(srv, http.MethodGet, "/api/crawls/missing", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown request status = %d, want 404", rec.Code)
+	}
+}
+
+func TestSubmitValidation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "no urls", body: `{"urls":[]}`},
+		{name: "depth above max", body: `{"urls":["https://example.com/"],"max_depth":4}`},
+		{name: "unknown scope", body: `{"urls":["https://example.com/"],"scope":"planet"}`},
+		{name: "bad priority", body: `{"urls":["https://example.com/"],"priority":99}`},
+		{name: "unknown field", body: `{"urls":["https://example.com/"],"depth":1}`},
+		{name: "only invalid urls", body: `{"urls":["mailto:a@example.com"]}`},
+	}
+
+	srv, publisher := newTestServer(t, config.APIConfig{Insecure: true})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if rec := do(srv, http.MethodPost, "/api/crawls", tt.body); rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400: %s", rec.Code, rec.Body)
+			}
+		})
+	}
+	t.Cleanup(func() {
+		if len(publisher.msgs) != 0 {
+			t.Errorf("published %d messages for invalid requests", len(publisher.msgs))
+		}
+	})
+}
+
+func TestFetch(t *testing.T) {
+	t.Parallel()
+
+	srv, _ := newTestServer(t, config.APIConfig{Insecure: true})
+
+	rec := do(srv, http.MethodPost, "/api/fetch", `{"url":"https://example.com/moved"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var page Page
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if !page.Allowed || page.StatusCode != 200 || page.Text != "Hello there" {
+		t.Errorf("page = %+v, want allowed, 200, text %q", page, "Hello there")
+	}
+	if got := strings.Join(page.Links, " "); got != "https://example.com/a https://example.com/private" {
+		t.Errorf("links = %s", got)
+	}
+	if m := page.Metadata; m == nil || m.FinalURL != "https://example.com/" || m.ContentLanguage != "en" || m.Title != "Home" || len(m.ContentHash) != 64 || m.Bytes == 0 {
+		t.Errorf("metadata = %+v", page.Metadata)
+	}
+
+	rec = do(srv, http.MethodPost, "/api/fetch", `{"url":"https://example.com/private"}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"allowed":false`) {
+		t.Errorf("disallowed fetch = %d %s, want allowed false", rec.Code, rec.Body)
+	}
+
+	rec = do(srv, http.MethodPost, "/api/fetch", `{"url":"https://example.com/missing"}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status_code":404`) || strings.Contains(rec.Body.String(), `"text"`) {
+		t.Errorf("missing page = %d %s, want status_code 404 without text", rec.Code, rec.Body)
+	}
+
+	if rec := do(srv, http.MethodPost, "/api/fetch", `{"url":"file:///etc/passwd"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("file url status = %d, want 400", rec.Code)
+	}
+}
+
+func TestToken(t *testing.T) {
+	t.Parallel()
+
+	srv, _ := newTestServer(t, config.APIConfig{Token: "secret"})
+	if rec := do(srv, http.MethodGet, "/api/crawls/req-1", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without token: status = %d, want 401", rec.Code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/crawls/req-1", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("with token: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestNoTokenRefusesRequests(t *testing.T) {
+	t.Parallel()
+
+	srv, _ := newTestServer(t, config.APIConfig{})
+	req := httptest.NewRequest(http.MethodGet, "/api/crawls/req-1", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("empty token: status = %d, want 401", rec.Code)
+	}
+}
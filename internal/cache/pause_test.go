@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestPauseFlags(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	p := NewPauseFlags(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+
+	check := func(domain string, want bool) {
+		t.Helper()
+		got, err := p.Paused(ctx, domain)
+		if err != nil {
+			t.Fatalf("Paused(%q): %v", domain, err)
+		}
+		if got != want {
+			t.Errorf("Paused(%q) = %v, want %v", domain, got, want)
+		}
+	}
+
+	check("a.example", false)
+
+	if err := p.SetPaused(ctx, "a.example", true); err != nil {
+		t.Fatalf("SetPaused: %v", err)
+	}
+	check("a.example", true)
+	check("b.example", false)
+
+	if err := p.SetPaused(ctx, "", true); err != nil {
+		t.Fatalf("SetPaused: %v", err)
+	}
+	check("b.example", true)
+
+	global, domains, err := p.State(ctx)
+	if err != nil {
+		t.Fatalf("State: %v", err)
+	}
+	if !global || !slices.Equal(domains, []string{"a.example"}) {
+		t.Errorf("State = %v, %v; want true, [a.example]", global, domains)
+	}
+
+	if err := p.SetPaused(ctx, "", false); err != nil {
+		t.Fatalf("SetPaused: %v", err)
+	}
+	if err := p.SetPaused(ctx, "a.example", false); err != nil {
+		t.Fatalf("SetPaused: %v", err)
+	}
+	check("a.example", false)
+	check("b.example", false)
+}
@@ -26,6 +26,28 @@ end
 return 0
 `)
 
+// acquireScript is slidingWindowScript for a limit of 1 that, when the window
+// is closed, returns how many milliseconds remain until it opens instead of 0.
+var acquireScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local newest = redis.call('ZRANGE', key, -1, -1, 'WITHSCORES')
+
+if #newest == 0 then
+    redis.call('ZADD', key, now, now .. '-' .. math.random(1000000))
+    redis.call('EXPIRE', key, math.ceil(window / 1000))
+    return 0
+end
+local wait = tonumber(newest[2]) + window - now
+if wait < 1 then
+    wait = 1
+end
+return wait
+`)
+
 const (
 	rateLimitKeyPrefix = "ratelimit:"
 	jitterFactor       = 0.5
@@ -55,6 +77,21 @@ func (r *RateLimiter) Allow(ctx context.Context, domain string, windowMs int, li
 	return result == 1, nil
 }
 
+// TryAcquire takes the domain's single request slot for the next crawlDelayMs
+// if it is free and returns 0. Otherwise it returns how long until the slot
+// frees up, without blocking, so callers can work on other domains meanwhile.
+func (r *RateLimiter) TryAcquire(ctx context.Context, domain string, crawlDelayMs int) (time.Duration, error) {
+	key := rateLimitKeyPrefix + domain
+	now := time.Now().UnixMilli()
+
+	waitMs, err := acquireScript.Run(ctx, r.client, []string{key}, now, crawlDelayMs).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("rate limit acquire script: %w", err)
+	}
+
+	return time.Duration(waitMs) * time.Millisecond, nil
+}
+
 // WaitForAllow blocks until the rate limiter allows the request, adding jitter.
 func (r *RateLimiter) WaitForAllow(ctx context.Context, domain string, crawlDelayMs int) error {
 	for {
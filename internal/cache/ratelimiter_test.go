@@ -130,3 +130,64 @@ func TestWaitForAllow_AllowedImmediately(t *testing.T) {
 		t.Fatalf("expected nil, got %v", err)
 	}
 }
+
+func TestTryAcquire_FreeSlot(t *testing.T) {
+	t.Parallel()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	rl := NewRateLimiter(rdb)
+
+	wait, err := rl.TryAcquire(context.Background(), "example.com", 1000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wait != 0 {
+		t.Errorf("wait = %v, want 0 for a free slot", wait)
+	}
+}
+
+func TestTryAcquire_ReturnsRemainingWindow(t *testing.T) {
+	t.Parallel()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	rl := NewRateLimiter(rdb)
+
+	if _, err := rl.TryAcquire(context.Background(), "example.com", 60000); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wait, err := rl.TryAcquire(context.Background(), "example.com", 60000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wait <= 0 || wait > 60*time.Second {
+		t.Errorf("wait = %v, want within (0, 60s]", wait)
+	}
+
+	// Other domains are unaffected.
+	wait, err = rl.TryAcquire(context.Background(), "other.com", 60000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wait != 0 {
+		t.Errorf("other domain wait = %v, want 0", wait)
+	}
+}
+
+func TestTryAcquire_SharesWindowWithAllow(t *testing.T) {
+	t.Parallel()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	rl := NewRateLimiter(rdb)
+
+	if _, err := rl.Allow(context.Background(), "example.com", 60000, 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wait, err := rl.TryAcquire(context.Background(), "example.com", 60000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wait == 0 {
+		t.Error("TryAcquire should see the slot taken by Allow")
+	}
+}
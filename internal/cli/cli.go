@@ -0,0 +1,262 @@
+// Package cli implements the nimbus command line: one binary whose
+// subcommands run each part of the crawler. The commands under cmd/ are thin
+// wrappers that run a single subcommand.
+//
+// Every command accepts the global flags --config, --env-only, --log-level
+// and --log-format, before or after its name. A config file that cannot be
+// loaded is fatal unless --env-only is given, in which case the config comes
+// from environment variables and defaults alone.
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+	"github.com/theognis1002/nimbus-crawler/internal/queue"
+)
+
+// DefaultConfigPath is the config file loaded when --config and
+// NIMBUS_CONFIG are not set.
+const DefaultConfigPath = "configs/development.yaml"
+
+// Exit codes.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// runFunc runs a command with its parsed flags and remaining arguments.
+type runFunc func(ctx context.Context, e *env, args []string) error
+
+// command is a nimbus subcommand. A command with subcommands dispatches to
+// the one named by its first argument; any other command runs.
+type command struct {
+	name string
+	// args is the synopsis of the command's positional arguments, empty if
+	// it takes none.
+	args    string
+	summary string
+	// flags registers the command's own flags and returns the function to
+	// run once they are parsed.
+	flags       func(fs *flag.FlagSet) runFunc
+	subcommands []*command
+}
+
+func commands() []*command {
+	return []*command{
+		crawlCommand(),
+		parseCommand(),
+		seedCommand(),
+		runCommand(),
+		migrateCommand(),
+		dlqCommand(),
+		exportCommand(),
+		statusCommand(),
+		reparseCommand(),
+		recompressCommand(),
+		adminCommand(),
+		apiCommand(),
+		archiveCommand(),
+		webhooksCommand(),
+	}
+}
+
+// globals are the flags every command accepts. Their defaults come from
+// NIMBUS_CONFIG, NIMBUS_ENV_ONLY, NIMBUS_LOG_LEVEL and NIMBUS_LOG_FORMAT.
+type globals struct {
+	config    string
+	envOnly   bool
+	logLevel  string
+	logFormat string
+}
+
+func defaultGlobals() globals {
+	g := globals{config: DefaultConfigPath, logLevel: "info", logFormat: "json"}
+	if v := os.Getenv("NIMBUS_CONFIG"); v != "" {
+		g.config = v
+	}
+	if v, err := strconv.ParseBool(os.Getenv("NIMBUS_ENV_ONLY")); err == nil {
+		g.envOnly = v
+	}
+	if v := os.Getenv("NIMBUS_LOG_LEVEL"); v != "" {
+		g.logLevel = v
+	}
+	if v := os.Getenv("NIMBUS_LOG_FORMAT"); v != "" {
+		g.logFormat = v
+	}
+	return g
+}
+
+// register adds the global flags to fs. Values already parsed at an outer
+// level become the defaults, so a flag may be given at any level.
+func (g *globals) register(fs *flag.FlagSet) {
+	fs.StringVar(&g.config, "config", g.config, "config file to load")
+	fs.BoolVar(&g.envOnly, "env-only", g.envOnly, "configure from environment variables only, without a config file")
+	fs.StringVar(&g.logLevel, "log-level", g.logLevel, "log level: debug, info, warn or error")
+	fs.StringVar(&g.logFormat, "log-format", g.logFormat, "log format: json or text")
+}
+
+// env is what a command runs with.
+type env struct {
+	globals
+	logger *slog.Logger
+	stdout io.Writer
+}
+
+// loadConfig loads the config file, or only the environment in env-only
+// mode.
+func (e *env) loadConfig() (*config.Config, error) {
+	if e.envOnly {
+		e.logger.Debug("env-only mode, configuring from env vars")
+		cfg := config.LoadFromEnv()
+		if err := cfg.Validate(); err != nil {
+			return nil, err
+		}
+		return cfg, nil
+	}
+	cfg, err := config.Load(e.config)
+	if err != nil {
+		return nil, fmt.Errorf("%w (use --env-only to configure from env vars alone)", err)
+	}
+	return cfg, nil
+}
+
+// requireSharedQueue refuses the memory queue backend in commands whose
+// messages other processes read or write. Only run keeps producers and
+// consumers in one process, where the memory backend works.
+func requireSharedQueue(cfg *config.Config) error {
+	if cfg.Queue.Backend == queue.BackendMemory {
+		return errors.New("the memory queue backend only connects components within one process: use `nimbus run`, or set queue.backend to redis or nats")
+	}
+	return nil
+}
+
+// queueOptions returns the queue backend options cfg selects.
+func queueOptions(cfg *config.Config) queue.Options {
+	return queue.Options{
+		Backend:     cfg.Queue.Backend,
+		Encoding:    cfg.Queue.Encoding,
+		NATSURL:     cfg.Queue.NATSURL,
+		Partitions:  cfg.Frontier.Partitions,
+		LeaseTTL:    time.Duration(cfg.Frontier.LeaseTTLSecs) * time.Second,
+		LaneWeights: cfg.Crawler.LaneWeights,
+	}
+}
+
+// Main runs the command named by args, which excludes the program name, and
+// returns the process exit code.
+func Main(args []string) int {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	return run(ctx, args, os.Stdout, os.Stderr)
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	g := defaultGlobals()
+	root := &command{name: "nimbus", subcommands: commands()}
+	return dispatch(ctx, root, &g, root.name, args, stdout, stderr)
+}
+
+// dispatch parses the flags of cmd and runs it, or the subcommand named by
+// its first argument.
+func dispatch(ctx context.Context, cmd *command, g *globals, path string, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet(path, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var runCmd runFunc
+	if cmd.flags != nil {
+		runCmd = cmd.flags(fs)
+	}
+	g.register(fs)
+	fs.Usage = func() { printUsage(stderr, path, cmd, fs) }
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	if len(cmd.subcommands) > 0 {
+		if fs.NArg() == 0 {
+			fs.Usage()
+			return exitUsage
+		}
+		for _, sub := range cmd.subcommands {
+			if sub.name == fs.Arg(0) {
+				return dispatch(ctx, sub, g, path+" "+sub.name, fs.Args()[1:], stdout, stderr)
+			}
+		}
+		fmt.Fprintf(stderr, "%s: unknown command %q\n", path, fs.Arg(0))
+		fs.Usage()
+		return exitUsage
+	}
+	if cmd.args == "" && fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "%s: unexpected arguments %q\n", path, fs.Args())
+		fs.Usage()
+		return exitUsage
+	}
+
+	logger, err := newLogger(stderr, g.logLevel, g.logFormat)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", path, err)
+		return exitUsage
+	}
+	e := &env{globals: *g, logger: logger, stdout: stdout}
+	if err := runCmd(ctx, e, fs.Args()); err != nil {
+		logger.Error("fatal error", "error", err)
+		return exitError
+	}
+	return exitOK
+}
+
+func printUsage(w io.Writer, path string, cmd *command, fs *flag.FlagSet) {
+	switch {
+	case len(cmd.subcommands) > 0:
+		fmt.Fprintf(w, "Usage: %s [flags] <command> [args]\n", path)
+	case cmd.args != "":
+		fmt.Fprintf(w, "Usage: %s [flags] %s\n", path, cmd.args)
+	default:
+		fmt.Fprintf(w, "Usage: %s [flags]\n", path)
+	}
+	if cmd.summary != "" {
+		fmt.Fprintf(w, "\n%s.\n", cmd.summary)
+	}
+	if len(cmd.subcommands) > 0 {
+		fmt.Fprintf(w, "\nCommands:\n")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, sub := range cmd.subcommands {
+			fmt.Fprintf(tw, "  %s\t%s\n", sub.name, sub.summary)
+		}
+		tw.Flush()
+	}
+	fmt.Fprintf(w, "\nFlags:\n")
+	fs.PrintDefaults()
+}
+
+// newLogger returns a logger writing to w at the named level and format.
+func newLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid --log-level %q: want debug, info, warn or error", level)
+	}
+	opts := &slog.HandlerOptions{Level: l}
+	switch format {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid --log-format %q: want json or text", format)
+	}
+}
@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+	missing := filepath.Join(t.TempDir(), "missing.yaml")
+	memory := filepath.Join(t.TempDir(), "memory.yaml")
+	if err := os.WriteFile(memory, []byte("queue:\n  backend: memory\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		args []string
+		code int
+		// out must appear in stdout or stderr.
+		out string
+	}{
+		{"no command", nil, exitUsage, "Usage: nimbus [flags] <command>"},
+		{"help", []string{"-h"}, exitOK, "migrate"},
+		{"unknown command", []string{"frobnicate"}, exitUsage, `unknown command "frobnicate"`},
+		{"missing subcommand", []string{"migrate"}, exitUsage, "Usage: nimbus migrate"},
+		{"unknown subcommand", []string{"dlq", "drop"}, exitUsage, `unknown command "drop"`},
+		{"unexpected argument", []string{"crawl", "extra"}, exitUsage, "unexpected arguments"},
+		{"unknown flag", []string{"status", "--frobnicate"}, exitUsage, "flag provided but not defined"},
+		{"bad log level", []string{"--log-level", "loud", "status"}, exitUsage, "invalid --log-level"},
+		{"bad log format", []string{"status", "--log-format", "xml"}, exitUsage, "invalid --log-format"},
+		{"missing config is fatal", []string{"--config", missing, "status"}, exitError, "--env-only"},
+		{"bad step count", []string{"migrate", "down", "two"}, exitError, "invalid step count"},
+		{"purge needs confirmation", []string{"dlq", "purge", "--queue", "parse"}, exitError, "--yes"},
+		{"unknown dlq", []string{"dlq", "list", "--queue", "fetch", "--config", missing}, exitError, "unknown queue"},
+		{"seed takes one file", []string{"seed", "a.txt", "b.txt"}, exitError, "at most one file"},
+		{"run takes one seed file", []string{"run", "a.txt", "b.txt"}, exitError, "at most one seed file"},
+		{"crawl refuses the memory queue", []string{"crawl", "--config", memory}, exitError, "nimbus run"},
+		{"seed refuses the memory queue", []string{"seed", "--config", memory}, exitError, "nimbus run"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var stdout, stderr bytes.Buffer
+			code := run(context.Background(), tt.args, &stdout, &stderr)
+			if code != tt.code {
+				t.Errorf("exit code = %d, want %d\nstdout: %s\nstderr: %s", code, tt.code, &stdout, &stderr)
+			}
+			if out := stdout.String() + stderr.String(); !strings.Contains(out, tt.out) {
+				t.Errorf("output does not mention %q:\n%s", tt.out, out)
+			}
+		})
+	}
+}
+
+func TestGlobalFlags(t *testing.T) {
+	t.Parallel()
+	var got globals
+	leaf := &command{
+		name: "leaf",
+		flags: func(fs *flag.FlagSet) runFunc {
+			return func(ctx context.Context, e *env, _ []string) error {
+				got = e.globals
+				return nil
+			}
+		},
+	}
+	root := &command{name: "nimbus", subcommands: []*command{{name: "group", subcommands: []*command{leaf}}}}
+
+	tests := []struct {
+		args []string
+		want globals
+	}{
+		{
+			[]string{"group", "leaf"},
+			globals{config: DefaultConfigPath, logLevel: "info", logFormat: "json"},
+		},
+		{
+			[]string{"--config", "a.yaml", "group", "--log-level", "debug", "leaf", "--log-format", "text"},
+			globals{config: "a.yaml", logLevel: "debug", logFormat: "text"},
+		},
+		{
+			[]string{"--config", "a.yaml", "group", "leaf", "--config", "b.yaml", "--env-only"},
+			globals{config: "b.yaml", envOnly: true, logLevel: "info", logFormat: "json"},
+		},
+	}
+	for _, tt := range tests {
+		g := globals{config: DefaultConfigPath, logLevel: "info", logFormat: "json"}
+		var stdout, stderr bytes.Buffer
+		if code := dispatch(context.Background(), root, &g, "nimbus", tt.args, &stdout, &stderr); code != exitOK {
+			t.Fatalf("%v: exit code %d: %s", tt.args, code, &stderr)
+		}
+		if got != tt.want {
+			t.Errorf("%v: globals = %+v, want %+v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestLogsGoToStderr(t *testing.T) {
+	t.Parallel()
+	leaf := &command{
+		name: "leaf",
+		flags: func(fs *flag.FlagSet) runFunc {
+			return func(ctx context.Context, e *env, _ []string) error {
+				e.logger.Info("logged")
+				fmt.Fprintln(e.stdout, "output")
+				return nil
+			}
+		},
+	}
+	root := &command{name: "nimbus", subcommands: []*command{leaf}}
+
+	g := globals{config: DefaultConfigPath, logLevel: "info", logFormat: "json"}
+	var stdout, stderr bytes.Buffer
+	if code := dispatch(context.Background(), root, &g, "nimbus", []string{"leaf"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, &stderr)
+	}
+	if got := stdout.String(); got != "output\n" {
+		t.Errorf("stdout = %q, want only the command's output", got)
+	}
+	if !strings.Contains(stderr.String(), `"msg":"logged"`) {
+		t.Errorf("stderr = %q, want the log line", &stderr)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nimbus.yaml")
+	if err := os.WriteFile(path, []byte("crawler:\n  workers: 3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.DiscardHandler)
+
+	cfg, err := (&env{globals: globals{config: path}, logger: logger}).loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Crawler.Workers != 3 {
+		t.Errorf("workers = %d, want 3 from the config file", cfg.Crawler.Workers)
+	}
+
+	missing := filepath.Join(dir, "missing.yaml")
+	if _, err := (&env{globals: globals{config: missing}, logger: logger}).loadConfig(); err == nil {
+		t.Error("loading a missing config file succeeded")
+	}
+	if _, err := (&env{globals: globals{config: missing, envOnly: true}, logger: logger}).loadConfig(); err != nil {
+		t.Errorf("env-only mode: %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+	"github.com/theognis1002/nimbus-crawler/internal/cache"
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+	"github.com/theognis1002/nimbus-crawler/internal/crawler"
+	"github.com/theognis1002/nimbus-crawler/internal/database"
+	"github.com/theognis1002/nimbus-crawler/internal/database/models"
+	"github.com/theognis1002/nimbus-crawler/internal/events"
+	"github.com/theognis1002/nimbus-crawler/internal/metrics"
+	"github.com/theognis1002/nimbus-crawler/internal/queue"
+	"github.com/theognis1002/nimbus-crawler/internal/robots"
+	"github.com/theognis1002/nimbus-crawler/internal/storage"
+	"github.com/theognis1002/nimbus-crawler/internal/tracing"
+	"github.com/theognis1002/nimbus-crawler/internal/warc"
+)
+
+func crawlCommand() *command {
+	return &command{
+		name:    "crawl",
+		summary: "Fetch URLs from the frontier and store their HTML",
+		flags:   func(fs *flag.FlagSet) runFunc { return runCrawl },
+	}
+}
+
+func runCrawl(ctx context.Context, e *env, _ []string) error {
+	logger := e.logger
+	cfg, err := e.loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := requireSharedQueue(cfg); err != nil {
+		return err
+	}
+
+	cfg.AutoSizePoolForWorkers(cfg.Crawler.Workers)
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing, "nimbus-crawler")
+	if err != nil {
+		return fmt.Errorf("set up tracing: %w", err)
+	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			logger.Warn("failed to flush traces", "error", err)
+		}
+	}()
+
+	pool, err := database.NewPool(ctx, cfg.Postgres)
+	if err != nil {
+		return fmt.Errorf("connect to postgres: %w", err)
+	}
+	defer pool.Close()
+
+	rdb, err := cache.NewRedisClient(ctx, cfg.Redis)
+	if err != nil {
+		return fmt.Errorf("connect to redis: %w", err)
+	}
+	defer rdb.Close()
+
+	backend, err := queue.Open(ctx, queueOptions(cfg), rdb, logger)
+	if err != nil {
+		return fmt.Errorf("open queue backend: %w", err)
+	}
+	defer backend.Close()
+
+	store, err := storage.Open(ctx, cfg, logger)
+	if err != nil {
+		return fmt.Errorf("open object store: %w", err)
+	}
+
+	return crawl(ctx, cfg, pool, rdb, backend, store, logger)
+}
+
+// crawl runs the crawler over backend until ctx is cancelled.
+func crawl(ctx context.Context, cfg *config.Config, pool *pgxpool.Pool, rdb *redis.Client, backend queue.Backend, store *storage.CodecStore, logger *slog.Logger) error {
+	metrics.Serve(ctx, cfg.Metrics.CrawlerAddr, logger)
+	if cfg.Queue.Backend == queue.BackendRedis && cfg.Metrics.SampleIntervalSecs > 0 {
+		interval := time.Duration(cfg.Metrics.SampleIntervalSecs) * time.Second
+		go metrics.SampleRedisStreams(ctx, rdb, cfg.Frontier.Partitions, interval, logger)
+	}
+
+	publisher := backend.Publisher()
+
+	dnsCache := cache.NewDNSCache(rdb)
+	rateLimiter := cache.NewRateLimiter(rdb)
+	robotsChecker := robots.NewChecker(pool, rdb, logger)
+
+	proxyPool, err := crawler.NewProxyPool(cfg.Crawler.Proxy.File, rdb, cfg.Crawler.Proxy.HealthCooldownS, logger)
+	if err != nil {
+		return fmt.Errorf("load proxy pool: %w", err)
+	}
+	if proxyPool != nil {
+		logger.Info("proxy pool loaded", "count", proxyPool.Len())
+	} else {
+		logger.Info("no proxy file configured, using direct connections")
+	}
+
+	fetcher := crawler.NewFetcher(dnsCache, proxyPool, cfg.Crawler.TimeoutSecs, cfg.Crawler.MaxRedirects, logger)
+	if cfg.Crawler.ReplayDir != "" {
+		archive, err := warc.OpenArchive(cfg.Crawler.ReplayDir)
+		if err != nil {
+			return fmt.Errorf("open replay archive: %w", err)
+		}
+		fetcher = crawler.NewReplayFetcher(archive, cfg.Crawler.TimeoutSecs, cfg.Crawler.MaxRedirects, logger)
+		robotsChecker.SetTransport(archive)
+		logger.Info("replaying fetches from warc archive", "dir", cfg.Crawler.ReplayDir, "captures", archive.Len())
+	}
+
+	count, err := models.ResetStaleCrawlingURLs(ctx, pool, 5*time.Minute)
+	if err != nil {
+		logger.Error("failed to reset stale crawling urls", "error", err)
+	} else if count > 0 {
+		logger.Info("reset stale crawling urls", "count", count)
+	}
+
+	c := crawler.New(cfg.Crawler, pool, fetcher, publisher, rateLimiter, robotsChecker, store, cfg.Storage, logger)
+	c.SetPauseFlags(cache.NewPauseFlags(rdb))
+
+	if cfg.Events.Enabled {
+		bus := events.NewBus(rdb, cfg.Events.MaxLen, logger)
+		c.SetEvents(bus)
+		if cfg.Queue.Backend == queue.BackendRedis {
+			interval := time.Duration(cfg.Events.DrainCheckSecs) * time.Second
+			go events.WatchFrontier(ctx, rdb, bus, cfg.Frontier.Partitions, interval, logger)
+		}
+		logger.Info("emitting events", "stream", events.Stream)
+	}
+
+	var archive *warc.Writer
+	if cfg.WARC.Enabled {
+		// WARC files are gzipped per record already; store them as written.
+		archive = warc.NewWriter(store.Unwrap(), cfg.WARC, crawler.NewCaptureIndex(pool), logger)
+		c.SetWARCWriter(archive)
+		robotsChecker.SetRecorder(c.RecordRobots)
+		logger.Info("writing warc output", "bucket", cfg.WARC.Bucket, "prefix", cfg.WARC.Prefix)
+	}
+
+	consumer := backend.FrontierConsumer(queue.ConsumerName("crawler"), cfg.Crawler.PrefetchCount)
+	deliveries := consumer.Run(ctx)
+
+	logger.Info("crawler starting", "workers", cfg.Crawler.Workers, "max_depth", cfg.Crawler.MaxDepth)
+	c.Run(ctx, deliveries)
+	consumer.Wait()
+
+	if archive != nil {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		if err := archive.Close(closeCtx); err != nil {
+			return fmt.Errorf("flush warc output: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/theognis1002/nimbus-crawler/internal/cache"
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+	"github.com/theognis1002/nimbus-crawler/internal/queue"
+)
+
+func dlqCommand() *command {
+	return &command{
+		name:    "dlq",
+		summary: "List, replay or purge dead-lettered messages (redis backend)",
+		subcommands: []*command{
+			{
+				name:    "list",
+				summary: "Print dead-lettered messages as JSON lines, oldest first",
+				flags: func(fs *flag.FlagSet) runFunc {
+					name := fs.String("queue", "frontier", "dead-letter queue: frontier or parse")
+					limit := fs.Int64("limit", 20, "messages to print")
+					return func(ctx context.Context, e *env, _ []string) error {
+						return runDLQList(ctx, e, *name, *limit)
+					}
+				},
+			},
+			{
+				name:    "replay",
+				summary: "Publish dead-lettered messages to their queue again and remove them",
+				flags: func(fs *flag.FlagSet) runFunc {
+					name := fs.String("queue", "frontier", "dead-letter queue: frontier or parse")
+					limit := fs.Int64("limit", 0, "messages to replay (0: all)")
+					return func(ctx context.Context, e *env, _ []string) error {
+						return runDLQReplay(ctx, e, *name, *limit)
+					}
+				},
+			},
+			{
+				name:    "purge",
+				summary: "Delete every dead-lettered message",
+				flags: func(fs *flag.FlagSet) runFunc {
+					name := fs.String("queue", "frontier", "dead-letter queue: frontier or parse")
+					yes := fs.Bool("yes", false, "confirm deleting the messages")
+					return func(ctx context.Context, e *env, _ []string) error {
+						if !*yes {
+							return errors.New("purge deletes messages for good; pass --yes to confirm")
+						}
+						return runDLQPurge(ctx, e, *name)
+					}
+				},
+			},
+		},
+	}
+}
+
+// openDLQ returns the config, a redis client and the dead-letter stream of
+// the named queue.
+func openDLQ(ctx context.Context, e *env, name string) (*config.Config, *redis.Client, string, error) {
+	dlq, err := queue.DeadLetterStream(name)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	cfg, err := e.loadConfig()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if cfg.Queue.Backend != "" && cfg.Queue.Backend != queue.BackendRedis {
+		return nil, nil, "", fmt.Errorf("dlq needs the redis queue backend, not %q", cfg.Queue.Backend)
+	}
+	rdb, err := cache.NewRedisClient(ctx, cfg.Redis)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("connect to redis: %w", err)
+	}
+	return cfg, rdb, dlq, nil
+}
+
+func runDLQList(ctx context.Context, e *env, name string, limit int64) error {
+	_, rdb, dlq, err := openDLQ(ctx, e, name)
+	if err != nil {
+		return err
+	}
+	defer rdb.Close()
+
+	dls, err := queue.ListDeadLetters(ctx, rdb, dlq, max(limit, 1))
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(e.stdout)
+	for _, dl := range dls {
+		if err := enc.Encode(dl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runDLQReplay(ctx context.Context, e *env, name string, limit int64) error {
+	cfg, rdb, dlq, err := openDLQ(ctx, e, name)
+	if err != nil {
+		return err
+	}
+	defer rdb.Close()
+
+	backend, err := queue.Open(ctx, queueOptions(cfg), rdb, e.logger)
+	if err != nil {
+		return fmt.Errorf("open queue backend: %w", err)
+	}
+	defer backend.Close()
+
+	n, err := queue.ReplayDeadLetters(ctx, rdb, dlq, backend.Publisher(), limit)
+	e.logger.Info("replayed dead-lettered messages", "dlq", dlq, "replayed", n,
+		"remaining", rdb.XLen(ctx, dlq).Val())
+	return err
+}
+
+func runDLQPurge(ctx context.Context, e *env, name string) error {
+	_, rdb, dlq, err := openDLQ(ctx, e, name)
+	if err != nil {
+		return err
+	}
+	defer rdb.Close()
+
+	n, err := queue.PurgeDeadLetters(ctx, rdb, dlq)
+	if err != nil {
+		return err
+	}
+	e.logger.Info("purged dead-lettered messages", "dlq", dlq, "deleted", n)
+	return nil
+}
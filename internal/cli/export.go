@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/theognis1002/nimbus-crawler/internal/database"
+	"github.com/theognis1002/nimbus-crawler/internal/export"
+	"github.com/theognis1002/nimbus-crawler/internal/storage"
+)
+
+func exportCommand() *command {
+	return &command{
+		name:    "export",
+		summary: "Write stored pages matching a filter to sharded JSONL, CSV or Parquet files",
+		flags: func(fs *flag.FlagSet) runFunc {
+			name := fs.String("name", "", "export name: the directory or key prefix under the output (required)")
+			format := fs.String("format", string(export.FormatJSONL), "shard format: jsonl, csv or parquet")
+			out := fs.String("out", "data/export", "local output directory, or the staging directory when writing to a bucket")
+			bucket := fs.String("bucket", "", "write to this object storage bucket instead of -out (default export.bucket)")
+			shardRecords := fs.Int("shard-records", 100000, "records per shard")
+			filter := urlFilterFlags(fs, "pages")
+			minText := fs.Int("min-text-bytes", 0, "skip pages with less text than this")
+			batch := fs.Int("batch", 500, "pages to read from postgres at a time")
+			return func(ctx context.Context, e *env, _ []string) error {
+				if *name == "" || strings.ContainsAny(*name, `/\`) {
+					return fmt.Errorf("-name is required and must not contain slashes")
+				}
+				opts := export.Options{ShardRecords: *shardRecords, MinTextBytes: *minText}
+				var err error
+				if opts.Format, err = export.ParseFormat(*format); err != nil {
+					return err
+				}
+				if *shardRecords <= 0 {
+					return fmt.Errorf("-shard-records must be positive")
+				}
+				if opts.Filter, err = filter(); err != nil {
+					return err
+				}
+				return runExport(ctx, e, *name, *out, *bucket, opts, *batch)
+			}
+		},
+	}
+}
+
+func runExport(ctx context.Context, e *env, name, out, bucket string, opts export.Options, batch int) error {
+	logger := e.logger
+	cfg, err := e.loadConfig()
+	if err != nil {
+		return err
+	}
+	if bucket != "" {
+		cfg.Export.Bucket = bucket
+	}
+
+	pool, err := database.NewPool(ctx, cfg.Postgres)
+	if err != nil {
+		return fmt.Errorf("connect to postgres: %w", err)
+	}
+	defer pool.Close()
+
+	store, err := storage.Open(ctx, cfg, logger)
+	if err != nil {
+		return fmt.Errorf("open object store: %w", err)
+	}
+
+	var target export.Target
+	dest := filepath.Join(out, name)
+	if cfg.Export.Bucket != "" {
+		prefix := path.Join(cfg.Export.Prefix, name)
+		// Shards are compressed as their format dictates; store them as written.
+		target, err = export.NewStoreTarget(store.Unwrap(), cfg.Export.Bucket, prefix, dest)
+		dest = cfg.Export.Bucket + "/" + prefix
+	} else {
+		target, err = export.NewDirTarget(dest)
+	}
+	if err != nil {
+		return err
+	}
+
+	logger.Info("exporting pages", "to", dest, "format", opts.Format, "filter", fmt.Sprintf("%+v", opts.Filter),
+		"min_text_bytes", opts.MinTextBytes, "shard_records", opts.ShardRecords)
+	src := export.NewPostgresSource(pool, store, opts.Filter, logger)
+	m, err := export.NewExporter(src, target, opts, batch, logger).Run(ctx)
+	if err != nil {
+		return err
+	}
+	var records int
+	for _, s := range m.Shards {
+		records += s.Records
+	}
+	logger.Info("export complete", "to", dest, "shards", len(m.Shards), "records", records)
+	return nil
+}
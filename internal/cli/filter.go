@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/theognis1002/nimbus-crawler/internal/database/models"
+)
+
+// urlFilterFlags registers the flags selecting stored pages, describing them
+// as noun, and returns a function building the filter once they are parsed.
+func urlFilterFlags(fs *flag.FlagSet, noun string) func() (models.URLFilter, error) {
+	domain := fs.String("domain", "", "only "+noun+" of this domain")
+	status := fs.String("status", string(models.StatusParsed), "comma-separated URL statuses to select")
+	since := fs.String("since", "", "only "+noun+" last crawled at or after this date (YYYY-MM-DD or RFC 3339)")
+	until := fs.String("until", "", "only "+noun+" last crawled before this date (YYYY-MM-DD or RFC 3339)")
+	minDepth := fs.Int("min-depth", 0, "only "+noun+" at this depth or deeper")
+	maxDepth := fs.Int("max-depth", -1, "only "+noun+" at this depth or shallower (-1: no limit)")
+	language := fs.String("language", "", "only "+noun+" in this language")
+
+	return func() (models.URLFilter, error) {
+		filter := models.URLFilter{Domain: *domain, MinDepth: *minDepth, Language: *language}
+		for _, s := range strings.Split(*status, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				filter.Statuses = append(filter.Statuses, models.URLStatus(s))
+			}
+		}
+		var err error
+		if filter.CrawledSince, err = parseDate(*since); err != nil {
+			return filter, fmt.Errorf("invalid -since: %w", err)
+		}
+		if filter.CrawledUntil, err = parseDate(*until); err != nil {
+			return filter, fmt.Errorf("invalid -until: %w", err)
+		}
+		if *maxDepth >= 0 {
+			filter.MaxDepth = maxDepth
+		}
+		return filter, nil
+	}
+}
+
+// parseDate parses a YYYY-MM-DD date or an RFC 3339 time. "" is the zero time.
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+)
+
+func migrateCommand() *command {
+	return &command{
+		name:    "migrate",
+		summary: "Apply, revert and inspect database migrations",
+		subcommands: []*command{
+			{
+				name:    "up",
+				summary: "Apply every pending migration",
+				flags:   func(fs *flag.FlagSet) runFunc { return runMigrateUp },
+			},
+			{
+				name:    "down",
+				args:    "[N]",
+				summary: "Revert the last N migrations (default 1)",
+				flags: func(fs *flag.FlagSet) runFunc {
+					all := fs.Bool("all", false, "revert every migration")
+					return func(ctx context.Context, e *env, args []string) error {
+						return runMigrateDown(e, args, *all)
+					}
+				},
+			},
+			{
+				name:    "status",
+				summary: "Print the current migration version",
+				flags:   func(fs *flag.FlagSet) runFunc { return runMigrateStatus },
+			},
+			{
+				name:    "goto",
+				args:    "VERSION",
+				summary: "Migrate up or down to a version",
+				flags:   func(fs *flag.FlagSet) runFunc { return runMigrateGoto },
+			},
+		},
+	}
+}
+
+// openMigrator returns a migrator for the configured database and
+// migrations.
+func openMigrator(e *env) (*migrate.Migrate, error) {
+	cfg, err := e.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	e.logger.Debug("opening migrations", "path", cfg.Migration.Path, "host", cfg.Postgres.Host, "db", cfg.Postgres.Database)
+	m, err := migrate.New(cfg.Migration.Path, cfg.Postgres.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("create migrator: %w", err)
+	}
+	return m, nil
+}
+
+// finishMigration logs the outcome of a migration step. No change is not an
+// error.
+func finishMigration(e *env, m *migrate.Migrate, err error) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		e.logger.Info("no migrations to run")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		e.logger.Info("migrations completed successfully", "version", 0)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read migration version: %w", err)
+	}
+	e.logger.Info("migrations completed successfully", "version", version, "dirty", dirty)
+	return nil
+}
+
+func runMigrateUp(ctx context.Context, e *env, _ []string) error {
+	m, err := openMigrator(e)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	return finishMigration(e, m, m.Up())
+}
+
+func runMigrateDown(e *env, args []string, all bool) error {
+	steps := 1
+	switch {
+	case len(args) > 1:
+		return fmt.Errorf("migrate down takes at most one step count, got %d arguments", len(args))
+	case len(args) == 1 && all:
+		return errors.New("migrate down takes a step count or --all, not both")
+	case len(args) == 1:
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid step count %q: want a positive number", args[0])
+		}
+		steps = n
+	}
+
+	m, err := openMigrator(e)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	if all {
+		return finishMigration(e, m, m.Down())
+	}
+	return finishMigration(e, m, m.Steps(-steps))
+}
+
+func runMigrateStatus(ctx context.Context, e *env, _ []string) error {
+	m, err := openMigrator(e)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		fmt.Fprintln(e.stdout, "no migrations applied")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read migration version: %w", err)
+	}
+	fmt.Fprintf(e.stdout, "version %d", version)
+	if dirty {
+		fmt.Fprint(e.stdout, " (dirty: the last migration failed part way and must be fixed by hand)")
+	}
+	fmt.Fprintln(e.stdout)
+	return nil
+}
+
+func runMigrateGoto(ctx context.Context, e *env, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("migrate goto takes one version, got %d arguments", len(args))
+	}
+	version, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid version %q: %w", args[0], err)
+	}
+	m, err := openMigrator(e)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	return finishMigration(e, m, m.Migrate(uint(version)))
+}
@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+	"github.com/theognis1002/nimbus-crawler/internal/cache"
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+	"github.com/theognis1002/nimbus-crawler/internal/database"
+	"github.com/theognis1002/nimbus-crawler/internal/events"
+	"github.com/theognis1002/nimbus-crawler/internal/metrics"
+	internalparser "github.com/theognis1002/nimbus-crawler/internal/parser"
+	"github.com/theognis1002/nimbus-crawler/internal/queue"
+	"github.com/theognis1002/nimbus-crawler/internal/sink"
+	"github.com/theognis1002/nimbus-crawler/internal/storage"
+	"github.com/theognis1002/nimbus-crawler/internal/tracing"
+)
+
+func parseCommand() *command {
+	return &command{
+		name:    "parse",
+		summary: "Extract text and links from stored pages on the parse queue",
+		flags:   func(fs *flag.FlagSet) runFunc { return runParse },
+	}
+}
+
+func runParse(ctx context.Context, e *env, _ []string) error {
+	logger := e.logger
+	cfg, err := e.loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := requireSharedQueue(cfg); err != nil {
+		return err
+	}
+
+	cfg.AutoSizePoolForWorkers(cfg.Parser.Workers)
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing, "nimbus-parser")
+	if err != nil {
+		return fmt.Errorf("set up tracing: %w", err)
+	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			logger.Warn("failed to flush traces", "error", err)
+		}
+	}()
+
+	pool, err := database.NewPool(ctx, cfg.Postgres)
+	if err != nil {
+		return fmt.Errorf("connect to postgres: %w", err)
+	}
+	defer pool.Close()
+
+	rdb, err := cache.NewRedisClient(ctx, cfg.Redis)
+	if err != nil {
+		return fmt.Errorf("connect to redis: %w", err)
+	}
+	defer rdb.Close()
+
+	backend, err := queue.Open(ctx, queueOptions(cfg), rdb, logger)
+	if err != nil {
+		return fmt.Errorf("open queue backend: %w", err)
+	}
+	defer backend.Close()
+
+	store, err := storage.Open(ctx, cfg, logger)
+	if err != nil {
+		return fmt.Errorf("open object store: %w", err)
+	}
+
+	return parse(ctx, cfg, pool, rdb, backend, store, logger)
+}
+
+// parse runs the parser over backend until ctx is cancelled.
+func parse(ctx context.Context, cfg *config.Config, pool *pgxpool.Pool, rdb *redis.Client, backend queue.Backend, store *storage.CodecStore, logger *slog.Logger) error {
+	metrics.Serve(ctx, cfg.Metrics.ParserAddr, logger)
+	if cfg.Queue.Backend == queue.BackendRedis && cfg.Metrics.SampleIntervalSecs > 0 {
+		interval := time.Duration(cfg.Metrics.SampleIntervalSecs) * time.Second
+		go metrics.SampleRedisStreams(ctx, rdb, cfg.Frontier.Partitions, interval, logger)
+	}
+
+	publisher := backend.Publisher()
+
+	p := internalparser.New(cfg.Parser, pool, publisher, store, cfg.Storage, logger)
+
+	sinks, err := sink.Open(cfg.Sinks, rdb, logger)
+	if err != nil {
+		return fmt.Errorf("open sinks: %w", err)
+	}
+	if sinks != nil {
+		p.SetSink(sinks, cfg.Sinks.MaxTextBytes)
+		defer func() {
+			flushCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := sinks.Close(flushCtx); err != nil {
+				logger.Warn("failed to flush sinks", "error", err)
+			}
+		}()
+	}
+
+	if cfg.Events.Enabled {
+		p.SetEvents(events.NewBus(rdb, cfg.Events.MaxLen, logger))
+		logger.Info("emitting events", "stream", events.Stream)
+	}
+
+	consumer := backend.ParseConsumer(queue.ConsumerName("parser"), cfg.Parser.PrefetchCount)
+	deliveries := consumer.Run(ctx)
+
+	logger.Info("parser starting", "workers", cfg.Parser.Workers, "max_depth", cfg.Parser.MaxDepth)
+	p.Run(ctx, deliveries)
+	consumer.Wait()
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/theognis1002/nimbus-crawler/internal/storage"
+)
+
+func recompressCommand() *command {
+	return &command{
+		name:    "recompress",
+		summary: "Rewrite stored HTML and text objects with the configured compression",
+		flags: func(fs *flag.FlagSet) runFunc {
+			bucket := fs.String("bucket", "", "only recompress this bucket (default: all)")
+			workers := fs.Int("workers", 8, "objects to recompress concurrently")
+			return func(ctx context.Context, e *env, _ []string) error {
+				return runRecompress(ctx, e, *bucket, *workers)
+			}
+		},
+	}
+}
+
+// runRecompress rewrites objects in place. Objects already stored with the
+// configured compression are left alone, so it can be rerun safely after an
+// interruption.
+func runRecompress(ctx context.Context, e *env, bucket string, workers int) error {
+	logger := e.logger
+	cfg, err := e.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	store, err := storage.Open(ctx, cfg, logger)
+	if err != nil {
+		return fmt.Errorf("open object store: %w", err)
+	}
+
+	buckets := []string{cfg.Storage.HTMLBucket, cfg.Storage.TextBucket}
+	if bucket != "" {
+		buckets = []string{bucket}
+	}
+
+	logger.Info("recompressing objects",
+		"compression", cfg.Storage.Compression, "level", cfg.Storage.CompressionLevel, "buckets", buckets)
+
+	for _, b := range buckets {
+		var rewritten, skipped, failed atomic.Int64
+		keys := make(chan string)
+		var wg sync.WaitGroup
+		for i := 0; i < max(workers, 1); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for key := range keys {
+					changed, err := store.Recompress(ctx, b, key)
+					switch {
+					case err != nil:
+						failed.Add(1)
+						logger.Error("failed to recompress object", "bucket", b, "key", key, "error", err)
+					case changed:
+						rewritten.Add(1)
+					default:
+						skipped.Add(1)
+					}
+				}
+			}()
+		}
+
+		err := store.Walk(ctx, b, func(key string) error {
+			select {
+			case keys <- key:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		close(keys)
+		wg.Wait()
+
+		logger.Info("bucket recompressed", "bucket", b,
+			"rewritten", rewritten.Load(), "skipped", skipped.Load(), "failed", failed.Load())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
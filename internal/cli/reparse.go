@@ -0,0 +1,165 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/theognis1002/nimbus-crawler/internal/cache"
+	"github.com/theognis1002/nimbus-crawler/internal/database"
+	"github.com/theognis1002/nimbus-crawler/internal/database/models"
+	"github.com/theognis1002/nimbus-crawler/internal/queue"
+)
+
+// backlogPoll is how long to wait before rechecking a busy parse queue.
+const backlogPoll = 5 * time.Second
+
+// reparseOptions are the settings of a reparse run.
+type reparseOptions struct {
+	filter     models.URLFilter
+	skipLinks  bool
+	rate       float64
+	maxBacklog int64
+	batch      int
+	dryRun     bool
+}
+
+func reparseCommand() *command {
+	return &command{
+		name:    "reparse",
+		summary: "Republish parse messages for stored pages so extraction changes reach them",
+		flags: func(fs *flag.FlagSet) runFunc {
+			filter := urlFilterFlags(fs, "URLs")
+			skipLinks := fs.Bool("skip-links", false, "regenerate text and metadata without enqueueing discovered links")
+			rate := fs.Float64("rate", 20, "parse messages to publish per second")
+			maxBacklog := fs.Int64("max-backlog", 1000, "pause while the parse queue holds more messages than this (0: never)")
+			batch := fs.Int("batch", 100, "URLs to read from postgres at a time")
+			dryRun := fs.Bool("dry-run", false, "count matching URLs without publishing")
+			return func(ctx context.Context, e *env, _ []string) error {
+				opts := reparseOptions{
+					skipLinks:  *skipLinks,
+					rate:       *rate,
+					maxBacklog: *maxBacklog,
+					batch:      max(*batch, 1),
+					dryRun:     *dryRun,
+				}
+				var err error
+				if opts.filter, err = filter(); err != nil {
+					return err
+				}
+				if opts.rate <= 0 {
+					return fmt.Errorf("-rate must be positive")
+				}
+				return runReparse(ctx, e, opts)
+			}
+		},
+	}
+}
+
+func runReparse(ctx context.Context, e *env, opts reparseOptions) error {
+	logger := e.logger
+	cfg, err := e.loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := requireSharedQueue(cfg); err != nil {
+		return err
+	}
+
+	pool, err := database.NewPool(ctx, cfg.Postgres)
+	if err != nil {
+		return fmt.Errorf("connect to postgres: %w", err)
+	}
+	defer pool.Close()
+
+	rdb, err := cache.NewRedisClient(ctx, cfg.Redis)
+	if err != nil {
+		return fmt.Errorf("connect to redis: %w", err)
+	}
+	defer rdb.Close()
+
+	backend, err := queue.Open(ctx, queueOptions(cfg), rdb, logger)
+	if err != nil {
+		return fmt.Errorf("open queue backend: %w", err)
+	}
+	defer backend.Close()
+	publisher := backend.Publisher()
+
+	logger.Info("reparsing stored pages", "filter", fmt.Sprintf("%+v", opts.filter),
+		"skip_links", opts.skipLinks, "rate", opts.rate, "dry_run", opts.dryRun)
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.rate))
+	defer ticker.Stop()
+
+	var published int64
+	after := ""
+	for {
+		pages, err := models.ListStoredPages(ctx, pool, opts.filter, after, opts.batch)
+		if err != nil {
+			return err
+		}
+		if len(pages) == 0 {
+			break
+		}
+		after = pages[len(pages)-1].ID
+
+		if opts.dryRun {
+			published += int64(len(pages))
+			continue
+		}
+		if err := waitForBacklog(ctx, publisher, opts.maxBacklog, logger); err != nil {
+			return err
+		}
+		for _, page := range pages {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+			msg := queue.ParseMessage{
+				URLID:      page.ID,
+				URL:        page.URL,
+				S3HTMLLink: page.S3HTMLLink,
+				Depth:      page.Depth,
+				Reparse:    true,
+				SkipLinks:  opts.skipLinks,
+			}
+			if err := publisher.PublishParse(ctx, msg); err != nil {
+				return fmt.Errorf("publishing parse message for %s: %w", page.URL, err)
+			}
+			published++
+		}
+		logger.Info("reparse progress", "published", published, "last_id", after)
+	}
+
+	if opts.dryRun {
+		logger.Info("dry run complete", "matching", published)
+		return nil
+	}
+	logger.Info("reparse complete", "published", published)
+	return nil
+}
+
+// waitForBacklog blocks while the parse queue holds more than limit messages.
+func waitForBacklog(ctx context.Context, publisher queue.Publisher, limit int64, logger *slog.Logger) error {
+	if limit <= 0 {
+		return nil
+	}
+	for {
+		n, err := publisher.ParseLen(ctx)
+		if err != nil {
+			return err
+		}
+		if n <= limit {
+			return nil
+		}
+		logger.Info("parse queue busy, waiting", "backlog", n, "max_backlog", limit)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backlogPoll):
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/theognis1002/nimbus-crawler/internal/cache"
+	"github.com/theognis1002/nimbus-crawler/internal/database"
+	"github.com/theognis1002/nimbus-crawler/internal/queue"
+	"github.com/theognis1002/nimbus-crawler/internal/seeder"
+	"github.com/theognis1002/nimbus-crawler/internal/storage"
+	"github.com/theognis1002/nimbus-crawler/internal/tracing"
+)
+
+func runCommand() *command {
+	return &command{
+		name:    "run",
+		args:    "[seed file]",
+		summary: "Seed, crawl and parse in one process, e.g. over the memory queue backend",
+		flags: func(fs *flag.FlagSet) runFunc {
+			return func(ctx context.Context, e *env, args []string) error {
+				if len(args) > 1 {
+					return fmt.Errorf("run takes at most one seed file, got %d", len(args))
+				}
+				var seedFile string
+				if len(args) == 1 {
+					seedFile = args[0]
+				}
+				return runAll(ctx, e, seedFile)
+			}
+		},
+	}
+}
+
+// runAll runs the crawler and the parser side by side over one queue
+// backend, after seeding it from seedFile if one is given. It stops both
+// when either fails.
+func runAll(ctx context.Context, e *env, seedFile string) error {
+	logger := e.logger
+	cfg, err := e.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	cfg.AutoSizePoolForWorkers(cfg.Crawler.Workers + cfg.Parser.Workers)
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing, "nimbus")
+	if err != nil {
+		return fmt.Errorf("set up tracing: %w", err)
+	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			logger.Warn("failed to flush traces", "error", err)
+		}
+	}()
+
+	pool, err := database.NewPool(ctx, cfg.Postgres)
+	if err != nil {
+		return fmt.Errorf("connect to postgres: %w", err)
+	}
+	defer pool.Close()
+
+	rdb, err := cache.NewRedisClient(ctx, cfg.Redis)
+	if err != nil {
+		return fmt.Errorf("connect to redis: %w", err)
+	}
+	defer rdb.Close()
+
+	backend, err := queue.Open(ctx, queueOptions(cfg), rdb, logger)
+	if err != nil {
+		return fmt.Errorf("open queue backend: %w", err)
+	}
+	defer backend.Close()
+
+	// The crawler and parser share one object store, as they share the
+	// queue, so the memory storage backend works here too.
+	store, err := storage.Open(ctx, cfg, logger)
+	if err != nil {
+		return fmt.Errorf("open object store: %w", err)
+	}
+
+	if seedFile != "" {
+		if err := seeder.LoadAndPublish(ctx, seedFile, pool, backend.Publisher(), logger); err != nil {
+			return fmt.Errorf("seeding failed: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	var crawlErr, parseErr error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer cancel()
+		crawlErr = crawl(ctx, cfg, pool, rdb, backend, store, logger.With("component", "crawler"))
+	}()
+	go func() {
+		defer wg.Done()
+		defer cancel()
+		parseErr = parse(ctx, cfg, pool, rdb, backend, store, logger.With("component", "parser"))
+	}()
+	wg.Wait()
+	return errors.Join(crawlErr, parseErr)
+}
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/theognis1002/nimbus-crawler/internal/cache"
+	"github.com/theognis1002/nimbus-crawler/internal/database"
+	"github.com/theognis1002/nimbus-crawler/internal/queue"
+	"github.com/theognis1002/nimbus-crawler/internal/seeder"
+)
+
+func seedCommand() *command {
+	return &command{
+		name:    "seed",
+		args:    "[file]",
+		summary: "Insert the seed URLs of a file and publish them to the frontier",
+		flags: func(fs *flag.FlagSet) runFunc {
+			file := fs.String("file", "seeds.txt", "seed file, one URL per line (a positional file overrides it)")
+			return func(ctx context.Context, e *env, args []string) error {
+				if len(args) > 1 {
+					return fmt.Errorf("seed takes at most one file, got %d", len(args))
+				}
+				if len(args) == 1 {
+					*file = args[0]
+				}
+				return runSeed(ctx, e, *file)
+			}
+		},
+	}
+}
+
+func runSeed(ctx context.Context, e *env, seedFile string) error {
+	logger := e.logger
+	cfg, err := e.loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := requireSharedQueue(cfg); err != nil {
+		return err
+	}
+
+	pool, err := database.NewPool(ctx, cfg.Postgres)
+	if err != nil {
+		return fmt.Errorf("connect to postgres: %w", err)
+	}
+	defer pool.Close()
+
+	rdb, err := cache.NewRedisClient(ctx, cfg.Redis)
+	if err != nil {
+		return fmt.Errorf("connect to redis: %w", err)
+	}
+	defer rdb.Close()
+
+	backend, err := queue.Open(ctx, queueOptions(cfg), rdb, logger)
+	if err != nil {
+		return fmt.Errorf("open queue backend: %w", err)
+	}
+	defer backend.Close()
+
+	if err := seeder.LoadAndPublish(ctx, seedFile, pool, backend.Publisher(), logger); err != nil {
+		return fmt.Errorf("seeding failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/theognis1002/nimbus-crawler/internal/admin"
+	"github.com/theognis1002/nimbus-crawler/internal/api"
+	"github.com/theognis1002/nimbus-crawler/internal/cache"
+	"github.com/theognis1002/nimbus-crawler/internal/crawler"
+	"github.com/theognis1002/nimbus-crawler/internal/database"
+	"github.com/theognis1002/nimbus-crawler/internal/events"
+	"github.com/theognis1002/nimbus-crawler/internal/queue"
+	"github.com/theognis1002/nimbus-crawler/internal/robots"
+	"github.com/theognis1002/nimbus-crawler/internal/storage"
+	"github.com/theognis1002/nimbus-crawler/internal/wayback"
+)
+
+func adminCommand() *command {
+	return &command{
+		name:    "admin",
+		summary: "Serve the admin API: queue stats, domain state, URL records and pausing",
+		flags: func(fs *flag.FlagSet) runFunc {
+			addr := fs.String("addr", ":8091", "address to listen on")
+			return func(ctx context.Context, e *env, _ []string) error {
+				return runAdmin(ctx, e, *addr)
+			}
+		},
+	}
+}
+
+func apiCommand() *command {
+	return &command{
+		name:    "api",
+		summary: "Serve the crawl API: URL batches, single-page fetches and page reads",
+		flags: func(fs *flag.FlagSet) runFunc {
+			addr := fs.String("addr", ":8092", "address to listen on")
+			return func(ctx context.Context, e *env, _ []string) error {
+				return runAPI(ctx, e, *addr)
+			}
+		},
+	}
+}
+
+func archiveCommand() *command {
+	return &command{
+		name:    "archive",
+		summary: "Serve stored pages for browsing, Wayback Machine style",
+		flags: func(fs *flag.FlagSet) runFunc {
+			addr := fs.String("addr", ":8090", "address to listen on")
+			return func(ctx context.Context, e *env, _ []string) error {
+				return runArchive(ctx, e, *addr)
+			}
+		},
+	}
+}
+
+func webhooksCommand() *command {
+	return &command{
+		name:    "webhooks",
+		summary: "Deliver crawl lifecycle events to the configured webhook endpoints",
+		flags:   func(fs *flag.FlagSet) runFunc { return runWebhooks },
+	}
+}
+
+// serveHTTP serves handler on addr until ctx is cancelled.
+func serveHTTP(ctx context.Context, name, addr string, handler http.Handler, logger *slog.Logger) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info(name+" listening", "addr", addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serve: %w", err)
+	}
+	return nil
+}
+
+func runAdmin(ctx context.Context, e *env, addr string) error {
+	logger := e.logger
+	cfg, err := e.loadConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.Admin.Token == "" && cfg.Admin.ReadToken == "" {
+		return errors.New("no admin token configured: set ADMIN_TOKEN or ADMIN_READ_TOKEN")
+	}
+
+	pool, err := database.NewPool(ctx, cfg.Postgres)
+	if err != nil {
+		return fmt.Errorf("connect to postgres: %w", err)
+	}
+	defer pool.Close()
+
+	rdb, err := cache.NewRedisClient(ctx, cfg.Redis)
+	if err != nil {
+		return fmt.Errorf("connect to redis: %w", err)
+	}
+	defer rdb.Close()
+
+	var streams []string
+	if cfg.Queue.Backend == queue.BackendRedis {
+		streams = queue.Streams(cfg.Frontier.Partitions)
+	}
+	tokens := admin.Tokens{Admin: cfg.Admin.Token, Read: cfg.Admin.ReadToken}
+	handler := admin.NewServer(admin.NewPostgresStore(pool), rdb, streams, tokens, logger)
+	return serveHTTP(ctx, "admin server", addr, handler, logger)
+}
+
+func runAPI(ctx context.Context, e *env, addr string) error {
+	logger := e.logger
+	cfg, err := e.loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := requireSharedQueue(cfg); err != nil {
+		return err
+	}
+	if cfg.API.Token == "" && !cfg.API.Insecure {
+		return errors.New("no api token configured: set API_TOKEN, or API_INSECURE=true to allow any caller")
+	}
+
+	pool, err := database.NewPool(ctx, cfg.Postgres)
+	if err != nil {
+		return fmt.Errorf("connect to postgres: %w", err)
+	}
+	defer pool.Close()
+
+	rdb, err := cache.NewRedisClient(ctx, cfg.Redis)
+	if err != nil {
+		return fmt.Errorf("connect to redis: %w", err)
+	}
+	defer rdb.Close()
+
+	backend, err := queue.Open(ctx, queueOptions(cfg), rdb, logger)
+	if err != nil {
+		return fmt.Errorf("open queue backend: %w", err)
+	}
+	defer backend.Close()
+
+	proxyPool, err := crawler.NewProxyPool(cfg.Crawler.Proxy.File, rdb, cfg.Crawler.Proxy.HealthCooldownS, logger)
+	if err != nil {
+		return fmt.Errorf("load proxy pool: %w", err)
+	}
+	fetcher := crawler.NewFetcher(cache.NewDNSCache(rdb), proxyPool, cfg.Crawler.TimeoutSecs, cfg.Crawler.MaxRedirects, logger)
+	var robotsChecker *robots.Checker
+	if cfg.Crawler.RespectRobotsTxt == nil || *cfg.Crawler.RespectRobotsTxt {
+		robotsChecker = robots.NewChecker(pool, rdb, logger)
+	}
+	pages := api.NewPageFetcher(fetcher, robotsChecker, cache.NewRateLimiter(rdb))
+
+	store, err := storage.Open(ctx, cfg, logger)
+	if err != nil {
+		return fmt.Errorf("open object store: %w", err)
+	}
+
+	handler := api.NewServer(cfg.API, cfg.Parser.MaxDepth, api.NewPostgresStore(pool), store, backend.Publisher(), pages, logger)
+	return serveHTTP(ctx, "api server", addr, handler, logger)
+}
+
+func runArchive(ctx context.Context, e *env, addr string) error {
+	logger := e.logger
+	cfg, err := e.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	pool, err := database.NewPool(ctx, cfg.Postgres)
+	if err != nil {
+		return fmt.Errorf("connect to postgres: %w", err)
+	}
+	defer pool.Close()
+
+	store, err := storage.Open(ctx, cfg, logger)
+	if err != nil {
+		return fmt.Errorf("open object store: %w", err)
+	}
+
+	handler := wayback.NewServer(wayback.NewPostgresIndex(pool), store, logger)
+	return serveHTTP(ctx, "archive server", addr, handler, logger)
+}
+
+func runWebhooks(ctx context.Context, e *env, _ []string) error {
+	logger := e.logger
+	cfg, err := e.loadConfig()
+	if err != nil {
+		return err
+	}
+	if len(cfg.Webhooks.Endpoints) == 0 {
+		return errors.New("no webhook endpoints configured: set WEBHOOK_URL or webhooks.endpoints")
+	}
+
+	rdb, err := cache.NewRedisClient(ctx, cfg.Redis)
+	if err != nil {
+		return fmt.Errorf("connect to redis: %w", err)
+	}
+	defer rdb.Close()
+
+	d := events.NewDispatcher(rdb, cfg.Webhooks, queue.ConsumerName("webhooks"), logger)
+	logger.Info("webhook dispatcher starting", "endpoints", len(cfg.Webhooks.Endpoints))
+	if err := d.Run(ctx); err != nil {
+		return fmt.Errorf("dispatch webhooks: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+
+	"github.com/theognis1002/nimbus-crawler/internal/cache"
+	"github.com/theognis1002/nimbus-crawler/internal/database"
+	"github.com/theognis1002/nimbus-crawler/internal/database/models"
+	"github.com/theognis1002/nimbus-crawler/internal/queue"
+)
+
+// statusReport is what the status command prints.
+type statusReport struct {
+	URLs   map[models.URLStatus]int64 `json:"urls"`
+	Queue  queueStatus                `json:"queue"`
+	Paused pauseStatus                `json:"paused"`
+}
+
+type queueStatus struct {
+	Backend  string `json:"backend"`
+	Frontier int64  `json:"frontier"`
+	Parse    int64  `json:"parse"`
+	// Streams details each stream of the redis backend.
+	Streams []queue.StreamStats `json:"streams,omitempty"`
+}
+
+type pauseStatus struct {
+	Global  bool     `json:"global"`
+	Domains []string `json:"domains"`
+}
+
+func statusCommand() *command {
+	return &command{
+		name:    "status",
+		summary: "Print URL counts, queue depths and pause state as JSON",
+		flags:   func(fs *flag.FlagSet) runFunc { return runStatus },
+	}
+}
+
+func runStatus(ctx context.Context, e *env, _ []string) error {
+	cfg, err := e.loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := requireSharedQueue(cfg); err != nil {
+		return err
+	}
+
+	pool, err := database.NewPool(ctx, cfg.Postgres)
+	if err != nil {
+		return fmt.Errorf("connect to postgres: %w", err)
+	}
+	defer pool.Close()
+
+	rdb, err := cache.NewRedisClient(ctx, cfg.Redis)
+	if err != nil {
+		return fmt.Errorf("connect to redis: %w", err)
+	}
+	defer rdb.Close()
+
+	backend, err := queue.Open(ctx, queueOptions(cfg), rdb, e.logger)
+	if err != nil {
+		return fmt.Errorf("open queue backend: %w", err)
+	}
+	defer backend.Close()
+
+	var report statusReport
+	if report.URLs, err = models.CountAllURLsByStatus(ctx, pool); err != nil {
+		return err
+	}
+
+	report.Queue.Backend = cfg.Queue.Backend
+	if report.Queue.Backend == "" {
+		report.Queue.Backend = queue.BackendRedis
+	}
+	pub := backend.Publisher()
+	if report.Queue.Frontier, err = pub.FrontierLen(ctx); err != nil {
+		return fmt.Errorf("reading frontier length: %w", err)
+	}
+	if report.Queue.Parse, err = pub.ParseLen(ctx); err != nil {
+		return fmt.Errorf("reading parse queue length: %w", err)
+	}
+	if report.Queue.Backend == queue.BackendRedis {
+		if report.Queue.Streams, err = queue.InspectStreams(ctx, rdb, queue.Streams(cfg.Frontier.Partitions)); err != nil {
+			return err
+		}
+	}
+
+	if report.Paused.Global, report.Paused.Domains, err = cache.NewPauseFlags(rdb).State(ctx); err != nil {
+		return fmt.Errorf("reading pause state: %w", err)
+	}
+
+	enc := json.NewEncoder(e.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
@@ -16,6 +16,18 @@ type Config struct {
 	MinIO     MinIOConfig     `yaml:"minio"`
 	Crawler   CrawlerConfig   `yaml:"crawler"`
 	Parser    ParserConfig    `yaml:"parser"`
+	Frontier  FrontierConfig  `yaml:"frontier"`
+	Queue     QueueConfig     `yaml:"queue"`
+	Storage   StorageConfig   `yaml:"storage"`
+	WARC      WARCConfig      `yaml:"warc"`
+	Export    ExportConfig    `yaml:"export"`
+	Metrics   MetricsConfig   `yaml:"metrics"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	Admin     AdminConfig     `yaml:"admin"`
+	API       APIConfig       `yaml:"api"`
+	Sinks     SinksConfig     `yaml:"sinks"`
+	Events    EventsConfig    `yaml:"events"`
+	Webhooks  WebhooksConfig  `yaml:"webhooks"`
 	Migration MigrationConfig `yaml:"migration"`
 }
 
@@ -57,11 +69,20 @@ func (c RedisConfig) Addr() string {
 	return fmt.Sprintf("%s:%d", c.Host, c.Port)
 }
 
+// MinIOConfig configures the S3 storage backend, which works with MinIO or
+// any other S3-compatible service. Addressing is "auto", "path" or "virtual"
+// (virtual-host style). Object keys are stored under KeyPrefix, if set.
+// SkipBucketCreation is for credentials that cannot create buckets; the
+// buckets must then exist already.
 type MinIOConfig struct {
-	Endpoint  string `yaml:"endpoint"`
-	AccessKey string `yaml:"access_key"`
-	SecretKey string `yaml:"secret_key"`
-	UseSSL    bool   `yaml:"use_ssl"`
+	Endpoint           string `yaml:"endpoint"`
+	AccessKey          string `yaml:"access_key"`
+	SecretKey          string `yaml:"secret_key"`
+	UseSSL             bool   `yaml:"use_ssl"`
+	Region             string `yaml:"region"`
+	KeyPrefix          string `yaml:"key_prefix"`
+	Addressing         string `yaml:"addressing"`
+	SkipBucketCreation bool   `yaml:"skip_bucket_creation"`
 }
 
 type CrawlerConfig struct {
@@ -73,6 +94,15 @@ type CrawlerConfig struct {
 	PrefetchCount    int         `yaml:"prefetch_count"`
 	RespectRobotsTxt *bool       `yaml:"respect_robots_txt"`
 	Proxy            ProxyConfig `yaml:"proxy"`
+	// LaneWeights are the relative read weights of the frontier priority
+	// lanes, lowest priority first. Unset lanes default to 4^lane.
+	LaneWeights []int `yaml:"lane_weights"`
+	// BackQueueCapacity caps the deliveries buffered across all per-host
+	// back-queues in one crawler process.
+	BackQueueCapacity int `yaml:"back_queue_capacity"`
+	// ReplayDir, if set, replays fetches from the .warc.gz files and CDXJ
+	// indexes under it instead of the network.
+	ReplayDir string `yaml:"replay_dir"`
 }
 
 type ProxyConfig struct {
@@ -81,9 +111,187 @@ type ProxyConfig struct {
 }
 
 type ParserConfig struct {
-	Workers       int `yaml:"workers"`
-	MaxDepth      int `yaml:"max_depth"`
-	PrefetchCount int `yaml:"prefetch_count"`
+	Workers       int            `yaml:"workers"`
+	MaxDepth      int            `yaml:"max_depth"`
+	PrefetchCount int            `yaml:"prefetch_count"`
+	Priority      PriorityConfig `yaml:"priority"`
+	// RecordLinks keeps the links found on each page in the links table.
+	RecordLinks bool `yaml:"record_links"`
+	// IndexSearch keeps each page's text in the page_search table for
+	// full-text search.
+	IndexSearch bool `yaml:"index_search"`
+}
+
+// PriorityConfig weights the signals used to score discovered URLs into
+// frontier priority lanes.
+type PriorityConfig struct {
+	DepthWeight     float64  `yaml:"depth_weight"`
+	SitemapWeight   float64  `yaml:"sitemap_weight"`
+	HostWeight      float64  `yaml:"host_weight"`
+	FreshnessWeight float64  `yaml:"freshness_weight"`
+	ImportantHosts  []string `yaml:"important_hosts"`
+}
+
+// FrontierConfig controls how the frontier streams are sharded across crawler
+// replicas. Hosts are consistently hashed onto Partitions streams, and each
+// replica leases a share of the partitions for LeaseTTLSecs at a time.
+type FrontierConfig struct {
+	Partitions   int `yaml:"partitions"`
+	LeaseTTLSecs int `yaml:"lease_ttl_secs"`
+}
+
+// QueueConfig selects the queue transport and how messages are written.
+// Backend is "redis", "nats" (JetStream at NATSURL) or "memory" (only for
+// `nimbus run`, which keeps every component in one process). Encoding is
+// "json" or "msgpack"; consumers read both, so it can be changed without
+// draining.
+type QueueConfig struct {
+	Backend  string `yaml:"backend"`
+	Encoding string `yaml:"encoding"`
+	NATSURL  string `yaml:"nats_url"`
+}
+
+// StorageConfig selects where objects are stored and how they are written.
+// Backend is "s3" (see MinIOConfig), "fs" (files under Dir) or "memory"
+// (single process only). Compression is "none", "gzip" or "zstd", at
+// CompressionLevel (0 for the codec's default). Objects record their own
+// compression, so it can be changed at any time. With ContentAddressed, HTML
+// is stored under its content hash instead of its URL and every fetch is
+// recorded as a snapshot, keeping each version of a page.
+type StorageConfig struct {
+	Backend          string `yaml:"backend"`
+	HTMLBucket       string `yaml:"html_bucket"`
+	TextBucket       string `yaml:"text_bucket"`
+	Dir              string `yaml:"dir"`
+	Compression      string `yaml:"compression"`
+	CompressionLevel int    `yaml:"compression_level"`
+	ContentAddressed bool   `yaml:"content_addressed"`
+}
+
+// WARCConfig controls the crawler's WARC output. When Enabled, every fetch
+// is recorded in .warc.gz files of about MaxFileBytes, uploaded to Bucket
+// under names starting with Prefix, each with a .cdxj index alongside.
+type WARCConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	Bucket       string `yaml:"bucket"`
+	Prefix       string `yaml:"prefix"`
+	MaxFileBytes int64  `yaml:"max_file_bytes"`
+}
+
+// ExportConfig is where cmd/export writes datasets by default: objects in
+// Bucket under Prefix when Bucket is set, otherwise local files.
+type ExportConfig struct {
+	Bucket string `yaml:"bucket"`
+	Prefix string `yaml:"prefix"`
+}
+
+// SinksConfig sends each parsed page to every sink that is configured: an
+// Elasticsearch or OpenSearch index (Elasticsearch.URL), rolling .jsonl.gz
+// files (JSONL.Dir) and a Kafka topic through a Kafka REST Proxy
+// (Kafka.RESTURL). Pages are sent in batches of up to BatchSize pages and
+// BatchMaxBytes bytes, at least every FlushIntervalMs; failed deliveries are
+// retried MaxRetries times, backing off from RetryBackoffMs, then
+// dead-lettered. Pages a backed-up sink cannot queue within SendTimeoutMs
+// are dead-lettered too. MaxTextBytes caps the text sent with each page.
+type SinksConfig struct {
+	BatchSize       int                     `yaml:"batch_size"`
+	BatchMaxBytes   int                     `yaml:"batch_max_bytes"`
+	FlushIntervalMs int                     `yaml:"flush_interval_ms"`
+	SendTimeoutMs   int                     `yaml:"send_timeout_ms"`
+	MaxRetries      int                     `yaml:"max_retries"`
+	RetryBackoffMs  int                     `yaml:"retry_backoff_ms"`
+	MaxTextBytes    int                     `yaml:"max_text_bytes"`
+	Elasticsearch   ElasticsearchSinkConfig `yaml:"elasticsearch"`
+	JSONL           JSONLSinkConfig         `yaml:"jsonl"`
+	Kafka           KafkaSinkConfig         `yaml:"kafka"`
+}
+
+// ElasticsearchSinkConfig authenticates with APIKey if set, else with
+// Username and Password if set.
+type ElasticsearchSinkConfig struct {
+	URL      string `yaml:"url"`
+	Index    string `yaml:"index"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	APIKey   string `yaml:"api_key"`
+}
+
+// JSONLSinkConfig writes files named Prefix-<time>-<id>.jsonl.gz under Dir,
+// starting a new one after MaxFileBytes or MaxFileAgeSecs.
+type JSONLSinkConfig struct {
+	Dir            string `yaml:"dir"`
+	Prefix         string `yaml:"prefix"`
+	MaxFileBytes   int64  `yaml:"max_file_bytes"`
+	MaxFileAgeSecs int    `yaml:"max_file_age_secs"`
+}
+
+type KafkaSinkConfig struct {
+	RESTURL string `yaml:"rest_url"`
+	Topic   string `yaml:"topic"`
+}
+
+// EventsConfig makes the crawler and parser write lifecycle events to the
+// stream:events Redis stream, capped at about MaxLen entries. Crawlers check
+// every DrainCheckSecs whether the frontier has drained.
+type EventsConfig struct {
+	Enabled        bool  `yaml:"enabled"`
+	MaxLen         int64 `yaml:"max_len"`
+	DrainCheckSecs int   `yaml:"drain_check_secs"`
+}
+
+// WebhooksConfig lists the endpoints the webhook dispatcher delivers events
+// to. Failed deliveries are retried MaxRetries times, backing off from
+// RetryBackoffMs, each attempt given TimeoutSecs.
+type WebhooksConfig struct {
+	MaxRetries     int               `yaml:"max_retries"`
+	RetryBackoffMs int               `yaml:"retry_backoff_ms"`
+	TimeoutSecs    int               `yaml:"timeout_secs"`
+	Endpoints      []WebhookEndpoint `yaml:"endpoints"`
+}
+
+// WebhookEndpoint receives the events whose types are in Events, or all of
+// them if it is empty, signed with Secret. Name identifies it in logs and
+// names its consumer group, so it should not change.
+type WebhookEndpoint struct {
+	Name   string   `yaml:"name"`
+	URL    string   `yaml:"url"`
+	Secret string   `yaml:"secret"`
+	Events []string `yaml:"events"`
+}
+
+// MetricsConfig sets where the crawler and parser serve Prometheus metrics
+// on /metrics, and how often queue gauges are sampled from Redis.
+type MetricsConfig struct {
+	CrawlerAddr        string `yaml:"crawler_addr"`
+	ParserAddr         string `yaml:"parser_addr"`
+	SampleIntervalSecs int    `yaml:"sample_interval_secs"`
+}
+
+// TracingConfig selects where OpenTelemetry spans are exported. Exporter is
+// "none", which disables tracing, "otlp" (OTLP over HTTP to Endpoint, a
+// host:port) or "stdout". SampleRatio is the fraction of new traces
+// recorded; unset means all of them, and 0 records none.
+type TracingConfig struct {
+	Exporter    string   `yaml:"exporter"`
+	Endpoint    string   `yaml:"endpoint"`
+	Insecure    bool     `yaml:"insecure"`
+	SampleRatio *float64 `yaml:"sample_ratio"`
+}
+
+// AdminConfig holds the bearer tokens of the admin API. Token grants full
+// access; ReadToken only allows reads. The API refuses to start without
+// either.
+type AdminConfig struct {
+	Token     string `yaml:"token"`
+	ReadToken string `yaml:"read_token"`
+}
+
+// APIConfig configures the crawl API. Requests must carry Token as a bearer
+// token; the API refuses to start without one unless Insecure explicitly
+// opts out of authentication.
+type APIConfig struct {
+	Token    string `yaml:"token"`
+	Insecure bool   `yaml:"insecure"`
 }
 
 type MigrationConfig struct {
@@ -91,26 +299,70 @@ type MigrationConfig struct {
 }
 
 const (
-	defaultPostgresHost     = "localhost"
-	defaultPostgresPort     = 5432
-	defaultPostgresUser     = "nimbus"
-	defaultPostgresDB       = "nimbus"
-	defaultPostgresMaxConns = 20
-	defaultPostgresMinConns = 2
-	defaultRedisHost        = "localhost"
-	defaultRedisPort        = 6379
-	defaultRedisPoolSize    = 50
-	defaultRedisMinIdle     = 5
-	defaultMinIOEndpoint    = "localhost:9000"
-	defaultCrawlerWorkers   = 10
-	defaultMaxDepth         = 3
-	defaultMaxRetries       = 3
-	defaultTimeoutSecs      = 30
-	defaultMaxRedirects     = 5
-	defaultPrefetchCount    = 10
-	defaultParserWorkers    = 5
+	defaultPostgresHost         = "localhost"
+	defaultPostgresPort         = 5432
+	defaultPostgresUser         = "nimbus"
+	defaultPostgresDB           = "nimbus"
+	defaultPostgresMaxConns     = 20
+	defaultPostgresMinConns     = 2
+	defaultRedisHost            = "localhost"
+	defaultRedisPort            = 6379
+	defaultRedisPoolSize        = 50
+	defaultRedisMinIdle         = 5
+	defaultMinIOEndpoint        = "localhost:9000"
+	defaultCrawlerWorkers       = 10
+	defaultMaxDepth             = 3
+	defaultMaxRetries           = 3
+	defaultTimeoutSecs          = 30
+	defaultMaxRedirects         = 5
+	defaultPrefetchCount        = 10
+	defaultParserWorkers        = 5
+	defaultBackQueueCapacity    = 500
+	defaultFrontierPartitions   = 1
+	defaultLeaseTTLSecs         = 30
+	defaultQueueBackend         = "redis"
+	defaultQueueEncoding        = "json"
+	defaultNATSURL              = "nats://localhost:4222"
+	defaultStorageBackend       = "s3"
+	defaultHTMLBucket           = "nimbus-html"
+	defaultTextBucket           = "nimbus-text"
+	defaultStorageDir           = "data/objects"
+	defaultStorageCompression   = "none"
+	defaultS3Addressing         = "auto"
+	defaultWARCBucket           = "nimbus-warc"
+	defaultWARCPrefix           = "nimbus"
+	defaultWARCMaxFileBytes     = 1 << 30 // 1GiB
+	defaultExportPrefix         = "exports"
+	defaultSinkBatchSize        = 500
+	defaultSinkBatchMaxBytes    = 10 << 20 // 10MiB
+	defaultSinkFlushIntervalMs  = 1000
+	defaultSinkSendTimeoutMs    = 100
+	defaultSinkMaxRetries       = 5
+	defaultSinkRetryBackoffMs   = 500
+	defaultSinkMaxTextBytes     = 1 << 20 // 1MiB
+	defaultSinkIndex            = "nimbus-pages"
+	defaultSinkJSONLPrefix      = "nimbus-pages"
+	defaultSinkJSONLFileBytes   = 256 << 20 // 256MiB
+	defaultSinkJSONLFileAgeSecs = 3600
+	defaultSinkKafkaTopic       = "nimbus-pages"
+	defaultEventsMaxLen         = 100000
+	defaultEventsDrainCheckSecs = 10
+	defaultWebhookMaxRetries    = 5
+	defaultWebhookBackoffMs     = 1000
+	defaultWebhookTimeoutSecs   = 10
+	defaultMetricsCrawlerAddr   = ":9100"
+	defaultMetricsParserAddr    = ":9101"
+	defaultMetricsSampleSecs    = 15
+	defaultTracingExporter      = "none"
+	defaultTracingEndpoint      = "localhost:4318"
+	defaultTracingSampleRatio   = 1.0
 	defaultMigrationPath        = "file://internal/database/migrations"
 	defaultProxyHealthCooldownS = 60
+
+	defaultPriorityDepthWeight     = 0.5
+	defaultPrioritySitemapWeight   = 0.2
+	defaultPriorityHostWeight      = 0.2
+	defaultPriorityFreshnessWeight = 0.1
 )
 
 func LoadFromEnv() *Config {
@@ -185,6 +437,9 @@ func (c *Config) applyDefaults() {
 	if c.Crawler.PrefetchCount == 0 {
 		c.Crawler.PrefetchCount = defaultPrefetchCount
 	}
+	if c.Crawler.BackQueueCapacity == 0 {
+		c.Crawler.BackQueueCapacity = defaultBackQueueCapacity
+	}
 	if c.Parser.Workers == 0 {
 		c.Parser.Workers = defaultParserWorkers
 	}
@@ -194,6 +449,12 @@ func (c *Config) applyDefaults() {
 	if c.Parser.PrefetchCount == 0 {
 		c.Parser.PrefetchCount = defaultPrefetchCount
 	}
+	if pc := &c.Parser.Priority; pc.DepthWeight == 0 && pc.SitemapWeight == 0 && pc.HostWeight == 0 && pc.FreshnessWeight == 0 {
+		pc.DepthWeight = defaultPriorityDepthWeight
+		pc.SitemapWeight = defaultPrioritySitemapWeight
+		pc.HostWeight = defaultPriorityHostWeight
+		pc.FreshnessWeight = defaultPriorityFreshnessWeight
+	}
 	if c.Crawler.RespectRobotsTxt == nil {
 		t := true
 		c.Crawler.RespectRobotsTxt = &t
@@ -201,6 +462,121 @@ func (c *Config) applyDefaults() {
 	if c.Crawler.Proxy.HealthCooldownS == 0 {
 		c.Crawler.Proxy.HealthCooldownS = defaultProxyHealthCooldownS
 	}
+	if c.Frontier.Partitions == 0 {
+		c.Frontier.Partitions = defaultFrontierPartitions
+	}
+	if c.Frontier.LeaseTTLSecs == 0 {
+		c.Frontier.LeaseTTLSecs = defaultLeaseTTLSecs
+	}
+	if c.Queue.Backend == "" {
+		c.Queue.Backend = defaultQueueBackend
+	}
+	if c.Queue.Encoding == "" {
+		c.Queue.Encoding = defaultQueueEncoding
+	}
+	if c.Queue.NATSURL == "" {
+		c.Queue.NATSURL = defaultNATSURL
+	}
+	if c.MinIO.Addressing == "" {
+		c.MinIO.Addressing = defaultS3Addressing
+	}
+	if c.Storage.Backend == "" {
+		c.Storage.Backend = defaultStorageBackend
+	}
+	if c.Storage.HTMLBucket == "" {
+		c.Storage.HTMLBucket = defaultHTMLBucket
+	}
+	if c.Storage.TextBucket == "" {
+		c.Storage.TextBucket = defaultTextBucket
+	}
+	if c.Storage.Dir == "" {
+		c.Storage.Dir = defaultStorageDir
+	}
+	if c.Storage.Compression == "" {
+		c.Storage.Compression = defaultStorageCompression
+	}
+	if c.WARC.Bucket == "" {
+		c.WARC.Bucket = defaultWARCBucket
+	}
+	if c.WARC.Prefix == "" {
+		c.WARC.Prefix = defaultWARCPrefix
+	}
+	if c.WARC.MaxFileBytes == 0 {
+		c.WARC.MaxFileBytes = defaultWARCMaxFileBytes
+	}
+	if c.Export.Prefix == "" {
+		c.Export.Prefix = defaultExportPrefix
+	}
+	if c.Sinks.BatchSize == 0 {
+		c.Sinks.BatchSize = defaultSinkBatchSize
+	}
+	if c.Sinks.BatchMaxBytes == 0 {
+		c.Sinks.BatchMaxBytes = defaultSinkBatchMaxBytes
+	}
+	if c.Sinks.FlushIntervalMs == 0 {
+		c.Sinks.FlushIntervalMs = defaultSinkFlushIntervalMs
+	}
+	if c.Sinks.SendTimeoutMs == 0 {
+		c.Sinks.SendTimeoutMs = defaultSinkSendTimeoutMs
+	}
+	if c.Sinks.MaxRetries == 0 {
+		c.Sinks.MaxRetries = defaultSinkMaxRetries
+	}
+	if c.Sinks.RetryBackoffMs == 0 {
+		c.Sinks.RetryBackoffMs = defaultSinkRetryBackoffMs
+	}
+	if c.Sinks.MaxTextBytes == 0 {
+		c.Sinks.MaxTextBytes = defaultSinkMaxTextBytes
+	}
+	if c.Sinks.Elasticsearch.Index == "" {
+		c.Sinks.Elasticsearch.Index = defaultSinkIndex
+	}
+	if c.Sinks.JSONL.Prefix == "" {
+		c.Sinks.JSONL.Prefix = defaultSinkJSONLPrefix
+	}
+	if c.Sinks.JSONL.MaxFileBytes == 0 {
+		c.Sinks.JSONL.MaxFileBytes = defaultSinkJSONLFileBytes
+	}
+	if c.Sinks.JSONL.MaxFileAgeSecs == 0 {
+		c.Sinks.JSONL.MaxFileAgeSecs = defaultSinkJSONLFileAgeSecs
+	}
+	if c.Sinks.Kafka.Topic == "" {
+		c.Sinks.Kafka.Topic = defaultSinkKafkaTopic
+	}
+	if c.Events.MaxLen == 0 {
+		c.Events.MaxLen = defaultEventsMaxLen
+	}
+	if c.Events.DrainCheckSecs == 0 {
+		c.Events.DrainCheckSecs = defaultEventsDrainCheckSecs
+	}
+	if c.Webhooks.MaxRetries == 0 {
+		c.Webhooks.MaxRetries = defaultWebhookMaxRetries
+	}
+	if c.Webhooks.RetryBackoffMs == 0 {
+		c.Webhooks.RetryBackoffMs = defaultWebhookBackoffMs
+	}
+	if c.Webhooks.TimeoutSecs == 0 {
+		c.Webhooks.TimeoutSecs = defaultWebhookTimeoutSecs
+	}
+	if c.Metrics.CrawlerAddr == "" {
+		c.Metrics.CrawlerAddr = defaultMetricsCrawlerAddr
+	}
+	if c.Metrics.ParserAddr == "" {
+		c.Metrics.ParserAddr = defaultMetricsParserAddr
+	}
+	if c.Metrics.SampleIntervalSecs == 0 {
+		c.Metrics.SampleIntervalSecs = defaultMetricsSampleSecs
+	}
+	if c.Tracing.Exporter == "" {
+		c.Tracing.Exporter = defaultTracingExporter
+	}
+	if c.Tracing.Endpoint == "" {
+		c.Tracing.Endpoint = defaultTracingEndpoint
+	}
+	if c.Tracing.SampleRatio == nil {
+		r := defaultTracingSampleRatio
+		c.Tracing.SampleRatio = &r
+	}
 	if c.Migration.Path == "" {
 		c.Migration.Path = defaultMigrationPath
 	}
@@ -221,9 +597,29 @@ func Load(path string) (*Config, error) {
 
 	cfg.applyDefaults()
 	cfg.applyEnvOverrides()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
 	return cfg, nil
 }
 
+// Validate reports settings that cannot work. Unset values have their
+// defaults by now, so only values given explicitly can fail.
+func (c *Config) Validate() error {
+	if c.Frontier.Partitions < 1 {
+		return fmt.Errorf("frontier.partitions must be at least 1, got %d", c.Frontier.Partitions)
+	}
+	if c.Frontier.LeaseTTLSecs < 1 {
+		return fmt.Errorf("frontier.lease_ttl_secs must be at least 1, got %d", c.Frontier.LeaseTTLSecs)
+	}
+	switch c.Queue.Backend {
+	case "redis", "nats", "memory":
+	default:
+		return fmt.Errorf("unknown queue.backend %q: want redis, nats or memory", c.Queue.Backend)
+	}
+	return nil
+}
+
 func (c *Config) applyEnvOverrides() {
 	if v := os.Getenv("POSTGRES_HOST"); v != "" {
 		c.Postgres.Host = v
@@ -288,6 +684,18 @@ func (c *Config) applyEnvOverrides() {
 	if v := os.Getenv("MINIO_USE_SSL"); v != "" {
 		c.MinIO.UseSSL = strings.EqualFold(v, "true")
 	}
+	if v := os.Getenv("MINIO_REGION"); v != "" {
+		c.MinIO.Region = v
+	}
+	if v := os.Getenv("MINIO_KEY_PREFIX"); v != "" {
+		c.MinIO.KeyPrefix = v
+	}
+	if v := os.Getenv("MINIO_ADDRESSING"); v != "" {
+		c.MinIO.Addressing = v
+	}
+	if v := os.Getenv("MINIO_SKIP_BUCKET_CREATION"); v != "" {
+		c.MinIO.SkipBucketCreation = strings.EqualFold(v, "true")
+	}
 	if v := os.Getenv("MAX_DEPTH"); v != "" {
 		if d, err := strconv.Atoi(v); err == nil {
 			c.Crawler.MaxDepth = d
@@ -299,11 +707,25 @@ func (c *Config) applyEnvOverrides() {
 			c.Crawler.Workers = w
 		}
 	}
+	if v := os.Getenv("CRAWLER_BACK_QUEUE_CAPACITY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.Crawler.BackQueueCapacity = n
+		}
+	}
+	if v := os.Getenv("CRAWLER_REPLAY_DIR"); v != "" {
+		c.Crawler.ReplayDir = v
+	}
 	if v := os.Getenv("PARSER_WORKERS"); v != "" {
 		if w, err := strconv.Atoi(v); err == nil {
 			c.Parser.Workers = w
 		}
 	}
+	if v := os.Getenv("PARSER_RECORD_LINKS"); v != "" {
+		c.Parser.RecordLinks = strings.EqualFold(v, "true")
+	}
+	if v := os.Getenv("PARSER_INDEX_SEARCH"); v != "" {
+		c.Parser.IndexSearch = strings.EqualFold(v, "true")
+	}
 	if v := os.Getenv("PROXY_FILE"); v != "" {
 		c.Crawler.Proxy.File = v
 	}
@@ -316,6 +738,142 @@ func (c *Config) applyEnvOverrides() {
 		b := strings.EqualFold(v, "true")
 		c.Crawler.RespectRobotsTxt = &b
 	}
+	if v := os.Getenv("FRONTIER_PARTITIONS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.Frontier.Partitions = n
+		}
+	}
+	if v := os.Getenv("QUEUE_BACKEND"); v != "" {
+		c.Queue.Backend = v
+	}
+	if v := os.Getenv("NATS_URL"); v != "" {
+		c.Queue.NATSURL = v
+	}
+	if v := os.Getenv("QUEUE_ENCODING"); v != "" {
+		c.Queue.Encoding = v
+	}
+	if v := os.Getenv("STORAGE_BACKEND"); v != "" {
+		c.Storage.Backend = v
+	}
+	if v := os.Getenv("STORAGE_HTML_BUCKET"); v != "" {
+		c.Storage.HTMLBucket = v
+	}
+	if v := os.Getenv("STORAGE_TEXT_BUCKET"); v != "" {
+		c.Storage.TextBucket = v
+	}
+	if v := os.Getenv("STORAGE_DIR"); v != "" {
+		c.Storage.Dir = v
+	}
+	if v := os.Getenv("STORAGE_COMPRESSION"); v != "" {
+		c.Storage.Compression = v
+	}
+	if v := os.Getenv("STORAGE_COMPRESSION_LEVEL"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.Storage.CompressionLevel = n
+		}
+	}
+	if v := os.Getenv("STORAGE_CONTENT_ADDRESSED"); v != "" {
+		c.Storage.ContentAddressed = strings.EqualFold(v, "true")
+	}
+	if v := os.Getenv("WARC_ENABLED"); v != "" {
+		c.WARC.Enabled = strings.EqualFold(v, "true")
+	}
+	if v := os.Getenv("WARC_BUCKET"); v != "" {
+		c.WARC.Bucket = v
+	}
+	if v := os.Getenv("WARC_PREFIX"); v != "" {
+		c.WARC.Prefix = v
+	}
+	if v := os.Getenv("WARC_MAX_FILE_BYTES"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			c.WARC.MaxFileBytes = n
+		}
+	}
+	if v := os.Getenv("EXPORT_BUCKET"); v != "" {
+		c.Export.Bucket = v
+	}
+	if v := os.Getenv("EXPORT_PREFIX"); v != "" {
+		c.Export.Prefix = v
+	}
+	if v := os.Getenv("SINK_BATCH_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.Sinks.BatchSize = n
+		}
+	}
+	if v := os.Getenv("SINK_ELASTICSEARCH_URL"); v != "" {
+		c.Sinks.Elasticsearch.URL = v
+	}
+	if v := os.Getenv("SINK_ELASTICSEARCH_INDEX"); v != "" {
+		c.Sinks.Elasticsearch.Index = v
+	}
+	if v := os.Getenv("SINK_ELASTICSEARCH_USERNAME"); v != "" {
+		c.Sinks.Elasticsearch.Username = v
+	}
+	if v := os.Getenv("SINK_ELASTICSEARCH_PASSWORD"); v != "" {
+		c.Sinks.Elasticsearch.Password = v
+	}
+	if v := os.Getenv("SINK_ELASTICSEARCH_API_KEY"); v != "" {
+		c.Sinks.Elasticsearch.APIKey = v
+	}
+	if v := os.Getenv("SINK_JSONL_DIR"); v != "" {
+		c.Sinks.JSONL.Dir = v
+	}
+	if v := os.Getenv("SINK_KAFKA_REST_URL"); v != "" {
+		c.Sinks.Kafka.RESTURL = v
+	}
+	if v := os.Getenv("SINK_KAFKA_TOPIC"); v != "" {
+		c.Sinks.Kafka.Topic = v
+	}
+	if v := os.Getenv("EVENTS_ENABLED"); v != "" {
+		c.Events.Enabled = strings.EqualFold(v, "true")
+	}
+	// One endpoint can be configured from the environment.
+	if v := os.Getenv("WEBHOOK_URL"); v != "" {
+		ep := WebhookEndpoint{Name: "default", URL: v, Secret: os.Getenv("WEBHOOK_SECRET")}
+		for _, t := range strings.Split(os.Getenv("WEBHOOK_EVENTS"), ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				ep.Events = append(ep.Events, t)
+			}
+		}
+		c.Webhooks.Endpoints = append(c.Webhooks.Endpoints, ep)
+	}
+	if v := os.Getenv("METRICS_CRAWLER_ADDR"); v != "" {
+		c.Metrics.CrawlerAddr = v
+	}
+	if v := os.Getenv("METRICS_PARSER_ADDR"); v != "" {
+		c.Metrics.ParserAddr = v
+	}
+	if v := os.Getenv("METRICS_SAMPLE_INTERVAL_SECS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.Metrics.SampleIntervalSecs = n
+		}
+	}
+	if v := os.Getenv("TRACING_EXPORTER"); v != "" {
+		c.Tracing.Exporter = v
+	}
+	if v := os.Getenv("TRACING_ENDPOINT"); v != "" {
+		c.Tracing.Endpoint = v
+	}
+	if v := os.Getenv("TRACING_INSECURE"); v != "" {
+		c.Tracing.Insecure = strings.EqualFold(v, "true")
+	}
+	if v := os.Getenv("TRACING_SAMPLE_RATIO"); v != "" {
+		if r, err := strconv.ParseFloat(v, 64); err == nil {
+			c.Tracing.SampleRatio = &r
+		}
+	}
+	if v := os.Getenv("ADMIN_TOKEN"); v != "" {
+		c.Admin.Token = v
+	}
+	if v := os.Getenv("ADMIN_READ_TOKEN"); v != "" {
+		c.Admin.ReadToken = v
+	}
+	if v := os.Getenv("API_TOKEN"); v != "" {
+		c.API.Token = v
+	}
+	if v := os.Getenv("API_INSECURE"); v != "" {
+		c.API.Insecure = strings.EqualFold(v, "true")
+	}
 	if v := os.Getenv("MIGRATION_PATH"); v != "" {
 		c.Migration.Path = v
 	}
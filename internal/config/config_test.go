@@ -76,6 +76,27 @@ func TestLoadFromEnv_Defaults(t *testing.T) {
 	if cfg.Redis.MinIdleConns != 5 {
 		t.Errorf("Redis.MinIdleConns = %d, want 5", cfg.Redis.MinIdleConns)
 	}
+	if cfg.Parser.Priority.DepthWeight != 0.5 {
+		t.Errorf("Parser.Priority.DepthWeight = %v, want 0.5", cfg.Parser.Priority.DepthWeight)
+	}
+	if cfg.Queue.Backend != "redis" {
+		t.Errorf("Queue.Backend = %q, want redis", cfg.Queue.Backend)
+	}
+	if cfg.Queue.Encoding != "json" {
+		t.Errorf("Queue.Encoding = %q, want json", cfg.Queue.Encoding)
+	}
+	if cfg.Storage.Backend != "s3" {
+		t.Errorf("Storage.Backend = %q, want s3", cfg.Storage.Backend)
+	}
+	if cfg.Storage.HTMLBucket != "nimbus-html" || cfg.Storage.TextBucket != "nimbus-text" {
+		t.Errorf("Storage buckets = %q, %q, want nimbus-html, nimbus-text", cfg.Storage.HTMLBucket, cfg.Storage.TextBucket)
+	}
+	if cfg.Storage.Compression != "none" {
+		t.Errorf("Storage.Compression = %q, want none", cfg.Storage.Compression)
+	}
+	if cfg.Metrics.CrawlerAddr != ":9100" || cfg.Metrics.ParserAddr != ":9101" {
+		t.Errorf("Metrics addrs = %q, %q, want :9100, :9101", cfg.Metrics.CrawlerAddr, cfg.Metrics.ParserAddr)
+	}
 }
 
 func TestLoadFromEnv_EnvOverrides(t *testing.T) {
@@ -199,6 +220,35 @@ func TestLoad_NonexistentFile(t *testing.T) {
 	}
 }
 
+func TestTracingSampleRatio(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		yaml string
+		want float64
+	}{
+		{"unset records every trace", "tracing:\n  exporter: otlp\n", 1},
+		{"zero records none", "tracing:\n  sample_ratio: 0\n", 0},
+		{"fraction", "tracing:\n  sample_ratio: 0.25\n", 0.25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0644); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if cfg.Tracing.SampleRatio == nil || *cfg.Tracing.SampleRatio != tt.want {
+				t.Errorf("SampleRatio = %v, want %v", cfg.Tracing.SampleRatio, tt.want)
+			}
+		})
+	}
+}
+
 func TestRespectRobotsTxt_DefaultTrue(t *testing.T) {
 	t.Parallel()
 	cfg := LoadFromEnv()
@@ -286,3 +336,26 @@ postgres:
 		t.Errorf("Postgres.Port = %d, want 1234 (YAML value should persist)", cfg.Postgres.Port)
 	}
 }
+
+func TestLoad_RejectsInvalidSettings(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name, yaml, want string
+	}{
+		{"partitions", "frontier:\n  partitions: -2\n", "frontier.partitions"},
+		{"lease ttl", "frontier:\n  lease_ttl_secs: -1\n", "frontier.lease_ttl_secs"},
+		{"queue backend", "queue:\n  backend: kafka\n", "queue.backend"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tc.yaml), 0644); err != nil {
+				t.Fatalf("writing temp config: %v", err)
+			}
+			_, err := Load(path)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("Load() error = %v, want one about %s", err, tc.want)
+			}
+		})
+	}
+}
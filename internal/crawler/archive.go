@@ -0,0 +1,143 @@
+package crawler
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/theognis1002/nimbus-crawler/internal/database/models"
+	"github.com/theognis1002/nimbus-crawler/internal/queue"
+	"github.com/theognis1002/nimbus-crawler/internal/warc"
+)
+
+// SetWARCWriter makes the crawler record every fetch to w, whatever its
+// content type or status, and fetches that got no response as metadata
+// records. It must be called before Run.
+func (c *Crawler) SetWARCWriter(w *warc.Writer) {
+	c.archive = w
+}
+
+// startCapture begins recording a fetched body, teeing the payload into the
+// capture as the body is read. fetchErr, if not nil, is why the response
+// was rejected. It returns nil if archiving is off or the capture could not
+// be started.
+func (c *Crawler) startCapture(logger *slog.Logger, msg queue.URLMessage, body *Body, fetchErr error) *warc.Capture {
+	if c.archive == nil || body == nil {
+		return nil
+	}
+	capture, err := warc.NewCapture(body.Response, body.FetchedAt)
+	if err != nil {
+		logger.Error("failed to start warc capture", "error", err)
+		return nil
+	}
+
+	capture.Metadata = warc.Fields{{Name: "fetchTimeMs", Value: strconv.FormatInt(body.Elapsed.Milliseconds(), 10)}}
+	if fetchErr != nil {
+		capture.Metadata = append(capture.Metadata, warc.Field{Name: "fetchError", Value: fetchErr.Error()})
+	}
+	capture.Metadata = append(capture.Metadata, captureMetadata(msg, capture.TargetURI())...)
+
+	body.Reader = io.TeeReader(body.Reader, capture)
+	return capture
+}
+
+// recordFailure archives a fetch of msg, begun at fetchedAt, that got no
+// response, as a metadata record with the error. Archiving failures are
+// logged but do not fail the crawl.
+func (c *Crawler) recordFailure(ctx context.Context, logger *slog.Logger, msg queue.URLMessage, fetchedAt time.Time, fetchErr error) {
+	if c.archive == nil {
+		return
+	}
+	metadata := append(warc.Fields{{Name: "fetchError", Value: fetchErr.Error()}}, captureMetadata(msg, msg.URL)...)
+	if err := c.archive.CommitFailure(ctx, msg.URL, fetchedAt, metadata); err != nil {
+		logger.Error("failed to write warc failure record", "error", err)
+	}
+}
+
+// captureMetadata returns the metadata of a capture of msg that ended at
+// targetURI: how it was reached and for which job.
+func captureMetadata(msg queue.URLMessage, targetURI string) warc.Fields {
+	var fields warc.Fields
+	if targetURI != msg.URL {
+		fields = append(fields, warc.Field{Name: "requestedURI", Value: msg.URL})
+	}
+	if msg.DiscoveredFrom != "" {
+		fields = append(fields, warc.Field{Name: "via", Value: msg.DiscoveredFrom})
+	}
+	fields = append(fields, warc.Field{Name: "hopsFromSeed", Value: strconv.Itoa(msg.Depth)})
+	if msg.JobID != "" {
+		fields = append(fields, warc.Field{Name: "jobID", Value: msg.JobID})
+	}
+	return fields
+}
+
+// commitCapture reads whatever of the body is left into the capture and
+// writes it. Archiving failures are logged but do not fail the crawl.
+func (c *Crawler) commitCapture(ctx context.Context, logger *slog.Logger, capture *warc.Capture, body *Body) {
+	if capture == nil {
+		return
+	}
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		logger.Warn("failed to read body for warc capture", "error", err)
+		capture.Discard()
+		return
+	}
+	if err := c.archive.Commit(ctx, capture); err != nil {
+		logger.Error("failed to write warc capture", "error", err)
+	}
+}
+
+// RecordRobots archives a robots.txt response, so replayed crawls see the
+// same rules. It is a robots.Recorder; archiving failures are logged.
+func (c *Crawler) RecordRobots(ctx context.Context, resp *http.Response, body []byte, fetchedAt time.Time) {
+	if c.archive == nil {
+		return
+	}
+	logger := c.logger.With("url", resp.Request.URL.String())
+	capture, err := warc.NewCapture(resp, fetchedAt)
+	if err != nil {
+		logger.Error("failed to start warc capture", "error", err)
+		return
+	}
+	if _, err := capture.Write(body); err != nil {
+		logger.Warn("failed to spool robots.txt for warc capture", "error", err)
+		capture.Discard()
+		return
+	}
+	if err := c.archive.Commit(ctx, capture); err != nil {
+		logger.Error("failed to write warc capture", "error", err)
+	}
+}
+
+// captureIndex keeps the first capture of each payload in Postgres, so
+// revisit records are written across restarts and crawler replicas.
+type captureIndex struct {
+	pool *pgxpool.Pool
+}
+
+// NewCaptureIndex returns a warc.CaptureIndex stored in the warc_captures
+// table.
+func NewCaptureIndex(pool *pgxpool.Pool) warc.CaptureIndex {
+	return &captureIndex{pool: pool}
+}
+
+func (i *captureIndex) Claim(ctx context.Context, digest string, ref warc.CaptureRef) (warc.CaptureRef, bool, error) {
+	first, seen, err := models.ClaimWARCCapture(ctx, i.pool, models.WARCCapture{
+		PayloadDigest: digest,
+		TargetURI:     ref.TargetURI,
+		CapturedAt:    ref.Date,
+		RecordID:      ref.RecordID,
+	})
+	if err != nil {
+		return warc.CaptureRef{}, false, err
+	}
+	return warc.CaptureRef{RecordID: first.RecordID, TargetURI: first.TargetURI, Date: first.CapturedAt}, seen, nil
+}
+
+func (i *captureIndex) Release(ctx context.Context, digest, recordID string) error {
+	return models.ReleaseWARCCapture(ctx, i.pool, digest, recordID)
+}
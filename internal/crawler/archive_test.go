@@ -0,0 +1,160 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+	"github.com/theognis1002/nimbus-crawler/internal/queue"
+	"github.com/theognis1002/nimbus-crawler/internal/storage"
+	"github.com/theognis1002/nimbus-crawler/internal/warc"
+)
+
+// TestReplayArchivedCrawl records a crawl through a redirect, and a
+// robots.txt fetch, then replays both from the archive.
+func TestReplayArchivedCrawl(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+		case "/new":
+			w.Header().Set("Content-Type", "text/html")
+			io.WriteString(w, "<html>new</html>")
+		case "/robots.txt":
+			w.Header().Set("Content-Type", "text/plain")
+			io.WriteString(w, "User-agent: *\nDisallow: /private\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	w := warc.NewWriter(store, config.WARCConfig{Bucket: "warc", Prefix: "test", MaxFileBytes: 1 << 20}, warc.NewMemoryIndex(), testLogger())
+	c := &Crawler{archive: w, logger: testLogger()}
+
+	live := NewReplayFetcher(srv.Client().Transport, 5, 3, testLogger())
+	msg := queue.URLMessage{URL: srv.URL + "/old"}
+	body, _, err := live.FetchStream(ctx, msg.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.commitCapture(ctx, testLogger(), c.startCapture(testLogger(), msg, body, nil), body)
+	body.Close()
+
+	resp, err := srv.Client().Get(srv.URL + "/robots.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	robotsTxt, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	c.RecordRobots(ctx, resp, robotsTxt, body.FetchedAt)
+
+	if err := w.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	err = store.Walk(ctx, "warc", func(key string) error {
+		data, err := storage.ReadObject(ctx, store, "warc", key)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dir, key), data, 0o644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err := warc.OpenArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+
+	replay := NewReplayFetcher(archive, 5, 3, testLogger())
+	got, status, err := replay.Fetch(ctx, srv.URL+"/old")
+	if err != nil {
+		t.Fatalf("replaying redirected url: %v", err)
+	}
+	if status != http.StatusOK || string(got) != "<html>new</html>" {
+		t.Errorf("replay of /old = %d %q, want 200 %q", status, got, "<html>new</html>")
+	}
+
+	// Robots checkers replay through the archive directly.
+	robotsResp, err := (&http.Client{Transport: archive}).Get(srv.URL + "/robots.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer robotsResp.Body.Close()
+	replayed, _ := io.ReadAll(robotsResp.Body)
+	if robotsResp.StatusCode != http.StatusOK || string(replayed) != string(robotsTxt) {
+		t.Errorf("replay of robots.txt = %d %q, want 200 %q", robotsResp.StatusCode, replayed, robotsTxt)
+	}
+}
+
+// TestArchivesRejectedFetches records a response rejected for its content
+// type and a fetch that got no response.
+func TestArchivesRejectedFetches(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		io.WriteString(w, "%PDF-1.7")
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	w := warc.NewWriter(store, config.WARCConfig{Bucket: "warc", Prefix: "test", MaxFileBytes: 1 << 20}, warc.NewMemoryIndex(), testLogger())
+	c := &Crawler{archive: w, logger: testLogger()}
+
+	f := NewReplayFetcher(srv.Client().Transport, 5, 3, testLogger())
+	msg := queue.URLMessage{URL: srv.URL + "/doc.pdf"}
+	body, _, err := f.FetchStream(ctx, msg.URL)
+	if err == nil || body == nil {
+		t.Fatalf("FetchStream = %v, %v; want the rejected response and an error", body, err)
+	}
+	c.commitCapture(ctx, testLogger(), c.startCapture(testLogger(), msg, body, err), body)
+	body.Close()
+	c.recordFailure(ctx, testLogger(), queue.URLMessage{URL: "https://unreachable.invalid/"}, time.Now(), errors.New("no such host"))
+	if err := w.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var warcs, index []byte
+	err = store.Walk(ctx, "warc", func(key string) error {
+		data, err := storage.ReadObject(ctx, store, "warc", key)
+		if strings.HasSuffix(key, ".cdxj") {
+			index = data
+		} else {
+			warcs = data
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(warcs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, _ := io.ReadAll(zr)
+	for _, want := range []string{"%PDF-1.7", "fetchError: unexpected content-type", "WARC-Target-URI: https://unreachable.invalid/", "fetchError: no such host"} {
+		if !bytes.Contains(plain, []byte(want)) {
+			t.Errorf("archive is missing %q", want)
+		}
+	}
+	if !bytes.Contains(index, []byte(`"mime":"application/pdf"`)) || bytes.Contains(index, []byte("unreachable")) {
+		t.Errorf("cdxj = %s, want only the pdf response", index)
+	}
+}
@@ -2,8 +2,10 @@ package crawler
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
@@ -14,32 +16,48 @@ import (
 	"github.com/theognis1002/nimbus-crawler/internal/cache"
 	"github.com/theognis1002/nimbus-crawler/internal/config"
 	"github.com/theognis1002/nimbus-crawler/internal/database/models"
+	"github.com/theognis1002/nimbus-crawler/internal/events"
+	"github.com/theognis1002/nimbus-crawler/internal/metrics"
 	"github.com/theognis1002/nimbus-crawler/internal/queue"
 	"github.com/theognis1002/nimbus-crawler/internal/robots"
 	"github.com/theognis1002/nimbus-crawler/internal/storage"
+	"github.com/theognis1002/nimbus-crawler/internal/warc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// pauseRecheck is how long a paused URL waits before its flags are checked
+// again.
+const pauseRecheck = 5 * time.Second
+
 type Crawler struct {
 	cfg         config.CrawlerConfig
 	pool        *pgxpool.Pool
 	fetcher     *Fetcher
-	publisher   *queue.Publisher
+	publisher   queue.Publisher
 	rateLimiter *cache.RateLimiter
 	robotsCheck *robots.Checker
-	minio       *storage.MinIOClient
+	store       storage.ObjectStore
+	storageCfg  config.StorageConfig
+	archive     *warc.Writer
+	pause       *cache.PauseFlags
+	events      *events.Bus
 	logger      *slog.Logger
 	domainCache sync.Map
 	retryWg     sync.WaitGroup
+	frontier    *hostFrontier
 }
 
 func New(
 	cfg config.CrawlerConfig,
 	pool *pgxpool.Pool,
 	fetcher *Fetcher,
-	publisher *queue.Publisher,
+	publisher queue.Publisher,
 	rateLimiter *cache.RateLimiter,
 	robotsCheck *robots.Checker,
-	minio *storage.MinIOClient,
+	store storage.ObjectStore,
+	storageCfg config.StorageConfig,
 	logger *slog.Logger,
 ) *Crawler {
 	return &Crawler{
@@ -49,19 +67,35 @@ func New(
 		publisher:   publisher,
 		rateLimiter: rateLimiter,
 		robotsCheck: robotsCheck,
-		minio:       minio,
+		store:       store,
+		storageCfg:  storageCfg,
 		logger:      logger,
+		frontier:    newHostFrontier(cfg.BackQueueCapacity),
 	}
 }
 
+// SetPauseFlags makes the crawler hold back URLs while the crawl or their
+// domain is paused. It must be called before Run.
+func (c *Crawler) SetPauseFlags(p *cache.PauseFlags) {
+	c.pause = p
+}
+
+// SetEvents makes the crawler emit failure and robots events to b. It must
+// be called before Run.
+func (c *Crawler) SetEvents(b *events.Bus) {
+	c.events = b
+}
+
 func (c *Crawler) Run(ctx context.Context, deliveries <-chan queue.Delivery) {
 	var wg sync.WaitGroup
 
+	go c.frontier.feed(ctx, deliveries)
+
 	for i := 0; i < c.cfg.Workers; i++ {
 		wg.Add(1)
 		go func(workerID int) {
 			defer wg.Done()
-			c.worker(ctx, workerID, deliveries)
+			c.worker(ctx, workerID)
 		}(i)
 	}
 
@@ -70,28 +104,28 @@ func (c *Crawler) Run(ctx context.Context, deliveries <-chan queue.Delivery) {
 	c.logger.Info("all crawler workers stopped")
 }
 
-func (c *Crawler) worker(ctx context.Context, id int, deliveries <-chan queue.Delivery) {
+func (c *Crawler) worker(ctx context.Context, id int) {
 	logger := c.logger.With("worker", id)
 	logger.Info("crawler worker started")
 
 	for {
-		select {
-		case <-ctx.Done():
+		lease, ok := c.frontier.next(ctx)
+		if !ok {
 			logger.Info("crawler worker stopping")
 			return
-		case d, ok := <-deliveries:
-			if !ok {
-				logger.Info("delivery channel closed")
-				return
-			}
-			c.processMessage(ctx, logger, d)
 		}
+		c.processMessage(ctx, logger, lease)
+		lease.done()
 	}
 }
 
-func (c *Crawler) processMessage(ctx context.Context, logger *slog.Logger, d queue.Delivery) {
+// processMessage handles one delivery under a host lease. It sets how long the
+// host must rest on the lease, or requeues the delivery if the host's rate
+// limit window is still closed.
+func (c *Crawler) processMessage(ctx context.Context, logger *slog.Logger, lease *hostLease) {
+	d := metrics.Instrument("frontier", lease.d)
 	var msg queue.URLMessage
-	if err := json.Unmarshal(d.Body, &msg); err != nil {
+	if err := d.Decode(&msg); err != nil {
 		logger.Error("failed to unmarshal message", "error", err)
 		if err := d.Nack(true); err != nil {
 			logger.Error("failed to nack message", "error", err)
@@ -99,7 +133,12 @@ func (c *Crawler) processMessage(ctx context.Context, logger *slog.Logger, d que
 		return
 	}
 
+	ctx, span := startCrawlSpan(ctx, d, msg)
+	defer span.End()
 	logger = logger.With("url", msg.URL, "depth", msg.Depth)
+	if sc := span.SpanContext(); sc.IsValid() {
+		logger = logger.With("trace_id", sc.TraceID().String())
+	}
 
 	if msg.Depth > c.cfg.MaxDepth {
 		logger.Info("max depth exceeded, skipping")
@@ -119,6 +158,22 @@ func (c *Crawler) processMessage(ctx context.Context, logger *slog.Logger, d que
 	}
 	domain := parsed.Hostname()
 
+	// A paused URL goes back to the head of its host's back-queue and the
+	// host is rechecked after pauseRecheck. It stays unacked and is touched
+	// while it waits, so no peer reclaims it before the crawl is resumed. A
+	// failed check does not stop the crawl.
+	if c.pause != nil {
+		paused, err := c.pause.Paused(ctx, domain)
+		if err != nil {
+			logger.Warn("failed to check pause flags", "error", err)
+		} else if paused {
+			logger.Debug("crawl paused, deferring")
+			span.AddEvent("deferred by pause")
+			lease.requeue(pauseRecheck)
+			return
+		}
+	}
+
 	// Ensure domain exists (skip DB call if already cached in-process)
 	if _, loaded := c.domainCache.LoadOrStore(domain, true); !loaded {
 		if err := models.UpsertDomain(ctx, c.pool, domain, robots.DefaultCrawlDelayMs); err != nil {
@@ -151,13 +206,25 @@ func (c *Crawler) processMessage(ctx context.Context, logger *slog.Logger, d que
 	// Check robots.txt
 	crawlDelay := robots.DefaultCrawlDelayMs
 	if c.cfg.RespectRobotsTxt == nil || *c.cfg.RespectRobotsTxt {
-		allowed, delay, err := c.robotsCheck.IsAllowed(ctx, msg.URL, domain)
-		if err != nil {
+		robotsCtx, robotsSpan := tracer.Start(ctx, "robots.check")
+		allowed, delay, err := c.robotsCheck.IsAllowed(robotsCtx, msg.URL, domain)
+		robotsSpan.SetAttributes(attribute.Bool("robots.allowed", allowed))
+		endSpan(robotsSpan, err)
+		switch {
+		case err != nil:
 			logger.Warn("robots check failed", "error", err)
+			metrics.RobotsDecisions.WithLabelValues("error").Inc()
+		case allowed:
+			metrics.RobotsDecisions.WithLabelValues("allowed").Inc()
+		default:
+			metrics.RobotsDecisions.WithLabelValues("disallowed").Inc()
 		}
 		crawlDelay = delay
 		if !allowed {
 			logger.Info("disallowed by robots.txt")
+			if c.events != nil {
+				c.events.EmitOnce(ctx, events.RobotsDisallowed, domain, events.RobotsDisallow{Domain: domain, URL: msg.URL})
+			}
 			_ = models.UpdateURLStatus(ctx, c.pool, urlID, models.StatusSkipped)
 			if err := d.Ack(); err != nil {
 				logger.Error("failed to ack message", "error", err)
@@ -168,8 +235,13 @@ func (c *Crawler) processMessage(ctx context.Context, logger *slog.Logger, d que
 		logger.Debug("robots.txt checking disabled")
 	}
 
-	// Rate limit
-	if err := c.rateLimiter.WaitForAllow(ctx, domain, crawlDelay); err != nil {
+	// Rate limit: if another replica holds the domain's window, put the URL
+	// back in its back-queue and let this worker move on to a ready host.
+	limitCtx, limitSpan := tracer.Start(ctx, "ratelimit.acquire")
+	wait, err := c.rateLimiter.TryAcquire(limitCtx, domain, crawlDelay)
+	limitSpan.SetAttributes(attribute.Int64("ratelimit.wait_ms", wait.Milliseconds()))
+	endSpan(limitSpan, err)
+	if err != nil {
 		if ctx.Err() != nil {
 			logger.Info("shutting down, requeueing message")
 		} else {
@@ -180,13 +252,37 @@ func (c *Crawler) processMessage(ctx context.Context, logger *slog.Logger, d que
 		}
 		return
 	}
+	metrics.RateLimitWait.Observe(wait.Seconds())
+	if wait > 0 {
+		span.AddEvent("deferred by rate limit")
+		logger.Debug("domain rate limited, deferring", "wait", wait)
+		lease.requeue(wait)
+		return
+	}
+	lease.hold(time.Duration(crawlDelay) * time.Millisecond)
 
 	// Fetch
-	body, statusCode, err := c.fetcher.Fetch(ctx, msg.URL)
+	fetchedAt := time.Now()
+	body, statusCode, err := c.fetcher.FetchStream(ctx, msg.URL)
+	capture := c.startCapture(logger, msg, body, err)
 	if err != nil || statusCode != http.StatusOK {
+		if body != nil {
+			c.commitCapture(ctx, logger, capture, body)
+			body.Close()
+		} else if ctx.Err() == nil {
+			c.recordFailure(ctx, logger, msg, fetchedAt, err)
+		}
 		logger.Warn("fetch failed", "error", err, "status", statusCode)
+		reason := failureReason(err, statusCode)
+		metrics.FetchFailuresTotal.WithLabelValues(reason).Inc()
+		span.SetStatus(codes.Error, "fetch failed: "+reason)
 		retryCount, _ := models.IncrementRetryAndMaybeFailURL(ctx, c.pool, urlID, c.cfg.MaxRetries)
 		if retryCount >= c.cfg.MaxRetries {
+			if c.events != nil {
+				c.events.Emit(ctx, events.URLFailed, events.URLFailure{
+					URLID: urlID, URL: msg.URL, Domain: domain, Retries: retryCount, Reason: reason,
+				})
+			}
 			if err := d.Nack(true); err != nil {
 				logger.Error("failed to nack message to DLQ", "error", err)
 			}
@@ -197,6 +293,7 @@ func (c *Crawler) processMessage(ctx context.Context, logger *slog.Logger, d que
 			}
 			delay := backoffDuration(retryCount)
 			logger.Info("scheduling retry", "retry", retryCount, "delay", delay)
+			msg.Attempt = retryCount + 1
 			c.retryWg.Add(1)
 			go func() {
 				defer c.retryWg.Done()
@@ -205,7 +302,9 @@ func (c *Crawler) processMessage(ctx context.Context, logger *slog.Logger, d que
 				case <-ctx.Done():
 					return
 				}
-				pubCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				// The retry continues this trace; see startCrawlSpan.
+				pubCtx := trace.ContextWithSpanContext(context.Background(), span.SpanContext())
+				pubCtx, cancel := context.WithTimeout(pubCtx, 10*time.Second)
 				defer cancel()
 				if err := c.publisher.PublishURL(pubCtx, msg); err != nil {
 					logger.Error("failed to re-publish after backoff", "error", err)
@@ -215,9 +314,16 @@ func (c *Crawler) processMessage(ctx context.Context, logger *slog.Logger, d que
 		return
 	}
 
-	// Store HTML in MinIO
-	s3Key := storage.HTMLKey(msg.URL)
-	if err := c.minio.PutObject(ctx, storage.HTMLBucket, s3Key, body, "text/html"); err != nil {
+	finalURL := body.FinalURL()
+	s3Key, contentHash, err := c.storeHTML(ctx, msg.URL, body)
+	if err == nil {
+		c.commitCapture(ctx, logger, capture, body)
+	} else if capture != nil {
+		capture.Discard()
+	}
+	body.Close()
+	if err != nil {
+		failSpan(span, err)
 		logger.Error("failed to store html", "error", err)
 		if err := d.Nack(false); err != nil {
 			logger.Error("failed to nack message", "error", err)
@@ -225,18 +331,26 @@ func (c *Crawler) processMessage(ctx context.Context, logger *slog.Logger, d que
 		return
 	}
 
-	s3Link := fmt.Sprintf("%s/%s", storage.HTMLBucket, s3Key)
+	s3Link := fmt.Sprintf("%s/%s", c.storageCfg.HTMLBucket, s3Key)
 
 	// Publish parse message before marking as crawled to avoid orphaned state:
 	// if we mark crawled first and the publish fails, the Nack'd re-delivery
 	// would see 'crawled' status and skip the URL permanently.
 	parseMsg := queue.ParseMessage{
-		URLID:      urlID,
-		URL:        msg.URL,
-		S3HTMLLink: s3Link,
-		Depth:      msg.Depth,
+		URLID:       urlID,
+		URL:         msg.URL,
+		S3HTMLLink:  s3Link,
+		Depth:       msg.Depth,
+		JobID:       msg.JobID,
+		Scope:       msg.Scope,
+		MaxDepth:    msg.MaxDepth,
+		ContentHash: contentHash,
 	}
-	if err := c.publisher.PublishParse(ctx, parseMsg); err != nil {
+	pubCtx, pubSpan := tracer.Start(ctx, "queue.publish parse", trace.WithSpanKind(trace.SpanKindProducer))
+	err = c.publisher.PublishParse(pubCtx, parseMsg)
+	endSpan(pubSpan, err)
+	if err != nil {
+		failSpan(span, err)
 		logger.Error("failed to publish parse message", "error", err)
 		if err := d.Nack(false); err != nil {
 			logger.Error("failed to nack message", "error", err)
@@ -244,7 +358,12 @@ func (c *Crawler) processMessage(ctx context.Context, logger *slog.Logger, d que
 		return
 	}
 
-	if err := models.UpdateURLCrawledAndDomainTime(ctx, c.pool, urlID, s3Link, domain); err != nil {
+	if c.storageCfg.ContentAddressed {
+		err = models.UpdateURLCrawledWithSnapshot(ctx, c.pool, urlID, s3Link, finalURL, domain, contentHash)
+	} else {
+		err = models.UpdateURLCrawledAndDomainTime(ctx, c.pool, urlID, s3Link, finalURL, domain)
+	}
+	if err != nil {
 		logger.Error("failed to update url/domain records", "error", err)
 		if err := d.Nack(false); err != nil {
 			logger.Error("failed to nack message", "error", err)
@@ -257,3 +376,56 @@ func (c *Crawler) processMessage(ctx context.Context, logger *slog.Logger, d que
 		logger.Error("failed to ack message", "error", err)
 	}
 }
+
+// storeHTML streams a fetched body into the HTML bucket, hashing it on the
+// way through, and returns its key and content hash. Content-addressed bodies
+// are keyed by the hash, so identical pages share one blob and re-crawls keep
+// earlier versions; otherwise the key is derived from the URL.
+func (c *Crawler) storeHTML(ctx context.Context, rawURL string, body *Body) (key, hash string, err error) {
+	ctx, span := tracer.Start(ctx, "storage.put", trace.WithAttributes(attribute.String("storage.bucket", c.storageCfg.HTMLBucket)))
+	defer func() {
+		if err != nil {
+			failSpan(span, err)
+		}
+		span.SetAttributes(attribute.String("storage.key", key))
+		span.End()
+	}()
+
+	opts := storage.PutOptions{ContentType: "text/html"}
+	if c.storageCfg.ContentAddressed {
+		hash, err = storage.PutBlob(ctx, c.store, c.storageCfg.HTMLBucket, body, opts)
+		if err != nil {
+			return "", "", err
+		}
+		return storage.BlobKey(hash), hash, nil
+	}
+
+	h := sha256.New()
+	key = storage.HTMLKey(rawURL)
+	if err := c.store.Put(ctx, c.storageCfg.HTMLBucket, key, io.TeeReader(body, h), body.Size, opts); err != nil {
+		return "", "", err
+	}
+	return key, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// startCrawlSpan starts the span of one crawl attempt. A URL's first attempt
+// starts a new trace, linked to the span that discovered it, so each URL gets
+// its own trace instead of joining its parent page's; retries carry on the
+// trace of the attempt before.
+func startCrawlSpan(ctx context.Context, d queue.Delivery, msg queue.URLMessage) (context.Context, trace.Span) {
+	opts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("url.full", msg.URL),
+			attribute.Int("crawl.depth", msg.Depth),
+			attribute.Int("crawl.attempt", max(msg.Attempt, 1)),
+		),
+	}
+	remote := d.Context(ctx)
+	if msg.Attempt > 1 {
+		ctx = remote
+	} else {
+		opts = append(opts, trace.WithNewRoot(), trace.WithLinks(trace.LinkFromContext(remote)))
+	}
+	return tracer.Start(ctx, "crawl", opts...)
+}
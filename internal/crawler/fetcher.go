@@ -2,17 +2,24 @@ package crawler
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"mime"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"strings"
 	"time"
 
 	"github.com/theognis1002/nimbus-crawler/internal/cache"
+	"github.com/theognis1002/nimbus-crawler/internal/metrics"
 	"github.com/theognis1002/nimbus-crawler/internal/robots"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -21,7 +28,12 @@ const (
 	maxIdleConns        = 100
 	maxIdleConnsPerHost = 10
 	idleConnTimeout     = 90 * time.Second
-	acceptHeader        = "text/html,application/xhtml+xml"
+	acceptHeader        = "text/html,application/xhtml+xml,application/xml;q=0.9"
+)
+
+var (
+	errTooManyRedirects      = errors.New("too many redirects")
+	errUnexpectedContentType = errors.New("unexpected content-type")
 )
 
 type Fetcher struct {
@@ -43,7 +55,16 @@ func NewFetcher(dnsCache *cache.DNSCache, proxyPool *ProxyPool, timeoutSecs, max
 				return dialer.DialContext(ctx, network, addr)
 			}
 
+			// The cache replaces the resolver, so report its lookup to any
+			// client trace the way net would.
+			ct := httptrace.ContextClientTrace(ctx)
+			if ct != nil && ct.DNSStart != nil {
+				ct.DNSStart(httptrace.DNSStartInfo{Host: host})
+			}
 			ip, err := dnsCache.LookupHost(ctx, host)
+			if ct != nil && ct.DNSDone != nil {
+				ct.DNSDone(httptrace.DNSDoneInfo{Addrs: []net.IPAddr{{IP: net.ParseIP(ip)}}, Err: err})
+			}
 			if err != nil {
 				return nil, err
 			}
@@ -57,12 +78,7 @@ func NewFetcher(dnsCache *cache.DNSCache, proxyPool *ProxyPool, timeoutSecs, max
 		ResponseHeaderTimeout: 15 * time.Second,
 	}
 
-	checkRedirect := func(req *http.Request, via []*http.Request) error {
-		if len(via) >= maxRedirects {
-			return fmt.Errorf("stopped after %d redirects", maxRedirects)
-		}
-		return nil
-	}
+	checkRedirect := redirectPolicy(maxRedirects)
 
 	directClient := &http.Client{
 		Transport:     directTransport,
@@ -99,43 +115,142 @@ func NewFetcher(dnsCache *cache.DNSCache, proxyPool *ProxyPool, timeoutSecs, max
 	return f
 }
 
+// NewReplayFetcher returns a Fetcher that never touches the network: every
+// request is answered by replay, usually a *warc.Archive, with redirects,
+// timeouts and content checks applied as for live fetches.
+func NewReplayFetcher(replay http.RoundTripper, timeoutSecs, maxRedirects int, logger *slog.Logger) *Fetcher {
+	return &Fetcher{
+		directClient: &http.Client{
+			Transport:     replay,
+			Timeout:       time.Duration(timeoutSecs) * time.Second,
+			CheckRedirect: redirectPolicy(maxRedirects),
+		},
+		logger: logger,
+	}
+}
+
+func redirectPolicy(maxRedirects int) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("%w: stopped after %d", errTooManyRedirects, maxRedirects)
+		}
+		return nil
+	}
+}
+
+// Body is a response body streamed from the network. Reads stop after
+// maxBodyBytes.
+type Body struct {
+	io.Reader
+	// Size is the body length when the server declared one within the read
+	// limit, otherwise -1.
+	Size int64
+	// Response is the final response, after redirects. Its Body must not be
+	// read directly.
+	Response *http.Response
+	// FetchedAt is when the request was sent and Elapsed how long the
+	// response headers took to arrive.
+	FetchedAt time.Time
+	Elapsed   time.Duration
+	close     func() error
+}
+
+func (b *Body) Close() error {
+	return b.close()
+}
+
+// FinalURL returns the URL the body was fetched from after redirects, or ""
+// if unknown.
+func (b *Body) FinalURL() string {
+	if b.Response == nil || b.Response.Request == nil {
+		return ""
+	}
+	return b.Response.Request.URL.String()
+}
+
+// Fetch fetches rawURL and reads the whole body into memory.
 func (f *Fetcher) Fetch(ctx context.Context, rawURL string) ([]byte, int, error) {
+	body, status, err := f.FetchStream(ctx, rawURL)
+	if body != nil {
+		defer body.Close()
+	}
+	if err != nil {
+		return nil, status, err
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, status, fmt.Errorf("reading response body: %w", err)
+	}
+	return data, status, nil
+}
+
+// FetchStream fetches rawURL and returns its body unread, for the caller to
+// stream and close. The body is returned for any status, and alongside the
+// error when a response arrived but was rejected, for an unexpected content
+// type or too many redirects, so it can still be archived. A non-nil body
+// must always be closed.
+func (f *Fetcher) FetchStream(ctx context.Context, rawURL string) (*Body, int, error) {
 	if f.proxyPool == nil {
-		return f.doFetch(ctx, rawURL, f.directClient)
+		return f.fetchDirect(ctx, rawURL)
 	}
 
 	proxy := f.proxyPool.Next(ctx)
 	if proxy == nil {
 		f.logger.WarnContext(ctx, "all proxies unhealthy, falling back to direct", "url", rawURL)
-		return f.doFetch(ctx, rawURL, f.directClient)
+		return f.fetchDirect(ctx, rawURL)
 	}
 
 	client, ok := f.proxyClients[proxy.String()]
 	if !ok {
 		f.logger.ErrorContext(ctx, "no http client for proxy", "proxy", proxy.Redacted())
-		return f.doFetch(ctx, rawURL, f.directClient)
+		return f.fetchDirect(ctx, rawURL)
 	}
 
+	metrics.ProxySelections.WithLabelValues(proxy.Host).Inc()
 	body, status, err := f.doFetch(ctx, rawURL, client)
-	if err != nil {
+	// A rejected response still came through the proxy.
+	if err != nil && body == nil {
+		metrics.ProxyFailures.WithLabelValues(proxy.Host).Inc()
 		f.proxyPool.MarkUnhealthy(ctx, proxy)
 		f.logger.WarnContext(ctx, "proxy failed, retrying with next", "proxy", proxy.Redacted(), "url", rawURL, "error", err)
 
 		nextProxy := f.proxyPool.Next(ctx)
 		if nextProxy == nil {
-			return f.doFetch(ctx, rawURL, f.directClient)
+			return f.fetchDirect(ctx, rawURL)
 		}
 		nextClient, ok := f.proxyClients[nextProxy.String()]
 		if !ok {
-			return f.doFetch(ctx, rawURL, f.directClient)
+			return f.fetchDirect(ctx, rawURL)
+		}
+		metrics.ProxySelections.WithLabelValues(nextProxy.Host).Inc()
+		body, status, err = f.doFetch(ctx, rawURL, nextClient)
+		if err != nil && body == nil {
+			metrics.ProxyFailures.WithLabelValues(nextProxy.Host).Inc()
 		}
-		return f.doFetch(ctx, rawURL, nextClient)
+		return body, status, err
 	}
 
 	return body, status, nil
 }
 
-func (f *Fetcher) doFetch(ctx context.Context, rawURL string, client *http.Client) ([]byte, int, error) {
+func (f *Fetcher) fetchDirect(ctx context.Context, rawURL string) (*Body, int, error) {
+	metrics.ProxySelections.WithLabelValues("direct").Inc()
+	return f.doFetch(ctx, rawURL, f.directClient)
+}
+
+func (f *Fetcher) doFetch(ctx context.Context, rawURL string, client *http.Client) (body *Body, status int, err error) {
+	ctx, span := tracer.Start(ctx, "fetch", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("url.full", rawURL)))
+	defer func() {
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if err != nil {
+			failSpan(span, err)
+		}
+		span.End()
+	}()
+	ctx = withClientTrace(ctx, span)
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
 	if err != nil {
 		return nil, 0, fmt.Errorf("creating request: %w", err)
@@ -144,23 +259,91 @@ func (f *Fetcher) doFetch(ctx context.Context, rawURL string, client *http.Clien
 	req.Header.Set("User-Agent", robots.CrawlerUserAgent)
 	req.Header.Set("Accept", acceptHeader)
 
+	start := time.Now()
 	resp, err := client.Do(req)
+	elapsed := time.Since(start)
 	if err != nil {
-		return nil, 0, fmt.Errorf("fetching %s: %w", rawURL, err)
+		metrics.FetchesTotal.WithLabelValues(metrics.StatusClass(0)).Inc()
+		err = fmt.Errorf("fetching %s: %w", rawURL, err)
+		if resp == nil {
+			return nil, 0, err
+		}
+		// The redirect policy stopped the client at resp, whose body it
+		// has closed.
+		return &Body{
+			Reader:    http.NoBody,
+			Response:  resp,
+			FetchedAt: start,
+			Elapsed:   elapsed,
+			close:     func() error { return nil },
+		}, resp.StatusCode, err
 	}
-	defer resp.Body.Close()
+	metrics.FetchesTotal.WithLabelValues(metrics.StatusClass(resp.StatusCode)).Inc()
+	metrics.FetchDuration.Observe(elapsed.Seconds())
 
+	size := resp.ContentLength
+	if size > maxBodyBytes {
+		size = -1
+	}
+	body = &Body{
+		Reader:    countingReader{io.LimitReader(resp.Body, maxBodyBytes)},
+		Size:      size,
+		Response:  resp,
+		FetchedAt: start,
+		Elapsed:   elapsed,
+		close:     resp.Body.Close,
+	}
 	if ct := resp.Header.Get("Content-Type"); ct != "" {
 		mediaType, _, _ := mime.ParseMediaType(ct)
-		if mediaType != "" && !strings.HasPrefix(mediaType, "text/") && mediaType != "application/xhtml+xml" {
-			return nil, resp.StatusCode, fmt.Errorf("unexpected content-type %q for %s", ct, rawURL)
+		// application/xml admits sitemaps, which the parser reads for links.
+		if mediaType != "" && !strings.HasPrefix(mediaType, "text/") && mediaType != "application/xhtml+xml" && mediaType != "application/xml" {
+			return body, resp.StatusCode, fmt.Errorf("%w %q for %s", errUnexpectedContentType, ct, rawURL)
 		}
 	}
+	return body, resp.StatusCode, nil
+}
 
-	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes))
-	if err != nil {
-		return nil, resp.StatusCode, fmt.Errorf("reading response body: %w", err)
-	}
+// countingReader adds the bytes read through it to BytesDownloaded.
+type countingReader struct {
+	r io.Reader
+}
 
-	return body, resp.StatusCode, nil
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	metrics.BytesDownloaded.Add(float64(n))
+	return n, err
+}
+
+// failureReason classifies why a fetch yielded no page, for
+// FetchFailuresTotal. status is the response status, if any.
+func failureReason(err error, status int) string {
+	if err == nil {
+		return "http_" + metrics.StatusClass(status)
+	}
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var hostnameErr x509.HostnameError
+	var unknownAuthority x509.UnknownAuthorityError
+	var netErr net.Error
+	var opErr *net.OpError
+	switch {
+	case errors.Is(err, errTooManyRedirects):
+		return "redirects"
+	case errors.Is(err, errUnexpectedContentType):
+		return "content_type"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.As(err, &certErr), errors.As(err, &hostnameErr), errors.As(err, &unknownAuthority):
+		return "tls"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &opErr):
+		return "connection"
+	default:
+		return "other"
+	}
 }
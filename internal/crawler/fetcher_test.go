@@ -2,6 +2,10 @@ package crawler
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -45,8 +49,8 @@ func TestFetcher_Fetch_Headers(t *testing.T) {
 		if ua := r.Header.Get("User-Agent"); ua != "NimbusCrawler/1.0" {
 			t.Errorf("User-Agent = %q, want NimbusCrawler/1.0", ua)
 		}
-		if accept := r.Header.Get("Accept"); accept != "text/html,application/xhtml+xml" {
-			t.Errorf("Accept = %q, want text/html,application/xhtml+xml", accept)
+		if accept := r.Header.Get("Accept"); accept != acceptHeader {
+			t.Errorf("Accept = %q, want %q", accept, acceptHeader)
 		}
 		w.WriteHeader(http.StatusOK)
 	}))
@@ -221,3 +225,126 @@ func TestFetcher_Fetch_5xxNotMarkedAsProxyError(t *testing.T) {
 		t.Errorf("body = %q, want %q", body, "service unavailable")
 	}
 }
+
+func TestFetcher_FetchStream(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		body     string
+		chunked  bool
+		wantSize int64
+	}{
+		{name: "declared length", body: "hello", wantSize: 5},
+		{name: "chunked", body: "hello", chunked: true, wantSize: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				if tt.chunked {
+					w.(http.Flusher).Flush()
+				}
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			f := newTestFetcher(srv.Client())
+			body, status, err := f.FetchStream(context.Background(), srv.URL)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer body.Close()
+			if status != 200 {
+				t.Errorf("status = %d, want 200", status)
+			}
+			if body.Size != tt.wantSize {
+				t.Errorf("size = %d, want %d", body.Size, tt.wantSize)
+			}
+			if body.Response == nil || body.Response.Request.URL.String() != srv.URL {
+				t.Errorf("response does not record the request for %s", srv.URL)
+			}
+			data, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("reading body: %v", err)
+			}
+			if string(data) != tt.body {
+				t.Errorf("body = %q, want %q", data, tt.body)
+			}
+		})
+	}
+}
+
+func TestFetcher_FetchStream_RejectsContentType(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	f := newTestFetcher(srv.Client())
+	body, _, err := f.FetchStream(context.Background(), srv.URL)
+	if err == nil {
+		t.Fatal("expected error for non-HTML content type")
+	}
+	// The rejected response is still returned, for archiving.
+	if body == nil || body.Response.Header.Get("Content-Type") != "application/pdf" {
+		t.Fatal("expected the rejected response with the error")
+	}
+	body.Close()
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestNewReplayFetcher(t *testing.T) {
+	t.Parallel()
+	replay := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/html"}},
+			Body:       io.NopCloser(strings.NewReader("archived")),
+			Request:    req,
+		}, nil
+	})
+
+	f := NewReplayFetcher(replay, 5, 3, testLogger())
+	body, status, err := f.Fetch(context.Background(), "https://example.com/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status != http.StatusOK || string(body) != "archived" {
+		t.Errorf("Fetch = %d %q, want 200 %q", status, body, "archived")
+	}
+}
+
+func TestFailureReason(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		want   string
+	}{
+		{"status", nil, http.StatusNotFound, "http_4xx"},
+		{"redirects", fmt.Errorf("fetching x: %w", errTooManyRedirects), 0, "redirects"},
+		{"content type", fmt.Errorf("%w %q", errUnexpectedContentType, "image/png"), 200, "content_type"},
+		{"timeout", fmt.Errorf("fetching x: %w", context.DeadlineExceeded), 0, "timeout"},
+		{"canceled", context.Canceled, 0, "canceled"},
+		{"dns", &net.DNSError{Err: "no such host", Name: "example.invalid"}, 0, "dns"},
+		{"connection", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, 0, "connection"},
+		{"other", errors.New("boom"), 0, "other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := failureReason(tt.err, tt.status); got != tt.want {
+				t.Errorf("failureReason = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package crawler
+
+import (
+	"container/heap"
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/theognis1002/nimbus-crawler/internal/queue"
+)
+
+// backQueueTouchInterval is how often deliveries held in back-queues or
+// leased to workers are touched. It is kept well below the consumer's reclaim
+// idle time (30s) so another consumer never claims a message this one holds.
+const backQueueTouchInterval = 10 * time.Second
+
+// hostFrontier is a Mercator-style back-queue layer between the stream
+// consumer (the front queue) and the workers. Deliveries are bucketed into one
+// FIFO per host, and hosts wait in a heap keyed by the time they may next be
+// contacted. Workers always take a URL whose host is ready, so one slow host
+// never blocks workers that could be fetching from others.
+type hostFrontier struct {
+	mu       sync.Mutex
+	queues   map[string]*backQueue
+	leased   map[*hostLease]struct{}
+	ready    hostHeap
+	size     int
+	capacity int
+	closed   bool
+	wake     chan struct{}
+	space    chan struct{}
+	now      func() time.Time
+}
+
+type backQueue struct {
+	host   string
+	items  []frontierItem
+	nextAt time.Time
+	busy   bool
+	index  int // position in the ready heap, -1 when not in it
+}
+
+type frontierItem struct {
+	d       queue.Delivery
+	touched time.Time
+}
+
+// hostLease is a worker's exclusive claim on a host for one delivery. The
+// worker must call done when finished.
+type hostLease struct {
+	d         queue.Delivery
+	host      string
+	item      frontierItem
+	notBefore time.Time
+	requeued  bool
+	f         *hostFrontier
+}
+
+func newHostFrontier(capacity int) *hostFrontier {
+	return &hostFrontier{
+		queues:   make(map[string]*backQueue),
+		leased:   make(map[*hostLease]struct{}),
+		capacity: max(capacity, 1),
+		wake:     make(chan struct{}, 1),
+		space:    make(chan struct{}, 1),
+		now:      time.Now,
+	}
+}
+
+// feed moves deliveries from the front queue into the back-queues until
+// deliveries is closed or ctx is cancelled, touching the deliveries it holds
+// meanwhile.
+func (f *hostFrontier) feed(ctx context.Context, deliveries <-chan queue.Delivery) {
+	defer f.close()
+	touchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go f.touchLoop(touchCtx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d, ok := <-deliveries:
+			if !ok {
+				return
+			}
+			if !f.push(ctx, d) {
+				return
+			}
+		}
+	}
+}
+
+// push adds a delivery to its host's back-queue, blocking while the frontier
+// is at capacity. Undecodable messages go to the "" host and are rejected by
+// the worker that picks them up.
+func (f *hostFrontier) push(ctx context.Context, d queue.Delivery) bool {
+	host := deliveryHost(d)
+	for {
+		f.mu.Lock()
+		if f.size < f.capacity {
+			q, ok := f.queues[host]
+			if !ok {
+				q = &backQueue{host: host, index: -1}
+				f.queues[host] = q
+			}
+			q.items = append(q.items, frontierItem{d: d, touched: f.now()})
+			f.size++
+			if !q.busy && q.index < 0 {
+				heap.Push(&f.ready, q)
+			}
+			f.mu.Unlock()
+			signal(f.wake)
+			return true
+		}
+		f.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-f.space:
+		}
+	}
+}
+
+// next blocks until some host is ready and returns a lease on its oldest
+// delivery. It returns false once ctx is cancelled, or the frontier is closed
+// and drained.
+func (f *hostFrontier) next(ctx context.Context) (*hostLease, bool) {
+	for {
+		f.mu.Lock()
+		var wait time.Duration = -1
+		if f.ready.Len() > 0 {
+			q := f.ready[0]
+			now := f.now()
+			if !q.nextAt.After(now) {
+				heap.Pop(&f.ready)
+				item := q.items[0]
+				q.items = q.items[1:]
+				f.size--
+				signal(f.space)
+				q.busy = true
+				lease := &hostLease{d: item.d, host: q.host, item: item, f: f}
+				f.leased[lease] = struct{}{}
+				more := f.ready.Len() > 0
+				f.mu.Unlock()
+				if more {
+					signal(f.wake)
+				}
+				return lease, true
+			}
+			wait = q.nextAt.Sub(now)
+		} else if f.closed && f.size == 0 {
+			f.mu.Unlock()
+			return nil, false
+		}
+		f.mu.Unlock()
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if wait >= 0 {
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-f.wake:
+		case <-timeout:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return nil, false
+		}
+	}
+}
+
+// touchLoop touches held and leased deliveries every backQueueTouchInterval
+// until ctx is cancelled.
+func (f *hostFrontier) touchLoop(ctx context.Context) {
+	ticker := time.NewTicker(backQueueTouchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			f.touchStale()
+		}
+	}
+}
+
+// touchStale touches every delivery not touched for backQueueTouchInterval.
+// Backends are called without holding f.mu.
+func (f *hostFrontier) touchStale() {
+	now := f.now()
+	var stale []queue.Delivery
+	f.mu.Lock()
+	for _, q := range f.queues {
+		for i := range q.items {
+			if now.Sub(q.items[i].touched) >= backQueueTouchInterval {
+				q.items[i].touched = now
+				stale = append(stale, q.items[i].d)
+			}
+		}
+	}
+	for l := range f.leased {
+		if now.Sub(l.item.touched) >= backQueueTouchInterval {
+			l.item.touched = now
+			stale = append(stale, l.item.d)
+		}
+	}
+	f.mu.Unlock()
+	for _, d := range stale {
+		if d.Touch != nil {
+			_ = d.Touch()
+		}
+	}
+}
+
+func (f *hostFrontier) dropIfEmpty(q *backQueue) {
+	if len(q.items) == 0 && !q.busy && q.index < 0 {
+		delete(f.queues, q.host)
+	}
+}
+
+func (f *hostFrontier) close() {
+	f.mu.Lock()
+	f.closed = true
+	f.mu.Unlock()
+	signal(f.wake)
+}
+
+// hold marks the host as not contactable again until now+d.
+func (l *hostLease) hold(d time.Duration) {
+	l.notBefore = l.f.now().Add(d)
+}
+
+// requeue puts the delivery back at the head of its host's back-queue and
+// makes the host ready again after d.
+func (l *hostLease) requeue(d time.Duration) {
+	l.requeued = true
+	l.notBefore = l.f.now().Add(d)
+}
+
+// done returns the host to the ready heap.
+func (l *hostLease) done() {
+	f := l.f
+	f.mu.Lock()
+	delete(f.leased, l)
+	q := f.queues[l.host]
+	if l.requeued {
+		q.items = append([]frontierItem{l.item}, q.items...)
+		f.size++
+	}
+	q.busy = false
+	q.nextAt = l.notBefore
+	if len(q.items) > 0 {
+		heap.Push(&f.ready, q)
+	} else {
+		f.dropIfEmpty(q)
+	}
+	f.mu.Unlock()
+	signal(f.wake)
+}
+
+func deliveryHost(d queue.Delivery) string {
+	var msg queue.URLMessage
+	if err := d.Decode(&msg); err != nil {
+		return ""
+	}
+	u, err := url.Parse(msg.URL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// signal performs a non-blocking send on a 1-buffered notification channel.
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// hostHeap orders back-queues by the time their host may next be contacted.
+type hostHeap []*backQueue
+
+func (h hostHeap) Len() int           { return len(h) }
+func (h hostHeap) Less(i, j int) bool { return h[i].nextAt.Before(h[j].nextAt) }
+func (h hostHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *hostHeap) Push(x any) {
+	q := x.(*backQueue)
+	q.index = len(*h)
+	*h = append(*h, q)
+}
+
+func (h *hostHeap) Pop() any {
+	old := *h
+	n := len(old)
+	q := old[n-1]
+	old[n-1] = nil
+	q.index = -1
+	*h = old[:n-1]
+	return q
+}
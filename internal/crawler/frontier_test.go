@@ -0,0 +1,184 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/theognis1002/nimbus-crawler/internal/queue"
+)
+
+func testDelivery(t *testing.T, rawURL string, nacks *atomic.Int32) queue.Delivery {
+	t.Helper()
+	body, err := json.Marshal(queue.URLMessage{URL: rawURL})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return queue.Delivery{
+		Body: body,
+		Ack:  func() error { return nil },
+		Nack: func(bool) error {
+			if nacks != nil {
+				nacks.Add(1)
+			}
+			return nil
+		},
+	}
+}
+
+func leaseURL(t *testing.T, l *hostLease) string {
+	t.Helper()
+	var msg queue.URLMessage
+	if err := l.d.Decode(&msg); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	return msg.URL
+}
+
+func TestHostFrontier_SkipsRestingHost(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	f := newHostFrontier(10)
+
+	f.push(ctx, testDelivery(t, "https://slow.com/1", nil))
+	f.push(ctx, testDelivery(t, "https://slow.com/2", nil))
+
+	lease, ok := f.next(ctx)
+	if !ok || lease.host != "slow.com" {
+		t.Fatalf("first lease = %+v, want slow.com", lease)
+	}
+	lease.hold(time.Hour)
+	lease.done()
+
+	f.push(ctx, testDelivery(t, "https://fast.com/1", nil))
+
+	lease, ok = f.next(ctx)
+	if !ok {
+		t.Fatal("expected a lease")
+	}
+	if got := leaseURL(t, lease); got != "https://fast.com/1" {
+		t.Errorf("lease url = %q, want fast.com while slow.com rests", got)
+	}
+}
+
+func TestHostFrontier_OneLeasePerHost(t *testing.T) {
+	t.Parallel()
+	f := newHostFrontier(10)
+	f.push(context.Background(), testDelivery(t, "https://a.com/1", nil))
+	f.push(context.Background(), testDelivery(t, "https://a.com/2", nil))
+
+	if _, ok := f.next(context.Background()); !ok {
+		t.Fatal("expected a lease")
+	}
+
+	// a.com is checked out, so nothing else is ready.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if lease, ok := f.next(ctx); ok {
+		t.Errorf("got lease for %q while host already leased", leaseURL(t, lease))
+	}
+}
+
+func TestHostFrontier_RequeueKeepsOrder(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	f := newHostFrontier(10)
+	f.push(ctx, testDelivery(t, "https://a.com/1", nil))
+	f.push(ctx, testDelivery(t, "https://a.com/2", nil))
+
+	lease, _ := f.next(ctx)
+	lease.requeue(10 * time.Millisecond)
+	lease.done()
+
+	start := time.Now()
+	lease, ok := f.next(ctx)
+	if !ok {
+		t.Fatal("expected a lease")
+	}
+	if got := leaseURL(t, lease); got != "https://a.com/1" {
+		t.Errorf("lease url = %q, want requeued a.com/1 first", got)
+	}
+	if time.Since(start) < 5*time.Millisecond {
+		t.Error("requeued host should rest before becoming ready")
+	}
+}
+
+func TestHostFrontier_PushBlocksAtCapacity(t *testing.T) {
+	t.Parallel()
+	f := newHostFrontier(1)
+	f.push(context.Background(), testDelivery(t, "https://a.com/1", nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if f.push(ctx, testDelivery(t, "https://b.com/1", nil)) {
+		t.Fatal("push should block while the frontier is full")
+	}
+
+	lease, _ := f.next(context.Background())
+	lease.done()
+	if !f.push(context.Background(), testDelivery(t, "https://b.com/1", nil)) {
+		t.Error("push should succeed once space frees up")
+	}
+}
+
+func TestHostFrontier_TouchesHeldDeliveries(t *testing.T) {
+	t.Parallel()
+	var touches atomic.Int32
+	touched := func(d queue.Delivery) queue.Delivery {
+		d.Touch = func() error {
+			touches.Add(1)
+			return nil
+		}
+		return d
+	}
+	f := newHostFrontier(10)
+	clock := time.Now()
+	f.now = func() time.Time { return clock }
+
+	f.push(context.Background(), touched(testDelivery(t, "https://a.com/1", nil)))
+	f.push(context.Background(), touched(testDelivery(t, "https://a.com/2", nil)))
+	lease, ok := f.next(context.Background())
+	if !ok {
+		t.Fatal("expected a lease")
+	}
+
+	f.touchStale()
+	if n := touches.Load(); n != 0 {
+		t.Errorf("touches = %d before the interval passed, want 0", n)
+	}
+	clock = clock.Add(backQueueTouchInterval)
+	f.touchStale()
+	if n := touches.Load(); n != 2 {
+		t.Errorf("touches = %d, want the held and the leased delivery", n)
+	}
+	lease.done()
+	clock = clock.Add(backQueueTouchInterval)
+	f.touchStale()
+	if n := touches.Load(); n != 3 {
+		t.Errorf("touches = %d after done, want only the held delivery touched again", n)
+	}
+	if lease, ok := f.next(context.Background()); !ok || leaseURL(t, lease) != "https://a.com/2" {
+		t.Error("held delivery should still be leased out, not released")
+	}
+}
+
+func TestHostFrontier_FeedClosesWhenDrained(t *testing.T) {
+	t.Parallel()
+	f := newHostFrontier(10)
+	deliveries := make(chan queue.Delivery, 1)
+	deliveries <- testDelivery(t, "https://a.com/1", nil)
+	close(deliveries)
+
+	f.feed(context.Background(), deliveries)
+
+	lease, ok := f.next(context.Background())
+	if !ok {
+		t.Fatal("expected buffered delivery before close")
+	}
+	lease.done()
+	if _, ok := f.next(context.Background()); ok {
+		t.Error("next should report false once closed and drained")
+	}
+}
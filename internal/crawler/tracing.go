@@ -0,0 +1,74 @@
+package crawler
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/theognis1002/nimbus-crawler/internal/crawler")
+
+// failSpan marks span as failed with err.
+func failSpan(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// endSpan ends span, marking it failed if err is set.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		failSpan(span, err)
+	}
+	span.End()
+}
+
+// withClientTrace returns ctx with an httptrace.ClientTrace that records the
+// DNS, connect, TLS and first-byte milestones of a request as span events.
+func withClientTrace(ctx context.Context, span trace.Span) context.Context {
+	event := func(name string, attrs ...attribute.KeyValue) {
+		span.AddEvent(name, trace.WithAttributes(attrs...))
+	}
+	errAttrs := func(err error) []attribute.KeyValue {
+		if err == nil {
+			return nil
+		}
+		return []attribute.KeyValue{attribute.String("error", err.Error())}
+	}
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn: func(hostPort string) {
+			event("get_conn", attribute.String("host_port", hostPort))
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			event("got_conn", attribute.Bool("reused", info.Reused))
+		},
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			event("dns_start", attribute.String("host", info.Host))
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			event("dns_done", errAttrs(info.Err)...)
+		},
+		ConnectStart: func(network, addr string) {
+			event("connect_start", attribute.String("addr", addr))
+		},
+		ConnectDone: func(network, addr string, err error) {
+			event("connect_done", append(errAttrs(err), attribute.String("addr", addr))...)
+		},
+		TLSHandshakeStart: func() {
+			event("tls_start")
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			event("tls_done", append(errAttrs(err), attribute.String("tls.version", tls.VersionName(state.Version)))...)
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			event("wrote_request", errAttrs(info.Err)...)
+		},
+		GotFirstResponseByte: func() {
+			event("first_byte")
+		},
+	})
+}
@@ -0,0 +1,57 @@
+package crawler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/theognis1002/nimbus-crawler/internal/queue"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestStartCrawlSpan(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	tp := sdktrace.NewTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { tp.Shutdown(context.Background()) })
+
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+	headers := map[string]string{}
+	propagation.TraceContext{}.Inject(trace.ContextWithSpanContext(context.Background(), parent), propagation.MapCarrier(headers))
+	d := queue.Delivery{Headers: headers}
+
+	tests := []struct {
+		name      string
+		attempt   int
+		sameTrace bool
+	}{
+		{"first attempt starts a trace", 0, false},
+		{"retry continues the trace", 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, span := startCrawlSpan(context.Background(), d, queue.URLMessage{URL: "https://example.com/", Attempt: tt.attempt})
+			span.End()
+
+			ro := span.(sdktrace.ReadOnlySpan)
+			if got := ro.SpanContext().TraceID() == parent.TraceID(); got != tt.sameTrace {
+				t.Errorf("same trace as message = %v, want %v", got, tt.sameTrace)
+			}
+			if tt.sameTrace {
+				if ro.Parent().SpanID() != parent.SpanID() {
+					t.Errorf("parent = %v, want %v", ro.Parent().SpanID(), parent.SpanID())
+				}
+				return
+			}
+			if links := ro.Links(); len(links) != 1 || links[0].SpanContext.SpanID() != parent.SpanID() {
+				t.Errorf("links = %v, want one to %v", links, parent.SpanID())
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+-- One row per fetch of a URL whose body was stored content-addressed, so
+-- every version of a page stays reachable and identical bodies share a blob.
+CREATE TABLE url_snapshots (
+    id           BIGSERIAL PRIMARY KEY,
+    url_id       UUID NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+    fetched_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    content_hash CHAR(64) NOT NULL,
+    s3_html_link TEXT NOT NULL
+);
+
+CREATE INDEX idx_url_snapshots_url_fetched ON url_snapshots(url_id, fetched_at DESC);
+CREATE INDEX idx_url_snapshots_content_hash ON url_snapshots(content_hash);
@@ -0,0 +1,8 @@
+-- The first WARC capture of each payload digest. Later captures of the same
+-- payload are written as revisit records referring to it.
+CREATE TABLE warc_captures (
+    payload_digest TEXT PRIMARY KEY,
+    target_uri     TEXT NOT NULL,
+    captured_at    TIMESTAMPTZ NOT NULL,
+    record_id      TEXT NOT NULL
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS crawl_request_urls;
+DROP TABLE IF EXISTS crawl_requests;
@@ -0,0 +1,16 @@
+-- On-demand crawl requests submitted through the API, and the URLs each one
+-- asked for. Progress is read from the urls rows of those URLs.
+CREATE TABLE crawl_requests (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    priority   INTEGER NOT NULL,
+    max_depth  INTEGER NOT NULL,
+    scope      TEXT NOT NULL,
+    enqueued   INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE crawl_request_urls (
+    request_id UUID NOT NULL REFERENCES crawl_requests(id) ON DELETE CASCADE,
+    url        TEXT NOT NULL,
+    PRIMARY KEY (request_id, url)
+);
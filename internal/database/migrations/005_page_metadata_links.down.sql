@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS links;
+DROP INDEX IF EXISTS idx_urls_content_changed;
+DROP INDEX IF EXISTS idx_urls_language;
+ALTER TABLE urls
+    DROP COLUMN IF EXISTS content_changed_at,
+    DROP COLUMN IF EXISTS language,
+    DROP COLUMN IF EXISTS title;
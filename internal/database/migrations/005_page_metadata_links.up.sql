@@ -0,0 +1,21 @@
+-- Page metadata found by the parser, and when a page's content last changed
+-- (first parse included), for incremental sync.
+ALTER TABLE urls
+    ADD COLUMN title              TEXT,
+    ADD COLUMN language           TEXT,
+    ADD COLUMN content_changed_at TIMESTAMPTZ;
+
+CREATE INDEX idx_urls_language ON urls(language);
+CREATE INDEX idx_urls_content_changed ON urls(content_changed_at, id) WHERE content_changed_at IS NOT NULL;
+
+-- The links found on each parsed page, when the parser records them. Links
+-- are keyed on a hash of the target URL: a btree entry holding the URL itself
+-- fails for URLs longer than about 2.7kB.
+CREATE TABLE links (
+    source_id   UUID NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+    target_url  TEXT NOT NULL,
+    target_hash BYTEA NOT NULL GENERATED ALWAYS AS (sha256(convert_to(target_url, 'UTF8'))) STORED,
+    PRIMARY KEY (source_id, target_hash)
+);
+
+CREATE INDEX idx_links_target_hash ON links(target_hash);
@@ -0,0 +1,12 @@
+-- Full-text search over parsed pages: the text, and a tsvector of the title
+-- (weight A) and text (weight D) built with the page language's text search
+-- configuration.
+CREATE TABLE page_search (
+    url_id     UUID PRIMARY KEY REFERENCES urls(id) ON DELETE CASCADE,
+    config     REGCONFIG NOT NULL,
+    body       TEXT NOT NULL,
+    document   TSVECTOR NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_page_search_document ON page_search USING GIN (document);
@@ -0,0 +1,3 @@
+-- The URL a page was last fetched from after redirects, when it differs from
+-- the URL itself.
+ALTER TABLE urls ADD COLUMN final_url TEXT;
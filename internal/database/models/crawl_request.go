@@ -0,0 +1,92 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CrawlRequest is a batch of URLs submitted for crawling on demand. URLs is
+// how many it asked for and Enqueued how many of those were new and queued.
+type CrawlRequest struct {
+	ID        string
+	Priority  int
+	MaxDepth  int
+	Scope     string
+	URLs      int
+	Enqueued  int
+	CreatedAt time.Time
+}
+
+// InsertCrawlRequest records req and its urls, setting req's ID and
+// CreatedAt.
+func InsertCrawlRequest(ctx context.Context, pool *pgxpool.Pool, req *CrawlRequest, urls []string) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx,
+		`INSERT INTO crawl_requests (priority, max_depth, scope) VALUES ($1, $2, $3)
+		 RETURNING id, created_at`,
+		req.Priority, req.MaxDepth, req.Scope).Scan(&req.ID, &req.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("inserting crawl request: %w", err)
+	}
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO crawl_request_urls (request_id, url)
+		 SELECT $1, unnest($2::text[]) ON CONFLICT DO NOTHING`,
+		req.ID, urls); err != nil {
+		return fmt.Errorf("inserting crawl request urls: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("committing crawl request: %w", err)
+	}
+	req.URLs = len(urls)
+	return nil
+}
+
+func SetCrawlRequestEnqueued(ctx context.Context, pool *pgxpool.Pool, id string, n int) error {
+	if _, err := pool.Exec(ctx, `UPDATE crawl_requests SET enqueued = $2 WHERE id = $1`, id, n); err != nil {
+		return fmt.Errorf("updating crawl request %s: %w", id, err)
+	}
+	return nil
+}
+
+// GetCrawlRequest returns a crawl request and the number of its URLs in each
+// status. URLs without a urls row yet count as pending.
+func GetCrawlRequest(ctx context.Context, pool *pgxpool.Pool, id string) (*CrawlRequest, map[URLStatus]int64, error) {
+	req := &CrawlRequest{}
+	err := pool.QueryRow(ctx,
+		`SELECT id, priority, max_depth, scope, enqueued, created_at,
+		        (SELECT COUNT(*) FROM crawl_request_urls WHERE request_id = $1)
+		 FROM crawl_requests WHERE id = $1`, id,
+	).Scan(&req.ID, &req.Priority, &req.MaxDepth, &req.Scope, &req.Enqueued, &req.CreatedAt, &req.URLs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting crawl request %s: %w", id, err)
+	}
+
+	rows, err := pool.Query(ctx,
+		`SELECT COALESCE(u.status, 'pending'), COUNT(*)
+		 FROM crawl_request_urls r LEFT JOIN urls u ON u.url = r.url
+		 WHERE r.request_id = $1
+		 GROUP BY 1`, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("counting crawl request urls: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[URLStatus]int64)
+	for rows.Next() {
+		var status string
+		var n int64
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, nil, fmt.Errorf("scanning crawl request url count: %w", err)
+		}
+		counts[URLStatus(status)] = n
+	}
+	return req, counts, rows.Err()
+}
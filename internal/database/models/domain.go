@@ -69,3 +69,37 @@ func UpdateDomainLastCrawlTime(ctx context.Context, pool *pgxpool.Pool, domain s
 	}
 	return nil
 }
+
+// DomainSummary is a domain with the number of its URLs that have stored
+// HTML.
+type DomainSummary struct {
+	Domain        string
+	ArchivedURLs  int64
+	LastCrawlTime *time.Time
+}
+
+// ListArchivedDomains returns up to limit domains with stored pages, in name
+// order after the given domain, for paging through them.
+func ListArchivedDomains(ctx context.Context, pool *pgxpool.Pool, after string, limit int) ([]DomainSummary, error) {
+	rows, err := pool.Query(ctx,
+		`SELECT d.domain, COUNT(u.id), d.last_crawl_time
+		 FROM domains d JOIN urls u ON u.domain = d.domain AND u.s3_html_link IS NOT NULL
+		 WHERE d.domain > $1
+		 GROUP BY d.domain
+		 ORDER BY d.domain
+		 LIMIT $2`, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("listing archived domains: %w", err)
+	}
+	defer rows.Close()
+
+	var domains []DomainSummary
+	for rows.Next() {
+		var d DomainSummary
+		if err := rows.Scan(&d.Domain, &d.ArchivedURLs, &d.LastCrawlTime); err != nil {
+			return nil, fmt.Errorf("scanning domain: %w", err)
+		}
+		domains = append(domains, d)
+	}
+	return domains, rows.Err()
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Link is one end of a link between pages: the URL, and its id when the URL
+// is in the urls table.
+type Link struct {
+	URL string
+	ID  *string
+}
+
+// ReplaceLinks sets the links found on a page to targets.
+func ReplaceLinks(ctx context.Context, pool *pgxpool.Pool, sourceID string, targets []string) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM links WHERE source_id = $1`, sourceID); err != nil {
+		return fmt.Errorf("deleting links: %w", err)
+	}
+	if len(targets) > 0 {
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO links (source_id, target_url)
+			 SELECT $1, unnest($2::text[]) ON CONFLICT DO NOTHING`,
+			sourceID, targets); err != nil {
+			return fmt.Errorf("inserting links: %w", err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("committing links: %w", err)
+	}
+	return nil
+}
+
+// ListOutlinks returns up to limit links found on a page, in URL order after
+// the given URL.
+func ListOutlinks(ctx context.Context, pool *pgxpool.Pool, sourceID, after string, limit int) ([]Link, error) {
+	return listLinks(ctx, pool,
+		`SELECT l.target_url, u.id::text FROM links l LEFT JOIN urls u ON u.url = l.target_url
+		 WHERE l.source_id = $1 AND l.target_url > $2
+		 ORDER BY l.target_url
+		 LIMIT $3`, sourceID, after, limit)
+}
+
+// ListInlinks returns up to limit pages linking to targetURL, in URL order
+// after the given URL. Links are looked up by the hash of their target.
+func ListInlinks(ctx context.Context, pool *pgxpool.Pool, targetURL, after string, limit int) ([]Link, error) {
+	return listLinks(ctx, pool,
+		`SELECT u.url, u.id::text FROM links l JOIN urls u ON u.id = l.source_id
+		 WHERE l.target_hash = sha256(convert_to($1::text, 'UTF8')) AND l.target_url = $1
+		   AND u.url > $2
+		 ORDER BY u.url
+		 LIMIT $3`, targetURL, after, limit)
+}
+
+func listLinks(ctx context.Context, pool *pgxpool.Pool, query string, args ...any) ([]Link, error) {
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing links: %w", err)
+	}
+	defer rows.Close()
+
+	var links []Link
+	for rows.Next() {
+		var l Link
+		if err := rows.Scan(&l.URL, &l.ID); err != nil {
+			return nil, fmt.Errorf("scanning link: %w", err)
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
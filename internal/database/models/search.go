@@ -0,0 +1,198 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// searchConfigs maps ISO 639-1 language codes to the text search
+// configurations Postgres ships with.
+var searchConfigs = map[string]string{
+	"ar": "arabic",
+	"ca": "catalan",
+	"da": "danish",
+	"de": "german",
+	"el": "greek",
+	"en": "english",
+	"es": "spanish",
+	"eu": "basque",
+	"fi": "finnish",
+	"fr": "french",
+	"ga": "irish",
+	"hi": "hindi",
+	"hu": "hungarian",
+	"hy": "armenian",
+	"id": "indonesian",
+	"it": "italian",
+	"lt": "lithuanian",
+	"ne": "nepali",
+	"nb": "norwegian",
+	"nl": "dutch",
+	"nn": "norwegian",
+	"no": "norwegian",
+	"pt": "portuguese",
+	"ro": "romanian",
+	"ru": "russian",
+	"sr": "serbian",
+	"sv": "swedish",
+	"ta": "tamil",
+	"tr": "turkish",
+	"yi": "yiddish",
+}
+
+// SearchConfig returns the text search configuration for a language code,
+// or "simple" (no stemming or stop words) for unknown languages.
+func SearchConfig(language string) string {
+	if cfg, ok := searchConfigs[strings.ToLower(language)]; ok {
+		return cfg
+	}
+	return "simple"
+}
+
+// searchConfigNames returns every configuration SearchConfig may return,
+// sorted.
+func searchConfigNames() []string {
+	names := []string{"simple"}
+	for _, cfg := range searchConfigs {
+		if !slices.Contains(names, cfg) {
+			names = append(names, cfg)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// UpsertPageSearch indexes a page's title and text for full-text search,
+// replacing what was indexed for it before. The title outranks the text.
+func UpsertPageSearch(ctx context.Context, pool *pgxpool.Pool, urlID, title, language, text string) error {
+	_, err := pool.Exec(ctx,
+		`INSERT INTO page_search (url_id, config, body, document, updated_at)
+		 VALUES ($1, $2::text::regconfig, $4,
+		         setweight(to_tsvector($2::text::regconfig, $3), 'A') || setweight(to_tsvector($2::text::regconfig, $4), 'D'),
+		         NOW())
+		 ON CONFLICT (url_id) DO UPDATE SET
+		     config = EXCLUDED.config, body = EXCLUDED.body, document = EXCLUDED.document, updated_at = NOW()`,
+		urlID, SearchConfig(language), title, text)
+	if err != nil {
+		return fmt.Errorf("indexing page for search: %w", err)
+	}
+	return nil
+}
+
+// SearchQuery is a full-text search over indexed pages.
+type SearchQuery struct {
+	// Query is in websearch syntax: words, "quoted phrases", or and -not.
+	Query string
+	// Language limits results to pages indexed with that language's
+	// configuration; "" searches all of them.
+	Language string
+	Domain   string
+	// Since and Until bound last_crawl_time when non-zero.
+	Since, Until  time.Time
+	Limit, Offset int
+}
+
+// Snippet markers ts_headline puts around matches. Control characters never
+// survive in the HTML-escaped snippet, so they cannot be forged by page text;
+// they are stripped from the text before highlighting anyway.
+const (
+	snippetStart = "\x02"
+	snippetStop  = "\x03"
+)
+
+var snippetMarkers = strings.NewReplacer(snippetStart, "<b>", snippetStop, "</b>")
+
+// highlight HTML-escapes a ts_headline snippet and turns its markers into
+// <b> tags.
+func highlight(snippet string) string {
+	return snippetMarkers.Replace(html.EscapeString(snippet))
+}
+
+// SearchResult is a page matching a search, with a snippet of its text
+// around the matches.
+type SearchResult struct {
+	ID            string
+	URL           string
+	Title         *string
+	LastCrawlTime *time.Time
+	Rank          float32
+	// Snippet is HTML: the escaped text around the matches, which are
+	// wrapped in <b> tags.
+	Snippet string
+}
+
+// sql returns the search statement and its arguments. The query is parsed
+// once per text search configuration, each matched against the pages
+// indexed with it, so the GIN index serves every language.
+func (q SearchQuery) sql() (string, []any) {
+	configs := searchConfigNames()
+	if q.Language != "" {
+		configs = []string{SearchConfig(q.Language)}
+	}
+	args := []any{q.Query, configs}
+	var conds []string
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if q.Domain != "" {
+		add("u.domain = $%d", q.Domain)
+	}
+	if !q.Since.IsZero() {
+		add("u.last_crawl_time >= $%d", q.Since)
+	}
+	if !q.Until.IsZero() {
+		add("u.last_crawl_time < $%d", q.Until)
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+	args = append(args, q.Limit, q.Offset)
+
+	return fmt.Sprintf(`WITH q AS (
+		     SELECT c::regconfig AS config, websearch_to_tsquery(c::regconfig, $1) AS query
+		     FROM unnest($2::text[]) AS c
+		 ), hits AS (
+		     SELECT u.id, u.url, u.title, u.last_crawl_time, ps.config, ps.body, q.query,
+		            ts_rank_cd(ps.document, q.query) AS rank
+		     FROM q
+		     JOIN page_search ps ON ps.config = q.config AND ps.document @@ q.query
+		     JOIN urls u ON u.id = ps.url_id
+		     %s
+		     ORDER BY rank DESC, u.id
+		     LIMIT $%d OFFSET $%d
+		 )
+		 SELECT id, url, title, last_crawl_time, rank,
+		        ts_headline(config, translate(body, chr(2) || chr(3), ''), query,
+		                    'MaxFragments=2, MaxWords=30, MinWords=10, StartSel=' || chr(2) || ', StopSel=' || chr(3))
+		 FROM hits
+		 ORDER BY rank DESC, id`, where, len(args)-1, len(args)), args
+}
+
+// SearchPages returns the pages matching q, best match first.
+func SearchPages(ctx context.Context, pool *pgxpool.Pool, q SearchQuery) ([]SearchResult, error) {
+	query, args := q.sql()
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("searching pages: %w", err)
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		if err := rows.Scan(&r.ID, &r.URL, &r.Title, &r.LastCrawlTime, &r.Rank, &r.Snippet); err != nil {
+			return nil, fmt.Errorf("scanning search result: %w", err)
+		}
+		r.Snippet = highlight(r.Snippet)
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearchConfig(t *testing.T) {
+	t.Parallel()
+	for lang, want := range map[string]string{"en": "english", "DE": "german", "nb": "norwegian", "ja": "simple", "": "simple"} {
+		if got := SearchConfig(lang); got != want {
+			t.Errorf("SearchConfig(%q) = %q, want %q", lang, got, want)
+		}
+	}
+	names := searchConfigNames()
+	if !strings.HasPrefix(strings.Join(names, ","), "arabic,armenian,basque,") || names[len(names)-1] != "yiddish" {
+		t.Errorf("searchConfigNames() = %v, want every configuration sorted", names)
+	}
+}
+
+func TestSearchQuerySQL(t *testing.T) {
+	t.Parallel()
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		query     SearchQuery
+		wantWhere string
+		wantLimit string
+		wantArgs  []any
+	}{
+		{
+			name:      "unfiltered",
+			query:     SearchQuery{Query: "crawler", Limit: 10},
+			wantLimit: "LIMIT $3 OFFSET $4",
+			wantArgs:  []any{"crawler", searchConfigNames(), 10, 0},
+		},
+		{
+			name:      "filtered",
+			query:     SearchQuery{Query: "crawler", Language: "en", Domain: "example.com", Since: since, Until: since.AddDate(0, 1, 0), Limit: 10, Offset: 20},
+			wantWhere: "WHERE u.domain = $3 AND u.last_crawl_time >= $4 AND u.last_crawl_time < $5",
+			wantLimit: "LIMIT $6 OFFSET $7",
+			wantArgs:  []any{"crawler", []string{"english"}, "example.com", since, since.AddDate(0, 1, 0), 10, 20},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			query, args := tt.query.sql()
+			if tt.wantWhere != "" && !strings.Contains(query, tt.wantWhere) {
+				t.Errorf("query does not contain %q:\n%s", tt.wantWhere, query)
+			}
+			if tt.wantWhere == "" && strings.Contains(query, "WHERE") {
+				t.Errorf("unfiltered query has a WHERE clause:\n%s", query)
+			}
+			if !strings.Contains(query, tt.wantLimit) {
+				t.Errorf("query does not contain %q:\n%s", tt.wantLimit, query)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	t.Parallel()
+	got := highlight("a <script>x</script> & \x02crawler\x03 said \"hi\"")
+	want := "a &lt;script&gt;x&lt;/script&gt; &amp; <b>crawler</b> said &#34;hi&#34;"
+	if got != want {
+		t.Errorf("highlight = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Snapshot is one stored version of a page.
+type Snapshot struct {
+	ID          int64
+	URLID       string
+	URL         string
+	FetchedAt   time.Time
+	ContentHash string
+	S3HTMLLink  string
+}
+
+const snapshotColumns = `s.id, s.url_id, u.url, s.fetched_at, s.content_hash, s.s3_html_link`
+
+func scanSnapshot(row pgx.Row) (*Snapshot, error) {
+	s := &Snapshot{}
+	if err := row.Scan(&s.ID, &s.URLID, &s.URL, &s.FetchedAt, &s.ContentHash, &s.S3HTMLLink); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetSnapshotAt returns the version of rawURL that was current at t: the
+// latest snapshot fetched at or before t. It returns pgx.ErrNoRows if the
+// URL had not been fetched by then.
+func GetSnapshotAt(ctx context.Context, pool *pgxpool.Pool, rawURL string, t time.Time) (*Snapshot, error) {
+	row := pool.QueryRow(ctx,
+		`SELECT `+snapshotColumns+`
+		 FROM url_snapshots s JOIN urls u ON u.id = s.url_id
+		 WHERE u.url = $1 AND s.fetched_at <= $2
+		 ORDER BY s.fetched_at DESC
+		 LIMIT 1`, rawURL, t)
+	return scanSnapshot(row)
+}
+
+// ListSnapshots returns every snapshot of rawURL, newest first.
+func ListSnapshots(ctx context.Context, pool *pgxpool.Pool, rawURL string) ([]Snapshot, error) {
+	rows, err := pool.Query(ctx,
+		`SELECT `+snapshotColumns+`
+		 FROM url_snapshots s JOIN urls u ON u.id = s.url_id
+		 WHERE u.url = $1
+		 ORDER BY s.fetched_at DESC`, rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("listing snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []Snapshot
+	for rows.Next() {
+		s, err := scanSnapshot(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning snapshot: %w", err)
+		}
+		snapshots = append(snapshots, *s)
+	}
+	return snapshots, rows.Err()
+}
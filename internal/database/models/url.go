@@ -2,7 +2,9 @@ package models
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -33,6 +35,14 @@ type URLRecord struct {
 	LastCrawlTime *time.Time
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
+	// Title and Language are set by the parser; Language is "" when the page
+	// declares none.
+	Title    *string
+	Language *string
+	// ContentChangedAt is when a parse last found new content.
+	ContentChangedAt *time.Time
+	// FinalURL is where the last fetch ended up after redirects, if not URL.
+	FinalURL *string
 }
 
 func InsertURL(ctx context.Context, pool *pgxpool.Pool, url, domain string, depth int) (string, error) {
@@ -51,24 +61,31 @@ func InsertURL(ctx context.Context, pool *pgxpool.Pool, url, domain string, dept
 	return id, nil
 }
 
+// InsertedURL is a URL row written by BulkInsertURLs.
+type InsertedURL struct {
+	URL string
+	// LastCrawlTime is when the URL was last fetched; nil means never.
+	LastCrawlTime *time.Time
+}
+
 // BulkInsertURLs inserts URLs and returns only the ones that were actually inserted (not already existing).
-func BulkInsertURLs(ctx context.Context, pool *pgxpool.Pool, urls []string, domains []string, depth int) ([]string, error) {
+func BulkInsertURLs(ctx context.Context, pool *pgxpool.Pool, urls []string, domains []string, depth int) ([]InsertedURL, error) {
 	if len(urls) != len(domains) {
 		return nil, fmt.Errorf("bulk insert: urls and domains length mismatch (%d != %d)", len(urls), len(domains))
 	}
 	batch := &pgx.Batch{}
 	for i, u := range urls {
 		batch.Queue(
-			`INSERT INTO urls (url, domain, depth) VALUES ($1, $2, $3) ON CONFLICT (url) DO NOTHING RETURNING url`,
+			`INSERT INTO urls (url, domain, depth) VALUES ($1, $2, $3) ON CONFLICT (url) DO NOTHING RETURNING url, last_crawl_time`,
 			u, domains[i], depth)
 	}
 	br := pool.SendBatch(ctx, batch)
 	defer br.Close()
 
-	var inserted []string
+	var inserted []InsertedURL
 	for range urls {
-		var u string
-		err := br.QueryRow().Scan(&u)
+		var u InsertedURL
+		err := br.QueryRow().Scan(&u.URL, &u.LastCrawlTime)
 		if err == pgx.ErrNoRows {
 			continue // already existed
 		}
@@ -80,19 +97,75 @@ func BulkInsertURLs(ctx context.Context, pool *pgxpool.Pool, urls []string, doma
 	return inserted, nil
 }
 
-func GetURLByURL(ctx context.Context, pool *pgxpool.Pool, url string) (*URLRecord, error) {
-	row := pool.QueryRow(ctx,
-		`SELECT id, url, domain, s3_html_link, s3_text_link, content_hash, depth, status, retry_count, last_crawl_time, created_at, updated_at
-		 FROM urls WHERE url = $1`, url)
+const urlColumns = `id, url, domain, s3_html_link, s3_text_link, content_hash, depth, status, retry_count, last_crawl_time, created_at, updated_at, title, language, content_changed_at, final_url`
 
+func scanURL(row pgx.Row) (*URLRecord, error) {
 	r := &URLRecord{}
 	if err := row.Scan(&r.ID, &r.URL, &r.Domain, &r.S3HTMLLink, &r.S3TextLink, &r.ContentHash,
-		&r.Depth, &r.Status, &r.RetryCount, &r.LastCrawlTime, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		&r.Depth, &r.Status, &r.RetryCount, &r.LastCrawlTime, &r.CreatedAt, &r.UpdatedAt,
+		&r.Title, &r.Language, &r.ContentChangedAt, &r.FinalURL); err != nil {
 		return nil, err
 	}
 	return r, nil
 }
 
+// scanURLs collects the urlColumns rows of a query.
+func scanURLs(rows pgx.Rows) ([]URLRecord, error) {
+	defer rows.Close()
+	var records []URLRecord
+	for rows.Next() {
+		r, err := scanURL(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning url: %w", err)
+		}
+		records = append(records, *r)
+	}
+	return records, rows.Err()
+}
+
+func GetURLByURL(ctx context.Context, pool *pgxpool.Pool, url string) (*URLRecord, error) {
+	return scanURL(pool.QueryRow(ctx, `SELECT `+urlColumns+` FROM urls WHERE url = $1`, url))
+}
+
+func GetURLByID(ctx context.Context, pool *pgxpool.Pool, id string) (*URLRecord, error) {
+	return scanURL(pool.QueryRow(ctx, `SELECT `+urlColumns+` FROM urls WHERE id = $1`, id))
+}
+
+// CountURLsByStatus returns the number of URLs of domain in each status.
+// Statuses with no URLs are left out.
+func CountURLsByStatus(ctx context.Context, pool *pgxpool.Pool, domain string) (map[URLStatus]int64, error) {
+	rows, err := pool.Query(ctx,
+		`SELECT status, COUNT(*) FROM urls WHERE domain = $1 GROUP BY status`, domain)
+	if err != nil {
+		return nil, fmt.Errorf("counting urls of %s: %w", domain, err)
+	}
+	return scanStatusCounts(rows)
+}
+
+// CountAllURLsByStatus returns the number of URLs in each status across all
+// domains. Statuses with no URLs are left out.
+func CountAllURLsByStatus(ctx context.Context, pool *pgxpool.Pool) (map[URLStatus]int64, error) {
+	rows, err := pool.Query(ctx, `SELECT status, COUNT(*) FROM urls GROUP BY status`)
+	if err != nil {
+		return nil, fmt.Errorf("counting urls: %w", err)
+	}
+	return scanStatusCounts(rows)
+}
+
+func scanStatusCounts(rows pgx.Rows) (map[URLStatus]int64, error) {
+	defer rows.Close()
+	counts := make(map[URLStatus]int64)
+	for rows.Next() {
+		var status string
+		var n int64
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, fmt.Errorf("scanning url count: %w", err)
+		}
+		counts[URLStatus(status)] = n
+	}
+	return counts, rows.Err()
+}
+
 func UpdateURLStatus(ctx context.Context, pool *pgxpool.Pool, id string, status URLStatus) error {
 	_, err := pool.Exec(ctx,
 		`UPDATE urls SET status = $2, updated_at = NOW() WHERE id = $1`,
@@ -110,15 +183,34 @@ func UpdateURLCrawled(ctx context.Context, pool *pgxpool.Pool, id, s3HTMLLink st
 
 // UpdateURLCrawledAndDomainTime batches the URL crawled update and the domain
 // last_crawl_time update into a single DB round-trip using pgx.Batch.
-func UpdateURLCrawledAndDomainTime(ctx context.Context, pool *pgxpool.Pool, urlID, s3HTMLLink, domain string) error {
+// finalURL is the URL fetched after redirects; it is recorded only when it
+// differs from the URL's own.
+func UpdateURLCrawledAndDomainTime(ctx context.Context, pool *pgxpool.Pool, urlID, s3HTMLLink, finalURL, domain string) error {
+	return updateURLCrawled(ctx, pool, urlID, s3HTMLLink, finalURL, domain, "")
+}
+
+// UpdateURLCrawledWithSnapshot is UpdateURLCrawledAndDomainTime for a body
+// stored content-addressed: it also records the fetch in url_snapshots, in
+// the same round-trip.
+func UpdateURLCrawledWithSnapshot(ctx context.Context, pool *pgxpool.Pool, urlID, s3HTMLLink, finalURL, domain, contentHash string) error {
+	return updateURLCrawled(ctx, pool, urlID, s3HTMLLink, finalURL, domain, contentHash)
+}
+
+func updateURLCrawled(ctx context.Context, pool *pgxpool.Pool, urlID, s3HTMLLink, finalURL, domain, snapshotHash string) error {
 	batch := &pgx.Batch{}
 	batch.Queue(
-		`UPDATE urls SET status = 'crawled', s3_html_link = $2, last_crawl_time = NOW(), updated_at = NOW()
+		`UPDATE urls SET status = 'crawled', s3_html_link = $2, final_url = NULLIF(NULLIF($3, ''), url),
+		     last_crawl_time = NOW(), updated_at = NOW()
 		 WHERE id = $1`,
-		urlID, s3HTMLLink)
+		urlID, s3HTMLLink, finalURL)
 	batch.Queue(
 		`UPDATE domains SET last_crawl_time = NOW() WHERE domain = $1`,
 		domain)
+	if snapshotHash != "" {
+		batch.Queue(
+			`INSERT INTO url_snapshots (url_id, content_hash, s3_html_link) VALUES ($1, $2, $3)`,
+			urlID, snapshotHash, s3HTMLLink)
+	}
 
 	br := pool.SendBatch(ctx, batch)
 	defer br.Close()
@@ -129,15 +221,39 @@ func UpdateURLCrawledAndDomainTime(ctx context.Context, pool *pgxpool.Pool, urlI
 	if _, err := br.Exec(); err != nil {
 		return fmt.Errorf("updating domain last_crawl_time: %w", err)
 	}
+	if snapshotHash != "" {
+		if _, err := br.Exec(); err != nil {
+			return fmt.Errorf("inserting url snapshot: %w", err)
+		}
+	}
 	return nil
 }
 
-func UpdateURLParsed(ctx context.Context, pool *pgxpool.Pool, id, contentHash, s3TextLink string) error {
-	_, err := pool.Exec(ctx,
-		`UPDATE urls SET status = 'parsed', content_hash = $2, s3_text_link = $3, updated_at = NOW()
-		 WHERE id = $1`,
-		id, contentHash, s3TextLink)
-	return err
+// ParsedPage is what the parser records about a page.
+type ParsedPage struct {
+	ContentHash string
+	S3TextLink  string
+	Title       string
+	Language    string
+}
+
+// UpdateURLParsed marks a URL parsed and returns the content hash it was
+// last parsed with, or "" if it was not parsed before. content_changed_at
+// moves only when the content hash differs from that one.
+func UpdateURLParsed(ctx context.Context, pool *pgxpool.Pool, id string, page ParsedPage) (previousHash string, err error) {
+	err = pool.QueryRow(ctx,
+		`UPDATE urls u SET status = 'parsed', s3_text_link = $3, title = NULLIF($4, ''), language = NULLIF($5, ''),
+		     content_changed_at = CASE WHEN old.content_hash IS DISTINCT FROM $2 THEN NOW() ELSE old.content_changed_at END,
+		     content_hash = $2, updated_at = NOW()
+		 FROM urls old
+		 WHERE u.id = $1 AND old.id = u.id
+		 RETURNING COALESCE(old.content_hash, '')`,
+		id, page.ContentHash, page.S3TextLink, page.Title, page.Language).Scan(&previousHash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// The URL was deleted while it was parsed; there is nothing to mark.
+		return "", nil
+	}
+	return previousHash, err
 }
 
 func IncrementRetryCount(ctx context.Context, pool *pgxpool.Pool, id string) (int, error) {
@@ -196,3 +312,162 @@ func ContentHashExists(ctx context.Context, pool *pgxpool.Pool, hash string) (bo
 		`SELECT EXISTS(SELECT 1 FROM urls WHERE content_hash = $1)`, hash).Scan(&exists)
 	return exists, err
 }
+
+// ContentHashExistsForOtherURL reports whether a URL other than id already
+// holds content hash, for reparsing a page that holds it itself.
+func ContentHashExistsForOtherURL(ctx context.Context, pool *pgxpool.Pool, hash, id string) (bool, error) {
+	var exists bool
+	err := pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM urls WHERE content_hash = $1 AND id <> $2)`, hash, id).Scan(&exists)
+	return exists, err
+}
+
+// ArchivedURL is a URL with stored HTML.
+type ArchivedURL struct {
+	URL           string
+	LastCrawlTime *time.Time
+}
+
+// ListArchivedURLs returns up to limit URLs of domain with stored HTML, in
+// order after the given URL, for paging through them.
+func ListArchivedURLs(ctx context.Context, pool *pgxpool.Pool, domain, after string, limit int) ([]ArchivedURL, error) {
+	rows, err := pool.Query(ctx,
+		`SELECT url, last_crawl_time FROM urls
+		 WHERE domain = $1 AND s3_html_link IS NOT NULL AND url > $2
+		 ORDER BY url
+		 LIMIT $3`, domain, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("listing archived urls: %w", err)
+	}
+	defer rows.Close()
+
+	var urls []ArchivedURL
+	for rows.Next() {
+		var u ArchivedURL
+		if err := rows.Scan(&u.URL, &u.LastCrawlTime); err != nil {
+			return nil, fmt.Errorf("scanning url: %w", err)
+		}
+		urls = append(urls, u)
+	}
+	return urls, rows.Err()
+}
+
+// URLFilter selects URLs with stored HTML. The zero filter selects them all.
+type URLFilter struct {
+	Domain   string
+	Statuses []URLStatus
+	// CrawledSince and CrawledUntil bound last_crawl_time when non-zero.
+	CrawledSince, CrawledUntil time.Time
+	MinDepth                   int
+	MaxDepth                   *int
+	Language                   string
+}
+
+// where returns the WHERE clause and arguments selecting f's URLs with ids
+// after the given one ("" for the first page).
+func (f URLFilter) where(after string) (string, []any) {
+	conds := []string{"s3_html_link IS NOT NULL"}
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if after != "" {
+		add("id > $%d", after)
+	}
+	if f.Domain != "" {
+		add("domain = $%d", f.Domain)
+	}
+	if len(f.Statuses) > 0 {
+		statuses := make([]string, len(f.Statuses))
+		for i, s := range f.Statuses {
+			statuses[i] = string(s)
+		}
+		add("status::text = ANY($%d)", statuses)
+	}
+	if !f.CrawledSince.IsZero() {
+		add("last_crawl_time >= $%d", f.CrawledSince)
+	}
+	if !f.CrawledUntil.IsZero() {
+		add("last_crawl_time < $%d", f.CrawledUntil)
+	}
+	if f.MinDepth > 0 {
+		add("depth >= $%d", f.MinDepth)
+	}
+	if f.MaxDepth != nil {
+		add("depth <= $%d", *f.MaxDepth)
+	}
+	if f.Language != "" {
+		add("language = $%d", f.Language)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// StoredPage is a URL and the HTML last stored for it.
+type StoredPage struct {
+	ID         string
+	URL        string
+	S3HTMLLink string
+	Depth      int
+}
+
+// ListStoredPages returns up to limit URLs matching f, in id order after the
+// given id, for paging through them.
+func ListStoredPages(ctx context.Context, pool *pgxpool.Pool, f URLFilter, after string, limit int) ([]StoredPage, error) {
+	where, args := f.where(after)
+	args = append(args, limit)
+	rows, err := pool.Query(ctx,
+		fmt.Sprintf(`SELECT id, url, s3_html_link, depth FROM urls
+		 WHERE %s
+		 ORDER BY id
+		 LIMIT $%d`, where, len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing stored pages: %w", err)
+	}
+	defer rows.Close()
+
+	var pages []StoredPage
+	for rows.Next() {
+		var p StoredPage
+		if err := rows.Scan(&p.ID, &p.URL, &p.S3HTMLLink, &p.Depth); err != nil {
+			return nil, fmt.Errorf("scanning url: %w", err)
+		}
+		pages = append(pages, p)
+	}
+	return pages, rows.Err()
+}
+
+// ListURLs returns up to limit URLs matching f, in id order after the given
+// id, for paging through them.
+func ListURLs(ctx context.Context, pool *pgxpool.Pool, f URLFilter, after string, limit int) ([]URLRecord, error) {
+	where, args := f.where(after)
+	args = append(args, limit)
+	rows, err := pool.Query(ctx,
+		fmt.Sprintf(`SELECT `+urlColumns+` FROM urls
+		 WHERE %s
+		 ORDER BY id
+		 LIMIT $%d`, where, len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing urls: %w", err)
+	}
+	return scanURLs(rows)
+}
+
+// ListChangedURLs returns up to limit URLs whose content changed after
+// since, oldest change first. To page through changes at the same instant,
+// afterID is the last id seen at since, or "" to start there.
+func ListChangedURLs(ctx context.Context, pool *pgxpool.Pool, since time.Time, afterID string, limit int) ([]URLRecord, error) {
+	if afterID == "" {
+		// Sorts after every id, so changes at exactly since are excluded.
+		afterID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+	}
+	rows, err := pool.Query(ctx,
+		`SELECT `+urlColumns+` FROM urls
+		 WHERE content_changed_at IS NOT NULL AND (content_changed_at, id) > ($1, $2::uuid)
+		 ORDER BY content_changed_at, id
+		 LIMIT $3`, since, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("listing changed urls: %w", err)
+	}
+	return scanURLs(rows)
+}
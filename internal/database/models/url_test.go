@@ -0,0 +1,59 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestURLFilterWhere(t *testing.T) {
+	t.Parallel()
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	maxDepth := 2
+	tests := []struct {
+		name      string
+		filter    URLFilter
+		after     string
+		wantWhere string
+		wantArgs  []any
+	}{
+		{
+			name:      "zero",
+			wantWhere: "s3_html_link IS NOT NULL",
+		},
+		{
+			name:      "after",
+			after:     "id-1",
+			wantWhere: "s3_html_link IS NOT NULL AND id > $1",
+			wantArgs:  []any{"id-1"},
+		},
+		{
+			name: "all",
+			filter: URLFilter{
+				Domain:       "example.com",
+				Statuses:     []URLStatus{StatusParsed, StatusSkipped},
+				CrawledSince: since,
+				CrawledUntil: since.AddDate(0, 1, 0),
+				MinDepth:     1,
+				MaxDepth:     &maxDepth,
+				Language:     "en",
+			},
+			after: "id-1",
+			wantWhere: "s3_html_link IS NOT NULL AND id > $1 AND domain = $2 AND status::text = ANY($3)" +
+				" AND last_crawl_time >= $4 AND last_crawl_time < $5 AND depth >= $6 AND depth <= $7 AND language = $8",
+			wantArgs: []any{"id-1", "example.com", []string{"parsed", "skipped"}, since, since.AddDate(0, 1, 0), 1, 2, "en"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			where, args := tt.filter.where(tt.after)
+			if where != tt.wantWhere {
+				t.Errorf("where = %q, want %q", where, tt.wantWhere)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// WARCCapture is the first WARC response record holding a payload.
+type WARCCapture struct {
+	PayloadDigest string
+	TargetURI     string
+	CapturedAt    time.Time
+	RecordID      string
+}
+
+// ClaimWARCCapture records c as the first capture of its payload digest. If
+// another capture was recorded first, it returns that one and true instead.
+func ClaimWARCCapture(ctx context.Context, pool *pgxpool.Pool, c WARCCapture) (WARCCapture, bool, error) {
+	first := WARCCapture{PayloadDigest: c.PayloadDigest}
+	err := pool.QueryRow(ctx,
+		`WITH claimed AS (
+			INSERT INTO warc_captures (payload_digest, target_uri, captured_at, record_id)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (payload_digest) DO NOTHING
+			RETURNING 1
+		)
+		SELECT target_uri, captured_at, record_id FROM warc_captures
+		WHERE payload_digest = $1 AND NOT EXISTS (SELECT 1 FROM claimed)`,
+		c.PayloadDigest, c.TargetURI, c.CapturedAt, c.RecordID,
+	).Scan(&first.TargetURI, &first.CapturedAt, &first.RecordID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Either our row went in, or a concurrent claim is not visible to
+		// this statement yet; a full record is correct in both cases.
+		return c, false, nil
+	}
+	if err != nil {
+		return WARCCapture{}, false, fmt.Errorf("claiming warc capture: %w", err)
+	}
+	return first, true, nil
+}
+
+// ReleaseWARCCapture deletes the capture of digest if it is still the
+// record recordID.
+func ReleaseWARCCapture(ctx context.Context, pool *pgxpool.Pool, digest, recordID string) error {
+	_, err := pool.Exec(ctx,
+		`DELETE FROM warc_captures WHERE payload_digest = $1 AND record_id = $2`, digest, recordID)
+	if err != nil {
+		return fmt.Errorf("releasing warc capture: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/theognis1002/nimbus-crawler/internal/queue"
+)
+
+// frontierStateKey records whether the queues last held work ("busy") or
+// not ("drained"), so replicas announce each drain once between them.
+const frontierStateKey = "events:frontier:state"
+
+// WatchFrontier emits FrontierDrained each time the frontier streams and the
+// parse stream run out of work after holding some, checking every interval
+// until ctx is done. Work is out when every entry has been delivered and
+// acknowledged; URLs waiting out a retry backoff are not counted.
+func WatchFrontier(ctx context.Context, rdb *redis.Client, bus *Bus, partitions int, interval time.Duration, logger *slog.Logger) {
+	groups := map[string]string{queue.ParseStream: queue.ParserGroup}
+	for _, s := range queue.FrontierStreams(partitions) {
+		groups[s] = queue.CrawlerGroup
+	}
+	streams := append(queue.FrontierStreams(partitions), queue.ParseStream)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := checkFrontier(ctx, rdb, bus, streams, groups); err != nil && ctx.Err() == nil {
+			logger.Warn("failed to check whether the frontier drained", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func checkFrontier(ctx context.Context, rdb *redis.Client, bus *Bus, streams []string, groups map[string]string) error {
+	for _, s := range streams {
+		done, err := consumed(ctx, rdb, s, groups[s])
+		if err != nil {
+			return err
+		}
+		if !done {
+			return rdb.Set(ctx, frontierStateKey, "busy", 0).Err()
+		}
+	}
+	prev, err := rdb.GetSet(ctx, frontierStateKey, "drained").Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return fmt.Errorf("recording frontier state: %w", err)
+	}
+	if prev == "busy" {
+		bus.Emit(ctx, FrontierDrained, Drained{Streams: streams})
+	}
+	return nil
+}
+
+// consumed reports whether group has read and acknowledged every entry of
+// stream.
+func consumed(ctx context.Context, rdb *redis.Client, stream, group string) (bool, error) {
+	last, err := rdb.XRevRangeN(ctx, stream, "+", "-", 1).Result()
+	if err != nil {
+		return false, fmt.Errorf("reading %s: %w", stream, err)
+	}
+	if len(last) == 0 {
+		return true, nil
+	}
+	gs, err := rdb.XInfoGroups(ctx, stream).Result()
+	if err != nil {
+		return false, fmt.Errorf("reading groups of %s: %w", stream, err)
+	}
+	for _, g := range gs {
+		if g.Name == group {
+			return g.Pending == 0 && !idBefore(g.LastDeliveredID, last[0].ID), nil
+		}
+	}
+	return false, nil
+}
+
+// idBefore reports whether stream entry ID a sorts before b.
+func idBefore(a, b string) bool {
+	am, as := splitID(a)
+	bm, bs := splitID(b)
+	return am < bm || am == bm && as < bs
+}
+
+func splitID(id string) (ms, seq uint64) {
+	m, s, _ := strings.Cut(id, "-")
+	ms, _ = strconv.ParseUint(m, 10, 64)
+	seq, _ = strconv.ParseUint(s, 10, 64)
+	return ms, seq
+}
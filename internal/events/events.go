@@ -0,0 +1,147 @@
+// Package events publishes crawl lifecycle events to a Redis stream and
+// delivers them to webhooks.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Stream holds the events, oldest first; entries carry the fields type,
+	// time (RFC 3339) and data (JSON).
+	Stream = "stream:events"
+	// seenKeyPrefix prefixes the keys EmitOnce remembers keys in, as
+	// events:seen:<type>:<key>.
+	seenKeyPrefix = "events:seen:"
+	// seenTTL is how long EmitOnce remembers a key.
+	seenTTL = 7 * 24 * time.Hour
+)
+
+// Type names a kind of event.
+type Type string
+
+const (
+	// PageParsed: a page was parsed. Data is Page.
+	PageParsed Type = "page.parsed"
+	// ContentChanged: a page parsed before was parsed again with different
+	// content. Data is Page, with PreviousHash.
+	ContentChanged Type = "page.changed"
+	// URLFailed: a URL ran out of retries and will not be crawled. Data is
+	// URLFailure.
+	URLFailed Type = "url.failed"
+	// RobotsDisallowed: a domain's robots.txt disallowed one of its URLs for
+	// the first time. Data is RobotsDisallow.
+	RobotsDisallowed Type = "robots.disallowed"
+	// FrontierDrained: the frontier and parse queues emptied after holding
+	// work. Data is Drained.
+	FrontierDrained Type = "frontier.drained"
+)
+
+// Types lists every event type.
+var Types = []Type{PageParsed, ContentChanged, URLFailed, RobotsDisallowed, FrontierDrained}
+
+// Event is one event as read back from Stream.
+type Event struct {
+	// ID is the stream entry ID, unique and increasing.
+	ID   string          `json:"id"`
+	Type Type            `json:"type"`
+	Time time.Time       `json:"time"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Page is the data of PageParsed and ContentChanged events.
+type Page struct {
+	URLID        string `json:"url_id"`
+	URL          string `json:"url"`
+	Domain       string `json:"domain"`
+	Title        string `json:"title,omitempty"`
+	ContentHash  string `json:"content_hash"`
+	PreviousHash string `json:"previous_hash,omitempty"`
+}
+
+// URLFailure is the data of URLFailed events.
+type URLFailure struct {
+	URLID   string `json:"url_id"`
+	URL     string `json:"url"`
+	Domain  string `json:"domain"`
+	Retries int    `json:"retries"`
+	// Reason is the last failure: an HTTP status class or error kind.
+	Reason string `json:"reason"`
+}
+
+// RobotsDisallow is the data of RobotsDisallowed events.
+type RobotsDisallow struct {
+	Domain string `json:"domain"`
+	URL    string `json:"url"`
+}
+
+// Drained is the data of FrontierDrained events.
+type Drained struct {
+	Streams []string `json:"streams"`
+}
+
+// Bus writes events to Stream. Failures are logged, never returned, so
+// events cannot hold up the crawl.
+type Bus struct {
+	rdb    *redis.Client
+	maxLen int64
+	logger *slog.Logger
+}
+
+// NewBus returns a bus that keeps about maxLen events.
+func NewBus(rdb *redis.Client, maxLen int64, logger *slog.Logger) *Bus {
+	return &Bus{rdb: rdb, maxLen: maxLen, logger: logger}
+}
+
+// Emit writes an event of type typ with data encoded as JSON.
+func (b *Bus) Emit(ctx context.Context, typ Type, data any) {
+	if err := b.emit(ctx, typ, data); err != nil {
+		b.logger.Warn("failed to emit event", "type", typ, "error", err)
+	}
+}
+
+// EmitOnce is Emit for the first call with typ and key only, across
+// processes. Keys are forgotten after seenTTL, so a later call emits again.
+func (b *Bus) EmitOnce(ctx context.Context, typ Type, key string, data any) {
+	added, err := b.rdb.SetNX(ctx, seenKeyPrefix+string(typ)+":"+key, 1, seenTTL).Result()
+	if err != nil {
+		b.logger.Warn("failed to emit event", "type", typ, "error", err)
+		return
+	}
+	if added {
+		b.Emit(ctx, typ, data)
+	}
+}
+
+func (b *Bus) emit(ctx context.Context, typ Type, data any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("encoding event: %w", err)
+	}
+	return b.rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: Stream,
+		MaxLen: b.maxLen,
+		Approx: true,
+		Values: []any{"type", string(typ), "time", time.Now().UTC().Format(time.RFC3339Nano), "data", body},
+	}).Err()
+}
+
+// decode reads an event from a stream entry.
+func decode(msg redis.XMessage) (Event, error) {
+	ev := Event{ID: msg.ID}
+	typ, _ := msg.Values["type"].(string)
+	ts, _ := msg.Values["time"].(string)
+	data, _ := msg.Values["data"].(string)
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if typ == "" || err != nil || !json.Valid([]byte(data)) {
+		return ev, fmt.Errorf("malformed event %s", msg.ID)
+	}
+	ev.Type, ev.Time, ev.Data = Type(typ), t, json.RawMessage(data)
+	return ev, nil
+}
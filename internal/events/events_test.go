@@ -0,0 +1,139 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/theognis1002/nimbus-crawler/internal/queue"
+)
+
+func newTestBus(t *testing.T) (*Bus, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return NewBus(rdb, 100, slog.New(slog.DiscardHandler)), rdb
+}
+
+// readEvents returns every event in Stream.
+func readEvents(t *testing.T, rdb *redis.Client) []Event {
+	t.Helper()
+	msgs, err := rdb.XRange(context.Background(), Stream, "-", "+").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]Event, len(msgs))
+	for i, msg := range msgs {
+		if out[i], err = decode(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return out
+}
+
+func TestEmit(t *testing.T) {
+	t.Parallel()
+	bus, rdb := newTestBus(t)
+	ctx := context.Background()
+
+	page := Page{URLID: "1", URL: "https://example.com/", Domain: "example.com", ContentHash: "h2", PreviousHash: "h1"}
+	bus.Emit(ctx, ContentChanged, page)
+	for range 2 {
+		bus.EmitOnce(ctx, RobotsDisallowed, "example.com", RobotsDisallow{Domain: "example.com", URL: "https://example.com/a"})
+	}
+	bus.EmitOnce(ctx, RobotsDisallowed, "example.org", RobotsDisallow{Domain: "example.org", URL: "https://example.org/"})
+
+	evs := readEvents(t, rdb)
+	if len(evs) != 3 {
+		t.Fatalf("got %d events, want 3", len(evs))
+	}
+	want := []Type{ContentChanged, RobotsDisallowed, RobotsDisallowed}
+	for i, ev := range evs {
+		if ev.Type != want[i] {
+			t.Errorf("event %d: type = %s, want %s", i, ev.Type, want[i])
+		}
+		if ev.ID == "" || ev.Time.IsZero() {
+			t.Errorf("event %d: missing id or time: %+v", i, ev)
+		}
+	}
+	var got Page
+	if err := json.Unmarshal(evs[0].Data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != page {
+		t.Errorf("data = %+v, want %+v", got, page)
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		values map[string]any
+	}{
+		{"no type", map[string]any{"time": "2026-01-02T03:04:05Z", "data": "{}"}},
+		{"bad time", map[string]any{"type": "page.parsed", "time": "yesterday", "data": "{}"}},
+		{"bad data", map[string]any{"type": "page.parsed", "time": "2026-01-02T03:04:05Z", "data": "{"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := decode(redis.XMessage{ID: "1-0", Values: tt.values}); err == nil {
+				t.Error("decode succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestCheckFrontier(t *testing.T) {
+	t.Parallel()
+	bus, rdb := newTestBus(t)
+	ctx := context.Background()
+	stream := queue.FrontierStreams(1)[0]
+	streams := []string{stream}
+	groups := map[string]string{stream: queue.CrawlerGroup}
+
+	check := func(wantEvents int) {
+		t.Helper()
+		if err := checkFrontier(ctx, rdb, bus, streams, groups); err != nil {
+			t.Fatal(err)
+		}
+		if n := len(readEvents(t, rdb)); n != wantEvents {
+			t.Fatalf("got %d events, want %d", n, wantEvents)
+		}
+	}
+
+	// Nothing was ever queued: not a drain.
+	check(0)
+
+	if err := rdb.XGroupCreateMkStream(ctx, stream, queue.CrawlerGroup, "$").Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rdb.XAdd(ctx, &redis.XAddArgs{Stream: stream, Values: []any{"url", "https://example.com/"}}).Err(); err != nil {
+		t.Fatal(err)
+	}
+	check(0)
+
+	read, err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{Group: queue.CrawlerGroup, Consumer: "c", Streams: []string{stream, ">"}}).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Delivered but not yet acknowledged: still busy.
+	check(0)
+
+	if err := rdb.XAck(ctx, stream, queue.CrawlerGroup, read[0].Messages[0].ID).Err(); err != nil {
+		t.Fatal(err)
+	}
+	check(1)
+	// The drain is announced once.
+	check(1)
+
+	evs := readEvents(t, rdb)
+	if evs[0].Type != FrontierDrained {
+		t.Errorf("type = %s, want %s", evs[0].Type, FrontierDrained)
+	}
+}
@@ -0,0 +1,347 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+)
+
+const (
+	// DeadLetterStream holds the deliveries that failed for good, with
+	// fields endpoint, error and event (the Event as JSON).
+	DeadLetterStream = "stream:events:dlq"
+	// groupPrefix prefixes each endpoint's consumer group on Stream.
+	groupPrefix = "webhook:"
+
+	// deadLetterMaxLen caps DeadLetterStream, dropping the oldest entries.
+	deadLetterMaxLen int64 = 100000
+
+	readCount     = 50
+	readBlock     = 5 * time.Second
+	maxBackoff    = 5 * time.Minute
+	maxErrorBody  = 1 << 10
+	claimInterval = 10 * time.Second
+	// claimMargin is added to the longest a delivery can take to get the
+	// idle time after which a pending entry is taken to be abandoned.
+	claimMargin = time.Minute
+)
+
+// Headers of webhook deliveries.
+const (
+	HeaderEvent     = "X-Nimbus-Event"
+	HeaderDelivery  = "X-Nimbus-Delivery"
+	HeaderSignature = "X-Nimbus-Signature"
+)
+
+// Dispatcher delivers events from Stream to webhook endpoints. Each
+// endpoint reads through a consumer group of its own, so a slow or failing
+// endpoint holds back only itself; replicas share the groups. Entries left
+// pending by a dispatcher that stopped or crashed are claimed by another
+// once they have been idle for longer than any delivery can take.
+type Dispatcher struct {
+	rdb       *redis.Client
+	cfg       config.WebhooksConfig
+	consumer  string
+	client    *http.Client
+	claimIdle time.Duration
+	logger    *slog.Logger
+}
+
+// NewDispatcher returns a dispatcher that reads as consumer.
+func NewDispatcher(rdb *redis.Client, cfg config.WebhooksConfig, consumer string, logger *slog.Logger) *Dispatcher {
+	return &Dispatcher{
+		rdb:       rdb,
+		cfg:       cfg,
+		consumer:  consumer,
+		client:    &http.Client{Timeout: time.Duration(cfg.TimeoutSecs) * time.Second},
+		claimIdle: maxDeliveryTime(cfg) + claimMargin,
+		logger:    logger,
+	}
+}
+
+// maxDeliveryTime is the longest deliver can take with cfg: every attempt
+// timing out, with the backoffs between them.
+func maxDeliveryTime(cfg config.WebhooksConfig) time.Duration {
+	timeout := time.Duration(cfg.TimeoutSecs) * time.Second
+	wait := time.Duration(cfg.RetryBackoffMs) * time.Millisecond
+	total := timeout
+	for range cfg.MaxRetries {
+		total += wait + timeout
+		wait = min(wait*2, maxBackoff)
+	}
+	return total
+}
+
+// Run delivers events until ctx is done. Endpoints new to the stream start
+// with the events emitted after they first run.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	for _, ep := range d.cfg.Endpoints {
+		if ep.Name == "" || ep.URL == "" {
+			return fmt.Errorf("webhook endpoint %q needs a name and a url", ep.Name)
+		}
+		err := d.rdb.XGroupCreateMkStream(ctx, Stream, groupPrefix+ep.Name, "$").Err()
+		if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
+			return fmt.Errorf("creating consumer group for %s: %w", ep.Name, err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, ep := range d.cfg.Endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.serve(ctx, ep)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// serve delivers events to ep: new ones, and every claimInterval those
+// abandoned by other dispatchers.
+func (d *Dispatcher) serve(ctx context.Context, ep config.WebhookEndpoint) {
+	logger := d.logger.With("endpoint", ep.Name)
+	group := groupPrefix + ep.Name
+	var lastClaim time.Time
+	for ctx.Err() == nil {
+		if time.Since(lastClaim) >= claimInterval {
+			lastClaim = time.Now()
+			if !d.claimAbandoned(ctx, logger, ep) {
+				return
+			}
+		}
+		streams, err := d.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    group,
+			Consumer: d.consumer,
+			Streams:  []string{Stream, ">"},
+			Count:    readCount,
+			Block:    readBlock,
+		}).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			if ctx.Err() == nil {
+				logger.Warn("failed to read events", "error", err)
+				sleep(ctx, time.Second)
+			}
+			continue
+		}
+		var msgs []redis.XMessage
+		if len(streams) > 0 {
+			msgs = streams[0].Messages
+		}
+		if !d.handleAll(ctx, logger, ep, msgs) {
+			return
+		}
+	}
+}
+
+// claimAbandoned takes over and delivers the entries of ep's group that
+// have been pending for longer than claimIdle. It returns false if ctx
+// ended first.
+func (d *Dispatcher) claimAbandoned(ctx context.Context, logger *slog.Logger, ep config.WebhookEndpoint) bool {
+	start := "0-0"
+	for {
+		msgs, next, err := d.rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   Stream,
+			Group:    groupPrefix + ep.Name,
+			Consumer: d.consumer,
+			MinIdle:  d.claimIdle,
+			Start:    start,
+			Count:    readCount,
+		}).Result()
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Warn("failed to claim abandoned events", "error", err)
+			}
+			return ctx.Err() == nil
+		}
+		if len(msgs) > 0 {
+			logger.Info("claimed abandoned events", "count", len(msgs))
+		}
+		if !d.handleAll(ctx, logger, ep, msgs) {
+			return false
+		}
+		if next == "0-0" || len(msgs) == 0 {
+			return true
+		}
+		start = next
+	}
+}
+
+// handleAll handles and acks msgs in order. Each entry is claimed afresh
+// first, resetting its idle time, so entries waiting behind slow
+// deliveries are not taken to be abandoned. It returns false if ctx ended
+// first.
+func (d *Dispatcher) handleAll(ctx context.Context, logger *slog.Logger, ep config.WebhookEndpoint, msgs []redis.XMessage) bool {
+	group := groupPrefix + ep.Name
+	for _, msg := range msgs {
+		ids, err := d.rdb.XClaimJustID(ctx, &redis.XClaimArgs{
+			Stream:   Stream,
+			Group:    group,
+			Consumer: d.consumer,
+			Messages: []string{msg.ID},
+		}).Result()
+		if err != nil {
+			if ctx.Err() != nil {
+				return false
+			}
+			logger.Warn("failed to refresh pending event", "id", msg.ID, "error", err)
+		} else if len(ids) == 0 {
+			// Acked meanwhile by a dispatcher that claimed it.
+			continue
+		}
+		if !d.handle(ctx, logger, ep, msg) {
+			return false
+		}
+		if err := d.rdb.XAck(ctx, Stream, group, msg.ID).Err(); err != nil {
+			logger.Warn("failed to ack event", "id", msg.ID, "error", err)
+		}
+	}
+	return true
+}
+
+// handle delivers one entry to ep if it wants it, dead-lettering it if
+// delivery fails for good. It returns false if ctx ended first, leaving the
+// entry pending.
+func (d *Dispatcher) handle(ctx context.Context, logger *slog.Logger, ep config.WebhookEndpoint, msg redis.XMessage) bool {
+	ev, err := decode(msg)
+	if err != nil {
+		logger.Warn("skipping malformed event", "error", err)
+		return true
+	}
+	if len(ep.Events) > 0 && !slices.Contains(ep.Events, string(ev.Type)) {
+		return true
+	}
+	err = d.deliver(ctx, ep, ev)
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		logger.Error("webhook delivery failed, dead-lettering", "id", ev.ID, "type", ev.Type, "error", err)
+		body, _ := json.Marshal(ev)
+		if err := d.rdb.XAdd(ctx, &redis.XAddArgs{
+			Stream: DeadLetterStream,
+			MaxLen: deadLetterMaxLen,
+			Approx: true,
+			Values: []any{"endpoint", ep.Name, "error", err.Error(), "event", body},
+		}).Err(); err != nil {
+			logger.Error("failed to dead-letter event", "id", ev.ID, "error", err)
+		}
+	}
+	return true
+}
+
+// deliver posts ev to ep, retrying with exponential backoff while the
+// failure may pass.
+func (d *Dispatcher) deliver(ctx context.Context, ep config.WebhookEndpoint, ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("encoding event: %w", err)
+	}
+	wait := time.Duration(d.cfg.RetryBackoffMs) * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		retry, err := d.post(ctx, ep, ev, body)
+		if err == nil || !retry || attempt == d.cfg.MaxRetries {
+			return err
+		}
+		d.logger.Warn("webhook delivery failed, retrying", "endpoint", ep.Name, "id", ev.ID, "attempt", attempt+1, "error", err)
+		if !sleep(ctx, wait) {
+			return ctx.Err()
+		}
+		wait = min(wait*2, maxBackoff)
+	}
+}
+
+// post makes one delivery attempt and reports whether a failure is worth
+// retrying.
+func (d *Dispatcher) post(ctx context.Context, ep config.WebhookEndpoint, ev Event, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(ev.Type))
+	req.Header.Set(HeaderDelivery, ev.ID)
+	if ep.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(ep.Secret, time.Now(), body))
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	err = fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	// Other client errors mean the endpoint will not take this event.
+	retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return retry, err
+}
+
+// Sign returns the signature header of a delivery of body at t:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>" keyed by secret>".
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + signature(secret, ts, body)
+}
+
+// Verify checks a signature header made by Sign, rejecting signatures older
+// than tolerance so captured deliveries cannot be replayed later.
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var ts, sig string
+	for part := range strings.SplitSeq(header, ",") {
+		k, v, _ := strings.Cut(part, "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return errors.New("malformed signature")
+	}
+	if age := time.Since(time.Unix(sec, 0)); age > tolerance || age < -tolerance {
+		return errors.New("signature expired")
+	}
+	if !hmac.Equal([]byte(sig), []byte(signature(secret, ts, body))) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+func signature(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// sleep waits for d or until ctx is done, reporting whether it waited.
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,200 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+)
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+	body := []byte(`{"id":"1-0"}`)
+	now := time.Now()
+	tests := []struct {
+		name    string
+		secret  string
+		header  string
+		body    []byte
+		wantErr bool
+	}{
+		{"valid", "s3cret", Sign("s3cret", now, body), body, false},
+		{"wrong secret", "other", Sign("s3cret", now, body), body, true},
+		{"altered body", "s3cret", Sign("s3cret", now, body), []byte(`{"id":"2-0"}`), true},
+		{"expired", "s3cret", Sign("s3cret", now.Add(-time.Hour), body), body, true},
+		{"malformed", "s3cret", "v1=abc", body, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := Verify(tt.secret, tt.header, tt.body, 5*time.Minute)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// receiver records the events delivered to it, failing the first fail
+// deliveries with status.
+type receiver struct {
+	t      *testing.T
+	secret string
+	status int
+	fail   int
+
+	mu       sync.Mutex
+	attempts int
+	got      []Type
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if err := Verify(rc.secret, r.Header.Get(HeaderSignature), body, time.Minute); err != nil {
+		rc.t.Errorf("bad signature: %v", err)
+	}
+	var ev Event
+	if err := json.Unmarshal(body, &ev); err != nil {
+		rc.t.Errorf("decoding delivery: %v", err)
+	}
+	if got := r.Header.Get(HeaderEvent); got != string(ev.Type) {
+		rc.t.Errorf("%s = %q, want %q", HeaderEvent, got, ev.Type)
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.attempts++
+	if rc.attempts <= rc.fail {
+		w.WriteHeader(rc.status)
+		return
+	}
+	rc.got = append(rc.got, ev.Type)
+}
+
+func (rc *receiver) delivered() []Type {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]Type(nil), rc.got...)
+}
+
+func TestDispatcher(t *testing.T) {
+	t.Parallel()
+	bus, rdb := newTestBus(t)
+
+	flaky := &receiver{t: t, secret: "a", status: http.StatusServiceUnavailable, fail: 1}
+	gone := &receiver{t: t, secret: "b", status: http.StatusGone, fail: 100}
+	flakySrv := httptest.NewServer(flaky)
+	defer flakySrv.Close()
+	goneSrv := httptest.NewServer(gone)
+	defer goneSrv.Close()
+
+	cfg := config.WebhooksConfig{
+		MaxRetries:     3,
+		RetryBackoffMs: 1,
+		TimeoutSecs:    5,
+		Endpoints: []config.WebhookEndpoint{
+			{Name: "flaky", URL: flakySrv.URL, Secret: "a"},
+			{Name: "gone", URL: goneSrv.URL, Secret: "b", Events: []string{string(URLFailed)}},
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- NewDispatcher(rdb, cfg, "test", slog.New(slog.DiscardHandler)).Run(ctx)
+	}()
+
+	// Endpoints see only the events emitted after their groups exist.
+	waitFor(t, func() bool {
+		gs, err := rdb.XInfoGroups(ctx, Stream).Result()
+		return err == nil && len(gs) == 2
+	})
+	bus.Emit(ctx, PageParsed, Page{URLID: "1"})
+	bus.Emit(ctx, URLFailed, URLFailure{URLID: "2"})
+
+	waitFor(t, func() bool {
+		n, err := rdb.XLen(ctx, DeadLetterStream).Result()
+		return len(flaky.delivered()) == 2 && err == nil && n == 1
+	})
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if got := flaky.delivered(); got[0] != PageParsed || got[1] != URLFailed {
+		t.Errorf("flaky endpoint got %v, want [%s %s]", got, PageParsed, URLFailed)
+	}
+	// A 410 is not retried, and the filtered-out page.parsed is never sent.
+	if gone.attempts != 1 {
+		t.Errorf("gone endpoint got %d attempts, want 1", gone.attempts)
+	}
+	dlq, err := rdb.XRange(context.Background(), DeadLetterStream, "-", "+").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ep := dlq[0].Values["endpoint"]; ep != "gone" {
+		t.Errorf("dead letter endpoint = %v, want gone", ep)
+	}
+}
+
+func TestDispatcherClaimsAbandoned(t *testing.T) {
+	t.Parallel()
+	bus, rdb := newTestBus(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// A dispatcher read the event and crashed before delivering it.
+	if err := rdb.XGroupCreateMkStream(ctx, Stream, groupPrefix+"ep", "$").Err(); err != nil {
+		t.Fatal(err)
+	}
+	bus.Emit(ctx, PageParsed, Page{URLID: "1"})
+	if err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    groupPrefix + "ep",
+		Consumer: "crashed",
+		Streams:  []string{Stream, ">"},
+	}).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	rc := &receiver{t: t, secret: "s"}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+	cfg := config.WebhooksConfig{
+		MaxRetries:     1,
+		RetryBackoffMs: 1,
+		TimeoutSecs:    5,
+		Endpoints:      []config.WebhookEndpoint{{Name: "ep", URL: srv.URL, Secret: "s"}},
+	}
+	d := NewDispatcher(rdb, cfg, "next", slog.New(slog.DiscardHandler))
+	d.claimIdle = time.Millisecond
+	time.Sleep(5 * time.Millisecond)
+	done := make(chan error, 1)
+	go func() { done <- d.Run(ctx) }()
+
+	waitFor(t, func() bool {
+		n, err := rdb.XPending(ctx, Stream, groupPrefix+"ep").Result()
+		return len(rc.delivered()) == 1 && err == nil && n.Count == 0
+	})
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
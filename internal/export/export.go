@@ -0,0 +1,295 @@
+// Package export writes selected pages to sharded dataset files.
+//
+// An export is deterministic: pages are taken in id order and shard n
+// always holds the n-th run of ShardRecords matching pages, so the same
+// options over the same data produce the same files. A manifest written
+// after each shard records the shards done, and a rerun with the same
+// options resumes after the last of them.
+package export
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/theognis1002/nimbus-crawler/internal/database/models"
+)
+
+const (
+	// ManifestName is the name of an export's manifest file.
+	ManifestName = "manifest.json"
+	// partialSuffix marks a file still being written.
+	partialSuffix = ".partial"
+
+	defaultShardRecords = 100000
+	defaultBatchSize    = 500
+)
+
+// Options selects what an export holds and how it is laid out.
+type Options struct {
+	Format Format `json:"format"`
+	// ShardRecords is the number of records per shard; the last shard may
+	// hold fewer.
+	ShardRecords int              `json:"shard_records"`
+	Filter       models.URLFilter `json:"filter"`
+	// MinTextBytes skips pages with less text than this.
+	MinTextBytes int `json:"min_text_bytes"`
+}
+
+// Manifest describes an export and the shards written so far.
+type Manifest struct {
+	Options  Options `json:"options"`
+	Shards   []Shard `json:"shards"`
+	Complete bool    `json:"complete"`
+}
+
+// Shard describes one complete shard.
+type Shard struct {
+	Name    string `json:"name"`
+	Records int    `json:"records"`
+	Bytes   int64  `json:"bytes"`
+	SHA256  string `json:"sha256"`
+	// LastID is the id of the shard's last page; the next shard starts
+	// after it.
+	LastID string `json:"last_id"`
+}
+
+// Source lists the pages to export.
+type Source interface {
+	// Records returns up to limit pages matching the filter with ids after
+	// the given one ("" for the first), in id order, with their text.
+	Records(ctx context.Context, after string, limit int) ([]Record, error)
+}
+
+// Exporter writes an export from a source to a target.
+type Exporter struct {
+	src    Source
+	target Target
+	opts   Options
+	batch  int
+	logger *slog.Logger
+}
+
+// NewExporter returns an exporter reading batchSize pages at a time.
+func NewExporter(src Source, target Target, opts Options, batchSize int, logger *slog.Logger) *Exporter {
+	if opts.ShardRecords <= 0 {
+		opts.ShardRecords = defaultShardRecords
+	}
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	return &Exporter{src: src, target: target, opts: opts, batch: batchSize, logger: logger}
+}
+
+// Run writes the shards not yet in the target's manifest and returns the
+// final manifest. It fails if the manifest was written with other options.
+func (e *Exporter) Run(ctx context.Context) (*Manifest, error) {
+	m, err := e.loadManifest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if m.Complete {
+		e.logger.Info("export already complete", "shards", len(m.Shards))
+		return m, nil
+	}
+	var after string
+	if n := len(m.Shards); n > 0 {
+		after = m.Shards[n-1].LastID
+		e.logger.Info("resuming export", "shards_done", n, "after", after)
+	}
+
+	var (
+		shard   *shardFile
+		pending []Record
+	)
+	defer func() {
+		if shard != nil {
+			shard.abandon()
+		}
+	}()
+	for {
+		if len(pending) == 0 {
+			recs, err := e.src.Records(ctx, after, e.batch)
+			if err != nil {
+				return nil, err
+			}
+			if len(recs) == 0 {
+				break
+			}
+			after = recs[len(recs)-1].ID
+			for _, r := range recs {
+				if len(r.Text) >= e.opts.MinTextBytes {
+					pending = append(pending, r)
+				}
+			}
+			continue
+		}
+
+		if shard == nil {
+			name := fmt.Sprintf("part-%05d%s", len(m.Shards), e.opts.Format.Ext())
+			if shard, err = e.createShard(name); err != nil {
+				return nil, err
+			}
+		}
+		r := pending[0]
+		pending = pending[1:]
+		if err := shard.write(r); err != nil {
+			return nil, err
+		}
+		if shard.records == e.opts.ShardRecords {
+			if err := e.commit(ctx, m, shard); err != nil {
+				return nil, err
+			}
+			shard = nil
+		}
+	}
+	if shard != nil {
+		if err := e.commit(ctx, m, shard); err != nil {
+			return nil, err
+		}
+		shard = nil
+	}
+
+	m.Complete = true
+	if err := e.saveManifest(ctx, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// loadManifest returns the target's manifest, or a new one if there is none.
+func (e *Exporter) loadManifest(ctx context.Context) (*Manifest, error) {
+	data, err := e.target.ReadFile(ctx, ManifestName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Manifest{Options: e.opts}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading export manifest: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("decoding export manifest: %w", err)
+	}
+	want, _ := json.Marshal(e.opts)
+	got, _ := json.Marshal(m.Options)
+	if !bytes.Equal(want, got) {
+		return nil, fmt.Errorf("export target holds an export with other options (%s); use a new target", got)
+	}
+	return &m, nil
+}
+
+func (e *Exporter) saveManifest(ctx context.Context, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding export manifest: %w", err)
+	}
+	if err := e.target.WriteFile(ctx, ManifestName, data); err != nil {
+		return fmt.Errorf("writing export manifest: %w", err)
+	}
+	return nil
+}
+
+// commit completes a shard, moves it into the target and records it in the
+// manifest.
+func (e *Exporter) commit(ctx context.Context, m *Manifest, s *shardFile) error {
+	info, err := s.finish()
+	if err != nil {
+		return err
+	}
+	if err := e.target.Commit(ctx, info.Name, s.path); err != nil {
+		return fmt.Errorf("committing %s: %w", info.Name, err)
+	}
+	m.Shards = append(m.Shards, info)
+	if err := e.saveManifest(ctx, m); err != nil {
+		return err
+	}
+	e.logger.Info("export shard written", "name", info.Name, "records", info.Records, "bytes", info.Bytes)
+	return nil
+}
+
+// shardFile is a shard being written to the staging directory.
+type shardFile struct {
+	name    string
+	path    string
+	file    *os.File
+	hash    hash.Hash
+	bytes   *countingWriter
+	rw      recordWriter
+	records int
+	lastID  string
+}
+
+func (e *Exporter) createShard(name string) (*shardFile, error) {
+	p := filepath.Join(e.target.StageDir(), name+partialSuffix)
+	f, err := os.Create(p)
+	if err != nil {
+		return nil, fmt.Errorf("creating %s: %w", name, err)
+	}
+	h := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(f, h)}
+	rw, err := newRecordWriter(e.opts.Format, counter)
+	if err != nil {
+		f.Close()
+		os.Remove(p)
+		return nil, err
+	}
+	return &shardFile{name: name, path: p, file: f, hash: h, bytes: counter, rw: rw}, nil
+}
+
+func (s *shardFile) write(r Record) error {
+	if err := s.rw.Write(r); err != nil {
+		return fmt.Errorf("writing %s: %w", s.name, err)
+	}
+	s.records++
+	s.lastID = r.ID
+	return nil
+}
+
+func (s *shardFile) finish() (Shard, error) {
+	err := s.rw.Close()
+	if err == nil {
+		err = s.file.Sync()
+	}
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(s.path)
+		return Shard{}, fmt.Errorf("closing %s: %w", s.name, err)
+	}
+	return Shard{
+		Name:    s.name,
+		Records: s.records,
+		Bytes:   s.bytes.n,
+		SHA256:  hex.EncodeToString(s.hash.Sum(nil)),
+		LastID:  s.lastID,
+	}, nil
+}
+
+// abandon removes a shard that will not be completed.
+func (s *shardFile) abandon() {
+	s.file.Close()
+	os.Remove(s.path)
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
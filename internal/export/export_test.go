@@ -0,0 +1,238 @@
+package export
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theognis1002/nimbus-crawler/internal/storage"
+)
+
+// fakeSource serves records in id order, failing once failAfter records
+// have been served when it is positive.
+type fakeSource struct {
+	recs      []Record
+	served    int
+	failAfter int
+}
+
+func (s *fakeSource) Records(ctx context.Context, after string, limit int) ([]Record, error) {
+	var out []Record
+	for _, r := range s.recs {
+		if r.ID > after && len(out) < limit {
+			out = append(out, r)
+		}
+	}
+	if s.failAfter > 0 && s.served+len(out) > s.failAfter {
+		return nil, errors.New("source failed")
+	}
+	s.served += len(out)
+	return out, nil
+}
+
+func testRecords(n int) []Record {
+	fetched := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	recs := make([]Record, n)
+	for i := range recs {
+		recs[i] = Record{
+			ID:          fmt.Sprintf("%08d-0000-0000-0000-000000000000", i),
+			URL:         fmt.Sprintf("https://example.com/%d", i),
+			FinalURL:    fmt.Sprintf("https://www.example.com/%d", i),
+			Title:       fmt.Sprintf("Page %d", i),
+			Text:        strings.Repeat("x", i%4*10),
+			FetchedAt:   &fetched,
+			ContentHash: fmt.Sprintf("%064d", i),
+		}
+	}
+	return recs
+}
+
+func runExport(t *testing.T, src Source, target Target, opts Options) (*Manifest, error) {
+	t.Helper()
+	return NewExporter(src, target, opts, 3, slog.New(slog.DiscardHandler)).Run(context.Background())
+}
+
+func readJSONL(t *testing.T, path string) []Record {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recs []Record
+	sc := bufio.NewScanner(gz)
+	for sc.Scan() {
+		var r Record
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, r)
+	}
+	return recs
+}
+
+func TestExport(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	target, err := NewDirTarget(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Records 0, 4 and 8 have no text and are skipped.
+	m, err := runExport(t, &fakeSource{recs: testRecords(10)}, target, Options{Format: FormatJSONL, ShardRecords: 3, MinTextBytes: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Complete || len(m.Shards) != 3 {
+		t.Fatalf("manifest = %+v, want 3 shards, complete", m)
+	}
+
+	var got []string
+	for i, s := range m.Shards {
+		if want := fmt.Sprintf("part-%05d.jsonl.gz", i); s.Name != want {
+			t.Errorf("shard %d name = %s, want %s", i, s.Name, want)
+		}
+		recs := readJSONL(t, filepath.Join(dir, s.Name))
+		if len(recs) != s.Records {
+			t.Errorf("%s holds %d records, manifest says %d", s.Name, len(recs), s.Records)
+		}
+		for _, r := range recs {
+			got = append(got, r.URL)
+		}
+		if last := recs[len(recs)-1].ID; last != s.LastID {
+			t.Errorf("%s last id = %s, manifest says %s", s.Name, last, s.LastID)
+		}
+	}
+	var want []string
+	for _, i := range []int{1, 2, 3, 5, 6, 7, 9} {
+		want = append(want, fmt.Sprintf("https://example.com/%d", i))
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("exported %v, want %v", got, want)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), partialSuffix) {
+			t.Errorf("left partial file %s", e.Name())
+		}
+	}
+}
+
+func TestExportResume(t *testing.T) {
+	t.Parallel()
+	opts := Options{Format: FormatCSV, ShardRecords: 4}
+	recs := testRecords(10)
+
+	// An uninterrupted export to compare against.
+	fresh, err := NewDirTarget(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := runExport(t, &fakeSource{recs: recs}, fresh, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	target, err := NewDirTarget(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runExport(t, &fakeSource{recs: recs, failAfter: 7}, target, opts); err == nil {
+		t.Fatal("interrupted export succeeded")
+	}
+	var m Manifest
+	data, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Complete || len(m.Shards) != 1 {
+		t.Fatalf("manifest after failure = %+v, want 1 shard, incomplete", m)
+	}
+
+	got, err := runExport(t, &fakeSource{recs: recs}, target, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Shards) != len(want.Shards) {
+		t.Fatalf("resumed export has %d shards, want %d", len(got.Shards), len(want.Shards))
+	}
+	for i := range want.Shards {
+		if got.Shards[i] != want.Shards[i] {
+			t.Errorf("shard %d = %+v, want %+v", i, got.Shards[i], want.Shards[i])
+		}
+	}
+
+	f, err := os.Open(filepath.Join(dir, "part-00000.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 5 || strings.Join(rows[0], ",") != strings.Join(columns, ",") {
+		t.Fatalf("csv rows = %v", rows)
+	}
+	if rows[1][2] != "https://www.example.com/0" || rows[1][5] != "2026-03-04T05:06:07Z" {
+		t.Errorf("first row = %v", rows[1])
+	}
+
+	// Options are part of the export; another run must not mix them in.
+	opts.ShardRecords = 5
+	if _, err := runExport(t, &fakeSource{recs: recs}, target, opts); err == nil {
+		t.Error("export with other options succeeded")
+	}
+}
+
+func TestStoreTarget(t *testing.T) {
+	t.Parallel()
+	store := storage.NewMemoryStore()
+	stage := t.TempDir()
+	target, err := NewStoreTarget(store, "exports", "run1", stage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := runExport(t, &fakeSource{recs: testRecords(5)}, target, Options{Format: FormatParquet, ShardRecords: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Shards) != 3 {
+		t.Fatalf("got %d shards, want 3", len(m.Shards))
+	}
+	for _, s := range m.Shards {
+		info, err := store.Stat(context.Background(), "exports", "run1/"+s.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size != s.Bytes {
+			t.Errorf("%s is %d bytes, manifest says %d", s.Name, info.Size, s.Bytes)
+		}
+	}
+	if _, err := target.ReadFile(context.Background(), ManifestName); err != nil {
+		t.Error(err)
+	}
+	if entries, _ := os.ReadDir(stage); len(entries) != 0 {
+		t.Errorf("staging dir holds %d files, want none", len(entries))
+	}
+}
@@ -0,0 +1,109 @@
+package export
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Format is the file format of an export's shards.
+type Format string
+
+const (
+	// FormatJSONL writes gzipped JSON lines, one object per record.
+	FormatJSONL Format = "jsonl"
+	// FormatCSV writes CSV with a header row.
+	FormatCSV Format = "csv"
+	// FormatParquet writes Parquet with snappy-compressed pages.
+	FormatParquet Format = "parquet"
+)
+
+// ParseFormat returns the format named s.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatJSONL, FormatCSV, FormatParquet:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown export format %q (want jsonl, csv or parquet)", s)
+}
+
+// Ext returns the file extension of f's shards.
+func (f Format) Ext() string {
+	switch f {
+	case FormatJSONL:
+		return ".jsonl.gz"
+	case FormatCSV:
+		return ".csv"
+	default:
+		return "." + string(f)
+	}
+}
+
+// Record is one exported page.
+type Record struct {
+	ID       string `json:"id"`
+	URL      string `json:"url"`
+	FinalURL string `json:"final_url"`
+	Title    string `json:"title"`
+	Text     string `json:"text"`
+	// FetchedAt is when the page was last fetched, nil if never.
+	FetchedAt   *time.Time `json:"fetch_time"`
+	ContentHash string     `json:"content_hash"`
+}
+
+// columns names the fields of a Record, in order, for CSV and Parquet.
+var columns = []string{"id", "url", "final_url", "title", "text", "fetch_time", "content_hash"}
+
+// recordWriter writes the records of one shard. Close completes the shard
+// without closing the underlying writer.
+type recordWriter interface {
+	Write(r Record) error
+	Close() error
+}
+
+func newRecordWriter(f Format, w io.Writer) (recordWriter, error) {
+	switch f {
+	case FormatJSONL:
+		gz := gzip.NewWriter(w)
+		return &jsonlWriter{gz: gz, enc: json.NewEncoder(gz)}, nil
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(columns); err != nil {
+			return nil, err
+		}
+		return &csvWriter{w: cw}, nil
+	case FormatParquet:
+		return newParquetWriter(w)
+	}
+	return nil, fmt.Errorf("unknown export format %q", f)
+}
+
+type jsonlWriter struct {
+	gz  *gzip.Writer
+	enc *json.Encoder
+}
+
+func (w *jsonlWriter) Write(r Record) error { return w.enc.Encode(r) }
+
+func (w *jsonlWriter) Close() error { return w.gz.Close() }
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (w *csvWriter) Write(r Record) error {
+	var fetched string
+	if r.FetchedAt != nil {
+		fetched = r.FetchedAt.UTC().Format(time.RFC3339Nano)
+	}
+	return w.w.Write([]string{r.ID, r.URL, r.FinalURL, r.Title, r.Text, fetched, r.ContentHash})
+}
+
+func (w *csvWriter) Close() error {
+	w.w.Flush()
+	return w.w.Error()
+}
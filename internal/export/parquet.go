@@ -0,0 +1,65 @@
+package export
+
+import (
+	"io"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// rowGroupBytes is roughly how much record data is buffered before a row
+// group is written out.
+const rowGroupBytes = 64 << 20
+
+// parquetRow is the Parquet schema of a Record: UTF-8 strings and an optional
+// millisecond timestamp, in the order of columns.
+type parquetRow struct {
+	ID          string     `parquet:"id"`
+	URL         string     `parquet:"url"`
+	FinalURL    string     `parquet:"final_url"`
+	Title       string     `parquet:"title"`
+	Text        string     `parquet:"text"`
+	FetchTime   *time.Time `parquet:"fetch_time,optional,timestamp(millisecond)"`
+	ContentHash string     `parquet:"content_hash"`
+}
+
+// parquetWriter writes records as a snappy-compressed Parquet file.
+type parquetWriter struct {
+	w        *parquet.GenericWriter[parquetRow]
+	buffered int
+}
+
+func newParquetWriter(w io.Writer) (*parquetWriter, error) {
+	return &parquetWriter{
+		w: parquet.NewGenericWriter[parquetRow](w, parquet.Compression(&parquet.Snappy)),
+	}, nil
+}
+
+func (pw *parquetWriter) Write(r Record) error {
+	row := parquetRow{
+		ID:          r.ID,
+		URL:         r.URL,
+		FinalURL:    r.FinalURL,
+		Title:       r.Title,
+		Text:        r.Text,
+		ContentHash: r.ContentHash,
+	}
+	if r.FetchedAt != nil {
+		t := r.FetchedAt.UTC()
+		row.FetchTime = &t
+	}
+	if _, err := pw.w.Write([]parquetRow{row}); err != nil {
+		return err
+	}
+	pw.buffered += len(r.ID) + len(r.URL) + len(r.FinalURL) + len(r.Title) + len(r.Text) + len(r.ContentHash) + 8
+	if pw.buffered >= rowGroupBytes {
+		pw.buffered = 0
+		return pw.w.Flush()
+	}
+	return nil
+}
+
+// Close writes the last row group and the footer.
+func (pw *parquetWriter) Close() error {
+	return pw.w.Close()
+}
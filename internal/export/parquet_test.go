@@ -0,0 +1,70 @@
+package export
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestParquetWriter(t *testing.T) {
+	t.Parallel()
+	fetched := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	recs := []Record{
+		{ID: "a", URL: "https://example.com/", FinalURL: "https://example.com/", Title: "Home", Text: "héllo", FetchedAt: &fetched, ContentHash: "h1"},
+		{ID: "b", URL: "https://example.com/b", FinalURL: "https://example.com/b", ContentHash: "h2"},
+		{ID: "c", URL: "https://example.com/c", FinalURL: "https://example.com/c", FetchedAt: &fetched, ContentHash: "h3"},
+	}
+	var buf bytes.Buffer
+	w, err := newRecordWriter(FormatParquet, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range recs {
+		if err := w.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Open the file without the writer's schema, as another tool would.
+	file, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.NumRows() != 3 {
+		t.Errorf("num_rows = %d, want 3", file.NumRows())
+	}
+	var names []string
+	for _, f := range file.Schema().Fields() {
+		names = append(names, f.Name())
+	}
+	if !slices.Equal(names, columns) {
+		t.Errorf("schema columns = %v, want %v", names, columns)
+	}
+	fetchTime, _ := file.Schema().Lookup("fetch_time")
+	if lt := fetchTime.Node.Type().LogicalType(); lt == nil || lt.String() != "TIMESTAMP(isAdjustedToUTC=true,unit=MILLIS)" {
+		t.Errorf("fetch_time logical type = %v, want a millisecond timestamp", lt)
+	}
+
+	got, err := parquet.Read[parquetRow](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("read %d rows, want 3", len(got))
+	}
+	if got[0].Text != "héllo" || got[1].Text != "" {
+		t.Errorf("text = %q, %q", got[0].Text, got[1].Text)
+	}
+	if got[2].ContentHash != "h3" {
+		t.Errorf("content_hash = %q, want h3", got[2].ContentHash)
+	}
+	if got[0].FetchTime == nil || !got[0].FetchTime.Equal(fetched) || got[1].FetchTime != nil {
+		t.Errorf("fetch_time = %v, %v; want %v and null", got[0].FetchTime, got[1].FetchTime, fetched)
+	}
+}
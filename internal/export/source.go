@@ -0,0 +1,106 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/theognis1002/nimbus-crawler/internal/database/models"
+	"github.com/theognis1002/nimbus-crawler/internal/storage"
+)
+
+// textFetchers is how many text objects are read at once.
+const textFetchers = 8
+
+// PostgresSource reads the pages matching a filter from postgres and their
+// text from the object store.
+type PostgresSource struct {
+	pool   *pgxpool.Pool
+	store  storage.ObjectStore
+	filter models.URLFilter
+	logger *slog.Logger
+}
+
+func NewPostgresSource(pool *pgxpool.Pool, store storage.ObjectStore, filter models.URLFilter, logger *slog.Logger) *PostgresSource {
+	return &PostgresSource{pool: pool, store: store, filter: filter, logger: logger}
+}
+
+// Records returns the pages with their text. A page whose text object is
+// missing is exported with no text.
+func (s *PostgresSource) Records(ctx context.Context, after string, limit int) ([]Record, error) {
+	urls, err := models.ListURLs(ctx, s.pool, s.filter, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	recs := make([]Record, len(urls))
+	for i, u := range urls {
+		recs[i] = newRecord(u)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		sem      = make(chan struct{}, textFetchers)
+	)
+	for i, u := range urls {
+		if u.S3TextLink == nil {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			text, err := s.text(ctx, *u.S3TextLink)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("reading text of %s: %w", u.URL, err)
+				}
+				mu.Unlock()
+				return
+			}
+			recs[i].Text = text
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return recs, nil
+}
+
+func (s *PostgresSource) text(ctx context.Context, link string) (string, error) {
+	bucket, key, ok := storage.SplitLink(link)
+	if !ok {
+		return "", fmt.Errorf("malformed object link %q", link)
+	}
+	data, err := storage.ReadObject(ctx, s.store, bucket, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		s.logger.Warn("text object missing, exporting page without text", "link", link)
+		return "", nil
+	}
+	return string(data), err
+}
+
+func newRecord(u models.URLRecord) Record {
+	r := Record{ID: u.ID, URL: u.URL, FinalURL: u.URL}
+	if u.FinalURL != nil {
+		r.FinalURL = *u.FinalURL
+	}
+	if u.Title != nil {
+		r.Title = *u.Title
+	}
+	if u.LastCrawlTime != nil {
+		t := u.LastCrawlTime.UTC()
+		r.FetchedAt = &t
+	}
+	if u.ContentHash != nil {
+		r.ContentHash = *u.ContentHash
+	}
+	return r
+}
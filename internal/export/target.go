@@ -0,0 +1,114 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/theognis1002/nimbus-crawler/internal/storage"
+)
+
+// Target is where an export's files go. Shards are written to a local
+// staging directory first and committed once complete, so a target never
+// holds a partial shard under its final name.
+type Target interface {
+	// StageDir returns the directory shards are written in.
+	StageDir() string
+	// Commit moves the complete file at local path to name.
+	Commit(ctx context.Context, name, path string) error
+	// ReadFile returns the file called name, or an error wrapping
+	// fs.ErrNotExist if there is none.
+	ReadFile(ctx context.Context, name string) ([]byte, error)
+	// WriteFile replaces the file called name.
+	WriteFile(ctx context.Context, name string, data []byte) error
+}
+
+// DirTarget writes an export to a local directory.
+type DirTarget struct {
+	dir string
+}
+
+// NewDirTarget returns a target writing to dir, creating it if needed.
+func NewDirTarget(dir string) (*DirTarget, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating export dir: %w", err)
+	}
+	return &DirTarget{dir: dir}, nil
+}
+
+func (t *DirTarget) StageDir() string { return t.dir }
+
+func (t *DirTarget) Commit(ctx context.Context, name, path string) error {
+	return os.Rename(path, filepath.Join(t.dir, name))
+}
+
+func (t *DirTarget) ReadFile(ctx context.Context, name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(t.dir, name))
+}
+
+// WriteFile replaces the file atomically, so a crash leaves the old one.
+func (t *DirTarget) WriteFile(ctx context.Context, name string, data []byte) error {
+	tmp := filepath.Join(t.dir, name+partialSuffix)
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(t.dir, name))
+}
+
+// StoreTarget writes an export to an object store bucket, under a key
+// prefix.
+type StoreTarget struct {
+	store  storage.ObjectStore
+	bucket string
+	prefix string
+	stage  string
+}
+
+// NewStoreTarget returns a target writing to bucket under prefix, staging
+// shards in stageDir.
+func NewStoreTarget(store storage.ObjectStore, bucket, prefix, stageDir string) (*StoreTarget, error) {
+	if err := os.MkdirAll(stageDir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating export staging dir: %w", err)
+	}
+	return &StoreTarget{store: store, bucket: bucket, prefix: prefix, stage: stageDir}, nil
+}
+
+func (t *StoreTarget) StageDir() string { return t.stage }
+
+// Commit uploads the file and removes the local copy.
+func (t *StoreTarget) Commit(ctx context.Context, name, local string) error {
+	f, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := t.store.Put(ctx, t.bucket, t.key(name), f, info.Size(), storage.PutOptions{}); err != nil {
+		return fmt.Errorf("uploading %s: %w", name, err)
+	}
+	return os.Remove(local)
+}
+
+func (t *StoreTarget) ReadFile(ctx context.Context, name string) ([]byte, error) {
+	data, err := storage.ReadObject(ctx, t.store, t.bucket, t.key(name))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("%s: %w", name, fs.ErrNotExist)
+	}
+	return data, err
+}
+
+func (t *StoreTarget) WriteFile(ctx context.Context, name string, data []byte) error {
+	return t.store.Put(ctx, t.bucket, t.key(name), bytes.NewReader(data), int64(len(data)), storage.PutOptions{ContentType: "application/json"})
+}
+
+func (t *StoreTarget) key(name string) string {
+	return path.Join(t.prefix, name)
+}
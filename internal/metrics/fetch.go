@@ -0,0 +1,10 @@
+package metrics
+
+// StatusClass returns the FetchesTotal label of an HTTP status: "2xx" for
+// 200-299 and so on, or "error" when there was no response.
+func StatusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "error"
+	}
+	return string(rune('0'+status/100)) + "xx"
+}
@@ -0,0 +1,114 @@
+// Package metrics holds the Prometheus metrics of the crawler and parser and
+// serves them on /metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const namespace = "nimbus"
+
+// Registry holds every nimbus metric plus the Go runtime and process
+// collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// FetchesTotal counts fetches by status class ("2xx" … "5xx", or
+	// "error" when no response arrived).
+	FetchesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "crawler", Name: "fetches_total",
+		Help: "Fetches by response status class.",
+	}, []string{"status_class"})
+	// FetchFailuresTotal counts fetches that did not yield a page, by reason.
+	FetchFailuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "crawler", Name: "fetch_failures_total",
+		Help: "Failed fetches by reason.",
+	}, []string{"reason"})
+	FetchDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "crawler", Name: "fetch_duration_seconds",
+		Help:    "Time until response headers arrived.",
+		Buckets: prometheus.ExponentialBuckets(0.05, 2, 10),
+	})
+	BytesDownloaded = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "crawler", Name: "downloaded_bytes_total",
+		Help: "Response body bytes read.",
+	})
+	RateLimitWait = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "crawler", Name: "rate_limit_wait_seconds",
+		Help:    "Wait imposed by per-domain rate limits, 0 when the window was open.",
+		Buckets: []float64{0, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30},
+	})
+	// RobotsDecisions counts robots.txt checks by decision ("allowed",
+	// "disallowed" or "error", which allows).
+	RobotsDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "crawler", Name: "robots_decisions_total",
+		Help: "robots.txt checks by decision.",
+	}, []string{"decision"})
+	// ProxySelections counts the connections a fetch was routed through, by
+	// proxy host or "direct".
+	ProxySelections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "crawler", Name: "proxy_selections_total",
+		Help: "Fetches by proxy, or direct.",
+	}, []string{"proxy"})
+	ProxyFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "crawler", Name: "proxy_failures_total",
+		Help: "Fetches that failed through a proxy, by proxy host.",
+	}, []string{"proxy"})
+
+	ParseDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "parser", Name: "parse_duration_seconds",
+		Help:    "Time to extract text and links from a stored page.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 12),
+	})
+	LinksExtracted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "parser", Name: "links_extracted_total",
+		Help: "Links found in parsed pages.",
+	})
+	LinksInserted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "parser", Name: "links_inserted_total",
+		Help: "Discovered URLs new to the urls table.",
+	})
+	DuplicatesDetected = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "parser", Name: "duplicates_total",
+		Help: "Pages skipped because their content was already parsed.",
+	})
+
+	// SinkDocuments counts pages handed to output sinks by sink and outcome
+	// ("delivered", "retried" or "dead_lettered").
+	SinkDocuments = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "sink", Name: "documents_total",
+		Help: "Pages sent to output sinks by sink and outcome.",
+	}, []string{"sink", "outcome"})
+
+	// Deliveries counts settled queue deliveries by queue ("frontier" or
+	// "parse") and outcome ("ack", "nack" or "dlq").
+	Deliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "queue", Name: "deliveries_total",
+		Help: "Settled deliveries by queue and outcome.",
+	}, []string{"queue", "outcome"})
+	StreamLength = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace, Subsystem: "queue", Name: "stream_length",
+		Help: "Entries in each Redis stream.",
+	}, []string{"stream"})
+	ConsumerLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace, Subsystem: "queue", Name: "consumer_group_lag",
+		Help: "Entries not yet delivered to each consumer group.",
+	}, []string{"stream", "group"})
+	PendingEntries = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace, Subsystem: "queue", Name: "pending_entries",
+		Help: "Delivered but unacknowledged entries of each consumer group.",
+	}, []string{"stream", "group"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		FetchesTotal, FetchFailuresTotal, FetchDuration, BytesDownloaded, RateLimitWait,
+		RobotsDecisions, ProxySelections, ProxyFailures,
+		ParseDuration, LinksExtracted, LinksInserted, DuplicatesDetected,
+		SinkDocuments,
+		Deliveries, StreamLength, ConsumerLag, PendingEntries,
+	)
+}
@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/theognis1002/nimbus-crawler/internal/queue"
+)
+
+func TestStatusClass(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		status int
+		want   string
+	}{
+		{0, "error"},
+		{200, "2xx"},
+		{204, "2xx"},
+		{301, "3xx"},
+		{404, "4xx"},
+		{503, "5xx"},
+		{600, "error"},
+	}
+	for _, tt := range tests {
+		if got := StatusClass(tt.status); got != tt.want {
+			t.Errorf("StatusClass(%d) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestInstrument(t *testing.T) {
+	t.Parallel()
+	var acked, nacked, dead int
+	d := Instrument("test-instrument", queue.Delivery{
+		Ack: func() error { acked++; return nil },
+		Nack: func(toDLQ bool) error {
+			if toDLQ {
+				dead++
+			} else {
+				nacked++
+			}
+			return nil
+		},
+	})
+	_ = d.Ack()
+	_ = d.Nack(false)
+	_ = d.Nack(true)
+	_ = d.Nack(true)
+
+	if acked != 1 || nacked != 1 || dead != 2 {
+		t.Errorf("underlying calls = ack %d, nack %d, dlq %d; want 1, 1, 2", acked, nacked, dead)
+	}
+	for outcome, want := range map[string]float64{"ack": 1, "nack": 1, "dlq": 2} {
+		if got := testutil.ToFloat64(Deliveries.WithLabelValues("test-instrument", outcome)); got != want {
+			t.Errorf("deliveries{outcome=%q} = %v, want %v", outcome, got, want)
+		}
+	}
+}
+
+func TestSampleStreams(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	p := queue.NewRedisPublisher(rdb, 1, queue.EncodingJSON)
+
+	if err := rdb.XGroupCreateMkStream(ctx, queue.ParseStream, queue.ParserGroup, "0").Err(); err != nil {
+		t.Fatalf("XGroupCreateMkStream: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := p.PublishParse(ctx, queue.ParseMessage{URLID: "id", URL: "https://example.com"}); err != nil {
+			t.Fatalf("PublishParse: %v", err)
+		}
+	}
+	if err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: queue.ParserGroup, Consumer: "c1", Streams: []string{queue.ParseStream, ">"}, Count: 2,
+	}).Err(); err != nil {
+		t.Fatalf("XReadGroup: %v", err)
+	}
+
+	// The DLQ stream does not exist yet and must not fail the sample.
+	if err := sampleStreams(ctx, rdb, []string{queue.ParseStream, queue.ParseDLQ}); err != nil {
+		t.Fatalf("sampleStreams: %v", err)
+	}
+	if got := testutil.ToFloat64(StreamLength.WithLabelValues(queue.ParseStream)); got != 3 {
+		t.Errorf("stream length = %v, want 3", got)
+	}
+	if got := testutil.ToFloat64(StreamLength.WithLabelValues(queue.ParseDLQ)); got != 0 {
+		t.Errorf("dlq length = %v, want 0", got)
+	}
+	if got := testutil.ToFloat64(PendingEntries.WithLabelValues(queue.ParseStream, queue.ParserGroup)); got != 2 {
+		t.Errorf("pending entries = %v, want 2", got)
+	}
+}
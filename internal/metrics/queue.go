@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/theognis1002/nimbus-crawler/internal/queue"
+)
+
+// Instrument returns d with its Ack and Nack counted in Deliveries under
+// queueName.
+func Instrument(queueName string, d queue.Delivery) queue.Delivery {
+	ack, nack := d.Ack, d.Nack
+	d.Ack = func() error {
+		Deliveries.WithLabelValues(queueName, "ack").Inc()
+		return ack()
+	}
+	d.Nack = func(toDLQ bool) error {
+		outcome := "nack"
+		if toDLQ {
+			outcome = "dlq"
+		}
+		Deliveries.WithLabelValues(queueName, outcome).Inc()
+		return nack(toDLQ)
+	}
+	return d
+}
+
+// SampleRedisStreams sets the stream length, consumer-group lag and pending
+// entry gauges of every queue stream every interval until ctx is cancelled.
+func SampleRedisStreams(ctx context.Context, rdb *redis.Client, partitions int, interval time.Duration, logger *slog.Logger) {
+	streams := queue.Streams(partitions)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := sampleStreams(ctx, rdb, streams); err != nil && ctx.Err() == nil {
+			logger.Warn("failed to sample queue metrics", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func sampleStreams(ctx context.Context, rdb *redis.Client, streams []string) error {
+	pipe := rdb.Pipeline()
+	lens := make([]*redis.IntCmd, len(streams))
+	groups := make([]*redis.XInfoGroupsCmd, len(streams))
+	for i, s := range streams {
+		lens[i] = pipe.XLen(ctx, s)
+		groups[i] = pipe.XInfoGroups(ctx, s)
+	}
+	// Streams not created yet fail XINFO; their length still reads as 0.
+	_, _ = pipe.Exec(ctx)
+
+	for i, s := range streams {
+		n, err := lens[i].Result()
+		if err != nil {
+			return err
+		}
+		StreamLength.WithLabelValues(s).Set(float64(n))
+		infos, err := groups[i].Result()
+		if err != nil {
+			continue
+		}
+		for _, g := range infos {
+			if g.Lag >= 0 {
+				ConsumerLag.WithLabelValues(s, g.Name).Set(float64(g.Lag))
+			}
+			PendingEntries.WithLabelValues(s, g.Name).Set(float64(g.Pending))
+		}
+	}
+	return nil
+}
@@ -4,7 +4,6 @@ import (
 	"net/url"
 	"strings"
 
-	"github.com/PuerkitoBio/goquery"
 	"github.com/PuerkitoBio/purell"
 )
 
@@ -18,59 +17,30 @@ const normalizationFlags = purell.FlagLowercaseScheme |
 	purell.FlagRemoveFragment |
 	purell.FlagSortQuery
 
-func ExtractText(doc *goquery.Document) string {
-	doc.Find("script, style, noscript, iframe").Remove()
-
-	var sb strings.Builder
-	doc.Find("body").Each(func(_ int, s *goquery.Selection) {
-		sb.WriteString(strings.TrimSpace(s.Text()))
-	})
+// resolveLink resolves href against base and normalizes it. It reports false
+// for empty, fragment-only and non-HTTP links.
+func resolveLink(base *url.URL, href string) (string, bool) {
+	href = strings.TrimSpace(href)
+	if href == "" {
+		return "", false
+	}
 
-	return sb.String()
-}
+	// Skip non-HTTP
+	if strings.HasPrefix(href, "javascript:") || strings.HasPrefix(href, "mailto:") ||
+		strings.HasPrefix(href, "tel:") || strings.HasPrefix(href, "#") {
+		return "", false
+	}
 
-func ExtractURLs(doc *goquery.Document, baseURL string) []string {
-	base, err := url.Parse(baseURL)
+	parsed, err := url.Parse(href)
 	if err != nil {
-		return nil
+		return "", false
 	}
 
-	seen := make(map[string]struct{})
-	var urls []string
-
-	doc.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
-		href, exists := s.Attr("href")
-		if !exists || href == "" {
-			return
-		}
-
-		href = strings.TrimSpace(href)
+	resolved := base.ResolveReference(parsed)
 
-		// Skip non-HTTP
-		if strings.HasPrefix(href, "javascript:") || strings.HasPrefix(href, "mailto:") ||
-			strings.HasPrefix(href, "tel:") || strings.HasPrefix(href, "#") {
-			return
-		}
-
-		parsed, err := url.Parse(href)
-		if err != nil {
-			return
-		}
-
-		resolved := base.ResolveReference(parsed)
-
-		if resolved.Scheme != "http" && resolved.Scheme != "https" {
-			return
-		}
-
-		normalized := purell.NormalizeURL(resolved, normalizationFlags)
-
-		if _, ok := seen[normalized]; ok {
-			return
-		}
-		seen[normalized] = struct{}{}
-		urls = append(urls, normalized)
-	})
+	if resolved.Scheme != "http" && resolved.Scheme != "https" {
+		return "", false
+	}
 
-	return urls
+	return purell.NormalizeURL(resolved, normalizationFlags), true
 }
@@ -3,17 +3,17 @@ package parser
 import (
 	"strings"
 	"testing"
-
-	"github.com/PuerkitoBio/goquery"
 )
 
-func docFromHTML(t *testing.T, html string) *goquery.Document {
+// extract runs Extract over html and returns the text and links.
+func extract(t *testing.T, html, baseURL string) (string, []string) {
 	t.Helper()
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	var text strings.Builder
+	urls, err := Extract(strings.NewReader(html), baseURL, &text)
 	if err != nil {
-		t.Fatalf("failed to parse HTML: %v", err)
+		t.Fatalf("Extract: %v", err)
 	}
-	return doc
+	return text.String(), urls
 }
 
 func TestExtractText(t *testing.T) {
@@ -31,8 +31,8 @@ func TestExtractText(t *testing.T) {
 			contains: "Hello World",
 		},
 		{
-			name:  "strips script style noscript iframe",
-			html:  `<html><body><script>var x=1;</script><style>.a{}</style><noscript>no</noscript><iframe>frame</iframe><p>Visible</p></body></html>`,
+			name:     "strips script style noscript iframe",
+			html:     `<html><body><script>var x=1;</script><style>.a{}</style><noscript>no</noscript><iframe>frame</iframe><p>Visible</p></body></html>`,
 			contains: "Visible",
 		},
 		{
@@ -50,8 +50,7 @@ func TestExtractText(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			doc := docFromHTML(t, tt.html)
-			got := ExtractText(doc)
+			got, _ := extract(t, tt.html, "https://example.com")
 			if tt.empty {
 				if got != "" {
 					t.Errorf("expected empty string, got %q", got)
@@ -67,14 +66,13 @@ func TestExtractText(t *testing.T) {
 
 func TestExtractText_StripsScriptContent(t *testing.T) {
 	t.Parallel()
-	doc := docFromHTML(t, `<html><body><script>var secret=1;</script><p>OK</p></body></html>`)
-	got := ExtractText(doc)
+	got, _ := extract(t, `<html><body><script>var secret=1;</script><p>OK</p></body></html>`, "https://example.com")
 	if strings.Contains(got, "secret") {
 		t.Errorf("script content should be stripped, got %q", got)
 	}
 }
 
-func TestExtractURLs(t *testing.T) {
+func TestExtractLinks(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
@@ -143,8 +141,7 @@ func TestExtractURLs(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			doc := docFromHTML(t, tt.html)
-			got := ExtractURLs(doc, tt.baseURL)
+			_, got := extract(t, tt.html, tt.baseURL)
 			if tt.wantNil {
 				if got != nil {
 					t.Errorf("expected nil, got %v", got)
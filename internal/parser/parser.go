@@ -1,46 +1,105 @@
 package parser
 
 import (
-	"bytes"
+	"bufio"
 	"context"
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/url"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/PuerkitoBio/goquery"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/theognis1002/nimbus-crawler/internal/config"
 	"github.com/theognis1002/nimbus-crawler/internal/database/models"
+	"github.com/theognis1002/nimbus-crawler/internal/events"
+	"github.com/theognis1002/nimbus-crawler/internal/metrics"
 	"github.com/theognis1002/nimbus-crawler/internal/queue"
 	"github.com/theognis1002/nimbus-crawler/internal/robots"
+	"github.com/theognis1002/nimbus-crawler/internal/sink"
 	"github.com/theognis1002/nimbus-crawler/internal/storage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/theognis1002/nimbus-crawler/internal/parser")
+
 type Parser struct {
-	cfg         config.ParserConfig
-	pool        *pgxpool.Pool
-	publisher   *queue.Publisher
-	minio       *storage.MinIOClient
-	logger      *slog.Logger
-	domainCache sync.Map
+	cfg            config.ParserConfig
+	pool           *pgxpool.Pool
+	publisher      queue.Publisher
+	store          storage.ObjectStore
+	storageCfg     config.StorageConfig
+	logger         *slog.Logger
+	domainCache    sync.Map
+	score          queue.ScoreFunc
+	importantHosts map[string]struct{}
+	sink           sink.Sink
+	sinkTextBytes  int
+	events         *events.Bus
 }
 
 func New(
 	cfg config.ParserConfig,
 	pool *pgxpool.Pool,
-	publisher *queue.Publisher,
-	minio *storage.MinIOClient,
+	publisher queue.Publisher,
+	store storage.ObjectStore,
+	storageCfg config.StorageConfig,
 	logger *slog.Logger,
 ) *Parser {
+	importantHosts := make(map[string]struct{}, len(cfg.Priority.ImportantHosts))
+	for _, h := range cfg.Priority.ImportantHosts {
+		importantHosts[strings.ToLower(h)] = struct{}{}
+	}
 	return &Parser{
-		cfg:       cfg,
-		pool:      pool,
-		publisher: publisher,
-		minio:     minio,
-		logger:    logger,
+		cfg:        cfg,
+		pool:       pool,
+		publisher:  publisher,
+		store:      store,
+		storageCfg: storageCfg,
+		logger:     logger,
+		score: queue.WeightedScore(queue.PriorityWeights{
+			Depth:     cfg.Priority.DepthWeight,
+			Sitemap:   cfg.Priority.SitemapWeight,
+			Host:      cfg.Priority.HostWeight,
+			Freshness: cfg.Priority.FreshnessWeight,
+		}),
+		importantHosts: importantHosts,
+	}
+}
+
+// SetScoreFunc replaces the function used to assign frontier priorities to
+// discovered URLs. It must be called before Run.
+func (p *Parser) SetScoreFunc(score queue.ScoreFunc) {
+	p.score = score
+}
+
+// SetSink makes the parser send every page it parses to s, with at most
+// maxTextBytes of its text. It must be called before Run.
+func (p *Parser) SetSink(s sink.Sink, maxTextBytes int) {
+	p.sink = s
+	p.sinkTextBytes = maxTextBytes
+}
+
+// SetEvents makes the parser emit page events to b. It must be called
+// before Run.
+func (p *Parser) SetEvents(b *events.Bus) {
+	p.events = b
+}
+
+// priorityFor scores a discovered URL on domain, adding the host's
+// importance to signals.
+func (p *Parser) priorityFor(domain string, signals queue.PrioritySignals) int {
+	if _, ok := p.importantHosts[domain]; ok {
+		signals.HostImportance = 1
 	}
+	return queue.PriorityFor(p.score(signals))
 }
 
 func (p *Parser) Run(ctx context.Context, deliveries <-chan queue.Delivery) {
@@ -78,8 +137,9 @@ func (p *Parser) worker(ctx context.Context, id int, deliveries <-chan queue.Del
 }
 
 func (p *Parser) processMessage(ctx context.Context, logger *slog.Logger, d queue.Delivery) {
+	d = metrics.Instrument("parse", d)
 	var msg queue.ParseMessage
-	if err := json.Unmarshal(d.Body, &msg); err != nil {
+	if err := d.Decode(&msg); err != nil {
 		logger.Error("failed to unmarshal message", "error", err)
 		if err := d.Nack(true); err != nil {
 			logger.Error("failed to nack message", "error", err)
@@ -87,11 +147,21 @@ func (p *Parser) processMessage(ctx context.Context, logger *slog.Logger, d queu
 		return
 	}
 
+	// Parsing continues the trace of the crawl that stored the page.
+	ctx, span := tracer.Start(d.Context(ctx), "parse", trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("url.full", msg.URL),
+			attribute.String("url.id", msg.URLID),
+			attribute.Bool("parse.reparse", msg.Reparse),
+		))
+	defer span.End()
 	logger = logger.With("url_id", msg.URLID, "url", msg.URL)
+	if sc := span.SpanContext(); sc.IsValid() {
+		logger = logger.With("trace_id", sc.TraceID().String())
+	}
 
-	// Download HTML from MinIO
-	parts := strings.SplitN(msg.S3HTMLLink, "/", 2)
-	if len(parts) != 2 {
+	bucket, key, ok := storage.SplitLink(msg.S3HTMLLink)
+	if !ok {
 		logger.Error("invalid s3 link", "link", msg.S3HTMLLink)
 		if err := d.Nack(true); err != nil {
 			logger.Error("failed to nack message", "error", err)
@@ -99,19 +169,32 @@ func (p *Parser) processMessage(ctx context.Context, logger *slog.Logger, d queu
 		return
 	}
 
-	htmlData, err := p.minio.GetObject(ctx, parts[0], parts[1])
-	if err != nil {
-		logger.Error("failed to get html from minio", "error", err)
-		if err := d.Nack(false); err != nil {
-			logger.Error("failed to nack message", "error", err)
+	// Content dedup. The crawler hashes pages as it stores them; messages
+	// from before that are hashed here with an extra pass over the object.
+	hash := msg.ContentHash
+	if hash == "" {
+		var err error
+		hash, err = p.hashObject(ctx, bucket, key)
+		if err != nil {
+			logger.Error("failed to hash stored html", "error", err)
+			if err := d.Nack(false); err != nil {
+				logger.Error("failed to nack message", "error", err)
+			}
+			return
 		}
-		return
 	}
-
-	// Content dedup
-	hash := ContentHash(htmlData)
-	exists, err := models.ContentHashExists(ctx, p.pool, hash)
+	dedupCtx, dedupSpan := tracer.Start(ctx, "dedup.check")
+	var exists bool
+	var err error
+	if msg.Reparse {
+		exists, err = models.ContentHashExistsForOtherURL(dedupCtx, p.pool, hash, msg.URLID)
+	} else {
+		exists, err = models.ContentHashExists(dedupCtx, p.pool, hash)
+	}
+	dedupSpan.SetAttributes(attribute.Bool("dedup.duplicate", exists))
+	endSpan(dedupSpan, err)
 	if err != nil {
+		failSpan(span, err)
 		logger.Error("content hash check failed, will retry", "error", err)
 		if err := d.Nack(false); err != nil {
 			logger.Error("failed to nack message", "error", err)
@@ -120,6 +203,8 @@ func (p *Parser) processMessage(ctx context.Context, logger *slog.Logger, d queu
 	}
 	if exists {
 		logger.Debug("duplicate content, skipping")
+		span.AddEvent("duplicate content")
+		metrics.DuplicatesDetected.Inc()
 		_ = models.UpdateURLStatus(ctx, p.pool, msg.URLID, models.StatusSkipped)
 		if err := d.Ack(); err != nil {
 			logger.Error("failed to ack message", "error", err)
@@ -127,45 +212,59 @@ func (p *Parser) processMessage(ctx context.Context, logger *slog.Logger, d queu
 		return
 	}
 
-	// Parse HTML
-	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(htmlData))
-	if err != nil {
-		logger.Error("failed to parse html", "error", err)
-		if err := d.Nack(true); err != nil {
-			logger.Error("failed to nack message", "error", err)
+	// Parse HTML straight from the object store, streaming the text back as it is found
+	textKey := storage.TextKey(msg.URL)
+	start := time.Now()
+	extractCtx, extractSpan := tracer.Start(ctx, "extract")
+	// Keep the start of the text for the search index and sinks.
+	var text *prefixBuffer
+	if p.cfg.IndexSearch || p.sink != nil {
+		text = &prefixBuffer{max: maxSearchTextBytes}
+		if p.sink != nil {
+			text.max = max(text.max, p.sinkTextBytes)
 		}
-		return
 	}
-
-	// Extract URLs before ExtractText (which mutates the document by removing elements)
-	extractedURLs := ExtractURLs(doc, msg.URL)
-
-	// Extract text (mutates doc by removing script/style/noscript/iframe)
-	text := ExtractText(doc)
-	textKey := storage.TextKey(msg.URL)
-	if err := p.minio.PutObject(ctx, storage.TextBucket, textKey, []byte(text), "text/plain"); err != nil {
-		logger.Error("failed to store text", "error", err)
+	doc, err := p.extract(extractCtx, bucket, key, msg.URL, textKey, text)
+	extractedURLs := doc.Links
+	metrics.ParseDuration.Observe(time.Since(start).Seconds())
+	extractSpan.SetAttributes(attribute.Int("parse.links", len(extractedURLs)))
+	endSpan(extractSpan, err)
+	if err != nil {
+		failSpan(span, err)
+		logger.Error("failed to parse html", "error", err)
 		if err := d.Nack(false); err != nil {
 			logger.Error("failed to nack message", "error", err)
 		}
 		return
 	}
-	s3TextLink := storage.TextBucket + "/" + textKey
+	s3TextLink := p.storageCfg.TextBucket + "/" + textKey
+	metrics.LinksExtracted.Add(float64(len(extractedURLs)))
 
 	// Bulk insert new URLs and publish only newly-inserted ones.
 	// Skip if frontier stream is under backpressure — the current page is still
 	// fully parsed and marked as 'parsed', but discovered URLs are not enqueued.
+	// Reparses asked to skip links and page-scoped jobs never enqueue them.
 	const backpressureThreshold int64 = 80000
+	followLinks := !msg.SkipLinks && msg.Scope != queue.ScopePage
+	maxDepth := p.cfg.MaxDepth
+	if msg.MaxDepth > 0 {
+		maxDepth = msg.MaxDepth
+	}
 	underBackpressure := false
-	if streamLen, bpErr := p.publisher.StreamLen(ctx, queue.FrontierStream); bpErr == nil && streamLen > backpressureThreshold {
-		logger.Warn("frontier stream backpressure, skipping URL publishing", "stream_len", streamLen)
-		underBackpressure = true
+	if followLinks {
+		if streamLen, bpErr := p.publisher.FrontierLen(ctx); bpErr == nil && streamLen > backpressureThreshold {
+			logger.Warn("frontier stream backpressure, skipping URL publishing", "stream_len", streamLen)
+			underBackpressure = true
+		}
 	}
 
-	if !underBackpressure && len(extractedURLs) > 0 && msg.Depth+1 <= p.cfg.MaxDepth {
+	if followLinks && !underBackpressure && len(extractedURLs) > 0 && msg.Depth+1 <= maxDepth {
+		ctx, linksSpan := tracer.Start(ctx, "links.publish")
 		newDepth := msg.Depth + 1
+		page, _ := url.Parse(msg.URL)
 		var validURLs []string
 		var validDomains []string
+		domainOf := make(map[string]string, len(extractedURLs))
 
 		// Deduplicate domains to minimize DB calls
 		unseenDomains := make(map[string]struct{})
@@ -178,12 +277,16 @@ func (p *Parser) processMessage(ctx context.Context, logger *slog.Logger, d queu
 			if domain == "" {
 				continue
 			}
+			if page != nil && !inScope(msg.Scope, page, parsed) {
+				continue
+			}
 			// Only upsert domains we haven't seen in-process
 			if _, loaded := p.domainCache.LoadOrStore(domain, true); !loaded {
 				unseenDomains[domain] = struct{}{}
 			}
 			validURLs = append(validURLs, u)
 			validDomains = append(validDomains, domain)
+			domainOf[u] = domain
 		}
 
 		for domain := range unseenDomains {
@@ -195,12 +298,25 @@ func (p *Parser) processMessage(ctx context.Context, logger *slog.Logger, d queu
 
 		if len(validURLs) > 0 {
 			inserted, err := models.BulkInsertURLs(ctx, p.pool, validURLs, validDomains, newDepth)
+			metrics.LinksInserted.Add(float64(len(inserted)))
 
 			// Publish whatever was successfully inserted, even on partial failure
 			if len(inserted) > 0 {
 				msgs := make([]queue.URLMessage, len(inserted))
 				for i, u := range inserted {
-					msgs[i] = queue.URLMessage{URL: u, Depth: newDepth}
+					signals := queue.PrioritySignals{Depth: newDepth, LastCrawl: u.LastCrawlTime}
+					if sp, ok := doc.SitemapPriorities[u.URL]; ok {
+						signals.SitemapPriority = &sp
+					}
+					msgs[i] = queue.URLMessage{
+						URL:            u.URL,
+						Depth:          newDepth,
+						Priority:       p.priorityFor(domainOf[u.URL], signals),
+						JobID:          msg.JobID,
+						Scope:          msg.Scope,
+						MaxDepth:       msg.MaxDepth,
+						DiscoveredFrom: msg.URL,
+					}
 				}
 				if pubErr := p.publisher.PublishURLBatch(ctx, msgs); pubErr != nil {
 					logger.Warn("failed to publish url batch", "error", pubErr)
@@ -210,11 +326,35 @@ func (p *Parser) processMessage(ctx context.Context, logger *slog.Logger, d queu
 			if err != nil {
 				logger.Error("bulk insert partially failed", "error", err, "inserted", len(inserted))
 			}
+			linksSpan.SetAttributes(attribute.Int("parse.links_inserted", len(inserted)))
+			if err != nil {
+				failSpan(linksSpan, err)
+			}
 		}
+		linksSpan.End()
 	}
 
 	// Update URL record
-	if err := models.UpdateURLParsed(ctx, p.pool, msg.URLID, hash, s3TextLink); err != nil {
+	updateCtx, updateSpan := tracer.Start(ctx, "db.update")
+	if p.cfg.RecordLinks {
+		if err := models.ReplaceLinks(updateCtx, p.pool, msg.URLID, extractedURLs); err != nil {
+			logger.Warn("failed to record links", "error", err)
+		}
+	}
+	previousHash, err := models.UpdateURLParsed(updateCtx, p.pool, msg.URLID, models.ParsedPage{
+		ContentHash: hash,
+		S3TextLink:  s3TextLink,
+		Title:       doc.Title,
+		Language:    doc.Language,
+	})
+	if err == nil && p.cfg.IndexSearch {
+		if err := models.UpsertPageSearch(updateCtx, p.pool, msg.URLID, doc.Title, doc.Language, text.Prefix(maxSearchTextBytes)); err != nil {
+			logger.Warn("failed to index page for search", "error", err)
+		}
+	}
+	endSpan(updateSpan, err)
+	if err != nil {
+		failSpan(span, err)
 		logger.Error("failed to update url record", "error", err)
 		if err := d.Nack(false); err != nil {
 			logger.Error("failed to nack message", "error", err)
@@ -222,8 +362,110 @@ func (p *Parser) processMessage(ctx context.Context, logger *slog.Logger, d queu
 		return
 	}
 
-	logger.Info("parsed successfully", "extracted_urls", len(extractedURLs))
+	var domain string
+	if u, err := url.Parse(msg.URL); err == nil {
+		domain = u.Hostname()
+	}
+	if p.events != nil {
+		page := events.Page{URLID: msg.URLID, URL: msg.URL, Domain: domain, Title: doc.Title, ContentHash: hash}
+		p.events.Emit(ctx, events.PageParsed, page)
+		if previousHash != "" && previousHash != hash {
+			page.PreviousHash = previousHash
+			p.events.Emit(ctx, events.ContentChanged, page)
+		}
+	}
+	if p.sink != nil {
+		out := sink.Document{
+			ID:          msg.URLID,
+			URL:         msg.URL,
+			Domain:      domain,
+			Depth:       msg.Depth,
+			Title:       doc.Title,
+			Language:    doc.Language,
+			ContentHash: hash,
+			Text:        text.Prefix(p.sinkTextBytes),
+			Links:       extractedURLs,
+			HTMLLink:    msg.S3HTMLLink,
+			TextLink:    s3TextLink,
+			ParsedAt:    time.Now().UTC(),
+		}
+		if err := p.sink.Send(ctx, out); err != nil {
+			logger.Warn("failed to send page to sinks", "error", err)
+		}
+	}
+
+	logger.Info("parsed successfully", "extracted_urls", len(extractedURLs), "reparse", msg.Reparse)
 	if err := d.Ack(); err != nil {
 		logger.Error("failed to ack message", "error", err)
 	}
 }
+
+// hashObject returns the ContentHash of an object without holding it in memory.
+func (p *Parser) hashObject(ctx context.Context, bucket, key string) (string, error) {
+	obj, _, err := p.store.Get(ctx, bucket, key)
+	if err != nil {
+		return "", err
+	}
+	defer obj.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, obj); err != nil {
+		return "", fmt.Errorf("reading object %s/%s: %w", bucket, key, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// extract streams the HTML object through ExtractDocument and its text into
+// the text bucket under textKey, concurrently, and returns the document. The
+// text is also copied to copyText when it is not nil. Sitemaps are read with
+// ExtractSitemap instead and have no text.
+func (p *Parser) extract(ctx context.Context, bucket, key, baseURL, textKey string, copyText *prefixBuffer) (Document, error) {
+	obj, _, err := p.store.Get(ctx, bucket, key)
+	if err != nil {
+		return Document{}, err
+	}
+	defer obj.Close()
+
+	pr, pw := io.Pipe()
+	uploaded := make(chan error, 1)
+	go func() {
+		err := p.store.Put(ctx, p.storageCfg.TextBucket, textKey, pr, -1, storage.PutOptions{ContentType: "text/plain"})
+		// Unblock Extract if the upload gave up early.
+		pr.CloseWithError(err)
+		uploaded <- err
+	}()
+
+	var text io.Writer = pw
+	if copyText != nil {
+		text = io.MultiWriter(pw, copyText)
+	}
+	r := bufio.NewReader(obj)
+	var doc Document
+	if head, _ := r.Peek(sitemapSniffBytes); isSitemap(head) {
+		doc, err = ExtractSitemap(r, baseURL)
+	} else {
+		doc, err = ExtractDocument(r, baseURL, text)
+	}
+	pw.CloseWithError(err)
+	if uploadErr := <-uploaded; uploadErr != nil {
+		return Document{}, fmt.Errorf("storing text: %w", uploadErr)
+	}
+	if err != nil {
+		return Document{}, fmt.Errorf("reading object %s/%s: %w", bucket, key, err)
+	}
+	return doc, nil
+}
+
+// failSpan marks span as failed with err.
+func failSpan(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// endSpan ends span, marking it failed if err is set.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		failSpan(span, err)
+	}
+	span.End()
+}
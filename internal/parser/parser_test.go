@@ -0,0 +1,41 @@
+package parser
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+	"github.com/theognis1002/nimbus-crawler/internal/queue"
+)
+
+func TestPriorityFor(t *testing.T) {
+	t.Parallel()
+	cfg := config.ParserConfig{Priority: config.PriorityConfig{
+		DepthWeight:    1,
+		SitemapWeight:  1,
+		ImportantHosts: []string{"Important.example"},
+	}}
+	p := New(cfg, nil, nil, nil, config.StorageConfig{}, slog.Default())
+
+	high, low := 1.0, 0.0
+	if got := p.priorityFor("example.com", queue.PrioritySignals{SitemapPriority: &high}); got != queue.HighestPriority {
+		t.Errorf("lane with sitemap priority 1.0 = %d, want %d", got, queue.HighestPriority)
+	}
+	if got := p.priorityFor("example.com", queue.PrioritySignals{SitemapPriority: &low}); got != 1 {
+		t.Errorf("lane with sitemap priority 0.0 = %d, want 1", got)
+	}
+
+	// A custom score function replaces the weighted score.
+	p.SetScoreFunc(func(s queue.PrioritySignals) float64 {
+		if s.HostImportance > 0 {
+			return 1
+		}
+		return 0
+	})
+	if got := p.priorityFor("important.example", queue.PrioritySignals{Depth: 5}); got != queue.HighestPriority {
+		t.Errorf("important host lane = %d, want %d", got, queue.HighestPriority)
+	}
+	if got := p.priorityFor("example.com", queue.PrioritySignals{Depth: 0}); got != 0 {
+		t.Errorf("shallow link lane with custom score = %d, want 0", got)
+	}
+}
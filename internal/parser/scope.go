@@ -0,0 +1,35 @@
+package parser
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/theognis1002/nimbus-crawler/internal/queue"
+	"golang.org/x/net/publicsuffix"
+)
+
+// inScope reports whether link, found on page, may be followed under a
+// job's scope.
+func inScope(scope string, page, link *url.URL) bool {
+	switch scope {
+	case queue.ScopePage:
+		return false
+	case queue.ScopeHost:
+		return strings.EqualFold(page.Hostname(), link.Hostname())
+	case queue.ScopeDomain:
+		return strings.EqualFold(registeredDomain(page.Hostname()), registeredDomain(link.Hostname()))
+	default:
+		return true
+	}
+}
+
+// registeredDomain returns host's registered domain, e.g. "example.co.uk"
+// for "www.example.co.uk", or host itself when it has none (IP addresses,
+// bare public suffixes).
+func registeredDomain(host string) string {
+	d, err := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(host))
+	if err != nil {
+		return strings.ToLower(host)
+	}
+	return d
+}
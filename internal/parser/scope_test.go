@@ -0,0 +1,39 @@
+package parser
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/theognis1002/nimbus-crawler/internal/queue"
+)
+
+func TestInScope(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		scope string
+		page  string
+		link  string
+		want  bool
+	}{
+		{name: "all", scope: queue.ScopeAll, page: "https://example.com/", link: "https://other.org/", want: true},
+		{name: "page", scope: queue.ScopePage, page: "https://example.com/", link: "https://example.com/a", want: false},
+		{name: "same host", scope: queue.ScopeHost, page: "https://example.com/", link: "http://EXAMPLE.com:8080/a", want: true},
+		{name: "subdomain is another host", scope: queue.ScopeHost, page: "https://example.com/", link: "https://www.example.com/", want: false},
+		{name: "subdomain in domain", scope: queue.ScopeDomain, page: "https://example.co.uk/", link: "https://blog.example.co.uk/", want: true},
+		{name: "sibling under public suffix", scope: queue.ScopeDomain, page: "https://a.co.uk/", link: "https://b.co.uk/", want: false},
+		{name: "ip address", scope: queue.ScopeDomain, page: "http://10.0.0.1/", link: "http://10.0.0.1/a", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			page, _ := url.Parse(tt.page)
+			link, _ := url.Parse(tt.link)
+			if got := inScope(tt.scope, page, link); got != tt.want {
+				t.Errorf("inScope(%q, %s, %s) = %v, want %v", tt.scope, tt.page, tt.link, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package parser
+
+import "strings"
+
+// maxSearchTextBytes caps the text of a page indexed for search; Postgres
+// refuses tsvectors over 1MB and snippets of long texts are slow.
+const maxSearchTextBytes = 256 << 10
+
+// prefixBuffer keeps the first max bytes written to it and discards the
+// rest, so it never fails a write.
+type prefixBuffer struct {
+	buf []byte
+	max int
+}
+
+func (b *prefixBuffer) Write(p []byte) (int, error) {
+	if room := b.max - len(b.buf); room > 0 {
+		b.buf = append(b.buf, p[:min(room, len(p))]...)
+	}
+	return len(p), nil
+}
+
+// String returns the text kept, without a rune cut off at the cap and
+// without the NUL bytes Postgres does not store.
+func (b *prefixBuffer) String() string {
+	return b.Prefix(len(b.buf))
+}
+
+// Prefix is like String for at most the first n bytes kept.
+func (b *prefixBuffer) Prefix(n int) string {
+	return strings.ReplaceAll(strings.ToValidUTF8(string(b.buf[:min(n, len(b.buf))]), ""), "\x00", "")
+}
@@ -0,0 +1,47 @@
+package parser
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestPrefixBuffer(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		max    int
+		writes []string
+		want   string
+	}{
+		{name: "under cap", max: 10, writes: []string{"abc", "def"}, want: "abcdef"},
+		{name: "at cap", max: 6, writes: []string{"abc", "def", "ghi"}, want: "abcdef"},
+		{name: "cuts a write", max: 4, writes: []string{"abc", "def"}, want: "abcd"},
+		{name: "drops a cut rune", max: 4, writes: []string{"abcé"}, want: "abc"},
+		{name: "drops nul", max: 10, writes: []string{"a\x00b"}, want: "ab"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			b := &prefixBuffer{max: tt.max}
+			for _, w := range tt.writes {
+				if n, err := io.WriteString(b, w); n != len(w) || err != nil {
+					t.Fatalf("Write(%q) = %d, %v; want %d, nil", w, n, err, len(w))
+				}
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// A page's text goes to both the object store and the buffer.
+	b := &prefixBuffer{max: maxSearchTextBytes}
+	var stored strings.Builder
+	if _, err := ExtractDocument(strings.NewReader("<p>Hello</p>"), "https://example.com/", io.MultiWriter(&stored, b)); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != stored.String() || b.String() != "Hello" {
+		t.Errorf("buffer = %q, stored = %q, want both %q", b.String(), stored.String(), "Hello")
+	}
+}
@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// sitemapSniffBytes is how much of a document is looked at to tell a
+// sitemap from an HTML page.
+const sitemapSniffBytes = 1024
+
+// isSitemap reports whether head, the start of a document, is an XML
+// sitemap or sitemap index.
+func isSitemap(head []byte) bool {
+	return bytes.Contains(head, []byte("<urlset")) || bytes.Contains(head, []byte("<sitemapindex"))
+}
+
+// ExtractSitemap reads a sitemap or sitemap index from r and returns the
+// URLs it lists, resolved against baseURL, with the <priority> of those
+// that have one. It decodes one element at a time, so large sitemaps are
+// not held in memory.
+func ExtractSitemap(r io.Reader, baseURL string) (Document, error) {
+	var doc Document
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return doc, nil
+	}
+
+	d := xml.NewDecoder(r)
+	d.Strict = false
+	seen := make(map[string]struct{})
+	var field string // the <url>/<sitemap> child being read
+	var loc, priority strings.Builder
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return doc, nil
+		}
+		if err != nil {
+			return doc, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "url", "sitemap":
+				loc.Reset()
+				priority.Reset()
+			case "loc", "priority":
+				field = t.Name.Local
+			}
+		case xml.CharData:
+			switch field {
+			case "loc":
+				loc.Write(t)
+			case "priority":
+				priority.Write(t)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "loc", "priority":
+				field = ""
+			case "url", "sitemap":
+				link, ok := resolveLink(base, loc.String())
+				if !ok {
+					continue
+				}
+				if _, dup := seen[link]; dup {
+					continue
+				}
+				seen[link] = struct{}{}
+				doc.Links = append(doc.Links, link)
+				if p, err := strconv.ParseFloat(strings.TrimSpace(priority.String()), 64); err == nil {
+					if doc.SitemapPriorities == nil {
+						doc.SitemapPriorities = make(map[string]float64)
+					}
+					doc.SitemapPriorities[link] = p
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExtractSitemap(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		xml            string
+		wantURLs       []string
+		wantPriorities map[string]float64
+	}{
+		{
+			name: "urlset with priorities",
+			xml: `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://example.com/</loc><priority>1.0</priority></url>
+  <url><loc> https://example.com/about </loc><lastmod>2026-01-01</lastmod></url>
+  <url><loc>https://example.com/blog</loc><priority>0.3</priority></url>
+  <url><loc>https://example.com/</loc><priority>0.1</priority></url>
+</urlset>`,
+			wantURLs:       []string{"https://example.com", "https://example.com/about", "https://example.com/blog"},
+			wantPriorities: map[string]float64{"https://example.com": 1, "https://example.com/blog": 0.3},
+		},
+		{
+			name: "sitemap index",
+			xml: `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>https://example.com/sitemap-1.xml</loc></sitemap>
+  <sitemap><loc>ftp://example.com/sitemap-2.xml</loc></sitemap>
+</sitemapindex>`,
+			wantURLs: []string{"https://example.com/sitemap-1.xml"},
+		},
+		{
+			name:     "relative loc and bad priority",
+			xml:      `<urlset><url><loc>/page</loc><priority>high</priority></url></urlset>`,
+			wantURLs: []string{"https://example.com/page"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if !isSitemap([]byte(tt.xml)) {
+				t.Fatal("isSitemap = false, want true")
+			}
+			doc, err := ExtractSitemap(strings.NewReader(tt.xml), "https://example.com/sitemap.xml")
+			if err != nil {
+				t.Fatalf("ExtractSitemap: %v", err)
+			}
+			if !slices.Equal(doc.Links, tt.wantURLs) {
+				t.Errorf("links = %v, want %v", doc.Links, tt.wantURLs)
+			}
+			if len(doc.SitemapPriorities) != len(tt.wantPriorities) {
+				t.Errorf("priorities = %v, want %v", doc.SitemapPriorities, tt.wantPriorities)
+			}
+			for u, want := range tt.wantPriorities {
+				if got, ok := doc.SitemapPriorities[u]; !ok || got != want {
+					t.Errorf("priority of %s = %v, want %v", u, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestIsSitemap_HTML(t *testing.T) {
+	t.Parallel()
+	if isSitemap([]byte(`<!DOCTYPE html><html><body><p>&lt;urlset&gt;</p></body></html>`)) {
+		t.Error("an HTML page was taken for a sitemap")
+	}
+}
@@ -0,0 +1,216 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Extract reads an HTML document from r in a single pass, writing its visible
+// body text to text and returning the links found on it, resolved against
+// baseURL. It holds only the current token in memory rather than a whole
+// DOM. When baseURL is invalid no links are returned.
+func Extract(r io.Reader, baseURL string, text io.Writer) ([]string, error) {
+	doc, err := ExtractDocument(r, baseURL, text)
+	return doc.Links, err
+}
+
+// maxTitleBytes caps the title kept from a document.
+const maxTitleBytes = 1024
+
+// Document is what ExtractDocument finds in a page besides its text.
+type Document struct {
+	Links []string
+	// Title is the page's <title>, whitespace collapsed.
+	Title string
+	// Language is the primary language subtag of the page's declared
+	// language ("en" for lang="en-US"), lowercased, or "" if none is
+	// declared.
+	Language string
+	// SitemapPriorities holds the <priority> of the links of a sitemap
+	// that give one. It is nil for HTML pages.
+	SitemapPriorities map[string]float64
+}
+
+// ExtractDocument is Extract that also returns the page's title and
+// declared language, from the <html lang> attribute or a Content-Language
+// <meta http-equiv>.
+func ExtractDocument(r io.Reader, baseURL string, text io.Writer) (Document, error) {
+	base, baseErr := url.Parse(baseURL)
+
+	z := html.NewTokenizer(r)
+	w := &trimWriter{w: text}
+	seen := make(map[string]struct{})
+	var doc Document
+	var title []byte
+
+	var inHead, inTitle bool
+	var skip atom.Atom // raw-text element whose content is not visible
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			doc.Title = strings.Join(strings.Fields(string(title)), " ")
+			if err := z.Err(); !errors.Is(err, io.EOF) {
+				return doc, err
+			}
+			return doc, nil
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch a := atom.Lookup(name); a {
+			case atom.Html:
+				if hasAttr && doc.Language == "" {
+					doc.Language = primaryLanguage(attr(z, "lang"))
+				}
+			case atom.Meta:
+				if hasAttr && doc.Language == "" {
+					doc.Language = metaLanguage(z)
+				}
+			case atom.Head:
+				inHead = true
+			case atom.Body:
+				inHead = false
+			case atom.Title:
+				inTitle = true
+			case atom.Script, atom.Style, atom.Noscript, atom.Iframe:
+				skip = a
+			case atom.A:
+				if !hasAttr || baseErr != nil {
+					continue
+				}
+				for {
+					key, val, more := z.TagAttr()
+					if string(key) == "href" {
+						if link, ok := resolveLink(base, string(val)); ok {
+							if _, dup := seen[link]; !dup {
+								seen[link] = struct{}{}
+								doc.Links = append(doc.Links, link)
+							}
+						}
+						break
+					}
+					if !more {
+						break
+					}
+				}
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch a := atom.Lookup(name); a {
+			case atom.Head:
+				inHead = false
+			case atom.Title:
+				inTitle = false
+			case skip:
+				skip = 0
+			}
+
+		case html.TextToken:
+			if inTitle && len(title) < maxTitleBytes {
+				title = append(title, z.Text()...)
+				continue
+			}
+			if inHead || inTitle || skip != 0 {
+				continue
+			}
+			if err := w.write(z.Text()); err != nil {
+				return doc, err
+			}
+		}
+	}
+}
+
+// attr returns the value of the named attribute of the current tag. It
+// consumes the tag's attributes.
+func attr(z *html.Tokenizer, name string) string {
+	for {
+		key, val, more := z.TagAttr()
+		if string(key) == name {
+			return string(val)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// metaLanguage returns the language of a <meta http-equiv="content-language">
+// tag, or "" for any other meta tag. It consumes the tag's attributes.
+func metaLanguage(z *html.Tokenizer) string {
+	var equiv, content string
+	for {
+		key, val, more := z.TagAttr()
+		switch string(key) {
+		case "http-equiv":
+			equiv = string(val)
+		case "content":
+			content = string(val)
+		}
+		if !more {
+			break
+		}
+	}
+	if !strings.EqualFold(equiv, "content-language") {
+		return ""
+	}
+	// The header may list several languages; the first is the primary one.
+	first, _, _ := strings.Cut(content, ",")
+	return primaryLanguage(first)
+}
+
+// primaryLanguage returns the lowercased primary subtag of a BCP 47 tag, or
+// "" if it does not look like one.
+func primaryLanguage(tag string) string {
+	primary, _, _ := strings.Cut(strings.TrimSpace(tag), "-")
+	primary, _, _ = strings.Cut(primary, "_")
+	if len(primary) < 2 || len(primary) > 3 {
+		return ""
+	}
+	for _, r := range primary {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return ""
+		}
+	}
+	return strings.ToLower(primary)
+}
+
+// trimWriter writes text with leading and trailing whitespace removed. It
+// holds back each whitespace run until it knows more text follows.
+type trimWriter struct {
+	w       io.Writer
+	started bool
+	pending []byte
+}
+
+func (t *trimWriter) write(p []byte) error {
+	if !t.started {
+		p = bytes.TrimLeftFunc(p, unicode.IsSpace)
+		if len(p) == 0 {
+			return nil
+		}
+		t.started = true
+	}
+	core := bytes.TrimRightFunc(p, unicode.IsSpace)
+	if len(core) == 0 {
+		t.pending = append(t.pending, p...)
+		return nil
+	}
+	if len(t.pending) > 0 {
+		if _, err := t.w.Write(t.pending); err != nil {
+			return err
+		}
+		t.pending = t.pending[:0]
+	}
+	if _, err := t.w.Write(core); err != nil {
+		return err
+	}
+	t.pending = append(t.pending, p[len(core):]...)
+	return nil
+}
@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		html     string
+		baseURL  string
+		wantText string
+		wantURLs []string
+	}{
+		{
+			name:     "text and links",
+			html:     `<html><head><title>Title</title></head><body><p>Hello <b>World</b></p><a href="/about">about</a></body></html>`,
+			baseURL:  "https://example.com",
+			wantText: "Hello Worldabout",
+			wantURLs: []string{"https://example.com/about"},
+		},
+		{
+			name:     "strips script style noscript iframe",
+			html:     `<html><body><script>var x=1;</script><style>.a{}</style><noscript>no</noscript><iframe>frame</iframe><p>Visible</p></body></html>`,
+			baseURL:  "https://example.com",
+			wantText: "Visible",
+		},
+		{
+			name:     "surrounding whitespace trimmed",
+			html:     "<html><body>\n  <p>one</p>\n  <p>two</p>\n</body></html>\n",
+			baseURL:  "https://example.com",
+			wantText: "one\n  two",
+		},
+		{
+			name:     "entities and duplicate links",
+			html:     `<html><body><a href="https://Example.COM/p?b=2&amp;a=1#x">a &amp; b</a><a href="https://example.com/p?a=1&b=2">again</a></body></html>`,
+			baseURL:  "https://example.com",
+			wantText: "a & bagain",
+			wantURLs: []string{"https://example.com/p?a=1&b=2"},
+		},
+		{
+			name:     "filtered links",
+			html:     `<html><body><a href="javascript:void(0)">js</a><a href="mailto:a@b.c">m</a><a href="">e</a><a href="ftp://x.com/f">f</a><a href="#top">h</a></body></html>`,
+			baseURL:  "https://example.com",
+			wantText: "jsmefh",
+		},
+		{
+			name:     "invalid base URL",
+			html:     `<html><body><a href="/page">link</a></body></html>`,
+			baseURL:  "://invalid",
+			wantText: "link",
+		},
+		{
+			name:    "empty body",
+			html:    `<html><body></body></html>`,
+			baseURL: "https://example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			text, urls := extract(t, tt.html, tt.baseURL)
+			if !slices.Equal(urls, tt.wantURLs) {
+				t.Errorf("urls = %v, want %v", urls, tt.wantURLs)
+			}
+			if text != tt.wantText {
+				t.Errorf("text = %q, want %q", text, tt.wantText)
+			}
+		})
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("write failed") }
+
+func TestExtract_WriteError(t *testing.T) {
+	t.Parallel()
+	_, err := Extract(strings.NewReader(`<html><body><p>text</p></body></html>`), "https://example.com", failingWriter{})
+	if err == nil {
+		t.Fatal("expected error from text writer")
+	}
+}
+
+func TestExtractDocument_TitleAndLanguage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		html      string
+		wantTitle string
+		wantLang  string
+	}{
+		{name: "html lang", html: `<html lang="en-US"><head><title> Hello
+			World </title></head><body>x</body></html>`, wantTitle: "Hello World", wantLang: "en"},
+		{name: "meta content-language", html: `<html><head><meta http-equiv="Content-Language" content="de, en"><title>T</title></head></html>`, wantTitle: "T", wantLang: "de"},
+		{name: "html lang wins over meta", html: `<html lang="fr"><head><meta http-equiv="content-language" content="de"></head></html>`, wantLang: "fr"},
+		{name: "other meta ignored", html: `<html><head><meta name="description" content="en"></head></html>`},
+		{name: "invalid lang", html: `<html lang="x-klingon-1"><body></body></html>`},
+		{name: "none", html: `<p>text</p>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var text strings.Builder
+			doc, err := ExtractDocument(strings.NewReader(tt.html), "https://example.com", &text)
+			if err != nil {
+				t.Fatalf("ExtractDocument: %v", err)
+			}
+			if doc.Title != tt.wantTitle || doc.Language != tt.wantLang {
+				t.Errorf("title, language = %q, %q, want %q, %q", doc.Title, doc.Language, tt.wantTitle, tt.wantLang)
+			}
+		})
+	}
+}
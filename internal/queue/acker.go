@@ -0,0 +1,128 @@
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// ackBatchSize flushes pending acks once this many have queued up.
+	ackBatchSize = 128
+	// ackFlushInterval bounds how long an ack waits for others to batch with.
+	ackFlushInterval = 5 * time.Millisecond
+)
+
+// deadLetterScript copies an entry to the dead-letter stream and acks it in
+// one atomic step, so a message is never in both or neither.
+// KEYS: stream, dlq. ARGV: group, id, field, value, ...
+var deadLetterScript = redis.NewScript(`
+redis.call('XADD', KEYS[2], '*', unpack(ARGV, 3))
+return redis.call('XACK', KEYS[1], ARGV[1], ARGV[2])
+`)
+
+type ackRequest struct {
+	stream string
+	id     string
+	done   chan error
+}
+
+// ack acknowledges one entry. While Run is active the ack is batched with
+// others and sent in a single pipeline; it still returns only once Redis has
+// applied it, so callers see the same durability as a direct XACK.
+func (c *RedisConsumer) ack(stream, id string) error {
+	c.ackMu.Lock()
+	stopped := c.ackerDone
+	c.ackMu.Unlock()
+
+	req := ackRequest{stream: stream, id: id, done: make(chan error, 1)}
+	select {
+	case c.acks <- req:
+		return <-req.done
+	case <-stopped:
+		return c.xack(stream, id)
+	}
+}
+
+// xack acknowledges one entry with its own round trip.
+func (c *RedisConsumer) xack(stream, id string) error {
+	ctx, cancel := ctxBG()
+	defer cancel()
+	return c.rdb.XAck(ctx, stream, c.group, id).Err()
+}
+
+// startAcks routes acks to an ackLoop from now on and returns the channel
+// that loop must close when it exits.
+func (c *RedisConsumer) startAcks() chan struct{} {
+	done := make(chan struct{})
+	c.ackMu.Lock()
+	c.ackerDone = done
+	c.ackMu.Unlock()
+	return done
+}
+
+// ackLoop collects ack requests and flushes them as a batch once it is full,
+// once ackFlushInterval has passed since its first ack, or as soon as no
+// more acks are waiting. A lone ack is therefore sent straight away, while
+// acks that arrive during a flush are grouped into the next one. On shutdown
+// it stops accepting acks and closes done; later ones fall back to xack.
+func (c *RedisConsumer) ackLoop(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	batch := make([]ackRequest, 0, ackBatchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case req := <-c.acks:
+			batch = append(batch[:0], req)
+		}
+
+		deadline := time.Now().Add(ackFlushInterval)
+	collect:
+		for len(batch) < ackBatchSize && time.Now().Before(deadline) {
+			select {
+			case req := <-c.acks:
+				batch = append(batch, req)
+			default:
+				break collect
+			}
+		}
+		c.flushAcks(batch)
+	}
+}
+
+// flushAcks sends one XACK per stream for the batch in a single pipeline and
+// reports each stream's result to its waiters.
+func (c *RedisConsumer) flushAcks(batch []ackRequest) {
+	if len(batch) == 0 {
+		return
+	}
+	byStream := make(map[string][]string)
+	for _, req := range batch {
+		byStream[req.stream] = append(byStream[req.stream], req.id)
+	}
+
+	ctx, cancel := ctxBG()
+	defer cancel()
+	pipe := c.rdb.Pipeline()
+	cmds := make(map[string]*redis.IntCmd, len(byStream))
+	for stream, ids := range byStream {
+		cmds[stream] = pipe.XAck(ctx, stream, c.group, ids...)
+	}
+	_, _ = pipe.Exec(ctx)
+
+	for _, req := range batch {
+		req.done <- cmds[req.stream].Err()
+	}
+}
+
+// deadLetter moves an entry to the dead-letter stream with the given
+// field/value pairs.
+func (c *RedisConsumer) deadLetter(stream, id string, fields ...interface{}) error {
+	args := append([]interface{}{c.group, id}, fields...)
+	ctx, cancel := ctxBG()
+	defer cancel()
+	return deadLetterScript.Run(ctx, c.rdb, []string{stream, c.dlq}, args...).Err()
+}
@@ -0,0 +1,186 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// readPending adds n entries to stream:test and reads them into the test
+// consumer's PEL.
+func readPending(t testing.TB, rdb *redis.Client, n int) []string {
+	t.Helper()
+	ctx := context.Background()
+	for i := 0; i < n; i++ {
+		if err := rdb.XAdd(ctx, &redis.XAddArgs{
+			Stream: "stream:test",
+			Values: map[string]interface{}{"payload": fmt.Sprint(i)},
+		}).Err(); err != nil {
+			t.Fatalf("XAdd: %v", err)
+		}
+	}
+	res, err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: "test-group", Consumer: "test-consumer", Streams: []string{"stream:test", ">"}, Count: int64(n),
+	}).Result()
+	if err != nil {
+		t.Fatalf("XReadGroup: %v", err)
+	}
+	ids := make([]string, 0, n)
+	for _, msg := range res[0].Messages {
+		ids = append(ids, msg.ID)
+	}
+	return ids
+}
+
+func pendingCount(t testing.TB, rdb *redis.Client) int64 {
+	t.Helper()
+	p, err := rdb.XPending(context.Background(), "stream:test", "test-group").Result()
+	if err != nil {
+		t.Fatalf("XPending: %v", err)
+	}
+	return p.Count
+}
+
+func TestAck_BatchesConcurrentAcks(t *testing.T) {
+	t.Parallel()
+	mr, rdb, c := setupConsumer(t)
+	ids := readPending(t, rdb, 300)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.ackLoop(ctx, c.startAcks())
+
+	before := mr.CommandCount()
+	var wg sync.WaitGroup
+	for _, id := range ids {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			if err := c.ack("stream:test", id); err != nil {
+				t.Errorf("ack %s: %v", id, err)
+			}
+		}(id)
+	}
+	wg.Wait()
+
+	if n := pendingCount(t, rdb); n != 0 {
+		t.Errorf("PEL count = %d after acks returned, want 0", n)
+	}
+	if cmds := mr.CommandCount() - before; cmds >= len(ids) {
+		t.Errorf("%d Redis commands for %d acks, want them batched", cmds, len(ids))
+	}
+}
+
+func TestAck_AfterShutdownFallsBackToDirect(t *testing.T) {
+	t.Parallel()
+	_, rdb, c := setupConsumer(t)
+	ids := readPending(t, rdb, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.Run(ctx)
+	cancel()
+	c.Wait()
+
+	if err := c.ack("stream:test", ids[0]); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	if n := pendingCount(t, rdb); n != 0 {
+		t.Errorf("PEL count = %d, want 0", n)
+	}
+}
+
+func TestAck_DuringRun(t *testing.T) {
+	t.Parallel()
+	_, rdb, c := setupConsumer(t)
+	ids := readPending(t, rdb, 50)
+
+	// Acks racing Run and its shutdown must all land, whichever path they take.
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for _, id := range ids {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			if err := c.ack("stream:test", id); err != nil {
+				t.Errorf("ack %s: %v", id, err)
+			}
+		}(id)
+	}
+	c.Run(ctx)
+	cancel()
+	wg.Wait()
+	c.Wait()
+
+	if n := pendingCount(t, rdb); n != 0 {
+		t.Errorf("PEL count = %d, want 0", n)
+	}
+}
+
+// latencyHook delays every command and pipeline by a fixed round-trip time,
+// standing in for the network between workers and Redis.
+type latencyHook time.Duration
+
+func (h latencyHook) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h latencyHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		time.Sleep(time.Duration(h))
+		return next(ctx, cmd)
+	}
+}
+
+func (h latencyHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		time.Sleep(time.Duration(h))
+		return next(ctx, cmds)
+	}
+}
+
+// BenchmarkAck compares one XACK round trip per ack with batched acks from
+// concurrent workers over a simulated 200µs round trip. redis-cmds/op is
+// the number of commands Redis served per ack, the load batching removes;
+// ns/op favours direct acks here because miniredis never saturates.
+func BenchmarkAck(b *testing.B) {
+	for _, mode := range []string{"direct", "batched"} {
+		b.Run(mode, func(b *testing.B) {
+			mr := miniredis.RunT(b)
+			rdb := redis.NewClient(&redis.Options{Addr: mr.Addr(), PoolSize: 64})
+			if err := rdb.XGroupCreateMkStream(context.Background(), "stream:test", "test-group", "0").Err(); err != nil {
+				b.Fatalf("XGroupCreateMkStream: %v", err)
+			}
+			c := NewRedisConsumer(rdb, "stream:test", "stream:test:dlq", "test-group", "test-consumer", 10, testLogger())
+			ids := readPending(b, rdb, b.N)
+			rdb.AddHook(latencyHook(200 * time.Microsecond))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if mode == "batched" {
+				go c.ackLoop(ctx, c.startAcks())
+			}
+
+			var next sync.Mutex
+			i := 0
+			before := mr.CommandCount()
+			b.SetParallelism(8)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					next.Lock()
+					id := ids[i]
+					i++
+					next.Unlock()
+					if err := c.ack("stream:test", id); err != nil {
+						b.Error(err)
+					}
+				}
+			})
+			b.StopTimer()
+			b.ReportMetric(float64(mr.CommandCount()-before)/float64(b.N), "redis-cmds/op")
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Queue backend names accepted by Open. BackendMemory connects only the
+// components of one process.
+const (
+	BackendRedis  = "redis"
+	BackendNATS   = "nats"
+	BackendMemory = "memory"
+)
+
+// Options selects and configures the backend Open returns.
+type Options struct {
+	// Backend is BackendRedis (the default), BackendNATS or BackendMemory.
+	Backend string
+	// Encoding is the envelope encoding published; see ParseEncoding.
+	Encoding string
+	// NATSURL is the server the NATS backend connects to.
+	NATSURL string
+	// Partitions is the number of frontier partitions (Redis only), whose
+	// leases expire after LeaseTTL.
+	Partitions int
+	LeaseTTL   time.Duration
+	// LaneWeights weighs the frontier priority lanes; see FrontierLanes.
+	LaneWeights []int
+}
+
+// Backend is a queue transport. It hands out the publisher and the consumers
+// of the frontier and parse queues.
+type Backend interface {
+	Publisher() Publisher
+	// FrontierConsumer returns a crawler consumer identified by name.
+	FrontierConsumer(name string, count int) Consumer
+	// ParseConsumer returns a parser consumer identified by name.
+	ParseConsumer(name string, count int) Consumer
+	Close()
+}
+
+// Open returns the backend selected by opts.Backend, creating its streams if
+// needed. rdb is only used by the Redis backend.
+func Open(ctx context.Context, opts Options, rdb *redis.Client, logger *slog.Logger) (Backend, error) {
+	enc, err := ParseEncoding(opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	switch opts.Backend {
+	case "", BackendRedis:
+		if err := EnsureStreams(ctx, rdb, opts.Partitions, logger); err != nil {
+			return nil, fmt.Errorf("ensuring streams: %w", err)
+		}
+		return NewRedisBackend(rdb, opts.Partitions, opts.LeaseTTL, opts.LaneWeights, enc, logger), nil
+	case BackendNATS:
+		return NewNATSBackend(ctx, opts.NATSURL, enc, opts.LaneWeights, logger)
+	case BackendMemory:
+		logger.Warn("using in-memory queue backend: messages are not shared between processes and are lost on exit")
+		return NewMemoryBroker(), nil
+	default:
+		return nil, fmt.Errorf("unknown queue backend %q: want %s, %s or %s", opts.Backend, BackendRedis, BackendNATS, BackendMemory)
+	}
+}
+
+// RedisBackend is the Redis Streams transport. With more than one frontier
+// partition, each frontier consumer leases its share of partitions.
+type RedisBackend struct {
+	rdb        *redis.Client
+	partitions int
+	leaseTTL   time.Duration
+	weights    []int
+	publisher  *RedisPublisher
+	logger     *slog.Logger
+}
+
+func NewRedisBackend(rdb *redis.Client, partitions int, leaseTTL time.Duration, weights []int, enc Encoding, logger *slog.Logger) *RedisBackend {
+	return &RedisBackend{
+		rdb:        rdb,
+		partitions: max(partitions, 1),
+		leaseTTL:   leaseTTL,
+		weights:    weights,
+		publisher:  NewRedisPublisher(rdb, partitions, enc),
+		logger:     logger,
+	}
+}
+
+func (b *RedisBackend) Publisher() Publisher {
+	return b.publisher
+}
+
+// FrontierConsumer reads every frontier lane when there is one partition.
+// Otherwise it reads the lanes of the partitions leased under name.
+func (b *RedisBackend) FrontierConsumer(name string, count int) Consumer {
+	if b.partitions == 1 {
+		lanes := StaticLanes(FrontierLanes(b.weights))
+		return NewRedisLaneConsumer(b.rdb, lanes, FrontierDLQ, CrawlerGroup, name, count, b.logger)
+	}
+	leaser := NewPartitionLeaser(b.rdb, NewRegistry(b.rdb, CrawlerGroup), name, b.partitions, b.weights, b.leaseTTL, b.logger)
+	return &leasedConsumer{
+		RedisConsumer: NewRedisLaneConsumer(b.rdb, leaser, FrontierDLQ, CrawlerGroup, name, count, b.logger),
+		leaser:        leaser,
+		logger:        b.logger,
+	}
+}
+
+func (b *RedisBackend) ParseConsumer(name string, count int) Consumer {
+	return NewRedisConsumer(b.rdb, ParseStream, ParseDLQ, ParserGroup, name, count, b.logger)
+}
+
+// Close is a no-op; the Redis client belongs to the caller.
+func (b *RedisBackend) Close() {}
+
+// leasedConsumer runs a PartitionLeaser alongside the consumer reading its lanes.
+type leasedConsumer struct {
+	*RedisConsumer
+	leaser *PartitionLeaser
+	logger *slog.Logger
+	wg     sync.WaitGroup
+}
+
+func (c *leasedConsumer) Run(ctx context.Context) <-chan Delivery {
+	c.leaser.Rebalance(ctx)
+	c.logger.Info("frontier partitioned", "partitions", c.leaser.partitions, "owned", c.leaser.Owned())
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.leaser.Run(ctx)
+	}()
+	return c.RedisConsumer.Run(ctx)
+}
+
+func (c *leasedConsumer) Wait() {
+	c.RedisConsumer.Wait()
+	c.wg.Wait()
+}
+
+var _ Backend = (*RedisBackend)(nil)
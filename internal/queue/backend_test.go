@@ -0,0 +1,65 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestOpen_SelectsBackend(t *testing.T) {
+	t.Parallel()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+
+	b, err := Open(ctx, Options{Backend: BackendRedis, Partitions: 1}, rdb, testLogger())
+	if err != nil {
+		t.Fatalf("Open(redis): %v", err)
+	}
+	if _, ok := b.(*RedisBackend); !ok {
+		t.Errorf("Open(redis) = %T, want *RedisBackend", b)
+	}
+	if !mr.Exists(ParseStream) {
+		t.Error("Open(redis) did not create the parse stream")
+	}
+
+	b, err = Open(ctx, Options{Backend: BackendMemory}, nil, testLogger())
+	if err != nil {
+		t.Fatalf("Open(memory): %v", err)
+	}
+	if _, ok := b.(*MemoryBroker); !ok {
+		t.Errorf("Open(memory) = %T, want *MemoryBroker", b)
+	}
+
+	if _, err := Open(ctx, Options{Backend: "kafka"}, nil, testLogger()); err == nil {
+		t.Error("Open(kafka) should fail")
+	}
+}
+
+func TestRedisBackend_PartitionedFrontierConsumerLeases(t *testing.T) {
+	t.Parallel()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if err := EnsureStreams(ctx, rdb, 2, testLogger()); err != nil {
+		t.Fatalf("EnsureStreams: %v", err)
+	}
+	b := NewRedisBackend(rdb, 2, 30*time.Second, nil, EncodingJSON, testLogger())
+	c := b.FrontierConsumer("crawler-a", 1)
+	c.Run(ctx)
+
+	for p := 0; p < 2; p++ {
+		if owner, _ := rdb.Get(ctx, leaseKey(p)).Result(); owner != "crawler-a" {
+			t.Errorf("partition %d leased by %q, want crawler-a", p, owner)
+		}
+	}
+	cancel()
+	c.Wait()
+	if n, _ := rdb.Exists(context.Background(), leaseKey(0), leaseKey(1)).Result(); n != 0 {
+		t.Errorf("%d leases still held after shutdown, want 0", n)
+	}
+}
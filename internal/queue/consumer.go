@@ -3,6 +3,8 @@ package queue
 import (
 	"context"
 	"log/slog"
+	"math/rand"
+	"slices"
 	"sync"
 	"time"
 
@@ -15,37 +17,86 @@ const (
 	reclaimMinIdle   = 30 * time.Second
 	reclaimBatchSize = 50
 	ackTimeout       = 5 * time.Second
+
+	heartbeatInterval = 10 * time.Second
+	janitorInterval   = 30 * time.Second
+	// consumerDeadAfter is how long a consumer may go without a heartbeat
+	// before its pending entries are claimed and it is removed from the group.
+	consumerDeadAfter = 60 * time.Second
 )
 
-type Consumer struct {
+// LaneSource supplies the lanes a Consumer reads. It is consulted on every
+// read, so the set may change while the consumer runs.
+type LaneSource interface {
+	Lanes() []Lane
+}
+
+// StaticLanes is a fixed LaneSource.
+type StaticLanes []Lane
+
+func (s StaticLanes) Lanes() []Lane { return s }
+
+// RedisConsumer reads Redis streams through a consumer group.
+type RedisConsumer struct {
 	rdb      *redis.Client
-	stream   string
+	source   LaneSource
 	dlq      string
 	group    string
 	consumer string
 	count    int
+	registry *Registry
 	logger   *slog.Logger
 	wg       sync.WaitGroup
+
+	acks      chan ackRequest
+	ackMu     sync.Mutex
+	ackerDone chan struct{} // closed while no ackLoop is running; guarded by ackMu
+}
+
+func NewRedisConsumer(rdb *redis.Client, stream, dlq, group, consumerName string, count int, logger *slog.Logger) *RedisConsumer {
+	return NewRedisLaneConsumer(rdb, StaticLanes{{Stream: stream, Weight: 1}}, dlq, group, consumerName, count, logger)
 }
 
-func NewConsumer(rdb *redis.Client, stream, dlq, group, consumerName string, count int, logger *slog.Logger) *Consumer {
-	return &Consumer{
-		rdb:      rdb,
-		stream:   stream,
-		dlq:      dlq,
-		group:    group,
-		consumer: consumerName,
-		count:    count,
-		logger:   logger,
+// NewRedisLaneConsumer returns a consumer that reads several streams (lanes) of the
+// same group, preferring lanes in proportion to their weight. Entries from any
+// lane that are dead-lettered go to the single dlq stream.
+func NewRedisLaneConsumer(rdb *redis.Client, source LaneSource, dlq, group, consumerName string, count int, logger *slog.Logger) *RedisConsumer {
+	return &RedisConsumer{
+		rdb:       rdb,
+		source:    source,
+		dlq:       dlq,
+		group:     group,
+		consumer:  consumerName,
+		count:     count,
+		registry:  NewRegistry(rdb, group),
+		logger:    logger,
+		acks:      make(chan ackRequest),
+		ackerDone: closedChan(),
 	}
 }
 
-// Run starts reading from the stream and returns a channel of Delivery.
+func closedChan() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}
+
+// Run starts reading from the stream(s) and returns a channel of Delivery.
 // The channel is closed when ctx is cancelled and both loops exit.
-func (c *Consumer) Run(ctx context.Context) <-chan Delivery {
+func (c *RedisConsumer) Run(ctx context.Context) <-chan Delivery {
 	ch := make(chan Delivery, c.count)
 
-	c.wg.Add(2)
+	// Register before reading so peers never see our PEL without a heartbeat.
+	if err := c.registry.Heartbeat(ctx, c.consumer); err != nil {
+		c.logger.Warn("failed to register consumer", "error", err, "consumer", c.consumer)
+	}
+
+	ackerDone := c.startAcks()
+	c.wg.Add(5)
+	go func() {
+		defer c.wg.Done()
+		c.ackLoop(ctx, ackerDone)
+	}()
 	go func() {
 		defer c.wg.Done()
 		c.readLoop(ctx, ch)
@@ -54,6 +105,14 @@ func (c *Consumer) Run(ctx context.Context) <-chan Delivery {
 		defer c.wg.Done()
 		c.reclaimLoop(ctx, ch)
 	}()
+	go func() {
+		defer c.wg.Done()
+		c.heartbeatLoop(ctx)
+	}()
+	go func() {
+		defer c.wg.Done()
+		c.janitorLoop(ctx, ch)
+	}()
 	go func() {
 		c.wg.Wait()
 		close(ch)
@@ -63,24 +122,17 @@ func (c *Consumer) Run(ctx context.Context) <-chan Delivery {
 }
 
 // Wait blocks until the consumer's internal goroutines have fully exited.
-func (c *Consumer) Wait() {
+func (c *RedisConsumer) Wait() {
 	c.wg.Wait()
 }
 
-func (c *Consumer) readLoop(ctx context.Context, ch chan<- Delivery) {
+func (c *RedisConsumer) readLoop(ctx context.Context, ch chan<- Delivery) {
 	for {
 		if ctx.Err() != nil {
 			return
 		}
 
-		streams, err := c.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
-			Group:    c.group,
-			Consumer: c.consumer,
-			Streams:  []string{c.stream, ">"},
-			Count:    int64(c.count),
-			Block:    blockDuration,
-		}).Result()
-
+		streams, err := c.read(ctx)
 		if err != nil {
 			if ctx.Err() != nil {
 				return
@@ -88,14 +140,14 @@ func (c *Consumer) readLoop(ctx context.Context, ch chan<- Delivery) {
 			if err == redis.Nil {
 				continue
 			}
-			c.logger.Error("XREADGROUP error", "error", err, "stream", c.stream)
+			c.logger.Error("XREADGROUP error", "error", err, "streams", streamNames(c.source.Lanes()))
 			time.Sleep(time.Second)
 			continue
 		}
 
 		for _, stream := range streams {
 			for _, msg := range stream.Messages {
-				d, ok := c.buildDelivery(msg)
+				d, ok := c.buildDelivery(stream.Stream, msg)
 				if !ok {
 					continue
 				}
@@ -109,7 +161,90 @@ func (c *Consumer) readLoop(ctx context.Context, ch chan<- Delivery) {
 	}
 }
 
-func (c *Consumer) reclaimLoop(ctx context.Context, ch chan<- Delivery) {
+// read fetches the next batch. With several lanes it first polls them without
+// blocking, starting from a lane picked at random in proportion to its weight,
+// so heavier lanes are preferred but lighter lanes are never starved. Only
+// when every lane is empty does it block on all of them at once.
+func (c *RedisConsumer) read(ctx context.Context) ([]redis.XStream, error) {
+	lanes := c.source.Lanes()
+	if len(lanes) == 0 {
+		// Nothing assigned to us (e.g. all partitions leased elsewhere).
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(blockDuration):
+			return nil, redis.Nil
+		}
+	}
+	if len(lanes) > 1 {
+		for _, lane := range pollOrder(lanes) {
+			streams, err := c.xreadgroup(ctx, []string{lane.Stream}, -1)
+			if err == redis.Nil {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			return streams, nil
+		}
+	}
+	return c.xreadgroup(ctx, streamNames(lanes), blockDuration)
+}
+
+func (c *RedisConsumer) xreadgroup(ctx context.Context, streams []string, block time.Duration) ([]redis.XStream, error) {
+	args := make([]string, 0, 2*len(streams))
+	args = append(args, streams...)
+	for range streams {
+		args = append(args, ">")
+	}
+	return c.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    c.group,
+		Consumer: c.consumer,
+		Streams:  args,
+		Count:    int64(c.count),
+		Block:    block,
+	}).Result()
+}
+
+// pollOrder returns the lanes with a weighted-random first pick followed by
+// the remaining lanes from heaviest to lightest.
+func pollOrder(lanes []Lane) []Lane {
+	total := 0
+	for _, l := range lanes {
+		total += max(l.Weight, 1)
+	}
+	pick := rand.Intn(total)
+	first := 0
+	for i, l := range lanes {
+		pick -= max(l.Weight, 1)
+		if pick < 0 {
+			first = i
+			break
+		}
+	}
+
+	order := make([]Lane, 0, len(lanes))
+	order = append(order, lanes[first])
+	for i, l := range lanes {
+		if i != first {
+			order = append(order, l)
+		}
+	}
+	slices.SortStableFunc(order[1:], func(a, b Lane) int {
+		return b.Weight - a.Weight
+	})
+	return order
+}
+
+func streamNames(lanes []Lane) []string {
+	names := make([]string, len(lanes))
+	for i, l := range lanes {
+		names[i] = l.Stream
+	}
+	return names
+}
+
+func (c *RedisConsumer) reclaimLoop(ctx context.Context, ch chan<- Delivery) {
 	ticker := time.NewTicker(reclaimInterval)
 	defer ticker.Stop()
 
@@ -118,16 +253,18 @@ func (c *Consumer) reclaimLoop(ctx context.Context, ch chan<- Delivery) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			c.reclaimPending(ctx, ch)
+			for _, lane := range c.source.Lanes() {
+				c.reclaimPending(ctx, ch, lane.Stream)
+			}
 		}
 	}
 }
 
-func (c *Consumer) reclaimPending(ctx context.Context, ch chan<- Delivery) {
+func (c *RedisConsumer) reclaimPending(ctx context.Context, ch chan<- Delivery, stream string) {
 	start := "0-0"
 	for {
 		msgs, newStart, err := c.rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{
-			Stream:   c.stream,
+			Stream:   stream,
 			Group:    c.group,
 			Consumer: c.consumer,
 			MinIdle:  reclaimMinIdle,
@@ -137,13 +274,13 @@ func (c *Consumer) reclaimPending(ctx context.Context, ch chan<- Delivery) {
 
 		if err != nil {
 			if ctx.Err() == nil {
-				c.logger.Error("XAUTOCLAIM error", "error", err, "stream", c.stream)
+				c.logger.Error("XAUTOCLAIM error", "error", err, "stream", stream)
 			}
 			return
 		}
 
 		for _, msg := range msgs {
-			d, ok := c.buildDelivery(msg)
+			d, ok := c.buildDelivery(stream, msg)
 			if !ok {
 				continue
 			}
@@ -161,44 +298,260 @@ func (c *Consumer) reclaimPending(ctx context.Context, ch chan<- Delivery) {
 	}
 }
 
-func (c *Consumer) buildDelivery(msg redis.XMessage) (Delivery, bool) {
-	payload, ok := msg.Values["payload"].(string)
+func (c *RedisConsumer) heartbeatLoop(ctx context.Context) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Deregister so peers can reap our leftover PEL entries once idle.
+			rmCtx, cancel := ctxBG()
+			defer cancel()
+			if err := c.registry.Remove(rmCtx, c.consumer); err != nil {
+				c.logger.Warn("failed to deregister consumer", "error", err, "consumer", c.consumer)
+			}
+			return
+		case <-ticker.C:
+			if err := c.registry.Heartbeat(ctx, c.consumer); err != nil && ctx.Err() == nil {
+				c.logger.Warn("consumer heartbeat failed", "error", err, "consumer", c.consumer)
+			}
+		}
+	}
+}
+
+func (c *RedisConsumer) janitorLoop(ctx context.Context, ch chan<- Delivery) {
+	ticker := time.NewTicker(janitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			members := make(map[string]struct{})
+			complete := true
+			for _, lane := range c.source.Lanes() {
+				names, ok := c.reapDeadConsumers(ctx, ch, lane.Stream)
+				for _, n := range names {
+					members[n] = struct{}{}
+				}
+				complete = complete && ok
+			}
+			if complete {
+				c.pruneRegistry(ctx, members)
+			}
+		}
+	}
+}
+
+// reapDeadConsumers claims the pending entries of consumers that have not sent
+// a heartbeat within consumerDeadAfter, then removes them from the group with
+// XGROUP DELCONSUMER. A consumer is only deleted once its PEL is empty, so a
+// live consumer that was misjudged loses nothing and is recreated by its next
+// XREADGROUP. A consumer whose entries cannot be claimed yet is left for the
+// next pass without holding up the others.
+//
+// It returns the consumers still in the stream's group, and false if the group
+// could not be listed.
+func (c *RedisConsumer) reapDeadConsumers(ctx context.Context, ch chan<- Delivery, stream string) ([]string, bool) {
+	live, err := c.registry.Live(ctx, consumerDeadAfter)
+	if err != nil {
+		if ctx.Err() == nil {
+			c.logger.Error("janitor registry error", "error", err, "stream", stream)
+		}
+		return nil, false
+	}
+
+	consumers, err := c.rdb.XInfoConsumers(ctx, stream, c.group).Result()
+	if err != nil {
+		if ctx.Err() == nil {
+			c.logger.Error("XINFO CONSUMERS error", "error", err, "stream", stream)
+		}
+		return nil, false
+	}
+
+	var remaining []string
+	for _, info := range consumers {
+		if info.Name == c.consumer {
+			remaining = append(remaining, info.Name)
+			continue
+		}
+		if _, ok := live[info.Name]; ok {
+			remaining = append(remaining, info.Name)
+			continue
+		}
+		if !c.claimAllFrom(ctx, ch, stream, info.Name) {
+			if ctx.Err() != nil {
+				return nil, false
+			}
+			c.logger.Warn("could not claim all entries of dead consumer", "stream", stream, "consumer", info.Name)
+			remaining = append(remaining, info.Name)
+			continue
+		}
+		if err := c.rdb.XGroupDelConsumer(ctx, stream, c.group, info.Name).Err(); err != nil {
+			c.logger.Error("XGROUP DELCONSUMER error", "error", err, "stream", stream, "consumer", info.Name)
+			remaining = append(remaining, info.Name)
+			continue
+		}
+		c.logger.Info("removed dead consumer", "stream", stream, "consumer", info.Name, "claimed", info.Pending)
+	}
+	return remaining, true
+}
+
+// pruneRegistry removes registry entries whose heartbeat has expired and that
+// no longer own a consumer in any lane's group, such as consumers that died
+// before their first read. Dead consumers that still do are kept until
+// reapDeadConsumers has emptied their PEL and deleted them.
+func (c *RedisConsumer) pruneRegistry(ctx context.Context, members map[string]struct{}) {
+	expired, err := c.registry.Expired(ctx, consumerDeadAfter)
+	if err != nil {
+		if ctx.Err() == nil {
+			c.logger.Error("janitor registry error", "error", err)
+		}
+		return
+	}
+	for _, name := range expired {
+		if _, ok := members[name]; ok {
+			continue
+		}
+		if err := c.registry.Remove(ctx, name); err != nil {
+			c.logger.Error("failed to prune consumer from registry", "error", err, "consumer", name)
+			continue
+		}
+		c.logger.Info("pruned expired consumer from registry", "consumer", name)
+	}
+}
+
+// claimAllFrom moves every pending entry owned by dead to this consumer and
+// delivers it. It returns true once dead's PEL is empty.
+func (c *RedisConsumer) claimAllFrom(ctx context.Context, ch chan<- Delivery, stream, dead string) bool {
+	lastFirst := ""
+	for {
+		pending, err := c.rdb.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream:   stream,
+			Group:    c.group,
+			Start:    "-",
+			End:      "+",
+			Count:    reclaimBatchSize,
+			Consumer: dead,
+		}).Result()
+		if err != nil {
+			if ctx.Err() == nil {
+				c.logger.Error("XPENDING error", "error", err, "stream", stream, "consumer", dead)
+			}
+			return false
+		}
+		if len(pending) == 0 {
+			return true
+		}
+		if pending[0].ID == lastFirst {
+			// Entries are not idle long enough (or were claimed by a peer).
+			return false
+		}
+		lastFirst = pending[0].ID
+
+		ids := make([]string, len(pending))
+		for i, p := range pending {
+			ids[i] = p.ID
+		}
+		// MinIdle guards against racing another janitor: whoever claims first
+		// resets the idle time and the other's XCLAIM becomes a no-op.
+		msgs, err := c.rdb.XClaim(ctx, &redis.XClaimArgs{
+			Stream:   stream,
+			Group:    c.group,
+			Consumer: c.consumer,
+			MinIdle:  consumerDeadAfter,
+			Messages: ids,
+		}).Result()
+		if err != nil {
+			if ctx.Err() == nil {
+				c.logger.Error("XCLAIM error", "error", err, "stream", stream, "consumer", dead)
+			}
+			return false
+		}
+
+		for _, msg := range msgs {
+			d, ok := c.buildDelivery(stream, msg)
+			if !ok {
+				continue
+			}
+			select {
+			case ch <- d:
+			case <-ctx.Done():
+				return false
+			}
+		}
+	}
+}
+
+func (c *RedisConsumer) buildDelivery(stream string, msg redis.XMessage) (Delivery, bool) {
+	payload, ok := msg.Values[payloadField].(string)
 	if !ok || payload == "" {
-		c.logger.Error("message missing payload field", "stream", c.stream, "id", msg.ID)
+		c.logger.Error("message missing payload field", "stream", stream, "id", msg.ID)
 		ackCtx, ackCancel := ctxBG()
 		defer ackCancel()
-		_ = c.rdb.XAck(ackCtx, c.stream, c.group, msg.ID).Err()
+		_ = c.rdb.XAck(ackCtx, stream, c.group, msg.ID).Err()
 		return Delivery{}, false
 	}
 
+	// Entries without an encoding field predate envelopes and are JSON.
+	enc := EncodingJSON
+	if v, ok := msg.Values[encodingField].(string); ok && v != "" {
+		enc = Encoding(v)
+	}
+
 	id := msg.ID
+	deadLetter := func() error {
+		if enc != EncodingJSON {
+			return c.deadLetter(stream, id, payloadField, payload, encodingField, string(enc))
+		}
+		return c.deadLetter(stream, id, payloadField, payload)
+	}
+
+	version, headers, body, err := decodeEnvelope(enc, []byte(payload))
+	if err != nil {
+		c.logger.Warn("undecodable message envelope, dead-lettering",
+			"stream", stream, "id", id, "encoding", string(enc), "schema_version", version, "error", err)
+		if err := deadLetter(); err != nil {
+			c.logger.Error("failed to dead-letter message", "stream", stream, "id", id, "error", err)
+		}
+		return Delivery{}, false
+	}
+
 	return Delivery{
-		Body: []byte(payload),
+		Body:     body,
+		Headers:  headers,
+		Encoding: enc,
 		Ack: func() error {
-			ctx, cancel := ctxBG()
-			defer cancel()
-			return c.rdb.XAck(ctx, c.stream, c.group, id).Err()
+			return c.ack(stream, id)
 		},
 		Nack: func(toDLQ bool) error {
 			if toDLQ {
-				addCtx, addCancel := ctxBG()
-				defer addCancel()
-				if err := c.rdb.XAdd(addCtx, &redis.XAddArgs{
-					Stream: c.dlq,
-					Values: map[string]interface{}{"payload": payload},
-				}).Err(); err != nil {
-					return err
-				}
-				ackCtx, ackCancel := ctxBG()
-				defer ackCancel()
-				return c.rdb.XAck(ackCtx, c.stream, c.group, id).Err()
+				return deadLetter()
 			}
 			// Requeue: no-op — message stays in PEL, reclaim loop will re-deliver it
 			return nil
 		},
+		Touch: func() error {
+			return c.touch(stream, id)
+		},
 	}, true
 }
 
+// touch resets the idle time of a pending entry by claiming it again for this
+// consumer, keeping it out of other consumers' reclaim loops.
+func (c *RedisConsumer) touch(stream, id string) error {
+	ctx, cancel := ctxBG()
+	defer cancel()
+	return c.rdb.XClaimJustID(ctx, &redis.XClaimArgs{
+		Stream:   stream,
+		Group:    c.group,
+		Consumer: c.consumer,
+		Messages: []string{id},
+	}).Err()
+}
+
 // ctxBG returns a background context with a timeout for ack/nack operations
 // that must complete even after the main context is cancelled.
 func ctxBG() (context.Context, context.CancelFunc) {
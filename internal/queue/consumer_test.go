@@ -15,7 +15,7 @@ func testLogger() *slog.Logger {
 	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
 }
 
-func setupConsumer(t *testing.T) (*miniredis.Miniredis, *redis.Client, *Consumer) {
+func setupConsumer(t *testing.T) (*miniredis.Miniredis, *redis.Client, *RedisConsumer) {
 	t.Helper()
 	mr := miniredis.RunT(t)
 	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
@@ -31,7 +31,7 @@ func setupConsumer(t *testing.T) (*miniredis.Miniredis, *redis.Client, *Consumer
 		t.Fatalf("XGroupCreateMkStream: %v", err)
 	}
 
-	c := NewConsumer(rdb, stream, dlq, group, consumer, 10, testLogger())
+	c := NewRedisConsumer(rdb, stream, dlq, group, consumer, 10, testLogger())
 	return mr, rdb, c
 }
 
@@ -44,7 +44,7 @@ func TestBuildDelivery_ValidPayload(t *testing.T) {
 		Values: map[string]interface{}{"payload": `{"url":"https://example.com","depth":0}`},
 	}
 
-	d, ok := c.buildDelivery(msg)
+	d, ok := c.buildDelivery("stream:test", msg)
 	if !ok {
 		t.Fatal("expected ok=true for valid payload")
 	}
@@ -62,7 +62,7 @@ func TestBuildDelivery_MissingPayload(t *testing.T) {
 		Values: map[string]interface{}{"other": "data"},
 	}
 
-	_, ok := c.buildDelivery(msg)
+	_, ok := c.buildDelivery("stream:test", msg)
 	if ok {
 		t.Error("expected ok=false for missing payload")
 	}
@@ -77,7 +77,7 @@ func TestBuildDelivery_EmptyPayload(t *testing.T) {
 		Values: map[string]interface{}{"payload": ""},
 	}
 
-	_, ok := c.buildDelivery(msg)
+	_, ok := c.buildDelivery("stream:test", msg)
 	if ok {
 		t.Error("expected ok=false for empty payload")
 	}
@@ -261,3 +261,144 @@ func TestDelivery_NackWithoutDLQ(t *testing.T) {
 		t.Fatal("timed out waiting for delivery")
 	}
 }
+
+func TestLaneConsumer_PrefersHeavierLaneWithoutStarving(t *testing.T) {
+	t.Parallel()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+
+	lanes := []Lane{{Stream: "stream:low", Weight: 1}, {Stream: "stream:high", Weight: 9}}
+	for _, l := range lanes {
+		if err := rdb.XGroupCreateMkStream(ctx, l.Stream, "test-group", "0").Err(); err != nil {
+			t.Fatalf("XGroupCreateMkStream: %v", err)
+		}
+		for i := 0; i < 200; i++ {
+			if err := rdb.XAdd(ctx, &redis.XAddArgs{
+				Stream: l.Stream,
+				Values: map[string]interface{}{"payload": l.Stream},
+			}).Err(); err != nil {
+				t.Fatalf("XAdd: %v", err)
+			}
+		}
+	}
+
+	c := NewRedisLaneConsumer(rdb, StaticLanes(lanes), "stream:test:dlq", "test-group", "test-consumer", 1, testLogger())
+	counts := map[string]int{}
+	for i := 0; i < 200; i++ {
+		streams, err := c.read(ctx)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		for _, s := range streams {
+			counts[s.Stream] += len(s.Messages)
+		}
+	}
+
+	if counts["stream:high"] <= counts["stream:low"] {
+		t.Errorf("high lane reads = %d, low lane reads = %d; want high > low", counts["stream:high"], counts["stream:low"])
+	}
+	if counts["stream:low"] == 0 {
+		t.Error("low lane was starved")
+	}
+}
+
+func TestLaneConsumer_FallsBackToNonEmptyLane(t *testing.T) {
+	t.Parallel()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+
+	lanes := []Lane{{Stream: "stream:low", Weight: 1}, {Stream: "stream:high", Weight: 100}}
+	for _, l := range lanes {
+		if err := rdb.XGroupCreateMkStream(ctx, l.Stream, "test-group", "0").Err(); err != nil {
+			t.Fatalf("XGroupCreateMkStream: %v", err)
+		}
+	}
+	if err := rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: "stream:low",
+		Values: map[string]interface{}{"payload": "only-low"},
+	}).Err(); err != nil {
+		t.Fatalf("XAdd: %v", err)
+	}
+
+	c := NewRedisLaneConsumer(rdb, StaticLanes(lanes), "stream:test:dlq", "test-group", "test-consumer", 10, testLogger())
+	streams, err := c.read(ctx)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(streams) != 1 || streams[0].Stream != "stream:low" || len(streams[0].Messages) != 1 {
+		t.Errorf("read = %+v, want the single low-lane message", streams)
+	}
+}
+
+func TestBuildDelivery_UnsupportedSchemaGoesToDLQ(t *testing.T) {
+	t.Parallel()
+	_, rdb, c := setupConsumer(t)
+	ctx := context.Background()
+
+	id, err := rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: "stream:test",
+		Values: map[string]interface{}{"payload": `{"schema_version":99,"body":{}}`},
+	}).Result()
+	if err != nil {
+		t.Fatalf("XAdd: %v", err)
+	}
+	if _, err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: "test-group", Consumer: "test-consumer", Streams: []string{"stream:test", ">"}, Count: 1,
+	}).Result(); err != nil {
+		t.Fatalf("XReadGroup: %v", err)
+	}
+
+	_, ok := c.buildDelivery("stream:test", redis.XMessage{
+		ID:     id,
+		Values: map[string]interface{}{"payload": `{"schema_version":99,"body":{}}`},
+	})
+	if ok {
+		t.Fatal("expected ok=false for unsupported schema version")
+	}
+	if n, _ := rdb.XLen(ctx, "stream:test:dlq").Result(); n != 1 {
+		t.Errorf("DLQ length = %d, want 1", n)
+	}
+	pending, err := rdb.XPending(ctx, "stream:test", "test-group").Result()
+	if err != nil {
+		t.Fatalf("XPending: %v", err)
+	}
+	if pending.Count != 0 {
+		t.Errorf("PEL count = %d, want 0 after dead-lettering", pending.Count)
+	}
+}
+
+func TestConsumerRun_DecodesMsgpackEnvelope(t *testing.T) {
+	t.Parallel()
+	_, rdb, c := setupConsumer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := c.Run(ctx)
+
+	p := &RedisPublisher{rdb: rdb, partitions: 1, encoding: EncodingMsgpack}
+	values, err := p.values(context.Background(), ParseMessage{URLID: "id-1", URL: "https://example.com", Depth: 2})
+	if err != nil {
+		t.Fatalf("values: %v", err)
+	}
+	if err := rdb.XAdd(context.Background(), &redis.XAddArgs{Stream: "stream:test", Values: values}).Err(); err != nil {
+		t.Fatalf("XAdd: %v", err)
+	}
+
+	select {
+	case d := <-ch:
+		var got ParseMessage
+		if err := d.Decode(&got); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		if got.URLID != "id-1" || got.Depth != 2 {
+			t.Errorf("got %+v", got)
+		}
+		if d.Headers[HeaderEnqueuedAt] == "" {
+			t.Errorf("headers = %v, want %s", d.Headers, HeaderEnqueuedAt)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for delivery")
+	}
+}
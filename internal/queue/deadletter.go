@@ -0,0 +1,150 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// deadLetterBatch is how many dead-letter entries are read at a time.
+const deadLetterBatch = 100
+
+// DeadLetter is one entry of a Redis dead-letter stream. Message is the
+// decoded body, or nil with Error set when the entry cannot be decoded.
+type DeadLetter struct {
+	ID       string            `json:"id"`
+	Encoding Encoding          `json:"encoding"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Message  map[string]any    `json:"message,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// DeadLetterStream returns the dead-letter stream of the frontier or parse
+// queue.
+func DeadLetterStream(queue string) (string, error) {
+	switch queue {
+	case "frontier":
+		return FrontierDLQ, nil
+	case "parse":
+		return ParseDLQ, nil
+	default:
+		return "", fmt.Errorf("unknown queue %q: want frontier or parse", queue)
+	}
+}
+
+// ListDeadLetters returns up to limit entries of a dead-letter stream, oldest
+// first.
+func ListDeadLetters(ctx context.Context, rdb *redis.Client, dlq string, limit int64) ([]DeadLetter, error) {
+	msgs, err := rdb.XRangeN(ctx, dlq, "-", "+", limit).Result()
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", dlq, err)
+	}
+	out := make([]DeadLetter, len(msgs))
+	for i, msg := range msgs {
+		dl, body, err := decodeDeadLetter(msg)
+		if err == nil {
+			err = unmarshalBody(dl.Encoding, body, &dl.Message)
+		}
+		if err != nil {
+			dl.Error = err.Error()
+		}
+		out[i] = dl
+	}
+	return out, nil
+}
+
+// ReplayDeadLetters republishes up to limit entries of the frontier or parse
+// dead-letter stream through pub, oldest first, removing each once it is
+// published; limit <= 0 replays them all. Entries that cannot be decoded are
+// left in place. It returns the number of entries replayed.
+func ReplayDeadLetters(ctx context.Context, rdb *redis.Client, dlq string, pub Publisher, limit int64) (int64, error) {
+	if dlq != FrontierDLQ && dlq != ParseDLQ {
+		return 0, fmt.Errorf("cannot replay %s: not a dead-letter stream", dlq)
+	}
+	var replayed int64
+	start := "-"
+	for limit <= 0 || replayed < limit {
+		count := int64(deadLetterBatch)
+		if limit > 0 {
+			count = min(limit-replayed, count)
+		}
+		msgs, err := rdb.XRangeN(ctx, dlq, start, "+", count).Result()
+		if err != nil {
+			return replayed, fmt.Errorf("reading %s: %w", dlq, err)
+		}
+		if len(msgs) == 0 {
+			break
+		}
+		for _, msg := range msgs {
+			start = "(" + msg.ID
+			ok, err := replayDeadLetter(ctx, dlq, msg, pub)
+			if err != nil {
+				return replayed, err
+			}
+			if !ok {
+				continue
+			}
+			if err := rdb.XDel(ctx, dlq, msg.ID).Err(); err != nil {
+				return replayed, fmt.Errorf("removing %s from %s: %w", msg.ID, dlq, err)
+			}
+			replayed++
+		}
+	}
+	return replayed, nil
+}
+
+// replayDeadLetter publishes one entry again. It reports false for entries
+// that cannot be decoded.
+func replayDeadLetter(ctx context.Context, dlq string, msg redis.XMessage, pub Publisher) (bool, error) {
+	dl, body, err := decodeDeadLetter(msg)
+	if err != nil {
+		return false, nil
+	}
+	if dlq == FrontierDLQ {
+		var m URLMessage
+		if err := unmarshalBody(dl.Encoding, body, &m); err != nil {
+			return false, nil
+		}
+		if err := pub.PublishURL(ctx, m); err != nil {
+			return false, fmt.Errorf("republishing %s: %w", msg.ID, err)
+		}
+		return true, nil
+	}
+	var m ParseMessage
+	if err := unmarshalBody(dl.Encoding, body, &m); err != nil {
+		return false, nil
+	}
+	if err := pub.PublishParse(ctx, m); err != nil {
+		return false, fmt.Errorf("republishing %s: %w", msg.ID, err)
+	}
+	return true, nil
+}
+
+// PurgeDeadLetters removes every entry of a dead-letter stream and returns
+// how many there were.
+func PurgeDeadLetters(ctx context.Context, rdb *redis.Client, dlq string) (int64, error) {
+	n, err := rdb.XTrimMaxLen(ctx, dlq, 0).Result()
+	if err != nil {
+		return 0, fmt.Errorf("purging %s: %w", dlq, err)
+	}
+	return n, nil
+}
+
+// decodeDeadLetter unwraps the envelope of a dead-letter entry.
+func decodeDeadLetter(msg redis.XMessage) (DeadLetter, []byte, error) {
+	dl := DeadLetter{ID: msg.ID, Encoding: EncodingJSON}
+	if enc, ok := msg.Values[encodingField].(string); ok {
+		dl.Encoding = Encoding(enc)
+	}
+	payload, ok := msg.Values[payloadField].(string)
+	if !ok {
+		return dl, nil, fmt.Errorf("entry has no %s field", payloadField)
+	}
+	_, headers, body, err := decodeEnvelope(dl.Encoding, []byte(payload))
+	if err != nil {
+		return dl, nil, err
+	}
+	dl.Headers = headers
+	return dl, body, nil
+}
@@ -0,0 +1,71 @@
+package queue
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestDeadLetters(t *testing.T) {
+	t.Parallel()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+
+	for _, enc := range []Encoding{EncodingJSON, EncodingMsgpack} {
+		pub := NewRedisPublisher(rdb, 1, enc)
+		values, err := pub.values(ctx, URLMessage{URL: "https://example.com/" + string(enc), Depth: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := rdb.XAdd(ctx, &redis.XAddArgs{Stream: FrontierDLQ, Values: values}).Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rdb.XAdd(ctx, &redis.XAddArgs{Stream: FrontierDLQ, Values: map[string]any{"junk": "x"}}).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	dls, err := ListDeadLetters(ctx, rdb, FrontierDLQ, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dls) != 3 {
+		t.Fatalf("listed %d dead letters, want 3", len(dls))
+	}
+	if dls[1].Encoding != EncodingMsgpack || dls[1].Message["url"] != "https://example.com/msgpack" {
+		t.Errorf("second dead letter = %+v", dls[1])
+	}
+	if dls[2].Error == "" || dls[2].Message != nil {
+		t.Errorf("undecodable dead letter = %+v, want an error", dls[2])
+	}
+
+	pub := NewRedisPublisher(rdb, 1, EncodingJSON)
+	n, err := ReplayDeadLetters(ctx, rdb, FrontierDLQ, pub, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("replayed %d, want 2", n)
+	}
+	if got := rdb.XLen(ctx, FrontierStream).Val(); got != 2 {
+		t.Errorf("frontier holds %d entries, want 2", got)
+	}
+	if got := rdb.XLen(ctx, FrontierDLQ).Val(); got != 1 {
+		t.Errorf("dlq holds %d entries after replay, want the undecodable one", got)
+	}
+
+	if _, err := ReplayDeadLetters(ctx, rdb, FrontierStream, pub, 0); err == nil {
+		t.Error("replaying a stream that is not a dlq succeeded")
+	}
+
+	n, err = PurgeDeadLetters(ctx, rdb, FrontierDLQ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || rdb.XLen(ctx, FrontierDLQ).Val() != 0 {
+		t.Errorf("purged %d, dlq left with %d entries", n, rdb.XLen(ctx, FrontierDLQ).Val())
+	}
+}
@@ -1,8 +1,34 @@
 package queue
 
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
 // Delivery is a transport-agnostic message envelope.
 type Delivery struct {
+	// Body is the encoded message body; use Decode to read it.
 	Body []byte
-	Ack  func() error
-	Nack func(toDLQ bool) error
+	// Headers are the envelope headers, nil for legacy messages.
+	Headers map[string]string
+	// Encoding is the wire format of Body. Empty means JSON.
+	Encoding Encoding
+	Ack      func() error
+	Nack     func(toDLQ bool) error
+	// Touch tells the backend the message is still being worked on, so it
+	// is not redelivered to another consumer while held. It may be nil.
+	Touch func() error
+}
+
+// Decode unmarshals the message body into v.
+func (d Delivery) Decode(v any) error {
+	return unmarshalBody(d.Encoding, d.Body, v)
+}
+
+// Context returns ctx carrying the trace context the message was published
+// with, if any.
+func (d Delivery) Context(ctx context.Context) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(d.Headers))
 }
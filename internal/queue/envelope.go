@@ -0,0 +1,146 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// SchemaVersion is the envelope version written by this build. Consumers
+// accept any version up to it. Payloads without a version are the bare JSON
+// bodies published before envelopes existed and are read as version 0.
+const SchemaVersion = 1
+
+// Stream entry fields.
+const (
+	payloadField  = "payload"
+	encodingField = "encoding"
+)
+
+// Well-known envelope headers.
+const (
+	// HeaderEnqueuedAt is when the message was published (RFC 3339, UTC).
+	HeaderEnqueuedAt = "enqueued_at"
+	// HeaderTraceParent carries W3C trace context across the queue.
+	HeaderTraceParent = "traceparent"
+)
+
+// ErrUnsupportedSchema is returned for envelopes newer than this build
+// understands.
+var ErrUnsupportedSchema = errors.New("unsupported message schema version")
+
+// Encoding is the wire format of an envelope.
+type Encoding string
+
+const (
+	EncodingJSON    Encoding = "json"
+	EncodingMsgpack Encoding = "msgpack"
+)
+
+// ParseEncoding validates a configured encoding name. Empty means JSON.
+func ParseEncoding(s string) (Encoding, error) {
+	switch enc := Encoding(strings.ToLower(s)); enc {
+	case "", EncodingJSON:
+		return EncodingJSON, nil
+	case EncodingMsgpack:
+		return EncodingMsgpack, nil
+	default:
+		return "", fmt.Errorf("unknown message encoding %q", s)
+	}
+}
+
+// jsonEnvelope is the JSON wire form. SchemaVersion is a pointer so legacy
+// payloads, which have no such field, can be told apart from version 0.
+type jsonEnvelope struct {
+	SchemaVersion *int              `json:"schema_version"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	Body          json.RawMessage   `json:"body"`
+}
+
+type msgpackEnvelope struct {
+	SchemaVersion int                `msgpack:"schema_version"`
+	Headers       map[string]string  `msgpack:"headers,omitempty"`
+	Body          msgpack.RawMessage `msgpack:"body"`
+}
+
+// publishHeaders returns the headers every backend stamps on a new message,
+// including the trace context of ctx.
+func publishHeaders(ctx context.Context) map[string]string {
+	headers := map[string]string{
+		HeaderEnqueuedAt: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(headers))
+	return headers
+}
+
+// encodeEnvelope wraps body in a current-version envelope.
+func encodeEnvelope(enc Encoding, headers map[string]string, body any) ([]byte, error) {
+	raw, err := marshalBody(enc, body)
+	if err != nil {
+		return nil, err
+	}
+	if enc == EncodingMsgpack {
+		return msgpack.Marshal(msgpackEnvelope{SchemaVersion: SchemaVersion, Headers: headers, Body: raw})
+	}
+	version := SchemaVersion
+	return json.Marshal(jsonEnvelope{SchemaVersion: &version, Headers: headers, Body: raw})
+}
+
+// decodeEnvelope splits a stream payload into its headers and encoded body.
+// JSON payloads that are not envelopes are returned whole as a version 0 body
+// so messages published before the upgrade, or malformed ones, still reach
+// the worker, which decides what to do with them.
+func decodeEnvelope(enc Encoding, payload []byte) (version int, headers map[string]string, body []byte, err error) {
+	if enc == EncodingMsgpack {
+		var env msgpackEnvelope
+		if err := msgpack.Unmarshal(payload, &env); err != nil {
+			return 0, nil, nil, fmt.Errorf("decoding msgpack envelope: %w", err)
+		}
+		if env.SchemaVersion < 1 || env.SchemaVersion > SchemaVersion {
+			return env.SchemaVersion, nil, nil, fmt.Errorf("%w: %d", ErrUnsupportedSchema, env.SchemaVersion)
+		}
+		return env.SchemaVersion, env.Headers, env.Body, nil
+	}
+
+	var env jsonEnvelope
+	if err := json.Unmarshal(payload, &env); err != nil || env.SchemaVersion == nil {
+		return 0, nil, payload, nil
+	}
+	if *env.SchemaVersion < 1 || *env.SchemaVersion > SchemaVersion {
+		return *env.SchemaVersion, nil, nil, fmt.Errorf("%w: %d", ErrUnsupportedSchema, *env.SchemaVersion)
+	}
+	return *env.SchemaVersion, env.Headers, env.Body, nil
+}
+
+// marshalBody encodes a message body. Msgpack bodies reuse the json struct
+// tags so both encodings share one set of field names.
+func marshalBody(enc Encoding, v any) ([]byte, error) {
+	if enc != EncodingMsgpack {
+		return json.Marshal(v)
+	}
+	var buf bytes.Buffer
+	e := msgpack.NewEncoder(&buf)
+	e.SetCustomStructTag("json")
+	e.UseCompactInts(true)
+	if err := e.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func unmarshalBody(enc Encoding, data []byte, v any) error {
+	if enc != EncodingMsgpack {
+		return json.Unmarshal(data, v)
+	}
+	d := msgpack.NewDecoder(bytes.NewReader(data))
+	d.SetCustomStructTag("json")
+	return d.Decode(v)
+}
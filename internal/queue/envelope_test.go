@@ -0,0 +1,105 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestEnvelope_RoundTrip(t *testing.T) {
+	t.Parallel()
+	msg := URLMessage{
+		URL:            "https://example.com/a",
+		Depth:          2,
+		Priority:       1,
+		JobID:          "job-1",
+		DiscoveredFrom: "https://example.com/",
+		AnchorText:     "A page",
+		Attempt:        3,
+	}
+	for _, enc := range []Encoding{EncodingJSON, EncodingMsgpack} {
+		t.Run(string(enc), func(t *testing.T) {
+			t.Parallel()
+			payload, err := encodeEnvelope(enc, map[string]string{HeaderTraceParent: "00-abc-def-01"}, msg)
+			if err != nil {
+				t.Fatalf("encodeEnvelope: %v", err)
+			}
+			version, headers, body, err := decodeEnvelope(enc, payload)
+			if err != nil {
+				t.Fatalf("decodeEnvelope: %v", err)
+			}
+			if version != SchemaVersion {
+				t.Errorf("version = %d, want %d", version, SchemaVersion)
+			}
+			if headers[HeaderTraceParent] != "00-abc-def-01" {
+				t.Errorf("headers = %v, want traceparent", headers)
+			}
+			var got URLMessage
+			if err := (Delivery{Body: body, Encoding: enc}).Decode(&got); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if got != msg {
+				t.Errorf("got %+v, want %+v", got, msg)
+			}
+		})
+	}
+}
+
+func TestDecodeEnvelope_LegacyJSON(t *testing.T) {
+	t.Parallel()
+	tests := []string{
+		`{"url":"https://example.com","depth":1}`,
+		`not json at all`,
+	}
+	for _, payload := range tests {
+		version, headers, body, err := decodeEnvelope(EncodingJSON, []byte(payload))
+		if err != nil {
+			t.Fatalf("decodeEnvelope(%q): %v", payload, err)
+		}
+		if version != 0 || headers != nil {
+			t.Errorf("decodeEnvelope(%q) = version %d, headers %v; want legacy", payload, version, headers)
+		}
+		if string(body) != payload {
+			t.Errorf("body = %q, want whole payload %q", body, payload)
+		}
+	}
+}
+
+func TestDecodeEnvelope_UnsupportedVersion(t *testing.T) {
+	t.Parallel()
+	future := SchemaVersion + 1
+
+	jsonPayload := []byte(`{"schema_version":99,"body":{"url":"https://example.com"}}`)
+	if _, _, _, err := decodeEnvelope(EncodingJSON, jsonPayload); !errors.Is(err, ErrUnsupportedSchema) {
+		t.Errorf("json: err = %v, want ErrUnsupportedSchema", err)
+	}
+
+	mpPayload, err := msgpack.Marshal(msgpackEnvelope{SchemaVersion: future})
+	if err != nil {
+		t.Fatalf("msgpack.Marshal: %v", err)
+	}
+	if _, _, _, err := decodeEnvelope(EncodingMsgpack, mpPayload); !errors.Is(err, ErrUnsupportedSchema) {
+		t.Errorf("msgpack: err = %v, want ErrUnsupportedSchema", err)
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		in      string
+		want    Encoding
+		wantErr bool
+	}{
+		{"", EncodingJSON, false},
+		{"json", EncodingJSON, false},
+		{"MsgPack", EncodingMsgpack, false},
+		{"protobuf", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseEncoding(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseEncoding(%q) = %q, %v; want %q, err=%v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
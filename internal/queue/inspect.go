@@ -0,0 +1,83 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// StreamStats describes one Redis stream and its consumer groups.
+type StreamStats struct {
+	Stream string       `json:"stream"`
+	Length int64        `json:"length"`
+	Groups []GroupStats `json:"groups"`
+}
+
+// GroupStats describes a consumer group. Lag is nil when Redis cannot tell,
+// for example after entries were trimmed before being read.
+type GroupStats struct {
+	Name            string          `json:"name"`
+	Lag             *int64          `json:"lag"`
+	Pending         int64           `json:"pending"`
+	LastDeliveredID string          `json:"last_delivered_id"`
+	Consumers       []ConsumerStats `json:"consumers"`
+}
+
+// ConsumerStats describes one consumer of a group.
+type ConsumerStats struct {
+	Name    string `json:"name"`
+	Pending int64  `json:"pending"`
+	// IdleMs is how long ago the consumer last read or claimed an entry.
+	IdleMs int64 `json:"idle_ms"`
+}
+
+// Streams returns every stream the Redis backend uses with the given number
+// of frontier partitions: the frontier lanes, the parse stream and both DLQs.
+func Streams(partitions int) []string {
+	return append(FrontierStreams(partitions), ParseStream, FrontierDLQ, ParseDLQ)
+}
+
+// InspectStreams reports the length, consumer groups and consumers of each
+// stream. Streams that do not exist yet are reported empty.
+func InspectStreams(ctx context.Context, rdb *redis.Client, streams []string) ([]StreamStats, error) {
+	stats := make([]StreamStats, 0, len(streams))
+	for _, stream := range streams {
+		s := StreamStats{Stream: stream, Groups: []GroupStats{}}
+		n, err := rdb.XLen(ctx, stream).Result()
+		if err != nil {
+			return nil, fmt.Errorf("reading length of %s: %w", stream, err)
+		}
+		s.Length = n
+		if n == 0 {
+			if exists, err := rdb.Exists(ctx, stream).Result(); err != nil {
+				return nil, fmt.Errorf("checking %s: %w", stream, err)
+			} else if exists == 0 {
+				stats = append(stats, s)
+				continue
+			}
+		}
+
+		groups, err := rdb.XInfoGroups(ctx, stream).Result()
+		if err != nil {
+			return nil, fmt.Errorf("reading groups of %s: %w", stream, err)
+		}
+		for _, g := range groups {
+			gs := GroupStats{Name: g.Name, Pending: g.Pending, LastDeliveredID: g.LastDeliveredID, Consumers: []ConsumerStats{}}
+			if g.Lag >= 0 {
+				lag := g.Lag
+				gs.Lag = &lag
+			}
+			consumers, err := rdb.XInfoConsumers(ctx, stream, g.Name).Result()
+			if err != nil {
+				return nil, fmt.Errorf("reading consumers of %s/%s: %w", stream, g.Name, err)
+			}
+			for _, c := range consumers {
+				gs.Consumers = append(gs.Consumers, ConsumerStats{Name: c.Name, Pending: c.Pending, IdleMs: c.Idle.Milliseconds()})
+			}
+			s.Groups = append(s.Groups, gs)
+		}
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
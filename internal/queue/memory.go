@@ -0,0 +1,325 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryBroker is an in-process queue backend for single-process runs and
+// tests; it cannot connect separate processes. It keeps the Redis backend's
+// delivery semantics: a delivery stays pending until it is acked, pending
+// deliveries idle for longer than the reclaim timeout are redelivered, and
+// Nack(true) moves a delivery to the topic's dead-letter list. Frontier
+// priorities are served strictly, highest first. Nothing survives a restart.
+type MemoryBroker struct {
+	mu      sync.Mutex
+	topics  map[string]*memoryTopic
+	nextID  uint64
+	minIdle time.Duration
+	now     func() time.Time
+}
+
+type memoryTopic struct {
+	ready   [PriorityLanes][]*memoryMessage
+	pending map[uint64]*memoryMessage
+	dead    []*memoryMessage
+	// notify is closed and replaced whenever a message becomes ready.
+	notify chan struct{}
+}
+
+type memoryMessage struct {
+	id          uint64
+	priority    int
+	headers     map[string]string
+	body        []byte
+	deliveredAt time.Time
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		topics:  make(map[string]*memoryTopic),
+		minIdle: reclaimMinIdle,
+		now:     time.Now,
+	}
+}
+
+// topic returns the named topic, creating it on first use. Caller must hold b.mu.
+func (b *MemoryBroker) topic(name string) *memoryTopic {
+	t, ok := b.topics[name]
+	if !ok {
+		t = &memoryTopic{pending: make(map[uint64]*memoryMessage), notify: make(chan struct{})}
+		b.topics[name] = t
+	}
+	return t
+}
+
+func (b *MemoryBroker) publish(ctx context.Context, topic string, priority int, msg any) error {
+	body, err := marshalBody(EncodingJSON, msg)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	t := b.topic(topic)
+	priority = clampLane(priority)
+	t.ready[priority] = append(t.ready[priority], &memoryMessage{
+		id:       b.nextID,
+		priority: priority,
+		headers:  publishHeaders(ctx),
+		body:     body,
+	})
+	t.wake()
+	return nil
+}
+
+// take moves the highest-priority ready message of topic to pending. When
+// none is ready it returns a channel that is closed once one might be.
+func (b *MemoryBroker) take(topic string) (*memoryMessage, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.topic(topic)
+	for p := PriorityLanes - 1; p >= 0; p-- {
+		if len(t.ready[p]) == 0 {
+			continue
+		}
+		m := t.ready[p][0]
+		t.ready[p][0] = nil
+		t.ready[p] = t.ready[p][1:]
+		m.deliveredAt = b.now()
+		t.pending[m.id] = m
+		return m, nil
+	}
+	return nil, t.notify
+}
+
+func (b *MemoryBroker) ack(topic string, id uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.topic(topic).pending, id)
+}
+
+func (b *MemoryBroker) touch(topic string, id uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if m, ok := b.topic(topic).pending[id]; ok {
+		m.deliveredAt = b.now()
+	}
+}
+
+func (b *MemoryBroker) deadLetter(topic string, id uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.topic(topic)
+	if m, ok := t.pending[id]; ok {
+		delete(t.pending, id)
+		t.dead = append(t.dead, m)
+	}
+}
+
+// reclaim makes pending messages of topic that have been idle for at least
+// minIdle ready again, ahead of newer messages of the same priority.
+func (b *MemoryBroker) reclaim(topic string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.topic(topic)
+	now := b.now()
+	n := 0
+	for id, m := range t.pending {
+		if now.Sub(m.deliveredAt) < b.minIdle {
+			continue
+		}
+		delete(t.pending, id)
+		t.ready[m.priority] = append([]*memoryMessage{m}, t.ready[m.priority]...)
+		n++
+	}
+	if n > 0 {
+		t.wake()
+	}
+	return n
+}
+
+// Len returns the number of ready and pending messages on topic.
+func (b *MemoryBroker) Len(topic string) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.topic(topic)
+	n := len(t.pending)
+	for _, lane := range t.ready {
+		n += len(lane)
+	}
+	return int64(n)
+}
+
+// DeadLetters returns the bodies dead-lettered on topic, oldest first.
+func (b *MemoryBroker) DeadLetters(topic string) [][]byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.topic(topic)
+	out := make([][]byte, len(t.dead))
+	for i, m := range t.dead {
+		out[i] = m.body
+	}
+	return out
+}
+
+// Publisher returns a publisher to the broker.
+func (b *MemoryBroker) Publisher() Publisher {
+	return NewMemoryPublisher(b)
+}
+
+// FrontierConsumer returns a consumer of the frontier topic. Consumers are
+// anonymous, so name is unused.
+func (b *MemoryBroker) FrontierConsumer(name string, count int) Consumer {
+	return NewMemoryConsumer(b, FrontierStream, count)
+}
+
+func (b *MemoryBroker) ParseConsumer(name string, count int) Consumer {
+	return NewMemoryConsumer(b, ParseStream, count)
+}
+
+func (b *MemoryBroker) Close() {}
+
+func (t *memoryTopic) wake() {
+	close(t.notify)
+	t.notify = make(chan struct{})
+}
+
+// MemoryPublisher publishes to a MemoryBroker. URL messages go to the
+// FrontierStream topic and parse messages to the ParseStream topic.
+type MemoryPublisher struct {
+	b *MemoryBroker
+}
+
+func NewMemoryPublisher(b *MemoryBroker) *MemoryPublisher {
+	return &MemoryPublisher{b: b}
+}
+
+func (p *MemoryPublisher) PublishURL(ctx context.Context, msg URLMessage) error {
+	return p.b.publish(ctx, FrontierStream, msg.Priority, msg)
+}
+
+func (p *MemoryPublisher) PublishURLBatch(ctx context.Context, msgs []URLMessage) error {
+	for _, msg := range msgs {
+		if err := p.PublishURL(ctx, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *MemoryPublisher) PublishParse(ctx context.Context, msg ParseMessage) error {
+	return p.b.publish(ctx, ParseStream, 0, msg)
+}
+
+func (p *MemoryPublisher) FrontierLen(ctx context.Context) (int64, error) {
+	return p.b.Len(FrontierStream), nil
+}
+
+func (p *MemoryPublisher) ParseLen(ctx context.Context) (int64, error) {
+	return p.b.Len(ParseStream), nil
+}
+
+func (p *MemoryPublisher) Close() {}
+
+// MemoryConsumer delivers messages from one MemoryBroker topic. Several
+// consumers of the same topic compete for its messages.
+type MemoryConsumer struct {
+	b               *MemoryBroker
+	topic           string
+	count           int
+	reclaimInterval time.Duration
+	wg              sync.WaitGroup
+}
+
+func NewMemoryConsumer(b *MemoryBroker, topic string, count int) *MemoryConsumer {
+	return &MemoryConsumer{b: b, topic: topic, count: count, reclaimInterval: reclaimInterval}
+}
+
+func (c *MemoryConsumer) Run(ctx context.Context) <-chan Delivery {
+	ch := make(chan Delivery, c.count)
+
+	c.wg.Add(2)
+	go func() {
+		defer c.wg.Done()
+		c.readLoop(ctx, ch)
+	}()
+	go func() {
+		defer c.wg.Done()
+		c.reclaimLoop(ctx)
+	}()
+	go func() {
+		c.wg.Wait()
+		close(ch)
+	}()
+
+	return ch
+}
+
+func (c *MemoryConsumer) Wait() {
+	c.wg.Wait()
+}
+
+func (c *MemoryConsumer) readLoop(ctx context.Context, ch chan<- Delivery) {
+	for {
+		m, notify := c.b.take(c.topic)
+		if m == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-notify:
+				continue
+			}
+		}
+		select {
+		case ch <- c.delivery(m):
+		case <-ctx.Done():
+			// Left pending; a later consumer reclaims it.
+			return
+		}
+	}
+}
+
+func (c *MemoryConsumer) reclaimLoop(ctx context.Context) {
+	ticker := time.NewTicker(c.reclaimInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.b.reclaim(c.topic)
+		}
+	}
+}
+
+func (c *MemoryConsumer) delivery(m *memoryMessage) Delivery {
+	id := m.id
+	return Delivery{
+		Body:     m.body,
+		Headers:  m.headers,
+		Encoding: EncodingJSON,
+		Ack: func() error {
+			c.b.ack(c.topic, id)
+			return nil
+		},
+		Nack: func(toDLQ bool) error {
+			if toDLQ {
+				c.b.deadLetter(c.topic, id)
+			}
+			// Requeue: no-op — message stays pending until reclaimed
+			return nil
+		},
+		Touch: func() error {
+			c.b.touch(c.topic, id)
+			return nil
+		},
+	}
+}
+
+var (
+	_ Backend   = (*MemoryBroker)(nil)
+	_ Publisher = (*MemoryPublisher)(nil)
+	_ Consumer  = (*MemoryConsumer)(nil)
+)
@@ -0,0 +1,217 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func receive(t *testing.T, ch <-chan Delivery) Delivery {
+	t.Helper()
+	select {
+	case d, ok := <-ch:
+		if !ok {
+			t.Fatal("delivery channel closed")
+		}
+		return d
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for delivery")
+	}
+	return Delivery{}
+}
+
+func TestMemoryBroker_PublishConsumeAck(t *testing.T) {
+	t.Parallel()
+	b := NewMemoryBroker()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := b.ParseConsumer("p1", 10).Run(ctx)
+	if err := b.Publisher().PublishParse(ctx, ParseMessage{URLID: "id-1", URL: "https://example.com"}); err != nil {
+		t.Fatalf("PublishParse: %v", err)
+	}
+
+	d := receive(t, ch)
+	var got ParseMessage
+	if err := d.Decode(&got); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got.URLID != "id-1" {
+		t.Errorf("URLID = %q, want id-1", got.URLID)
+	}
+	if d.Headers[HeaderEnqueuedAt] == "" {
+		t.Errorf("missing %s header", HeaderEnqueuedAt)
+	}
+	if n := b.Len(ParseStream); n != 1 {
+		t.Errorf("Len before ack = %d, want 1 (pending)", n)
+	}
+	if err := d.Ack(); err != nil {
+		t.Fatalf("Ack: %v", err)
+	}
+	if n := b.Len(ParseStream); n != 0 {
+		t.Errorf("Len after ack = %d, want 0", n)
+	}
+}
+
+func TestMemoryBroker_NackToDLQ(t *testing.T) {
+	t.Parallel()
+	b := NewMemoryBroker()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := b.FrontierConsumer("c1", 10).Run(ctx)
+	if err := b.Publisher().PublishURL(ctx, URLMessage{URL: "https://example.com"}); err != nil {
+		t.Fatalf("PublishURL: %v", err)
+	}
+	d := receive(t, ch)
+	if err := d.Nack(true); err != nil {
+		t.Fatalf("Nack: %v", err)
+	}
+	if dead := b.DeadLetters(FrontierStream); len(dead) != 1 {
+		t.Errorf("dead letters = %d, want 1", len(dead))
+	}
+	if n := b.Len(FrontierStream); n != 0 {
+		t.Errorf("Len = %d, want 0 after dead-lettering", n)
+	}
+}
+
+func TestMemoryBroker_ReclaimsIdlePending(t *testing.T) {
+	t.Parallel()
+	b := NewMemoryBroker()
+	now := time.Now()
+	b.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if err := b.Publisher().PublishURL(ctx, URLMessage{URL: "https://example.com"}); err != nil {
+		t.Fatalf("PublishURL: %v", err)
+	}
+	m, _ := b.take(FrontierStream)
+	if m == nil {
+		t.Fatal("expected a ready message")
+	}
+
+	// Nack(false) leaves it pending; it is not reclaimed before minIdle.
+	if n := b.reclaim(FrontierStream); n != 0 {
+		t.Fatalf("reclaimed %d before min idle, want 0", n)
+	}
+	now = now.Add(reclaimMinIdle)
+	if n := b.reclaim(FrontierStream); n != 1 {
+		t.Fatalf("reclaimed %d after min idle, want 1", n)
+	}
+	again, _ := b.take(FrontierStream)
+	if again == nil || again.id != m.id {
+		t.Errorf("expected message %d to be redelivered, got %v", m.id, again)
+	}
+}
+
+func TestMemoryBroker_TouchDefersReclaim(t *testing.T) {
+	t.Parallel()
+	b := NewMemoryBroker()
+	now := time.Now()
+	b.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if err := b.Publisher().PublishURL(ctx, URLMessage{URL: "https://example.com"}); err != nil {
+		t.Fatalf("PublishURL: %v", err)
+	}
+	m, _ := b.take(FrontierStream)
+	if m == nil {
+		t.Fatal("expected a ready message")
+	}
+
+	now = now.Add(reclaimMinIdle - time.Second)
+	b.touch(FrontierStream, m.id)
+	now = now.Add(time.Second)
+	if n := b.reclaim(FrontierStream); n != 0 {
+		t.Fatalf("reclaimed %d right after a touch, want 0", n)
+	}
+	now = now.Add(reclaimMinIdle)
+	if n := b.reclaim(FrontierStream); n != 1 {
+		t.Fatalf("reclaimed %d once idle again, want 1", n)
+	}
+}
+
+func TestMemoryBroker_HighestPriorityFirst(t *testing.T) {
+	t.Parallel()
+	b := NewMemoryBroker()
+	ctx := context.Background()
+	p := b.Publisher()
+
+	msgs := []URLMessage{
+		{URL: "https://example.com/low"},
+		{URL: "https://example.com/high", Priority: HighestPriority},
+		{URL: "https://example.com/mid", Priority: 1},
+	}
+	if err := p.PublishURLBatch(ctx, msgs); err != nil {
+		t.Fatalf("PublishURLBatch: %v", err)
+	}
+	if n, _ := p.FrontierLen(ctx); n != 3 {
+		t.Errorf("FrontierLen = %d, want 3", n)
+	}
+
+	want := []string{"https://example.com/high", "https://example.com/mid", "https://example.com/low"}
+	for _, w := range want {
+		m, _ := b.take(FrontierStream)
+		if m == nil {
+			t.Fatalf("expected %s, got nothing", w)
+		}
+		var got URLMessage
+		if err := unmarshalBody(EncodingJSON, m.body, &got); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if got.URL != w {
+			t.Errorf("got %s, want %s", got.URL, w)
+		}
+	}
+}
+
+func TestMemoryConsumer_CancelClosesChannel(t *testing.T) {
+	t.Parallel()
+	b := NewMemoryBroker()
+	ctx, cancel := context.WithCancel(context.Background())
+	c := b.ParseConsumer("p1", 1)
+	ch := c.Run(ctx)
+	cancel()
+
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Error("expected closed channel")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("channel not closed after cancel")
+	}
+	c.Wait()
+}
+
+func TestMemoryBroker_PropagatesTraceContext(t *testing.T) {
+	t.Parallel()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	b := NewMemoryBroker()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3},
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	})
+	pubCtx := trace.ContextWithSpanContext(ctx, sc)
+	ch := b.ParseConsumer("p1", 10).Run(ctx)
+	if err := b.Publisher().PublishParse(pubCtx, ParseMessage{URLID: "id-1"}); err != nil {
+		t.Fatalf("PublishParse: %v", err)
+	}
+
+	d := receive(t, ch)
+	if d.Headers[HeaderTraceParent] == "" {
+		t.Fatalf("missing %s header", HeaderTraceParent)
+	}
+	got := trace.SpanContextFromContext(d.Context(context.Background()))
+	if got.TraceID() != sc.TraceID() || got.SpanID() != sc.SpanID() || !got.IsRemote() {
+		t.Errorf("span context = %v/%v remote %v, want %v/%v remote", got.TraceID(), got.SpanID(), got.IsRemote(), sc.TraceID(), sc.SpanID())
+	}
+}
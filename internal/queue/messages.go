@@ -1,8 +1,34 @@
 package queue
 
+// Link-following scopes of a crawl job. A page's links are followed only if
+// they fall within its job's scope.
+const (
+	// ScopeAll follows every link.
+	ScopeAll = ""
+	// ScopePage follows no links.
+	ScopePage = "page"
+	// ScopeHost follows links to the page's host.
+	ScopeHost = "host"
+	// ScopeDomain follows links within the page's registered domain.
+	ScopeDomain = "domain"
+)
+
 type URLMessage struct {
 	URL   string `json:"url"`
 	Depth int    `json:"depth"`
+	// Priority selects the frontier lane (0 = lowest, HighestPriority = highest).
+	Priority int `json:"priority,omitempty"`
+	// JobID identifies the crawl job that enqueued the URL, if any.
+	JobID string `json:"job_id,omitempty"`
+	// Scope limits the links followed from the page, and MaxDepth, when
+	// set, replaces the parser's max_depth. Both pass on to the pages found.
+	Scope    string `json:"scope,omitempty"`
+	MaxDepth int    `json:"max_depth,omitempty"`
+	// DiscoveredFrom is the URL of the page the link was found on.
+	DiscoveredFrom string `json:"discovered_from,omitempty"`
+	AnchorText     string `json:"anchor_text,omitempty"`
+	// Attempt counts fetch attempts; 0 and 1 both mean the first.
+	Attempt int `json:"attempt,omitempty"`
 }
 
 type ParseMessage struct {
@@ -10,4 +36,15 @@ type ParseMessage struct {
 	URL        string `json:"url"`
 	S3HTMLLink string `json:"s3_html_link"`
 	Depth      int    `json:"depth"`
+	JobID      string `json:"job_id,omitempty"`
+	Scope      string `json:"scope,omitempty"`
+	MaxDepth   int    `json:"max_depth,omitempty"`
+	// ContentHash is the sha256 of the stored HTML, computed while it was
+	// uploaded. Older messages leave it empty.
+	ContentHash string `json:"content_hash,omitempty"`
+	// Reparse marks a page that was parsed before, so its own earlier parse
+	// does not count as duplicate content.
+	Reparse bool `json:"reparse,omitempty"`
+	// SkipLinks regenerates the page's text without enqueueing its links.
+	SkipLinks bool `json:"skip_links,omitempty"`
 }
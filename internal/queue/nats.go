@@ -0,0 +1,379 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// JetStream streams and subjects. Frontier priorities map onto one subject
+// per lane so each lane gets its own durable consumer.
+const (
+	natsFrontierStream = "NIMBUS_FRONTIER"
+	natsParseStream    = "NIMBUS_PARSE"
+	natsDLQStream      = "NIMBUS_DLQ"
+
+	natsFrontierSubjectPrefix = "nimbus.frontier."
+	natsParseSubject          = "nimbus.parse"
+	natsFrontierDLQSubject    = "nimbus.dlq.frontier"
+	natsParseDLQSubject       = "nimbus.dlq.parse"
+
+	// natsEncodingHeader carries the envelope Encoding, like the Redis
+	// backend's encoding field.
+	natsEncodingHeader = "Nimbus-Encoding"
+	// natsIdlePoll is how long a consumer sleeps when every lane is empty.
+	natsIdlePoll = 250 * time.Millisecond
+	// natsMaxBackoff caps the wait after repeated fetch failures, which
+	// doubles from natsIdlePoll.
+	natsMaxBackoff = 30 * time.Second
+)
+
+func natsFrontierSubject(priority int) string {
+	return natsFrontierSubjectPrefix + strconv.Itoa(clampLane(priority))
+}
+
+// NATSBackend connects to a NATS server with JetStream enabled and hands out
+// publishers and consumers on work-queue streams. Unacked messages are
+// redelivered after the consumer's ack wait, matching the Redis backend's
+// reclaim, and Nack(true) republishes to the dead-letter stream before
+// terminating the message. The frontier is not partitioned.
+type NATSBackend struct {
+	nc      *nats.Conn
+	js      jetstream.JetStream
+	enc     Encoding
+	weights []int
+	ackWait time.Duration
+	logger  *slog.Logger
+}
+
+// NewNATSBackend connects to natsURL and creates the streams if needed.
+// Frontier lanes are read with the given weights (see FrontierLanes).
+func NewNATSBackend(ctx context.Context, natsURL string, enc Encoding, weights []int, logger *slog.Logger) (*NATSBackend, error) {
+	nc, err := nats.Connect(natsURL, nats.Name("nimbus"))
+	if err != nil {
+		return nil, fmt.Errorf("connecting to nats: %w", err)
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("creating jetstream context: %w", err)
+	}
+	streams := []jetstream.StreamConfig{
+		{Name: natsFrontierStream, Subjects: []string{natsFrontierSubjectPrefix + "*"}, Retention: jetstream.WorkQueuePolicy},
+		{Name: natsParseStream, Subjects: []string{natsParseSubject}, Retention: jetstream.WorkQueuePolicy},
+		{Name: natsDLQStream, Subjects: []string{"nimbus.dlq.>"}},
+	}
+	for _, cfg := range streams {
+		if _, err := js.CreateOrUpdateStream(ctx, cfg); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("creating stream %s: %w", cfg.Name, err)
+		}
+	}
+	if enc == "" {
+		enc = EncodingJSON
+	}
+	return &NATSBackend{nc: nc, js: js, enc: enc, weights: weights, ackWait: reclaimMinIdle, logger: logger}, nil
+}
+
+func (b *NATSBackend) Close() {
+	b.nc.Close()
+}
+
+// NATSPublisher publishes envelopes to JetStream.
+type NATSPublisher struct {
+	b *NATSBackend
+}
+
+func (b *NATSBackend) Publisher() Publisher {
+	return &NATSPublisher{b: b}
+}
+
+func (p *NATSPublisher) message(ctx context.Context, subject string, body any) (*nats.Msg, error) {
+	payload, err := encodeEnvelope(p.b.enc, publishHeaders(ctx), body)
+	if err != nil {
+		return nil, err
+	}
+	m := nats.NewMsg(subject)
+	m.Data = payload
+	m.Header.Set(natsEncodingHeader, string(p.b.enc))
+	return m, nil
+}
+
+func (p *NATSPublisher) PublishURL(ctx context.Context, msg URLMessage) error {
+	m, err := p.message(ctx, natsFrontierSubject(msg.Priority), msg)
+	if err != nil {
+		return fmt.Errorf("marshaling url message: %w", err)
+	}
+	_, err = p.b.js.PublishMsg(ctx, m)
+	return err
+}
+
+// PublishURLBatch publishes asynchronously and waits for every ack.
+func (p *NATSPublisher) PublishURLBatch(ctx context.Context, msgs []URLMessage) error {
+	futures := make([]jetstream.PubAckFuture, 0, len(msgs))
+	for _, msg := range msgs {
+		m, err := p.message(ctx, natsFrontierSubject(msg.Priority), msg)
+		if err != nil {
+			return fmt.Errorf("marshaling url message: %w", err)
+		}
+		f, err := p.b.js.PublishMsgAsync(m)
+		if err != nil {
+			return fmt.Errorf("publishing url batch: %w", err)
+		}
+		futures = append(futures, f)
+	}
+	for _, f := range futures {
+		select {
+		case <-f.Ok():
+		case err := <-f.Err():
+			return fmt.Errorf("publishing url batch: %w", err)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (p *NATSPublisher) PublishParse(ctx context.Context, msg ParseMessage) error {
+	m, err := p.message(ctx, natsParseSubject, msg)
+	if err != nil {
+		return fmt.Errorf("marshaling parse message: %w", err)
+	}
+	_, err = p.b.js.PublishMsg(ctx, m)
+	return err
+}
+
+// FrontierLen returns the number of unacked messages in the frontier stream.
+func (p *NATSPublisher) FrontierLen(ctx context.Context) (int64, error) {
+	n, err := p.streamLen(ctx, natsFrontierStream)
+	if err != nil {
+		return 0, fmt.Errorf("reading frontier length: %w", err)
+	}
+	return n, nil
+}
+
+// ParseLen returns the number of unacked messages in the parse stream.
+func (p *NATSPublisher) ParseLen(ctx context.Context) (int64, error) {
+	n, err := p.streamLen(ctx, natsParseStream)
+	if err != nil {
+		return 0, fmt.Errorf("reading parse backlog: %w", err)
+	}
+	return n, nil
+}
+
+// streamLen returns the number of messages held by a work-queue stream,
+// which drops each message once it is acked.
+func (p *NATSPublisher) streamLen(ctx context.Context, name string) (int64, error) {
+	s, err := p.b.js.Stream(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	info, err := s.Info(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return int64(info.State.Msgs), nil
+}
+
+func (p *NATSPublisher) Close() {}
+
+// NATSConsumer pulls from one durable JetStream consumer per lane, preferring
+// lanes in proportion to their weight like RedisConsumer. Replicas that use
+// the same group share the durable consumers and so compete for messages: a
+// work-queue stream allows one consumer per subject. Each replica's name
+// identifies it in logs.
+type NATSConsumer struct {
+	b          *NATSBackend
+	name       string
+	logger     *slog.Logger
+	stream     string
+	group      string
+	lanes      []Lane // Stream holds the lane's subject
+	dlqSubject string
+	count      int
+	wg         sync.WaitGroup
+}
+
+// FrontierConsumer returns a consumer of every frontier lane.
+func (b *NATSBackend) FrontierConsumer(name string, count int) Consumer {
+	lanes := make([]Lane, 0, PriorityLanes)
+	for i, l := range FrontierLanes(b.weights) {
+		lanes = append(lanes, Lane{Stream: natsFrontierSubject(i), Weight: l.Weight})
+	}
+	return &NATSConsumer{
+		b:          b,
+		name:       name,
+		logger:     b.logger.With("consumer", name),
+		stream:     natsFrontierStream,
+		group:      CrawlerGroup,
+		lanes:      lanes,
+		dlqSubject: natsFrontierDLQSubject,
+		count:      count,
+	}
+}
+
+func (b *NATSBackend) ParseConsumer(name string, count int) Consumer {
+	return &NATSConsumer{
+		b:          b,
+		name:       name,
+		logger:     b.logger.With("consumer", name),
+		stream:     natsParseStream,
+		group:      ParserGroup,
+		lanes:      []Lane{{Stream: natsParseSubject, Weight: 1}},
+		dlqSubject: natsParseDLQSubject,
+		count:      count,
+	}
+}
+
+func (c *NATSConsumer) Run(ctx context.Context) <-chan Delivery {
+	ch := make(chan Delivery, c.count)
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.readLoop(ctx, ch)
+	}()
+	go func() {
+		c.wg.Wait()
+		close(ch)
+	}()
+
+	return ch
+}
+
+func (c *NATSConsumer) Wait() {
+	c.wg.Wait()
+}
+
+// durable returns the consumer name for a lane subject. Durable names may
+// not contain dots.
+func (c *NATSConsumer) durable(subject string) string {
+	return c.group + "-" + strings.ReplaceAll(subject, ".", "-")
+}
+
+func (c *NATSConsumer) readLoop(ctx context.Context, ch chan<- Delivery) {
+	consumers := make(map[string]jetstream.Consumer, len(c.lanes))
+	for _, lane := range c.lanes {
+		cons, err := c.b.js.CreateOrUpdateConsumer(ctx, c.stream, jetstream.ConsumerConfig{
+			Durable:       c.durable(lane.Stream),
+			FilterSubject: lane.Stream,
+			AckPolicy:     jetstream.AckExplicitPolicy,
+			AckWait:       c.b.ackWait,
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				c.logger.Error("failed to create jetstream consumer", "subject", lane.Stream, "error", err)
+			}
+			return
+		}
+		consumers[lane.Stream] = cons
+	}
+
+	failures := 0
+	for ctx.Err() == nil {
+		got := false
+		var fetchErr error
+		for _, lane := range pollOrder(c.lanes) {
+			batch, err := consumers[lane.Stream].FetchNoWait(c.count)
+			if err != nil {
+				fetchErr = fmt.Errorf("%s: %w", lane.Stream, err)
+				continue
+			}
+			for msg := range batch.Messages() {
+				got = true
+				d, ok := c.buildDelivery(msg)
+				if !ok {
+					continue
+				}
+				select {
+				case ch <- d:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err := batch.Error(); err != nil && !errors.Is(err, nats.ErrTimeout) {
+				fetchErr = fmt.Errorf("%s: %w", lane.Stream, err)
+			}
+			if got {
+				break
+			}
+		}
+		if got {
+			failures = 0
+			continue
+		}
+		wait := natsIdlePoll
+		if fetchErr != nil && ctx.Err() == nil {
+			failures++
+			wait = natsIdlePoll << min(failures, 7)
+			wait = min(wait, natsMaxBackoff)
+			c.logger.Error("jetstream fetch failed", "error", fetchErr, "failures", failures, "retry_in", wait)
+		} else {
+			failures = 0
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (c *NATSConsumer) buildDelivery(msg jetstream.Msg) (Delivery, bool) {
+	enc := EncodingJSON
+	if v := msg.Headers().Get(natsEncodingHeader); v != "" {
+		enc = Encoding(v)
+	}
+
+	deadLetter := func() error {
+		m := nats.NewMsg(c.dlqSubject)
+		m.Data = msg.Data()
+		m.Header.Set(natsEncodingHeader, string(enc))
+		ctx, cancel := ctxBG()
+		defer cancel()
+		if _, err := c.b.js.PublishMsg(ctx, m); err != nil {
+			return err
+		}
+		return msg.Term()
+	}
+
+	version, headers, body, err := decodeEnvelope(enc, msg.Data())
+	if err != nil {
+		c.logger.Warn("undecodable message envelope, dead-lettering",
+			"subject", msg.Subject(), "encoding", string(enc), "schema_version", version, "error", err)
+		if err := deadLetter(); err != nil {
+			c.logger.Error("failed to dead-letter message", "subject", msg.Subject(), "error", err)
+		}
+		return Delivery{}, false
+	}
+
+	return Delivery{
+		Body:     body,
+		Headers:  headers,
+		Encoding: enc,
+		Ack:      msg.Ack,
+		Nack: func(toDLQ bool) error {
+			if toDLQ {
+				return deadLetter()
+			}
+			// Requeue: no-op — the server redelivers once the ack wait expires
+			return nil
+		},
+		Touch: func() error {
+			return msg.InProgress()
+		},
+	}, true
+}
+
+var (
+	_ Backend   = (*NATSBackend)(nil)
+	_ Publisher = (*NATSPublisher)(nil)
+	_ Consumer  = (*NATSConsumer)(nil)
+)
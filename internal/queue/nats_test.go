@@ -0,0 +1,147 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// startNATSServer runs a local nats-server with JetStream for the test, or
+// skips the test when the binary is not installed.
+func startNATSServer(t *testing.T) string {
+	t.Helper()
+	bin, err := exec.LookPath("nats-server")
+	if err != nil {
+		t.Skip("nats-server not found in PATH")
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("finding free port: %v", err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	cmd := exec.Command(bin, "-js", "-a", "127.0.0.1", "-p", fmt.Sprint(port), "-sd", t.TempDir())
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("starting nats-server: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+			return "nats://" + addr
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("nats-server did not start")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func newTestNATSBackend(t *testing.T, enc Encoding) *NATSBackend {
+	t.Helper()
+	b, err := NewNATSBackend(context.Background(), startNATSServer(t), enc, nil, testLogger())
+	if err != nil {
+		t.Fatalf("NewNATSBackend: %v", err)
+	}
+	t.Cleanup(b.Close)
+	return b
+}
+
+func TestNATSBackend_PublishConsumeAck(t *testing.T) {
+	t.Parallel()
+	for _, enc := range []Encoding{EncodingJSON, EncodingMsgpack} {
+		t.Run(string(enc), func(t *testing.T) {
+			t.Parallel()
+			b := newTestNATSBackend(t, enc)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			p := b.Publisher()
+			msgs := []URLMessage{
+				{URL: "https://example.com/a", Depth: 1},
+				{URL: "https://example.com/b", Depth: 1, Priority: HighestPriority},
+			}
+			if err := p.PublishURLBatch(ctx, msgs); err != nil {
+				t.Fatalf("PublishURLBatch: %v", err)
+			}
+			if n, err := p.FrontierLen(ctx); err != nil || n != 2 {
+				t.Fatalf("FrontierLen = %d, %v; want 2", n, err)
+			}
+
+			ch := b.FrontierConsumer("c1", 10).Run(ctx)
+			seen := make(map[string]bool)
+			for range msgs {
+				d := receive(t, ch)
+				var got URLMessage
+				if err := d.Decode(&got); err != nil {
+					t.Fatalf("Decode: %v", err)
+				}
+				seen[got.URL] = true
+				if err := d.Ack(); err != nil {
+					t.Fatalf("Ack: %v", err)
+				}
+			}
+			if len(seen) != 2 {
+				t.Errorf("received %v, want both URLs", seen)
+			}
+
+			// Work-queue retention drops acked messages.
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				n, _ := p.FrontierLen(ctx)
+				if n == 0 {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("FrontierLen = %d after acks, want 0", n)
+				}
+				time.Sleep(50 * time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestNATSBackend_NackRedeliversAndDeadLetters(t *testing.T) {
+	t.Parallel()
+	b := newTestNATSBackend(t, EncodingJSON)
+	b.ackWait = time.Second
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := b.Publisher().PublishParse(ctx, ParseMessage{URLID: "id-1"}); err != nil {
+		t.Fatalf("PublishParse: %v", err)
+	}
+	ch := b.ParseConsumer("p1", 1).Run(ctx)
+
+	// Nack(false) leaves the message unacked; it comes back after the ack wait.
+	first := receive(t, ch)
+	if err := first.Nack(false); err != nil {
+		t.Fatalf("Nack(false): %v", err)
+	}
+	second := receive(t, ch)
+	if err := second.Nack(true); err != nil {
+		t.Fatalf("Nack(true): %v", err)
+	}
+
+	s, err := b.js.Stream(ctx, natsDLQStream)
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	info, err := s.Info(ctx)
+	if err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+	if info.State.Msgs != 1 {
+		t.Errorf("DLQ messages = %d, want 1", info.State.Msgs)
+	}
+}
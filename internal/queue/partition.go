@@ -0,0 +1,215 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	partitionLeaseKeyPrefix = "frontier:lease:"
+	// defaultLeaseTTL replaces a lease TTL that is not positive, and
+	// minLeaseTTL bounds the rest so leases are renewed at a sane rate.
+	defaultLeaseTTL = 30 * time.Second
+	minLeaseTTL     = time.Second
+)
+
+// renewLeaseScript extends a lease only if it is still held by the caller.
+var renewLeaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+    return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseLeaseScript deletes a lease only if it is still held by the caller.
+var releaseLeaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+    return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// PartitionLeaser claims frontier partitions for one crawler replica through
+// expiring Redis leases. Each replica aims for an equal share of the
+// partitions based on the live consumers in the registry (see fairShare),
+// releasing extras when replicas join and picking up orphaned partitions
+// when they leave.
+// It implements LaneSource with the lanes of the partitions it holds.
+type PartitionLeaser struct {
+	rdb        *redis.Client
+	registry   *Registry
+	owner      string
+	partitions int
+	weights    []int
+	ttl        time.Duration
+	logger     *slog.Logger
+
+	mu    sync.RWMutex
+	owned map[int]struct{}
+	lanes []Lane
+}
+
+func NewPartitionLeaser(rdb *redis.Client, registry *Registry, owner string, partitions int, weights []int, ttl time.Duration, logger *slog.Logger) *PartitionLeaser {
+	if ttl <= 0 {
+		ttl = defaultLeaseTTL
+	}
+	return &PartitionLeaser{
+		rdb:        rdb,
+		registry:   registry,
+		owner:      owner,
+		partitions: max(partitions, 1),
+		weights:    weights,
+		ttl:        max(ttl, minLeaseTTL),
+		logger:     logger,
+		owned:      make(map[int]struct{}),
+	}
+}
+
+// Run rebalances leases every third of the lease TTL until ctx is cancelled,
+// then releases every lease it holds so peers can take over immediately.
+// Call Rebalance once beforehand to hold partitions before consuming starts.
+func (l *PartitionLeaser) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			relCtx, cancel := ctxBG()
+			defer cancel()
+			l.releaseAll(relCtx)
+			return
+		case <-ticker.C:
+			l.Rebalance(ctx)
+		}
+	}
+}
+
+// Lanes returns the lanes of every partition currently leased.
+func (l *PartitionLeaser) Lanes() []Lane {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.lanes
+}
+
+// Owned returns the partitions currently leased, in ascending order.
+func (l *PartitionLeaser) Owned() []int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return sortedPartitions(l.owned)
+}
+
+// Rebalance renews held leases, releases any above this replica's fair share
+// and acquires free partitions up to it.
+func (l *PartitionLeaser) Rebalance(ctx context.Context) {
+	live, err := l.registry.Live(ctx, consumerDeadAfter)
+	if err != nil {
+		l.logger.Warn("partition rebalance: cannot list live consumers", "error", err)
+		return
+	}
+	live[l.owner] = struct{}{}
+	fair := fairShare(l.owner, live, l.partitions)
+
+	l.mu.RLock()
+	held := sortedPartitions(l.owned)
+	l.mu.RUnlock()
+
+	owned := make(map[int]struct{}, fair)
+	for _, p := range held {
+		ok, err := renewLeaseScript.Run(ctx, l.rdb, []string{leaseKey(p)}, l.owner, l.ttl.Milliseconds()).Int()
+		if err != nil {
+			l.logger.Warn("failed to renew partition lease", "partition", p, "error", err)
+			continue
+		}
+		if ok == 1 {
+			owned[p] = struct{}{}
+		} else {
+			l.logger.Info("lost partition lease", "partition", p)
+		}
+	}
+
+	for _, p := range sortedPartitions(owned) {
+		if len(owned) <= fair {
+			break
+		}
+		if err := releaseLeaseScript.Run(ctx, l.rdb, []string{leaseKey(p)}, l.owner).Err(); err != nil {
+			l.logger.Warn("failed to release partition lease", "partition", p, "error", err)
+			continue
+		}
+		delete(owned, p)
+		l.logger.Info("released partition lease for rebalance", "partition", p)
+	}
+
+	for p := 0; p < l.partitions && len(owned) < fair; p++ {
+		if _, ok := owned[p]; ok {
+			continue
+		}
+		acquired, err := l.rdb.SetNX(ctx, leaseKey(p), l.owner, l.ttl).Result()
+		if err != nil {
+			l.logger.Warn("failed to acquire partition lease", "partition", p, "error", err)
+			continue
+		}
+		if acquired {
+			owned[p] = struct{}{}
+			l.logger.Info("acquired partition lease", "partition", p)
+		}
+	}
+
+	l.setOwned(owned)
+}
+
+// fairShare returns how many of partitions owner should lease among the
+// live replicas: each gets partitions/len(live), and the first
+// partitions%len(live) of them by name one more, so every partition has
+// an owner and none is left with nothing while another holds extras.
+func fairShare(owner string, live map[string]struct{}, partitions int) int {
+	names := make([]string, 0, len(live))
+	for name := range live {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	share := partitions / len(names)
+	if sort.SearchStrings(names, owner) < partitions%len(names) {
+		share++
+	}
+	return share
+}
+
+func (l *PartitionLeaser) releaseAll(ctx context.Context) {
+	for _, p := range l.Owned() {
+		if err := releaseLeaseScript.Run(ctx, l.rdb, []string{leaseKey(p)}, l.owner).Err(); err != nil {
+			l.logger.Warn("failed to release partition lease", "partition", p, "error", err)
+		}
+	}
+	l.setOwned(map[int]struct{}{})
+}
+
+func (l *PartitionLeaser) setOwned(owned map[int]struct{}) {
+	var lanes []Lane
+	for _, p := range sortedPartitions(owned) {
+		lanes = append(lanes, FrontierPartitionLanes(p, l.weights)...)
+	}
+	l.mu.Lock()
+	l.owned = owned
+	l.lanes = lanes
+	l.mu.Unlock()
+}
+
+func leaseKey(partition int) string {
+	return fmt.Sprintf("%s%d", partitionLeaseKeyPrefix, partition)
+}
+
+func sortedPartitions(set map[int]struct{}) []int {
+	out := make([]int, 0, len(set))
+	for p := range set {
+		out = append(out, p)
+	}
+	sort.Ints(out)
+	return out
+}
@@ -0,0 +1,244 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestPartitionFor_StableAndInRange(t *testing.T) {
+	t.Parallel()
+	for i := 0; i < 1000; i++ {
+		host := fmt.Sprintf("host-%d.example.com", i)
+		p := PartitionFor(host, 8)
+		if p < 0 || p >= 8 {
+			t.Fatalf("PartitionFor(%q, 8) = %d, out of range", host, p)
+		}
+		if again := PartitionFor(host, 8); again != p {
+			t.Fatalf("PartitionFor(%q) not deterministic: %d then %d", host, p, again)
+		}
+	}
+	if p := PartitionFor("example.com", 1); p != 0 {
+		t.Errorf("single partition = %d, want 0", p)
+	}
+}
+
+func TestPartitionFor_MinimalMovement(t *testing.T) {
+	t.Parallel()
+	moved := 0
+	const hosts = 2000
+	for i := 0; i < hosts; i++ {
+		host := fmt.Sprintf("host-%d.example.com", i)
+		if PartitionFor(host, 8) != PartitionFor(host, 9) {
+			moved++
+		}
+	}
+	// Going from 8 to 9 partitions should move roughly 1/9 of hosts.
+	if moved > hosts/5 {
+		t.Errorf("%d of %d hosts moved when adding a partition, want ~%d", moved, hosts, hosts/9)
+	}
+}
+
+func TestFrontierPartitionStream(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		partition, priority int
+		want                string
+	}{
+		{0, 0, "stream:frontier"},
+		{0, 2, "stream:frontier:p2"},
+		{3, 0, "stream:frontier:s3"},
+		{3, 1, "stream:frontier:s3:p1"},
+	}
+	for _, tt := range tests {
+		if got := FrontierPartitionStream(tt.partition, tt.priority); got != tt.want {
+			t.Errorf("FrontierPartitionStream(%d, %d) = %q, want %q", tt.partition, tt.priority, got, tt.want)
+		}
+	}
+	if n := len(FrontierStreams(4)); n != 4*PriorityLanes {
+		t.Errorf("FrontierStreams(4) has %d streams, want %d", n, 4*PriorityLanes)
+	}
+}
+
+func TestPublisher_RoutesByHostPartition(t *testing.T) {
+	t.Parallel()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	p := NewRedisPublisher(rdb, 4, EncodingJSON)
+	ctx := context.Background()
+
+	msg := URLMessage{URL: "https://example.com/a", Depth: 1}
+	if err := p.PublishURL(ctx, msg); err != nil {
+		t.Fatalf("PublishURL: %v", err)
+	}
+	want := FrontierPartitionStream(PartitionFor("example.com", 4), 0)
+	if n, _ := rdb.XLen(ctx, want).Result(); n != 1 {
+		t.Errorf("stream %s length = %d, want 1", want, n)
+	}
+	total, err := p.FrontierLen(ctx)
+	if err != nil {
+		t.Fatalf("FrontierLen: %v", err)
+	}
+	if total != 1 {
+		t.Errorf("FrontierLen = %d, want 1", total)
+	}
+}
+
+func newTestLeaser(rdb *redis.Client, owner string, partitions int) *PartitionLeaser {
+	return NewPartitionLeaser(rdb, NewRegistry(rdb, "test-group"), owner, partitions, nil, 30*time.Second, testLogger())
+}
+
+func TestPartitionLeaser_SoleReplicaTakesAll(t *testing.T) {
+	t.Parallel()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+	l := newTestLeaser(rdb, "a", 4)
+	l.Rebalance(context.Background())
+
+	if got := l.Owned(); len(got) != 4 {
+		t.Errorf("owned = %v, want all 4 partitions", got)
+	}
+	if got := len(l.Lanes()); got != 4*PriorityLanes {
+		t.Errorf("lanes = %d, want %d", got, 4*PriorityLanes)
+	}
+}
+
+func TestPartitionLeaser_RebalancesWhenReplicaJoins(t *testing.T) {
+	t.Parallel()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+	reg := NewRegistry(rdb, "test-group")
+
+	a := newTestLeaser(rdb, "a", 4)
+	b := newTestLeaser(rdb, "b", 4)
+	_ = reg.Heartbeat(ctx, "a")
+	a.Rebalance(ctx)
+
+	_ = reg.Heartbeat(ctx, "b")
+	b.Rebalance(ctx) // nothing free yet
+	if got := b.Owned(); len(got) != 0 {
+		t.Fatalf("b owned = %v before a released, want none", got)
+	}
+	a.Rebalance(ctx) // a sees two replicas and releases down to its share
+	b.Rebalance(ctx)
+
+	if got := a.Owned(); len(got) != 2 {
+		t.Errorf("a owned = %v, want 2 partitions", got)
+	}
+	if got := b.Owned(); len(got) != 2 {
+		t.Errorf("b owned = %v, want 2 partitions", got)
+	}
+	for _, p := range a.Owned() {
+		for _, q := range b.Owned() {
+			if p == q {
+				t.Errorf("partition %d leased by both replicas", p)
+			}
+		}
+	}
+}
+
+func TestFairShare(t *testing.T) {
+	t.Parallel()
+	live := map[string]struct{}{"a": {}, "b": {}, "c": {}}
+	tests := []struct {
+		partitions int
+		want       map[string]int
+	}{
+		{3, map[string]int{"a": 1, "b": 1, "c": 1}},
+		{4, map[string]int{"a": 2, "b": 1, "c": 1}},
+		{5, map[string]int{"a": 2, "b": 2, "c": 1}},
+		{2, map[string]int{"a": 1, "b": 1, "c": 0}},
+	}
+	for _, tt := range tests {
+		total := 0
+		for owner, want := range tt.want {
+			got := fairShare(owner, live, tt.partitions)
+			if got != want {
+				t.Errorf("fairShare(%s, 3 replicas, %d) = %d, want %d", owner, tt.partitions, got, want)
+			}
+			total += got
+		}
+		if total != tt.partitions {
+			t.Errorf("shares of %d partitions sum to %d", tt.partitions, total)
+		}
+	}
+}
+
+func TestPartitionLeaser_SplitsUnevenPartitions(t *testing.T) {
+	t.Parallel()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+	reg := NewRegistry(rdb, "test-group")
+
+	var leasers []*PartitionLeaser
+	for _, owner := range []string{"a", "b", "c"} {
+		_ = reg.Heartbeat(ctx, owner)
+		leasers = append(leasers, newTestLeaser(rdb, owner, 4))
+	}
+	for range 2 {
+		for _, l := range leasers {
+			l.Rebalance(ctx)
+		}
+	}
+
+	want := []int{2, 1, 1}
+	total := 0
+	for i, l := range leasers {
+		if got := len(l.Owned()); got != want[i] {
+			t.Errorf("%s owned = %v, want %d partitions", l.owner, l.Owned(), want[i])
+		}
+		total += len(l.Owned())
+	}
+	if total != 4 {
+		t.Errorf("%d of 4 partitions leased", total)
+	}
+}
+
+func TestPartitionLeaser_TakesOverExpiredLeases(t *testing.T) {
+	t.Parallel()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+
+	a := newTestLeaser(rdb, "a", 2)
+	a.Rebalance(ctx)
+
+	// a dies: its leases expire and it stops heartbeating.
+	mr.FastForward(time.Minute)
+
+	b := newTestLeaser(rdb, "b", 2)
+	b.Rebalance(ctx)
+	if got := b.Owned(); len(got) != 2 {
+		t.Errorf("b owned = %v, want both partitions after a's leases expired", got)
+	}
+
+	a.Rebalance(ctx)
+	if got := a.Owned(); len(got) != 0 {
+		t.Errorf("a owned = %v, want none after losing its leases", got)
+	}
+}
+
+func TestNewPartitionLeaser_GuardsTTL(t *testing.T) {
+	t.Parallel()
+	rdb := redis.NewClient(&redis.Options{Addr: "localhost:0"})
+	for _, tc := range []struct {
+		ttl, want time.Duration
+	}{
+		{0, defaultLeaseTTL},
+		{-time.Second, defaultLeaseTTL},
+		{2 * time.Nanosecond, minLeaseTTL},
+		{time.Minute, time.Minute},
+	} {
+		l := NewPartitionLeaser(rdb, NewRegistry(rdb, "test-group"), "a", 1, nil, tc.ttl, testLogger())
+		if l.ttl != tc.want {
+			t.Errorf("ttl %v: leaser ttl = %v, want %v", tc.ttl, l.ttl, tc.want)
+		}
+	}
+}
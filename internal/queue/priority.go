@@ -0,0 +1,88 @@
+package queue
+
+import (
+	"math"
+	"time"
+)
+
+// PriorityLanes is the number of frontier priority lanes. Lane 0 is the
+// lowest priority and the default for messages without a priority;
+// lane PriorityLanes-1 is the highest.
+const PriorityLanes = 3
+
+// HighestPriority is the priority given to seeds and other operator-submitted URLs.
+const HighestPriority = PriorityLanes - 1
+
+const (
+	defaultSitemapPriority = 0.5
+	freshnessHalfLife      = 7 * 24 * time.Hour
+)
+
+// PrioritySignals are the inputs a ScoreFunc uses to rank a URL.
+type PrioritySignals struct {
+	Depth int
+	// SitemapPriority is the <priority> value from a sitemap (0..1);
+	// nil means unknown and is treated as the sitemap default of 0.5.
+	SitemapPriority *float64
+	// HostImportance is an operator-assigned weight for the host (0..1).
+	HostImportance float64
+	// LastCrawl is when the URL was last fetched; nil means never.
+	LastCrawl *time.Time
+}
+
+// ScoreFunc ranks a URL in [0, 1]; higher scores are crawled first.
+type ScoreFunc func(PrioritySignals) float64
+
+// PriorityWeights configures the relative weight of each signal in WeightedScore.
+type PriorityWeights struct {
+	Depth     float64
+	Sitemap   float64
+	Host      float64
+	Freshness float64
+}
+
+// WeightedScore returns a ScoreFunc that combines the normalized signals by
+// the given weights. Shallow, high-sitemap-priority, important and stale (or
+// never crawled) URLs score highest.
+func WeightedScore(w PriorityWeights) ScoreFunc {
+	total := w.Depth + w.Sitemap + w.Host + w.Freshness
+	return func(s PrioritySignals) float64 {
+		if total <= 0 {
+			return 0
+		}
+		depth := 1 / float64(1+max(s.Depth, 0))
+
+		sitemap := defaultSitemapPriority
+		if s.SitemapPriority != nil {
+			sitemap = clamp01(*s.SitemapPriority)
+		}
+
+		freshness := 1.0
+		if s.LastCrawl != nil {
+			age := time.Since(*s.LastCrawl)
+			freshness = 1 - math.Exp2(-age.Hours()/freshnessHalfLife.Hours())
+		}
+
+		score := w.Depth*depth + w.Sitemap*sitemap + w.Host*clamp01(s.HostImportance) + w.Freshness*clamp01(freshness)
+		return clamp01(score / total)
+	}
+}
+
+// PriorityFor maps a score in [0, 1] onto a priority lane.
+func PriorityFor(score float64) int {
+	return clampLane(int(clamp01(score) * PriorityLanes))
+}
+
+func clampLane(priority int) int {
+	if priority < 0 {
+		return 0
+	}
+	if priority >= PriorityLanes {
+		return PriorityLanes - 1
+	}
+	return priority
+}
+
+func clamp01(v float64) float64 {
+	return math.Min(math.Max(v, 0), 1)
+}
@@ -0,0 +1,97 @@
+package queue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWeightedScore_ShallowBeatsDeep(t *testing.T) {
+	t.Parallel()
+	score := WeightedScore(PriorityWeights{Depth: 0.5, Sitemap: 0.2, Host: 0.2, Freshness: 0.1})
+
+	shallow := score(PrioritySignals{Depth: 0})
+	deep := score(PrioritySignals{Depth: 3})
+	if shallow <= deep {
+		t.Errorf("depth 0 score %.3f should exceed depth 3 score %.3f", shallow, deep)
+	}
+}
+
+func TestWeightedScore_Signals(t *testing.T) {
+	t.Parallel()
+	score := WeightedScore(PriorityWeights{Depth: 1, Sitemap: 1, Host: 1, Freshness: 1})
+	high := 1.0
+	low := 0.0
+	recent := time.Now()
+
+	base := score(PrioritySignals{Depth: 2})
+	if got := score(PrioritySignals{Depth: 2, SitemapPriority: &high}); got <= base {
+		t.Errorf("high sitemap priority score %.3f should exceed base %.3f", got, base)
+	}
+	if got := score(PrioritySignals{Depth: 2, SitemapPriority: &low}); got >= base {
+		t.Errorf("low sitemap priority score %.3f should be below base %.3f", got, base)
+	}
+	if got := score(PrioritySignals{Depth: 2, HostImportance: 1}); got <= base {
+		t.Errorf("important host score %.3f should exceed base %.3f", got, base)
+	}
+	if got := score(PrioritySignals{Depth: 2, LastCrawl: &recent}); got >= base {
+		t.Errorf("just-crawled score %.3f should be below never-crawled %.3f", got, base)
+	}
+}
+
+func TestWeightedScore_ZeroWeights(t *testing.T) {
+	t.Parallel()
+	if got := WeightedScore(PriorityWeights{})(PrioritySignals{}); got != 0 {
+		t.Errorf("score with zero weights = %v, want 0", got)
+	}
+}
+
+func TestPriorityFor(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		score float64
+		want  int
+	}{
+		{-1, 0},
+		{0, 0},
+		{0.2, 0},
+		{0.5, 1},
+		{0.9, 2},
+		{1, HighestPriority},
+		{5, HighestPriority},
+	}
+	for _, tt := range tests {
+		if got := PriorityFor(tt.score); got != tt.want {
+			t.Errorf("PriorityFor(%v) = %d, want %d", tt.score, got, tt.want)
+		}
+	}
+}
+
+func TestFrontierLaneStream(t *testing.T) {
+	t.Parallel()
+	if got := FrontierLaneStream(0); got != FrontierStream {
+		t.Errorf("lane 0 = %q, want %q", got, FrontierStream)
+	}
+	if got := FrontierLaneStream(-3); got != FrontierStream {
+		t.Errorf("negative priority = %q, want %q", got, FrontierStream)
+	}
+	if got := FrontierLaneStream(2); got != "stream:frontier:p2" {
+		t.Errorf("lane 2 = %q, want stream:frontier:p2", got)
+	}
+	if got := FrontierLaneStream(99); got != FrontierLaneStream(HighestPriority) {
+		t.Errorf("out-of-range priority = %q, want highest lane", got)
+	}
+}
+
+func TestFrontierLanes_Weights(t *testing.T) {
+	t.Parallel()
+	lanes := FrontierLanes([]int{2})
+	if len(lanes) != PriorityLanes {
+		t.Fatalf("got %d lanes, want %d", len(lanes), PriorityLanes)
+	}
+	if lanes[0].Weight != 2 {
+		t.Errorf("lane 0 weight = %d, want configured 2", lanes[0].Weight)
+	}
+	if lanes[2].Weight != 16 {
+		t.Errorf("lane 2 weight = %d, want default 16", lanes[2].Weight)
+	}
+}
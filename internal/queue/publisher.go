@@ -2,8 +2,8 @@ package queue
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"net/url"
 
 	"github.com/redis/go-redis/v9"
 )
@@ -13,29 +13,57 @@ const (
 	pipelineBatchMax       = 500
 )
 
-type Publisher struct {
-	rdb *redis.Client
+// RedisPublisher publishes to Redis streams.
+type RedisPublisher struct {
+	rdb        *redis.Client
+	partitions int
+	encoding   Encoding
 }
 
-func NewPublisher(rdb *redis.Client) *Publisher {
-	return &Publisher{rdb: rdb}
+// NewRedisPublisher returns a publisher that routes URL messages across the given
+// number of frontier partitions by host and writes envelopes in enc.
+func NewRedisPublisher(rdb *redis.Client, partitions int, enc Encoding) *RedisPublisher {
+	if enc == "" {
+		enc = EncodingJSON
+	}
+	return &RedisPublisher{rdb: rdb, partitions: max(partitions, 1), encoding: enc}
 }
 
-func (p *Publisher) PublishURL(ctx context.Context, msg URLMessage) error {
-	body, err := json.Marshal(msg)
+// values wraps body in an envelope and returns the stream entry fields.
+func (p *RedisPublisher) values(ctx context.Context, body any) (map[string]interface{}, error) {
+	payload, err := encodeEnvelope(p.encoding, publishHeaders(ctx), body)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{payloadField: payload, encodingField: string(p.encoding)}, nil
+}
+
+// frontierStream returns the partition and priority lane stream for msg.
+func (p *RedisPublisher) frontierStream(msg URLMessage) string {
+	partition := 0
+	if p.partitions > 1 {
+		if u, err := url.Parse(msg.URL); err == nil {
+			partition = PartitionFor(u.Hostname(), p.partitions)
+		}
+	}
+	return FrontierPartitionStream(partition, msg.Priority)
+}
+
+func (p *RedisPublisher) PublishURL(ctx context.Context, msg URLMessage) error {
+	values, err := p.values(ctx, msg)
 	if err != nil {
 		return fmt.Errorf("marshaling url message: %w", err)
 	}
 	return p.rdb.XAdd(ctx, &redis.XAddArgs{
-		Stream: FrontierStream,
+		Stream: p.frontierStream(msg),
 		MaxLen: streamMaxLen,
 		Approx: true,
-		Values: map[string]interface{}{"payload": body},
+		Values: values,
 	}).Err()
 }
 
-func (p *Publisher) PublishParse(ctx context.Context, msg ParseMessage) error {
-	body, err := json.Marshal(msg)
+func (p *RedisPublisher) PublishParse(ctx context.Context, msg ParseMessage) error {
+	values, err := p.values(ctx, msg)
 	if err != nil {
 		return fmt.Errorf("marshaling parse message: %w", err)
 	}
@@ -43,13 +71,13 @@ func (p *Publisher) PublishParse(ctx context.Context, msg ParseMessage) error {
 		Stream: ParseStream,
 		MaxLen: streamMaxLen,
 		Approx: true,
-		Values: map[string]interface{}{"payload": body},
+		Values: values,
 	}).Err()
 }
 
-// PublishURLBatch pipelines multiple URL messages into the frontier stream.
+// PublishURLBatch pipelines multiple URL messages into their frontier partition and lane streams.
 // Large batches are chunked to avoid excessive memory usage in Redis pipelines.
-func (p *Publisher) PublishURLBatch(ctx context.Context, msgs []URLMessage) error {
+func (p *RedisPublisher) PublishURLBatch(ctx context.Context, msgs []URLMessage) error {
 	if len(msgs) == 0 {
 		return nil
 	}
@@ -60,15 +88,15 @@ func (p *Publisher) PublishURLBatch(ctx context.Context, msgs []URLMessage) erro
 		}
 		pipe := p.rdb.Pipeline()
 		for _, msg := range msgs[i:end] {
-			body, err := json.Marshal(msg)
+			values, err := p.values(ctx, msg)
 			if err != nil {
 				return fmt.Errorf("marshaling url message: %w", err)
 			}
 			pipe.XAdd(ctx, &redis.XAddArgs{
-				Stream: FrontierStream,
+				Stream: p.frontierStream(msg),
 				MaxLen: streamMaxLen,
 				Approx: true,
-				Values: map[string]interface{}{"payload": body},
+				Values: values,
 			})
 		}
 		if _, err := pipe.Exec(ctx); err != nil {
@@ -79,8 +107,47 @@ func (p *Publisher) PublishURLBatch(ctx context.Context, msgs []URLMessage) erro
 }
 
 // StreamLen returns the number of messages in the given stream.
-func (p *Publisher) StreamLen(ctx context.Context, stream string) (int64, error) {
+func (p *RedisPublisher) StreamLen(ctx context.Context, stream string) (int64, error) {
 	return p.rdb.XLen(ctx, stream).Result()
 }
 
-func (p *Publisher) Close() {}
+// FrontierLen returns the total number of messages across all frontier
+// partitions and lanes.
+func (p *RedisPublisher) FrontierLen(ctx context.Context) (int64, error) {
+	streams := FrontierStreams(p.partitions)
+	pipe := p.rdb.Pipeline()
+	cmds := make([]*redis.IntCmd, 0, len(streams))
+	for _, stream := range streams {
+		cmds = append(cmds, pipe.XLen(ctx, stream))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("reading frontier length: %w", err)
+	}
+	var total int64
+	for _, cmd := range cmds {
+		total += cmd.Val()
+	}
+	return total, nil
+}
+
+// ParseLen returns the parser group's lag plus its pending entries. The
+// parse stream keeps acknowledged entries until trimmed, so its length alone
+// overstates the backlog; it is used only when Redis cannot report the lag.
+func (p *RedisPublisher) ParseLen(ctx context.Context) (int64, error) {
+	groups, err := p.rdb.XInfoGroups(ctx, ParseStream).Result()
+	if err != nil {
+		return 0, fmt.Errorf("reading parse backlog: %w", err)
+	}
+	for _, g := range groups {
+		if g.Name != ParserGroup {
+			continue
+		}
+		if g.Lag < 0 {
+			break
+		}
+		return g.Lag + g.Pending, nil
+	}
+	return p.StreamLen(ctx, ParseStream)
+}
+
+func (p *RedisPublisher) Close() {}
@@ -2,7 +2,6 @@ package queue
 
 import (
 	"context"
-	"encoding/json"
 	"testing"
 
 	"github.com/alicebob/miniredis/v2"
@@ -13,7 +12,7 @@ func TestPublishURLBatch_Empty(t *testing.T) {
 	t.Parallel()
 	mr := miniredis.RunT(t)
 	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
-	p := NewPublisher(rdb)
+	p := NewRedisPublisher(rdb, 1, EncodingJSON)
 
 	if err := p.PublishURLBatch(context.Background(), nil); err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -33,7 +32,7 @@ func TestPublishURLBatch_SingleChunk(t *testing.T) {
 	t.Parallel()
 	mr := miniredis.RunT(t)
 	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
-	p := NewPublisher(rdb)
+	p := NewRedisPublisher(rdb, 1, EncodingJSON)
 
 	msgs := make([]URLMessage, 10)
 	for i := range msgs {
@@ -57,7 +56,7 @@ func TestPublishURLBatch_MultipleChunks(t *testing.T) {
 	t.Parallel()
 	mr := miniredis.RunT(t)
 	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
-	p := NewPublisher(rdb)
+	p := NewRedisPublisher(rdb, 1, EncodingJSON)
 
 	// Create more messages than pipelineBatchMax (500)
 	count := pipelineBatchMax + 100
@@ -83,7 +82,7 @@ func TestPublishURLBatch_ExactChunkBoundary(t *testing.T) {
 	t.Parallel()
 	mr := miniredis.RunT(t)
 	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
-	p := NewPublisher(rdb)
+	p := NewRedisPublisher(rdb, 1, EncodingJSON)
 
 	// Exactly pipelineBatchMax messages
 	msgs := make([]URLMessage, pipelineBatchMax)
@@ -108,7 +107,7 @@ func TestPublishURL(t *testing.T) {
 	t.Parallel()
 	mr := miniredis.RunT(t)
 	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
-	p := NewPublisher(rdb)
+	p := NewRedisPublisher(rdb, 1, EncodingJSON)
 
 	msg := URLMessage{URL: "https://example.com/page", Depth: 2}
 	if err := p.PublishURL(context.Background(), msg); err != nil {
@@ -124,15 +123,8 @@ func TestPublishURL(t *testing.T) {
 		t.Fatalf("got %d entries, want 1", len(entries))
 	}
 
-	payload, ok := entries[0].Values["payload"].(string)
-	if !ok {
-		t.Fatal("payload field not found or not a string")
-	}
-
 	var got URLMessage
-	if err := json.Unmarshal([]byte(payload), &got); err != nil {
-		t.Fatalf("unmarshal payload: %v", err)
-	}
+	decodeEntry(t, entries[0], &got)
 	if got.URL != msg.URL || got.Depth != msg.Depth {
 		t.Errorf("got %+v, want %+v", got, msg)
 	}
@@ -142,7 +134,7 @@ func TestPublishParse(t *testing.T) {
 	t.Parallel()
 	mr := miniredis.RunT(t)
 	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
-	p := NewPublisher(rdb)
+	p := NewRedisPublisher(rdb, 1, EncodingJSON)
 
 	msg := ParseMessage{
 		URLID:      "uuid-123",
@@ -162,15 +154,8 @@ func TestPublishParse(t *testing.T) {
 		t.Fatalf("got %d entries, want 1", len(entries))
 	}
 
-	payload, ok := entries[0].Values["payload"].(string)
-	if !ok {
-		t.Fatal("payload field not found or not a string")
-	}
-
 	var got ParseMessage
-	if err := json.Unmarshal([]byte(payload), &got); err != nil {
-		t.Fatalf("unmarshal payload: %v", err)
-	}
+	decodeEntry(t, entries[0], &got)
 	if got != msg {
 		t.Errorf("got %+v, want %+v", got, msg)
 	}
@@ -180,7 +165,7 @@ func TestStreamLen(t *testing.T) {
 	t.Parallel()
 	mr := miniredis.RunT(t)
 	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
-	p := NewPublisher(rdb)
+	p := NewRedisPublisher(rdb, 1, EncodingJSON)
 
 	// Publish a few messages
 	for i := 0; i < 5; i++ {
@@ -197,3 +182,90 @@ func TestStreamLen(t *testing.T) {
 		t.Errorf("StreamLen = %d, want 5", length)
 	}
 }
+
+func TestParseLen(t *testing.T) {
+	t.Parallel()
+	for _, withGroup := range []bool{false, true} {
+		ctx := context.Background()
+		mr := miniredis.RunT(t)
+		rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		p := NewRedisPublisher(rdb, 1, EncodingJSON)
+
+		// Without the parser group the stream length is the backlog.
+		if err := rdb.XGroupCreateMkStream(ctx, ParseStream, "other", "$").Err(); err != nil {
+			t.Fatalf("XGroupCreateMkStream: %v", err)
+		}
+		if withGroup {
+			if err := rdb.XGroupCreate(ctx, ParseStream, ParserGroup, "$").Err(); err != nil {
+				t.Fatalf("XGroupCreate: %v", err)
+			}
+		}
+		for i := 0; i < 3; i++ {
+			if err := p.PublishParse(ctx, ParseMessage{URLID: "id", URL: "https://example.com"}); err != nil {
+				t.Fatalf("PublishParse: %v", err)
+			}
+		}
+
+		n, err := p.ParseLen(ctx)
+		if err != nil {
+			t.Fatalf("ParseLen (group %v): %v", withGroup, err)
+		}
+		if n != 3 {
+			t.Errorf("ParseLen (group %v) = %d, want 3", withGroup, n)
+		}
+	}
+}
+
+func TestPublishURLBatch_RoutesByPriority(t *testing.T) {
+	t.Parallel()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	p := NewRedisPublisher(rdb, 1, EncodingJSON)
+
+	msgs := []URLMessage{
+		{URL: "https://example.com/a", Depth: 3},
+		{URL: "https://example.com/b", Depth: 0, Priority: HighestPriority},
+		{URL: "https://example.com/c", Depth: 0, Priority: HighestPriority},
+	}
+	if err := p.PublishURLBatch(context.Background(), msgs); err != nil {
+		t.Fatalf("PublishURLBatch: %v", err)
+	}
+
+	if n, _ := rdb.XLen(context.Background(), FrontierStream).Result(); n != 1 {
+		t.Errorf("lane 0 length = %d, want 1", n)
+	}
+	if n, _ := rdb.XLen(context.Background(), FrontierLaneStream(HighestPriority)).Result(); n != 2 {
+		t.Errorf("highest lane length = %d, want 2", n)
+	}
+
+	total, err := p.FrontierLen(context.Background())
+	if err != nil {
+		t.Fatalf("FrontierLen: %v", err)
+	}
+	if total != 3 {
+		t.Errorf("FrontierLen = %d, want 3", total)
+	}
+}
+
+// decodeEntry unwraps the envelope of a published stream entry into v.
+func decodeEntry(t *testing.T, entry redis.XMessage, v any) {
+	t.Helper()
+	payload, ok := entry.Values["payload"].(string)
+	if !ok {
+		t.Fatal("payload field not found or not a string")
+	}
+	enc, _ := entry.Values["encoding"].(string)
+	version, headers, body, err := decodeEnvelope(Encoding(enc), []byte(payload))
+	if err != nil {
+		t.Fatalf("decode envelope: %v", err)
+	}
+	if version != SchemaVersion {
+		t.Errorf("schema_version = %d, want %d", version, SchemaVersion)
+	}
+	if headers[HeaderEnqueuedAt] == "" {
+		t.Errorf("missing %s header", HeaderEnqueuedAt)
+	}
+	if err := unmarshalBody(Encoding(enc), body, v); err != nil {
+		t.Fatalf("unmarshal body: %v", err)
+	}
+}
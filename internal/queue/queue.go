@@ -0,0 +1,33 @@
+package queue
+
+import "context"
+
+// Publisher enqueues URLs onto the frontier and fetched pages for parsing.
+type Publisher interface {
+	PublishURL(ctx context.Context, msg URLMessage) error
+	// PublishURLBatch publishes msgs, possibly in fewer round trips.
+	PublishURLBatch(ctx context.Context, msgs []URLMessage) error
+	PublishParse(ctx context.Context, msg ParseMessage) error
+	// FrontierLen returns the number of URLs waiting on the frontier, for
+	// backpressure.
+	FrontierLen(ctx context.Context) (int64, error)
+	// ParseLen returns the number of parse messages not yet acknowledged.
+	ParseLen(ctx context.Context) (int64, error)
+	Close()
+}
+
+// Consumer delivers messages from one queue. Unacked deliveries are
+// redelivered after a timeout, and Nack(true) moves them to a dead-letter
+// queue.
+type Consumer interface {
+	// Run starts consuming and returns the delivery channel, which is closed
+	// once ctx is cancelled and all background loops have exited.
+	Run(ctx context.Context) <-chan Delivery
+	// Wait blocks until the goroutines started by Run have exited.
+	Wait()
+}
+
+var (
+	_ Publisher = (*RedisPublisher)(nil)
+	_ Consumer  = (*RedisConsumer)(nil)
+)
@@ -0,0 +1,95 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	registryKeyPrefix = "consumers:"
+	instanceIDBytes   = 4
+)
+
+// ConsumerName returns a consumer identity that is unique per process
+// instance: <role>-<pod or hostname>-<random instance id>. POD_NAME takes
+// precedence over the hostname so Kubernetes pods keep readable names.
+func ConsumerName(role string) string {
+	host := os.Getenv("POD_NAME")
+	if host == "" {
+		if h, err := os.Hostname(); err == nil {
+			host = h
+		} else {
+			host = "unknown"
+		}
+	}
+	return fmt.Sprintf("%s-%s-%s", role, host, instanceID())
+}
+
+func instanceID() string {
+	b := make([]byte, instanceIDBytes)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Registry tracks live consumers of a group in a Redis sorted set scored by
+// their last heartbeat (unix millis).
+type Registry struct {
+	rdb *redis.Client
+	key string
+}
+
+func NewRegistry(rdb *redis.Client, group string) *Registry {
+	return &Registry{rdb: rdb, key: registryKeyPrefix + group}
+}
+
+// Heartbeat records that the named consumer is alive now.
+func (r *Registry) Heartbeat(ctx context.Context, name string) error {
+	return r.rdb.ZAdd(ctx, r.key, redis.Z{
+		Score:  float64(time.Now().UnixMilli()),
+		Member: name,
+	}).Err()
+}
+
+// Remove deletes the named consumer from the registry.
+func (r *Registry) Remove(ctx context.Context, name string) error {
+	return r.rdb.ZRem(ctx, r.key, name).Err()
+}
+
+// Expired returns the consumers whose last heartbeat is older than deadAfter.
+func (r *Registry) Expired(ctx context.Context, deadAfter time.Duration) ([]string, error) {
+	cutoff := time.Now().Add(-deadAfter).UnixMilli()
+	names, err := r.rdb.ZRangeByScore(ctx, r.key, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: "(" + strconv.FormatInt(cutoff, 10),
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("listing expired consumers: %w", err)
+	}
+	return names, nil
+}
+
+// Live returns the consumers that have sent a heartbeat within deadAfter.
+func (r *Registry) Live(ctx context.Context, deadAfter time.Duration) (map[string]struct{}, error) {
+	cutoff := time.Now().Add(-deadAfter).UnixMilli()
+	names, err := r.rdb.ZRangeByScore(ctx, r.key, &redis.ZRangeBy{
+		Min: strconv.FormatInt(cutoff, 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("listing live consumers: %w", err)
+	}
+	live := make(map[string]struct{}, len(names))
+	for _, n := range names {
+		live[n] = struct{}{}
+	}
+	return live, nil
+}
@@ -0,0 +1,239 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestConsumerName_UsesPodName(t *testing.T) {
+	t.Setenv("POD_NAME", "crawler-7f9c")
+
+	a := ConsumerName("crawler")
+	b := ConsumerName("crawler")
+	if !strings.HasPrefix(a, "crawler-crawler-7f9c-") {
+		t.Errorf("ConsumerName = %q, want prefix %q", a, "crawler-crawler-7f9c-")
+	}
+	if a == b {
+		t.Errorf("ConsumerName should include a random instance id, got %q twice", a)
+	}
+}
+
+func TestRegistry_HeartbeatAndLive(t *testing.T) {
+	t.Parallel()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	r := NewRegistry(rdb, "test-group")
+	ctx := context.Background()
+
+	if err := r.Heartbeat(ctx, "alive"); err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+	// A heartbeat from long ago should not count as live.
+	stale := float64(time.Now().Add(-time.Hour).UnixMilli())
+	if err := rdb.ZAdd(ctx, "consumers:test-group", redis.Z{Score: stale, Member: "stale"}).Err(); err != nil {
+		t.Fatalf("ZAdd: %v", err)
+	}
+
+	live, err := r.Live(ctx, time.Minute)
+	if err != nil {
+		t.Fatalf("Live: %v", err)
+	}
+	if _, ok := live["alive"]; !ok {
+		t.Error("expected 'alive' to be live")
+	}
+	if _, ok := live["stale"]; ok {
+		t.Error("expected 'stale' not to be live")
+	}
+
+	if err := r.Remove(ctx, "alive"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	live, _ = r.Live(ctx, time.Minute)
+	if len(live) != 0 {
+		t.Errorf("live = %v, want empty after Remove", live)
+	}
+}
+
+func TestReapDeadConsumers_ClaimsPendingAndDeletesConsumer(t *testing.T) {
+	t.Parallel()
+	mr, rdb, c := setupConsumer(t)
+	ctx := context.Background()
+
+	if err := rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: "stream:test",
+		Values: map[string]interface{}{"payload": "orphaned"},
+	}).Err(); err != nil {
+		t.Fatalf("XAdd: %v", err)
+	}
+	// A consumer reads the entry and then dies without acking it.
+	if err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    "test-group",
+		Consumer: "dead-consumer",
+		Streams:  []string{"stream:test", ">"},
+		Count:    1,
+	}).Err(); err != nil {
+		t.Fatalf("XReadGroup: %v", err)
+	}
+
+	mr.SetTime(time.Now().Add(2 * consumerDeadAfter))
+
+	ch := make(chan Delivery, 1)
+	c.reapDeadConsumers(ctx, ch, "stream:test")
+
+	select {
+	case d := <-ch:
+		if string(d.Body) != "orphaned" {
+			t.Errorf("body = %q, want %q", d.Body, "orphaned")
+		}
+	default:
+		t.Fatal("expected orphaned entry to be redelivered")
+	}
+
+	consumers, err := rdb.XInfoConsumers(ctx, "stream:test", "test-group").Result()
+	if err != nil {
+		t.Fatalf("XInfoConsumers: %v", err)
+	}
+	for _, info := range consumers {
+		if info.Name == "dead-consumer" {
+			t.Error("dead consumer should have been removed from the group")
+		}
+	}
+}
+
+func TestReapDeadConsumers_SkipsLiveConsumers(t *testing.T) {
+	t.Parallel()
+	mr, rdb, c := setupConsumer(t)
+	ctx := context.Background()
+
+	if err := rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: "stream:test",
+		Values: map[string]interface{}{"payload": "in-flight"},
+	}).Err(); err != nil {
+		t.Fatalf("XAdd: %v", err)
+	}
+	if err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    "test-group",
+		Consumer: "slow-consumer",
+		Streams:  []string{"stream:test", ">"},
+		Count:    1,
+	}).Err(); err != nil {
+		t.Fatalf("XReadGroup: %v", err)
+	}
+	if err := c.registry.Heartbeat(ctx, "slow-consumer"); err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+
+	mr.SetTime(time.Now().Add(2 * consumerDeadAfter))
+
+	ch := make(chan Delivery, 1)
+	c.reapDeadConsumers(ctx, ch, "stream:test")
+
+	if len(ch) != 0 {
+		t.Error("entries of a consumer with a recent heartbeat must not be claimed")
+	}
+	pending, err := rdb.XPending(ctx, "stream:test", "test-group").Result()
+	if err != nil {
+		t.Fatalf("XPending: %v", err)
+	}
+	if pending.Consumers["slow-consumer"] != 1 {
+		t.Errorf("slow-consumer pending = %d, want 1", pending.Consumers["slow-consumer"])
+	}
+}
+
+// failXPending fails XPENDING for one consumer, so its entries cannot be claimed.
+type failXPending struct{ consumer string }
+
+func (failXPending) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h failXPending) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if cmd.Name() == "xpending" && slices.Contains(cmd.Args(), any(h.consumer)) {
+			err := errors.New("injected XPENDING failure")
+			cmd.SetErr(err)
+			return err
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (failXPending) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func TestReapDeadConsumers_ContinuesPastUnclaimableConsumer(t *testing.T) {
+	t.Parallel()
+	mr, rdb, c := setupConsumer(t)
+	ctx := context.Background()
+
+	for _, consumer := range []string{"a-stuck", "b-dead"} {
+		if err := rdb.XAdd(ctx, &redis.XAddArgs{
+			Stream: "stream:test",
+			Values: map[string]interface{}{"payload": consumer},
+		}).Err(); err != nil {
+			t.Fatalf("XAdd: %v", err)
+		}
+		if err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    "test-group",
+			Consumer: consumer,
+			Streams:  []string{"stream:test", ">"},
+			Count:    1,
+		}).Err(); err != nil {
+			t.Fatalf("XReadGroup: %v", err)
+		}
+	}
+	mr.SetTime(time.Now().Add(2 * consumerDeadAfter))
+	// a-stuck sorts first; failing its claim must not hold up b-dead.
+	rdb.AddHook(failXPending{consumer: "a-stuck"})
+
+	ch := make(chan Delivery, 2)
+	remaining, ok := c.reapDeadConsumers(ctx, ch, "stream:test")
+	if !ok {
+		t.Fatal("reapDeadConsumers reported failure")
+	}
+
+	select {
+	case d := <-ch:
+		if string(d.Body) != "b-dead" {
+			t.Errorf("body = %q, want b-dead's entry", d.Body)
+		}
+	default:
+		t.Fatal("b-dead's entry should be claimed even though a-stuck's could not be")
+	}
+	if !slices.Contains(remaining, "a-stuck") || slices.Contains(remaining, "b-dead") {
+		t.Errorf("remaining = %v, want a-stuck kept and b-dead removed", remaining)
+	}
+}
+
+func TestPruneRegistry_RemovesExpiredEntriesWithoutStreamConsumer(t *testing.T) {
+	t.Parallel()
+	_, rdb, c := setupConsumer(t)
+	ctx := context.Background()
+
+	stale := float64(time.Now().Add(-2 * consumerDeadAfter).UnixMilli())
+	for _, name := range []string{"never-read", "still-pending"} {
+		if err := rdb.ZAdd(ctx, "consumers:test-group", redis.Z{Score: stale, Member: name}).Err(); err != nil {
+			t.Fatalf("ZAdd: %v", err)
+		}
+	}
+	if err := c.registry.Heartbeat(ctx, "alive"); err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+
+	c.pruneRegistry(ctx, map[string]struct{}{"still-pending": {}})
+
+	names, err := rdb.ZRange(ctx, "consumers:test-group", 0, -1).Result()
+	if err != nil {
+		t.Fatalf("ZRange: %v", err)
+	}
+	slices.Sort(names)
+	if want := []string{"alive", "still-pending"}; !slices.Equal(names, want) {
+		t.Errorf("registry = %v, want %v", names, want)
+	}
+}
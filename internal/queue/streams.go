@@ -2,6 +2,8 @@ package queue
 
 import (
 	"context"
+	"fmt"
+	"hash/fnv"
 	"log/slog"
 	"strings"
 
@@ -18,15 +20,97 @@ const (
 	ParserGroup  = "parser-workers"
 )
 
+// Lane is one stream read by a Consumer, with its relative read weight.
+type Lane struct {
+	Stream string
+	Weight int
+}
+
+// FrontierLaneStream returns the frontier stream for a priority in partition 0.
+// Lane 0 is FrontierStream itself so messages published before priorities
+// existed are still consumed.
+func FrontierLaneStream(priority int) string {
+	return FrontierPartitionStream(0, priority)
+}
+
+// FrontierPartitionStream returns the frontier stream for a partition and
+// priority. Partition 0 keeps the unpartitioned names, so a single-partition
+// deployment uses exactly the streams it always has.
+func FrontierPartitionStream(partition, priority int) string {
+	base := FrontierStream
+	if partition > 0 {
+		base = fmt.Sprintf("%s:s%d", FrontierStream, partition)
+	}
+	lane := clampLane(priority)
+	if lane == 0 {
+		return base
+	}
+	return fmt.Sprintf("%s:p%d", base, lane)
+}
+
+// FrontierStreams returns every frontier stream across all partitions and
+// lanes, partition by partition, lowest priority first.
+func FrontierStreams(partitions int) []string {
+	partitions = max(partitions, 1)
+	streams := make([]string, 0, partitions*PriorityLanes)
+	for p := 0; p < partitions; p++ {
+		for lane := 0; lane < PriorityLanes; lane++ {
+			streams = append(streams, FrontierPartitionStream(p, lane))
+		}
+	}
+	return streams
+}
+
+// FrontierLanes returns the partition-0 frontier lanes with the given read weights.
+func FrontierLanes(weights []int) []Lane {
+	return FrontierPartitionLanes(0, weights)
+}
+
+// FrontierPartitionLanes returns a partition's frontier lanes with the given
+// read weights, indexed by priority. Missing or non-positive weights default
+// to 4^priority, so each lane is read four times as often as the one below it.
+func FrontierPartitionLanes(partition int, weights []int) []Lane {
+	lanes := make([]Lane, PriorityLanes)
+	for i := range lanes {
+		w := 1 << (2 * i)
+		if i < len(weights) && weights[i] > 0 {
+			w = weights[i]
+		}
+		lanes[i] = Lane{Stream: FrontierPartitionStream(partition, i), Weight: w}
+	}
+	return lanes
+}
+
+// PartitionFor maps a host onto one of n frontier partitions with jump
+// consistent hashing, so changing n only moves about 1/n of the hosts.
+func PartitionFor(host string, partitions int) int {
+	if partitions <= 1 {
+		return 0
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(strings.ToLower(host)))
+	key := h.Sum64()
+
+	var b, j int64 = -1, 0
+	for j < int64(partitions) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
 // EnsureStreams creates consumer groups (and their underlying streams) idempotently.
-func EnsureStreams(ctx context.Context, rdb *redis.Client, logger *slog.Logger) error {
-	groups := []struct {
+func EnsureStreams(ctx context.Context, rdb *redis.Client, partitions int, logger *slog.Logger) error {
+	type streamGroup struct {
 		stream string
 		group  string
-	}{
-		{FrontierStream, CrawlerGroup},
-		{ParseStream, ParserGroup},
 	}
+	var groups []streamGroup
+	for _, stream := range FrontierStreams(partitions) {
+		groups = append(groups, streamGroup{stream, CrawlerGroup})
+	}
+	groups = append(groups, streamGroup{ParseStream, ParserGroup})
 
 	for _, g := range groups {
 		err := rdb.XGroupCreateMkStream(ctx, g.stream, g.group, "0").Err()
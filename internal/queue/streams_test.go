@@ -37,7 +37,7 @@ func TestEnsureStreams_CreatesGroups(t *testing.T) {
 	mr := miniredis.RunT(t)
 	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
 
-	err := EnsureStreams(context.Background(), rdb, testLogger())
+	err := EnsureStreams(context.Background(), rdb, 1, testLogger())
 	if err != nil {
 		t.Fatalf("EnsureStreams: %v", err)
 	}
@@ -66,12 +66,12 @@ func TestEnsureStreams_Idempotent(t *testing.T) {
 	mr := miniredis.RunT(t)
 	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
 
-	err := EnsureStreams(context.Background(), rdb, testLogger())
+	err := EnsureStreams(context.Background(), rdb, 1, testLogger())
 	if err != nil {
 		t.Fatalf("first EnsureStreams: %v", err)
 	}
 
-	err = EnsureStreams(context.Background(), rdb, testLogger())
+	err = EnsureStreams(context.Background(), rdb, 1, testLogger())
 	if err != nil {
 		t.Fatalf("second EnsureStreams should be idempotent: %v", err)
 	}
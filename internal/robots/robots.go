@@ -39,9 +39,15 @@ type Checker struct {
 	pool   *pgxpool.Pool
 	rdb    *redis.Client
 	client *http.Client
+	record Recorder
 	logger *slog.Logger
 }
 
+// Recorder is called with every robots.txt response a Checker fetches and
+// as much of its body as was read, for archiving. fetchedAt is when the
+// request was sent.
+type Recorder func(ctx context.Context, resp *http.Response, body []byte, fetchedAt time.Time)
+
 func NewChecker(pool *pgxpool.Pool, rdb *redis.Client, logger *slog.Logger) *Checker {
 	return &Checker{
 		pool:   pool,
@@ -51,6 +57,18 @@ func NewChecker(pool *pgxpool.Pool, rdb *redis.Client, logger *slog.Logger) *Che
 	}
 }
 
+// SetTransport replaces the transport robots.txt files are fetched with, so
+// they can be served from an archive. It must be called before use.
+func (c *Checker) SetTransport(rt http.RoundTripper) {
+	c.client.Transport = rt
+}
+
+// SetRecorder makes the checker pass every robots.txt response it fetches
+// to record. It must be called before use.
+func (c *Checker) SetRecorder(record Recorder) {
+	c.record = record
+}
+
 func (c *Checker) IsAllowed(ctx context.Context, rawURL, domain string) (bool, int, error) {
 	robotsBody, crawlDelay, err := c.getRobotsText(ctx, domain)
 	if err != nil {
@@ -126,6 +144,7 @@ func (c *Checker) getRobotsText(ctx context.Context, domain string) (string, int
 		return "", DefaultCrawlDelayMs, nil
 	}
 	req.Header.Set("User-Agent", CrawlerUserAgent)
+	fetchedAt := time.Now()
 	resp, err := c.client.Do(req)
 	if err != nil {
 		_ = models.UpsertDomain(ctx, c.pool, domain, DefaultCrawlDelayMs)
@@ -134,15 +153,18 @@ func (c *Checker) getRobotsText(ctx context.Context, domain string) (string, int
 	}
 	defer resp.Body.Close()
 
+	body, readErr := io.ReadAll(io.LimitReader(resp.Body, maxRobotsBodySize))
+	if c.record != nil && readErr == nil {
+		c.record(ctx, resp, body, fetchedAt)
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		_ = models.UpsertDomain(ctx, c.pool, domain, DefaultCrawlDelayMs)
 		c.cacheRobotsHash(ctx, key, "", DefaultCrawlDelayMs)
 		return "", DefaultCrawlDelayMs, nil
 	}
-
-	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsBodySize))
-	if err != nil {
-		return "", DefaultCrawlDelayMs, fmt.Errorf("reading robots.txt: %w", err)
+	if readErr != nil {
+		return "", DefaultCrawlDelayMs, fmt.Errorf("reading robots.txt: %w", readErr)
 	}
 
 	robotsBody := string(body)
@@ -15,7 +15,7 @@ import (
 	"github.com/theognis1002/nimbus-crawler/internal/robots"
 )
 
-func LoadAndPublish(ctx context.Context, seedFile string, pool *pgxpool.Pool, publisher *queue.Publisher, logger *slog.Logger) error {
+func LoadAndPublish(ctx context.Context, seedFile string, pool *pgxpool.Pool, publisher queue.Publisher, logger *slog.Logger) error {
 	f, err := os.Open(seedFile)
 	if err != nil {
 		return fmt.Errorf("opening seed file: %w", err)
@@ -63,7 +63,7 @@ func LoadAndPublish(ctx context.Context, seedFile string, pool *pgxpool.Pool, pu
 			continue
 		}
 
-		if err := publisher.PublishURL(ctx, queue.URLMessage{URL: line, Depth: 0}); err != nil {
+		if err := publisher.PublishURL(ctx, queue.URLMessage{URL: line, Depth: 0, Priority: queue.HighestPriority}); err != nil {
 			logger.Error("failed to publish seed url", "url", line, "error", err)
 			continue
 		}
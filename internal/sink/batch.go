@@ -0,0 +1,265 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/theognis1002/nimbus-crawler/internal/metrics"
+)
+
+const (
+	// maxBackoff caps the wait between retries.
+	maxBackoff = 30 * time.Second
+	// deadLetterTimeout bounds dead-lettering once a Batcher gives up.
+	deadLetterTimeout = 5 * time.Second
+)
+
+var (
+	// errClosed is returned by Send after Close.
+	errClosed = errors.New("sink closed")
+	// errQueueFull is the dead-letter reason of documents Send could not
+	// queue in time.
+	errQueueFull = errors.New("sink queue full")
+)
+
+// BatchOptions sets how a Batcher groups and retries deliveries.
+type BatchOptions struct {
+	// Size is the most documents written at once.
+	Size int
+	// MaxBytes is the most bytes of documents written at once, or 0 for no
+	// limit. A document larger than MaxBytes is written on its own.
+	MaxBytes int
+	// FlushInterval is the longest a document waits for its batch to fill.
+	// It must be positive.
+	FlushInterval time.Duration
+	// SendTimeout is the longest Send waits for room in the queue before
+	// dead-lettering the document instead, or 0 to wait until its context
+	// is done.
+	SendTimeout time.Duration
+	// MaxRetries is how many times a failed delivery is retried before it is
+	// dead-lettered.
+	MaxRetries int
+	// Backoff is the wait before the first retry, doubling for each one.
+	Backoff time.Duration
+}
+
+// DeadLetters keeps documents a sink gave up on.
+type DeadLetters interface {
+	DeadLetter(ctx context.Context, sink string, failures []Failure) error
+}
+
+// Batcher is a Sink that writes documents to a Writer in batches from a
+// goroutine of its own, retrying failures with exponential backoff and
+// dead-lettering the ones that still fail. While the writer is backed up,
+// documents that cannot be queued within SendTimeout are dead-lettered, so
+// an outage does not hold up the caller.
+type Batcher struct {
+	w      Writer
+	opts   BatchOptions
+	dead   DeadLetters
+	logger *slog.Logger
+
+	docs chan Document
+	// ctx is cancelled when Close gives up waiting, to abandon retries.
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewBatcher starts a Batcher writing to w.
+func NewBatcher(w Writer, opts BatchOptions, dead DeadLetters, logger *slog.Logger) (*Batcher, error) {
+	if opts.Size <= 0 {
+		return nil, fmt.Errorf("%s sink: batch size must be positive, got %d", w.Name(), opts.Size)
+	}
+	if opts.FlushInterval <= 0 {
+		return nil, fmt.Errorf("%s sink: flush interval must be positive, got %v", w.Name(), opts.FlushInterval)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &Batcher{
+		w:      w,
+		opts:   opts,
+		dead:   dead,
+		logger: logger.With("sink", w.Name()),
+		docs:   make(chan Document, opts.Size),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go b.run()
+	return b, nil
+}
+
+func (b *Batcher) Send(ctx context.Context, doc Document) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return errClosed
+	}
+	select {
+	case b.docs <- doc:
+		return nil
+	default:
+	}
+	var timeout <-chan time.Time
+	if b.opts.SendTimeout > 0 {
+		timer := time.NewTimer(b.opts.SendTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case b.docs <- doc:
+		return nil
+	case <-timeout:
+		b.deadLetter([]Failure{{Doc: doc, Err: errQueueFull}})
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close flushes the queued documents and closes the writer. If ctx is done
+// first, retries stop and what is left is dead-lettered.
+func (b *Batcher) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.docs)
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		b.cancel()
+		<-b.done
+	}
+	b.cancel()
+	if err := b.w.Close(); err != nil {
+		return fmt.Errorf("closing %s sink: %w", b.w.Name(), err)
+	}
+	return nil
+}
+
+func (b *Batcher) run() {
+	defer close(b.done)
+	ticker := time.NewTicker(b.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Document, 0, b.opts.Size)
+	size := 0
+	for {
+		select {
+		case doc, ok := <-b.docs:
+			if !ok {
+				b.flush(batch)
+				return
+			}
+			n := docSize(doc)
+			if b.opts.MaxBytes > 0 && len(batch) > 0 && size+n > b.opts.MaxBytes {
+				b.flush(batch)
+				batch, size = batch[:0], 0
+			}
+			batch = append(batch, doc)
+			size += n
+			if len(batch) < b.opts.Size && (b.opts.MaxBytes <= 0 || size < b.opts.MaxBytes) {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		b.flush(batch)
+		batch, size = batch[:0], 0
+	}
+}
+
+// docSize estimates the encoded size of doc.
+func docSize(doc Document) int {
+	n := 512 + len(doc.ID) + len(doc.URL) + len(doc.Domain) + len(doc.Title) + len(doc.Text) + len(doc.HTMLLink) + len(doc.TextLink)
+	for _, l := range doc.Links {
+		n += len(l) + 3
+	}
+	return n
+}
+
+// flush writes batch, retrying what fails, and dead-letters what cannot be
+// delivered.
+func (b *Batcher) flush(batch []Document) {
+	if len(batch) == 0 {
+		return
+	}
+	pending := batch
+	var rejected []Failure
+	for attempt := 0; ; attempt++ {
+		failures, err := b.w.Write(b.ctx, pending)
+		if err != nil {
+			failures = make([]Failure, len(pending))
+			for i, doc := range pending {
+				failures[i] = Failure{Doc: doc, Err: err, Retryable: true}
+			}
+		}
+		metrics.SinkDocuments.WithLabelValues(b.w.Name(), "delivered").Add(float64(len(pending) - len(failures)))
+
+		var retry []Failure
+		for _, f := range failures {
+			if f.Retryable {
+				retry = append(retry, f)
+			} else {
+				rejected = append(rejected, f)
+			}
+		}
+		if len(retry) == 0 {
+			break
+		}
+		if attempt == b.opts.MaxRetries || b.ctx.Err() != nil {
+			rejected = append(rejected, retry...)
+			break
+		}
+
+		b.logger.Warn("sink write failed, retrying", "failed", len(retry), "attempt", attempt+1, "error", retry[0].Err)
+		metrics.SinkDocuments.WithLabelValues(b.w.Name(), "retried").Add(float64(len(retry)))
+		pending = make([]Document, len(retry))
+		for i, f := range retry {
+			pending[i] = f.Doc
+		}
+		select {
+		case <-time.After(backoff(b.opts.Backoff, attempt)):
+		case <-b.ctx.Done():
+		}
+	}
+
+	if len(rejected) > 0 {
+		b.deadLetter(rejected)
+	}
+}
+
+func (b *Batcher) deadLetter(failures []Failure) {
+	b.logger.Error("dead-lettering pages the sink could not take", "pages", len(failures), "error", failures[0].Err)
+	metrics.SinkDocuments.WithLabelValues(b.w.Name(), "dead_lettered").Add(float64(len(failures)))
+	ctx, cancel := context.WithTimeout(context.Background(), deadLetterTimeout)
+	defer cancel()
+	if err := b.dead.DeadLetter(ctx, b.w.Name(), failures); err != nil {
+		b.logger.Error("failed to dead-letter pages", "pages", len(failures), "error", err)
+	}
+}
+
+// backoff returns the wait before retry attempt+1: base doubled attempt
+// times, capped at maxBackoff.
+func backoff(base time.Duration, attempt int) time.Duration {
+	d := base
+	for range attempt {
+		d *= 2
+		if d >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return min(d, maxBackoff)
+}
@@ -0,0 +1,291 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeWriter records the batches written to it. fail decides how each
+// attempt fails.
+type fakeWriter struct {
+	mu      sync.Mutex
+	batches [][]string
+	fail    func(attempt int, docs []Document) ([]Failure, error)
+}
+
+func (w *fakeWriter) Name() string { return "fake" }
+
+func (w *fakeWriter) Close() error { return nil }
+
+func (w *fakeWriter) Write(ctx context.Context, docs []Document) ([]Failure, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ids := make([]string, len(docs))
+	for i, d := range docs {
+		ids[i] = d.ID
+	}
+	w.batches = append(w.batches, ids)
+	if w.fail == nil {
+		return nil, nil
+	}
+	return w.fail(len(w.batches)-1, docs)
+}
+
+type fakeDeadLetters struct {
+	mu   sync.Mutex
+	docs []string
+}
+
+func (d *fakeDeadLetters) DeadLetter(ctx context.Context, sink string, failures []Failure) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, f := range failures {
+		d.docs = append(d.docs, f.Doc.ID)
+	}
+	return nil
+}
+
+func newTestBatcher(t *testing.T, w Writer, opts BatchOptions, dead DeadLetters) *Batcher {
+	t.Helper()
+	b, err := NewBatcher(w, opts, dead, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func docs(n int) []Document {
+	out := make([]Document, n)
+	for i := range out {
+		out[i] = Document{ID: fmt.Sprint(i)}
+	}
+	return out
+}
+
+func TestBatcher(t *testing.T) {
+	t.Parallel()
+	errDown := errors.New("down")
+
+	tests := []struct {
+		name        string
+		docs        int
+		fail        func(attempt int, docs []Document) ([]Failure, error)
+		wantBatches [][]string
+		wantDead    []string
+	}{
+		{
+			name:        "batches by size",
+			docs:        5,
+			wantBatches: [][]string{{"0", "1"}, {"2", "3"}, {"4"}},
+		},
+		{
+			name: "retries a failed batch",
+			docs: 2,
+			fail: func(attempt int, docs []Document) ([]Failure, error) {
+				if attempt == 0 {
+					return nil, errDown
+				}
+				return nil, nil
+			},
+			wantBatches: [][]string{{"0", "1"}, {"0", "1"}},
+		},
+		{
+			name: "retries only retryable failures",
+			docs: 2,
+			fail: func(attempt int, docs []Document) ([]Failure, error) {
+				if attempt > 0 {
+					return nil, nil
+				}
+				return []Failure{
+					{Doc: docs[0], Err: errDown, Retryable: true},
+					{Doc: docs[1], Err: errors.New("mapping"), Retryable: false},
+				}, nil
+			},
+			wantBatches: [][]string{{"0", "1"}, {"0"}},
+			wantDead:    []string{"1"},
+		},
+		{
+			name: "dead-letters after max retries",
+			docs: 1,
+			fail: func(attempt int, docs []Document) ([]Failure, error) {
+				return nil, errDown
+			},
+			wantBatches: [][]string{{"0"}, {"0"}, {"0"}, {"0"}},
+			wantDead:    []string{"0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			w := &fakeWriter{fail: tt.fail}
+			dead := &fakeDeadLetters{}
+			b := newTestBatcher(t, w, BatchOptions{Size: 2, FlushInterval: time.Hour, MaxRetries: 3, Backoff: time.Millisecond}, dead)
+			ctx := context.Background()
+			for _, doc := range docs(tt.docs) {
+				if err := b.Send(ctx, doc); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := b.Close(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.EqualFunc(w.batches, tt.wantBatches, slices.Equal) {
+				t.Errorf("batches = %v, want %v", w.batches, tt.wantBatches)
+			}
+			if !slices.Equal(dead.docs, tt.wantDead) {
+				t.Errorf("dead letters = %v, want %v", dead.docs, tt.wantDead)
+			}
+			if err := b.Send(ctx, Document{}); !errors.Is(err, errClosed) {
+				t.Errorf("Send after Close = %v, want %v", err, errClosed)
+			}
+		})
+	}
+}
+
+func TestBatcherMaxBytes(t *testing.T) {
+	t.Parallel()
+	w := &fakeWriter{}
+	// Each document is about 1.5KiB, so two fit under the cap.
+	b := newTestBatcher(t, w, BatchOptions{Size: 100, MaxBytes: 4 << 10, FlushInterval: time.Hour}, &fakeDeadLetters{})
+	ctx := context.Background()
+	for _, doc := range docs(5) {
+		doc.Text = strings.Repeat("x", 1<<10)
+		if err := b.Send(ctx, doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"0", "1"}, {"2", "3"}, {"4"}}
+	if !slices.EqualFunc(w.batches, want, slices.Equal) {
+		t.Errorf("batches = %v, want %v", w.batches, want)
+	}
+}
+
+func TestBatcherSendTimeout(t *testing.T) {
+	t.Parallel()
+	release := make(chan struct{})
+	w := &fakeWriter{fail: func(int, []Document) ([]Failure, error) {
+		<-release
+		return nil, nil
+	}}
+	dead := &fakeDeadLetters{}
+	b := newTestBatcher(t, w, BatchOptions{Size: 1, FlushInterval: time.Hour, SendTimeout: time.Millisecond}, dead)
+
+	// The writer holds the first document and the queue the second; the
+	// third does not fit and is dead-lettered rather than blocking.
+	ctx := context.Background()
+	for _, doc := range docs(3) {
+		if err := b.Send(ctx, doc); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !slices.Equal(dead.docs, []string{"2"}) {
+		t.Errorf("dead letters = %v, want [2]", dead.docs)
+	}
+	close(release)
+	if err := b.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewBatcherRejectsFlushInterval(t *testing.T) {
+	t.Parallel()
+	if _, err := NewBatcher(&fakeWriter{}, BatchOptions{Size: 1}, &fakeDeadLetters{}, slog.New(slog.DiscardHandler)); err == nil {
+		t.Error("NewBatcher with no flush interval succeeded, want an error")
+	}
+}
+
+func TestBatcherFlushInterval(t *testing.T) {
+	t.Parallel()
+	w := &fakeWriter{}
+	b := newTestBatcher(t, w, BatchOptions{Size: 100, FlushInterval: 10 * time.Millisecond}, &fakeDeadLetters{})
+	defer b.Close(context.Background())
+
+	if err := b.Send(context.Background(), Document{ID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w.mu.Lock()
+		n := len(w.batches)
+		w.mu.Unlock()
+		if n == 1 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("batch was not flushed before it filled")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBatcherCloseGivesUp(t *testing.T) {
+	t.Parallel()
+	w := &fakeWriter{fail: func(int, []Document) ([]Failure, error) { return nil, errors.New("down") }}
+	dead := &fakeDeadLetters{}
+	b := newTestBatcher(t, w, BatchOptions{Size: 1, FlushInterval: time.Hour, MaxRetries: 100, Backoff: time.Hour}, dead)
+	if err := b.Send(context.Background(), Document{ID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := b.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(dead.docs, []string{"a"}) {
+		t.Errorf("dead letters = %v, want [a]", dead.docs)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+	for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if got := backoff(time.Second, attempt); got != want {
+			t.Errorf("backoff(1s, %d) = %v, want %v", attempt, got, want)
+		}
+	}
+	if got := backoff(time.Second, 100); got != maxBackoff {
+		t.Errorf("backoff(1s, 100) = %v, want %v", got, maxBackoff)
+	}
+}
+
+func TestRedisDeadLetters(t *testing.T) {
+	t.Parallel()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	ctx := context.Background()
+	failures := []Failure{{Doc: Document{ID: "a", URL: "https://example.com/"}, Err: errors.New("mapping")}}
+	if err := NewRedisDeadLetters(rdb).DeadLetter(ctx, "elasticsearch", failures); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := rdb.XRange(ctx, DeadLetterStream, "-", "+").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("entries = %d, want 1", len(entries))
+	}
+	v := entries[0].Values
+	if v["sink"] != "elasticsearch" || v["error"] != "mapping" || v["id"] != "a" || v["url"] != "https://example.com/" {
+		t.Errorf("entry = %v", v)
+	}
+	if _, ok := v["doc"]; ok {
+		t.Error("entry holds the whole document")
+	}
+}
@@ -0,0 +1,46 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// DeadLetterStream holds the pages sinks gave up on, one entry per page
+	// with fields sink, error, id and url; the page can be sent again by
+	// reparsing it.
+	DeadLetterStream = "stream:sink:dlq"
+	// deadLetterMaxLen caps the stream, dropping the oldest entries.
+	deadLetterMaxLen int64 = 100000
+)
+
+// RedisDeadLetters keeps dead letters in DeadLetterStream.
+type RedisDeadLetters struct {
+	rdb *redis.Client
+}
+
+func NewRedisDeadLetters(rdb *redis.Client) *RedisDeadLetters {
+	return &RedisDeadLetters{rdb: rdb}
+}
+
+func (d *RedisDeadLetters) DeadLetter(ctx context.Context, sink string, failures []Failure) error {
+	pipe := d.rdb.Pipeline()
+	for _, f := range failures {
+		reason := ""
+		if f.Err != nil {
+			reason = f.Err.Error()
+		}
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: DeadLetterStream,
+			MaxLen: deadLetterMaxLen,
+			Approx: true,
+			Values: []any{"sink", sink, "error", reason, "id", f.Doc.ID, "url", f.Doc.URL},
+		})
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("writing dead letters: %w", err)
+	}
+	return nil
+}
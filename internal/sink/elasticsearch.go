@@ -0,0 +1,117 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+)
+
+// maxErrorBody caps the response body read for an error message.
+const maxErrorBody = 4 << 10
+
+// ElasticsearchWriter indexes documents with the bulk API of Elasticsearch
+// or OpenSearch, using each page's id as the document _id so reparses
+// replace what was indexed before.
+type ElasticsearchWriter struct {
+	cfg    config.ElasticsearchSinkConfig
+	url    string
+	client *http.Client
+}
+
+func NewElasticsearchWriter(cfg config.ElasticsearchSinkConfig, client *http.Client) *ElasticsearchWriter {
+	return &ElasticsearchWriter{cfg: cfg, url: strings.TrimSuffix(cfg.URL, "/") + "/_bulk", client: client}
+}
+
+func (w *ElasticsearchWriter) Name() string { return "elasticsearch" }
+
+func (w *ElasticsearchWriter) Close() error { return nil }
+
+type bulkAction struct {
+	Index struct {
+		Index string `json:"_index"`
+		ID    string `json:"_id"`
+	} `json:"index"`
+}
+
+// bulkResponse is the part of a bulk response that reports failures. Items
+// are in request order.
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  *struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+func (w *ElasticsearchWriter) Write(ctx context.Context, docs []Document) ([]Failure, error) {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, doc := range docs {
+		var action bulkAction
+		action.Index.Index = w.cfg.Index
+		action.Index.ID = doc.ID
+		if err := enc.Encode(action); err != nil {
+			return nil, fmt.Errorf("encoding bulk action: %w", err)
+		}
+		if err := enc.Encode(doc); err != nil {
+			return nil, fmt.Errorf("encoding document: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, &body)
+	if err != nil {
+		return nil, fmt.Errorf("creating bulk request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	switch {
+	case w.cfg.APIKey != "":
+		req.Header.Set("Authorization", "ApiKey "+w.cfg.APIKey)
+	case w.cfg.Username != "":
+		req.SetBasicAuth(w.cfg.Username, w.cfg.Password)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("bulk request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return failAll(docs, resp.StatusCode, fmt.Errorf("bulk request: %s: %s", resp.Status, bytes.TrimSpace(msg)))
+	}
+
+	var result bulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding bulk response: %w", err)
+	}
+	if !result.Errors {
+		return nil, nil
+	}
+	if len(result.Items) != len(docs) {
+		return nil, fmt.Errorf("bulk response has %d items for %d documents", len(result.Items), len(docs))
+	}
+	var failures []Failure
+	for i, item := range result.Items {
+		for _, r := range item {
+			if r.Error == nil {
+				continue
+			}
+			failures = append(failures, Failure{
+				Doc: docs[i],
+				Err: fmt.Errorf("%s: %s", r.Error.Type, r.Error.Reason),
+				// Rejected for load or a failing node; the rest, such as
+				// mapping errors, would fail again.
+				Retryable: r.Status == http.StatusTooManyRequests || r.Status >= 500,
+			})
+		}
+	}
+	return failures, nil
+}
@@ -0,0 +1,121 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+)
+
+// bulkAPI stands in for the bulk API. It answers each index action with the
+// status given for its _id, 201 by default.
+func bulkAPI(t *testing.T, statuses map[string]int, got *[]Document) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/_bulk" {
+			http.NotFound(w, r)
+			return
+		}
+		if user, pass, ok := r.BasicAuth(); !ok || user != "elastic" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		var items []string
+		failed := false
+		scanner := bufio.NewScanner(r.Body)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			var action bulkAction
+			if err := json.Unmarshal(scanner.Bytes(), &action); err != nil || action.Index.Index != "pages" {
+				t.Errorf("bad action line %s", scanner.Bytes())
+			}
+			if !scanner.Scan() {
+				t.Error("action without a document")
+				break
+			}
+			var doc Document
+			if err := json.Unmarshal(scanner.Bytes(), &doc); err != nil {
+				t.Errorf("bad document line: %v", err)
+			}
+			*got = append(*got, doc)
+
+			status := statuses[action.Index.ID]
+			if status == 0 {
+				status = http.StatusCreated
+			}
+			item := fmt.Sprintf(`{"index":{"_id":%q,"status":%d}}`, action.Index.ID, status)
+			if status >= 300 {
+				failed = true
+				item = fmt.Sprintf(`{"index":{"_id":%q,"status":%d,"error":{"type":"x_exception","reason":"status %d"}}}`, action.Index.ID, status, status)
+			}
+			items = append(items, item)
+		}
+		fmt.Fprintf(w, `{"took":1,"errors":%t,"items":[%s]}`, failed, strings.Join(items, ","))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestElasticsearchWriter(t *testing.T) {
+	t.Parallel()
+	var got []Document
+	srv := bulkAPI(t, map[string]int{"1": http.StatusTooManyRequests, "2": http.StatusBadRequest}, &got)
+	w := NewElasticsearchWriter(config.ElasticsearchSinkConfig{
+		URL: srv.URL + "/", Index: "pages", Username: "elastic", Password: "secret",
+	}, srv.Client())
+
+	failures, err := w.Write(context.Background(), []Document{
+		{ID: "0", URL: "https://example.com/", Text: "hello"},
+		{ID: "1"},
+		{ID: "2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0].URL != "https://example.com/" || got[0].Text != "hello" {
+		t.Errorf("indexed %+v", got)
+	}
+	if len(failures) != 2 {
+		t.Fatalf("failures = %+v, want 2", failures)
+	}
+	if f := failures[0]; f.Doc.ID != "1" || !f.Retryable {
+		t.Errorf("failure %+v, want 1 retryable", f)
+	}
+	if f := failures[1]; f.Doc.ID != "2" || f.Retryable || f.Err.Error() != "x_exception: status 400" {
+		t.Errorf("failure %+v, want 2 rejected", f)
+	}
+}
+
+func TestElasticsearchWriterRequestError(t *testing.T) {
+	t.Parallel()
+	var got []Document
+	srv := bulkAPI(t, nil, &got)
+	w := NewElasticsearchWriter(config.ElasticsearchSinkConfig{URL: srv.URL, Index: "pages"}, srv.Client())
+
+	// Client errors would fail again, so every document is rejected for good.
+	failures, err := w.Write(context.Background(), []Document{{ID: "0"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 1 || failures[0].Retryable || !strings.Contains(failures[0].Err.Error(), "401") {
+		t.Errorf("Write without credentials = %+v, want a rejected 401", failures)
+	}
+
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+	w = NewElasticsearchWriter(config.ElasticsearchSinkConfig{URL: unavailable.URL, Index: "pages"}, unavailable.Client())
+	if _, err := w.Write(context.Background(), []Document{{ID: "0"}}); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Write to an unavailable cluster = %v, want a 503 error", err)
+	}
+}
@@ -0,0 +1,128 @@
+package sink
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+)
+
+// partialSuffix marks the file being written; it is renamed without it
+// once complete, so readers can skip files still growing.
+const partialSuffix = ".partial"
+
+// JSONLWriter appends documents, one JSON object per line, to gzipped
+// files under a directory, starting a new file once the current one is
+// big or old enough. A file that fails to complete is left with its
+// partial suffix and its batch retried into the next.
+type JSONLWriter struct {
+	cfg config.JSONLSinkConfig
+	now func() time.Time
+
+	file    *os.File
+	counter *countingWriter
+	gz      *gzip.Writer
+	opened  time.Time
+}
+
+func NewJSONLWriter(cfg config.JSONLSinkConfig) (*JSONLWriter, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating jsonl sink dir: %w", err)
+	}
+	return &JSONLWriter{cfg: cfg, now: time.Now}, nil
+}
+
+func (w *JSONLWriter) Name() string { return "jsonl" }
+
+func (w *JSONLWriter) Write(ctx context.Context, docs []Document) ([]Failure, error) {
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return nil, err
+		}
+	}
+	enc := json.NewEncoder(w.gz)
+	for _, doc := range docs {
+		if err := enc.Encode(doc); err != nil {
+			return nil, w.abandon(err)
+		}
+	}
+	// Flush so the size check sees the batch.
+	if err := w.gz.Flush(); err != nil {
+		return nil, w.abandon(err)
+	}
+	maxAge := time.Duration(w.cfg.MaxFileAgeSecs) * time.Second
+	if w.counter.n >= w.cfg.MaxFileBytes || w.now().Sub(w.opened) >= maxAge {
+		return nil, w.finish()
+	}
+	return nil, nil
+}
+
+// Close completes the current file.
+func (w *JSONLWriter) Close() error {
+	if w.file == nil {
+		return nil
+	}
+	return w.finish()
+}
+
+func (w *JSONLWriter) open() error {
+	w.opened = w.now()
+	name := fmt.Sprintf("%s-%s-%s.jsonl.gz", w.cfg.Prefix, w.opened.UTC().Format("20060102T150405Z"), uuid.NewString()[:8])
+	f, err := os.Create(filepath.Join(w.cfg.Dir, name+partialSuffix))
+	if err != nil {
+		return fmt.Errorf("creating jsonl file: %w", err)
+	}
+	w.file = f
+	w.counter = &countingWriter{w: f}
+	w.gz = gzip.NewWriter(w.counter)
+	return nil
+}
+
+// finish closes the current file and drops its partial suffix.
+func (w *JSONLWriter) finish() error {
+	f := w.file
+	w.file = nil
+	err := w.gz.Close()
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("closing %s: %w", f.Name(), err)
+	}
+	partial := f.Name()
+	if err := os.Rename(partial, partial[:len(partial)-len(partialSuffix)]); err != nil {
+		return fmt.Errorf("completing %s: %w", partial, err)
+	}
+	return nil
+}
+
+// abandon closes a file whose write failed, leaving it partial, so the
+// batch is retried into a new one.
+func (w *JSONLWriter) abandon(err error) error {
+	name := w.file.Name()
+	w.file.Close()
+	w.file = nil
+	return fmt.Errorf("writing %s: %w", name, err)
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,118 @@
+package sink
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+)
+
+// readJSONL returns the ids in the completed files under dir, in file name
+// order, and the number of files still partial.
+func readJSONL(t *testing.T, dir string) (files [][]string, partial int) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), partialSuffix) {
+			partial++
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		dec := json.NewDecoder(gz)
+		for dec.More() {
+			var doc Document
+			if err := dec.Decode(&doc); err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, doc.ID)
+		}
+		f.Close()
+		files = append(files, ids)
+	}
+	return files, partial
+}
+
+func TestJSONLWriter(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	w, err := NewJSONLWriter(config.JSONLSinkConfig{Dir: dir, Prefix: "pages", MaxFileBytes: 1 << 20, MaxFileAgeSecs: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	w.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	write := func(ids ...string) {
+		t.Helper()
+		batch := make([]Document, len(ids))
+		for i, id := range ids {
+			batch[i] = Document{ID: id}
+		}
+		if failures, err := w.Write(ctx, batch); err != nil || failures != nil {
+			t.Fatalf("Write = %v, %v", failures, err)
+		}
+	}
+
+	write("a", "b")
+	if files, partial := readJSONL(t, dir); len(files) != 0 || partial != 1 {
+		t.Fatalf("files = %v, partial = %d; want one partial file", files, partial)
+	}
+	// The file is old enough after this batch, so the next starts another.
+	now = now.Add(time.Minute)
+	write("c")
+	write("d")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, partial := readJSONL(t, dir)
+	if partial != 0 || len(files) != 2 {
+		t.Fatalf("files = %v, partial = %d; want 2 complete files", files, partial)
+	}
+	if !slices.Equal(files[0], []string{"a", "b", "c"}) || !slices.Equal(files[1], []string{"d"}) {
+		t.Errorf("files = %v, want [[a b c] [d]]", files)
+	}
+	names, _ := filepath.Glob(filepath.Join(dir, "pages-20260301T120000Z-*.jsonl.gz"))
+	if len(names) != 1 {
+		t.Errorf("want one file named after its start time, got %v", names)
+	}
+}
+
+func TestJSONLWriterRollsBySize(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	w, err := NewJSONLWriter(config.JSONLSinkConfig{Dir: dir, Prefix: "pages", MaxFileBytes: 1, MaxFileAgeSecs: 3600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if _, err := w.Write(context.Background(), []Document{{ID: id}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if files, partial := readJSONL(t, dir); len(files) != 3 || partial != 0 {
+		t.Errorf("files = %v, partial = %d; want one complete file per batch", files, partial)
+	}
+}
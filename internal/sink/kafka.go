@@ -0,0 +1,102 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+)
+
+// Content types of the Kafka REST Proxy v2 API.
+const (
+	contentTypeKafkaJSON = "application/vnd.kafka.json.v2+json"
+	contentTypeKafka     = "application/vnd.kafka.v2+json"
+)
+
+// kafkaRetriable is the error_code the REST Proxy gives records whose
+// produce may succeed if retried.
+const kafkaRetriable = 2
+
+// KafkaWriter produces documents to a Kafka topic through a Kafka REST
+// Proxy, keyed by page id so a page's versions land in one partition.
+type KafkaWriter struct {
+	url    string
+	client *http.Client
+}
+
+func NewKafkaWriter(cfg config.KafkaSinkConfig, client *http.Client) *KafkaWriter {
+	return &KafkaWriter{
+		url:    strings.TrimSuffix(cfg.RESTURL, "/") + "/topics/" + url.PathEscape(cfg.Topic),
+		client: client,
+	}
+}
+
+func (w *KafkaWriter) Name() string { return "kafka" }
+
+func (w *KafkaWriter) Close() error { return nil }
+
+type kafkaRecord struct {
+	Key   string   `json:"key"`
+	Value Document `json:"value"`
+}
+
+// kafkaResponse reports each record's outcome, in request order.
+type kafkaResponse struct {
+	Offsets []struct {
+		ErrorCode *int   `json:"error_code"`
+		Error     string `json:"error"`
+	} `json:"offsets"`
+}
+
+func (w *KafkaWriter) Write(ctx context.Context, docs []Document) ([]Failure, error) {
+	records := make([]kafkaRecord, len(docs))
+	for i, doc := range docs {
+		records[i] = kafkaRecord{Key: doc.ID, Value: doc}
+	}
+	body, err := json.Marshal(map[string]any{"records": records})
+	if err != nil {
+		return nil, fmt.Errorf("encoding records: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating produce request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentTypeKafkaJSON)
+	req.Header.Set("Accept", contentTypeKafka)
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("produce request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return failAll(docs, resp.StatusCode, fmt.Errorf("produce request: %s: %s", resp.Status, bytes.TrimSpace(msg)))
+	}
+
+	var result kafkaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding produce response: %w", err)
+	}
+	if len(result.Offsets) != len(docs) {
+		return nil, fmt.Errorf("produce response has %d offsets for %d records", len(result.Offsets), len(docs))
+	}
+	var failures []Failure
+	for i, o := range result.Offsets {
+		if o.ErrorCode == nil {
+			continue
+		}
+		failures = append(failures, Failure{
+			Doc:       docs[i],
+			Err:       fmt.Errorf("kafka error %d: %s", *o.ErrorCode, o.Error),
+			Retryable: *o.ErrorCode == kafkaRetriable,
+		})
+	}
+	return failures, nil
+}
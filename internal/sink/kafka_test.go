@@ -0,0 +1,53 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+)
+
+func TestKafkaWriter(t *testing.T) {
+	t.Parallel()
+	var got []kafkaRecord
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/topics/pages" || r.Header.Get("Content-Type") != contentTypeKafkaJSON {
+			t.Errorf("request to %s with Content-Type %q", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		var body struct {
+			Records []kafkaRecord `json:"records"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		got = body.Records
+		w.Header().Set("Content-Type", contentTypeKafka)
+		w.Write([]byte(`{"offsets":[
+			{"partition":0,"offset":7,"error_code":null,"error":null},
+			{"partition":null,"offset":null,"error_code":2,"error":"leader not available"},
+			{"partition":null,"offset":null,"error_code":1,"error":"record too large"}
+		]}`))
+	}))
+	defer srv.Close()
+
+	w := NewKafkaWriter(config.KafkaSinkConfig{RESTURL: srv.URL, Topic: "pages"}, srv.Client())
+	failures, err := w.Write(context.Background(), []Document{{ID: "0", URL: "https://example.com/"}, {ID: "1"}, {ID: "2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0].Key != "0" || got[0].Value.URL != "https://example.com/" {
+		t.Errorf("produced %+v", got)
+	}
+	if len(failures) != 2 {
+		t.Fatalf("failures = %+v, want 2", failures)
+	}
+	if f := failures[0]; f.Doc.ID != "1" || !f.Retryable {
+		t.Errorf("failure %+v, want 1 retryable", f)
+	}
+	if f := failures[1]; f.Doc.ID != "2" || f.Retryable {
+		t.Errorf("failure %+v, want 2 rejected", f)
+	}
+}
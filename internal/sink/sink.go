@@ -0,0 +1,135 @@
+// Package sink sends parsed pages to outputs besides the object store and
+// the urls table: search indexes, files and message topics.
+package sink
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+)
+
+// Document is a parsed page as sinks receive it.
+type Document struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Domain      string    `json:"domain"`
+	Depth       int       `json:"depth"`
+	Title       string    `json:"title,omitempty"`
+	Language    string    `json:"language,omitempty"`
+	ContentHash string    `json:"content_hash"`
+	Text        string    `json:"text"`
+	Links       []string  `json:"links"`
+	HTMLLink    string    `json:"html_link"`
+	TextLink    string    `json:"text_link"`
+	ParsedAt    time.Time `json:"parsed_at"`
+}
+
+// Sink takes parsed pages for delivery.
+type Sink interface {
+	// Send queues doc for delivery, waiting a bounded time while the sink
+	// is backed up. Delivery failures are handled by the sink, not returned.
+	Send(ctx context.Context, doc Document) error
+	// Close delivers what is queued, giving up when ctx is done.
+	Close(ctx context.Context) error
+}
+
+// Writer delivers batches of documents to one destination.
+type Writer interface {
+	// Name identifies the destination in logs, metrics and dead letters.
+	Name() string
+	// Write delivers docs and returns those it could not deliver. An error
+	// means none were delivered.
+	Write(ctx context.Context, docs []Document) ([]Failure, error)
+	Close() error
+}
+
+// Failure is a document a Writer could not deliver. Documents that are not
+// Retryable would fail again and are dead-lettered at once.
+type Failure struct {
+	Doc       Document
+	Err       error
+	Retryable bool
+}
+
+// httpTimeout bounds each request to an HTTP sink.
+const httpTimeout = 30 * time.Second
+
+// failAll reports the outcome of a request for docs that failed with an
+// HTTP status: a client error the sink would return again fails every
+// document for good, anything else is err, retried as a whole.
+func failAll(docs []Document, status int, err error) ([]Failure, error) {
+	if status < 400 || status >= 500 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests {
+		return nil, err
+	}
+	failures := make([]Failure, len(docs))
+	for i, doc := range docs {
+		failures[i] = Failure{Doc: doc, Err: err}
+	}
+	return failures, nil
+}
+
+// Open returns a sink for every writer cfg configures, each batching and
+// dead-lettering on its own into rdb, or nil if none is configured.
+func Open(cfg config.SinksConfig, rdb *redis.Client, logger *slog.Logger) (Sink, error) {
+	client := &http.Client{Timeout: httpTimeout}
+	var writers []Writer
+	if cfg.Elasticsearch.URL != "" {
+		writers = append(writers, NewElasticsearchWriter(cfg.Elasticsearch, client))
+	}
+	if cfg.JSONL.Dir != "" {
+		w, err := NewJSONLWriter(cfg.JSONL)
+		if err != nil {
+			return nil, err
+		}
+		writers = append(writers, w)
+	}
+	if cfg.Kafka.RESTURL != "" {
+		writers = append(writers, NewKafkaWriter(cfg.Kafka, client))
+	}
+	if len(writers) == 0 {
+		return nil, nil
+	}
+
+	dead := NewRedisDeadLetters(rdb)
+	sinks := make(Multi, len(writers))
+	for i, w := range writers {
+		b, err := NewBatcher(w, BatchOptions{
+			Size:          cfg.BatchSize,
+			MaxBytes:      cfg.BatchMaxBytes,
+			FlushInterval: time.Duration(cfg.FlushIntervalMs) * time.Millisecond,
+			SendTimeout:   time.Duration(cfg.SendTimeoutMs) * time.Millisecond,
+			MaxRetries:    cfg.MaxRetries,
+			Backoff:       time.Duration(cfg.RetryBackoffMs) * time.Millisecond,
+		}, dead, logger)
+		if err != nil {
+			return nil, errors.Join(err, sinks[:i].Close(context.Background()))
+		}
+		sinks[i] = b
+		logger.Info("sending parsed pages to sink", "sink", w.Name())
+	}
+	return sinks, nil
+}
+
+// Multi sends every document to each of its sinks.
+type Multi []Sink
+
+func (m Multi) Send(ctx context.Context, doc Document) error {
+	var errs []error
+	for _, s := range m {
+		errs = append(errs, s.Send(ctx, doc))
+	}
+	return errors.Join(errs...)
+}
+
+func (m Multi) Close(ctx context.Context) error {
+	var errs []error
+	for _, s := range m {
+		errs = append(errs, s.Close(ctx))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// BlobKey returns the content-addressed key for a body with the given sha256
+// hex hash. Keys are fanned out by the hash's first byte.
+func BlobKey(hash string) string {
+	if len(hash) < 2 {
+		return "sha256/" + hash
+	}
+	return "sha256/" + hash[:2] + "/" + hash
+}
+
+// PutBlob stores r in bucket under the BlobKey of its sha256 hash and returns
+// the hash. Identical bodies share one blob: if it is already stored nothing
+// is uploaded. r is spooled to a temporary file to learn the hash before
+// uploading, so memory use stays bounded whatever its size.
+func PutBlob(ctx context.Context, store ObjectStore, bucket string, r io.Reader, opts PutOptions) (string, error) {
+	spool, err := os.CreateTemp("", "nimbus-blob-*")
+	if err != nil {
+		return "", fmt.Errorf("creating blob spool: %w", err)
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(spool, h), r)
+	if err != nil {
+		return "", fmt.Errorf("spooling blob: %w", err)
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+	key := BlobKey(hash)
+
+	_, err = store.Stat(ctx, bucket, key)
+	if err == nil {
+		return hash, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return "", err
+	}
+
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("rewinding blob spool: %w", err)
+	}
+	if err := store.Put(ctx, bucket, key, spool, size, opts); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+)
+
+// countingStore counts the Puts that reach the wrapped store.
+type countingStore struct {
+	ObjectStore
+	puts int
+}
+
+func (s *countingStore) Put(ctx context.Context, bucket, key string, r io.Reader, size int64, opts PutOptions) error {
+	s.puts++
+	return s.ObjectStore.Put(ctx, bucket, key, r, size, opts)
+}
+
+func TestPutBlob(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := &countingStore{ObjectStore: NewMemoryStore()}
+
+	hash, err := PutBlob(ctx, store, "html", strings.NewReader("<html>same</html>"), PutOptions{ContentType: "text/html"})
+	if err != nil {
+		t.Fatalf("PutBlob: %v", err)
+	}
+	sum := sha256.Sum256([]byte("<html>same</html>"))
+	if want := hex.EncodeToString(sum[:]); hash != want {
+		t.Errorf("hash = %s, want %s", hash, want)
+	}
+	got, err := ReadObject(ctx, store, "html", BlobKey(hash))
+	if err != nil {
+		t.Fatalf("ReadObject: %v", err)
+	}
+	if string(got) != "<html>same</html>" {
+		t.Errorf("blob = %q", got)
+	}
+
+	again, err := PutBlob(ctx, store, "html", strings.NewReader("<html>same</html>"), PutOptions{ContentType: "text/html"})
+	if err != nil || again != hash {
+		t.Fatalf("second PutBlob = %s, %v; want %s", again, err, hash)
+	}
+	if store.puts != 1 {
+		t.Errorf("puts = %d, want 1 for identical bodies", store.puts)
+	}
+
+	other, err := PutBlob(ctx, store, "html", strings.NewReader("<html>other</html>"), PutOptions{})
+	if err != nil || other == hash {
+		t.Fatalf("PutBlob(other) = %s, %v; want a new hash", other, err)
+	}
+	if store.puts != 2 {
+		t.Errorf("puts = %d, want 2", store.puts)
+	}
+}
+
+func TestBlobKey(t *testing.T) {
+	t.Parallel()
+	hash := strings.Repeat("ab", 32)
+	if got, want := BlobKey(hash), "sha256/ab/"+hash; got != want {
+		t.Errorf("BlobKey = %q, want %q", got, want)
+	}
+}
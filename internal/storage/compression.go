@@ -0,0 +1,283 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+)
+
+// Compression is the codec objects are stored with. It is recorded on each
+// object as its Content-Encoding and in the compressionMetaKey metadata, so
+// readers decode every object by what it says, whatever the current setting.
+type Compression string
+
+const (
+	CompressionNone Compression = "none"
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+// Metadata keys recording how an object was compressed. S3 stores them as
+// X-Amz-Meta-Compression and X-Amz-Meta-Compression-Level.
+const (
+	compressionMetaKey      = "Compression"
+	compressionLevelMetaKey = "Compression-Level"
+)
+
+// ParseCompression validates a configured compression name. Empty means none.
+func ParseCompression(s string) (Compression, error) {
+	switch c := Compression(strings.ToLower(s)); c {
+	case "", CompressionNone:
+		return CompressionNone, nil
+	case CompressionGzip, CompressionZstd:
+		return c, nil
+	default:
+		return "", fmt.Errorf("unknown storage compression %q", s)
+	}
+}
+
+// Codec compresses objects on write and decompresses them on read.
+type Codec struct {
+	Compression Compression
+	// Level is the codec's compression level: 1-9 for gzip, 1-22 for zstd
+	// (mapped onto the encoder's speed levels). 0 selects the default.
+	Level int
+}
+
+// NewCodec returns the codec cfg configures for new objects.
+func NewCodec(cfg config.StorageConfig) (Codec, error) {
+	compression, err := ParseCompression(cfg.Compression)
+	if err != nil {
+		return Codec{}, err
+	}
+	return Codec{Compression: compression, Level: cfg.CompressionLevel}, nil
+}
+
+// compress returns a writer that compresses into w with the codec's
+// settings. Closing it flushes the compressed stream but does not close w.
+func (c Codec) compress(w io.Writer) (io.WriteCloser, error) {
+	switch c.Compression {
+	case CompressionGzip:
+		level := c.Level
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	case CompressionZstd:
+		level := zstd.SpeedDefault
+		if c.Level != 0 {
+			level = zstd.EncoderLevelFromZstd(c.Level)
+		}
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(level), zstd.WithEncoderConcurrency(1))
+	default:
+		return nopWriteCloser{w}, nil
+	}
+}
+
+// decompress wraps r, which holds data stored with compression, in a reader
+// of the original bytes.
+func decompress(r io.Reader, compression Compression) (io.ReadCloser, error) {
+	switch compression {
+	case CompressionGzip:
+		return gzip.NewReader(r)
+	case CompressionZstd:
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	case "", CompressionNone:
+		return io.NopCloser(r), nil
+	default:
+		return nil, fmt.Errorf("unknown object compression %q", compression)
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// CodecStore wraps an ObjectStore to compress new objects with a Codec and
+// decompress every object by the compression it records. It presents objects
+// as their original content: Get and Stat report no Content-Encoding and, for
+// compressed objects, a Size of -1. Reads stop after maxObjectSize.
+type CodecStore struct {
+	store ObjectStore
+	codec Codec
+}
+
+func NewCodecStore(store ObjectStore, codec Codec) *CodecStore {
+	if codec.Compression == "" {
+		codec.Compression = CompressionNone
+	}
+	return &CodecStore{store: store, codec: codec}
+}
+
+// Unwrap returns the wrapped store, for objects such as WARC files that are
+// already compressed and must be stored byte for byte.
+func (s *CodecStore) Unwrap() ObjectStore {
+	return s.store
+}
+
+// Put streams r through the codec into the wrapped store.
+func (s *CodecStore) Put(ctx context.Context, bucket, key string, r io.Reader, size int64, opts PutOptions) error {
+	if s.codec.Compression == CompressionNone {
+		return s.store.Put(ctx, bucket, key, r, size, opts)
+	}
+
+	opts.ContentEncoding = string(s.codec.Compression)
+	opts.Metadata = maps.Clone(opts.Metadata)
+	if opts.Metadata == nil {
+		opts.Metadata = make(map[string]string, 2)
+	}
+	opts.Metadata[compressionMetaKey] = string(s.codec.Compression)
+	opts.Metadata[compressionLevelMetaKey] = strconv.Itoa(s.codec.Level)
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(s.compressTo(pw, r))
+	}()
+	// Stop the compressor if the store gives up before reading it all.
+	defer pr.Close()
+	return s.store.Put(ctx, bucket, key, pr, -1, opts)
+}
+
+func (s *CodecStore) compressTo(w io.Writer, r io.Reader) error {
+	zw, err := s.codec.compress(w)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(zw, r); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+func (s *CodecStore) Get(ctx context.Context, bucket, key string) (io.ReadCloser, ObjectInfo, error) {
+	r, info, err := s.store.Get(ctx, bucket, key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	zr, err := decompress(r, objectCompression(info))
+	if err != nil {
+		r.Close()
+		return nil, ObjectInfo{}, fmt.Errorf("decoding object %s/%s: %w", bucket, key, err)
+	}
+	return &decodedReader{Reader: io.LimitReader(zr, maxObjectSize), zr: zr, r: r}, decodedInfo(info), nil
+}
+
+func (s *CodecStore) Stat(ctx context.Context, bucket, key string) (ObjectInfo, error) {
+	info, err := s.store.Stat(ctx, bucket, key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return decodedInfo(info), nil
+}
+
+func (s *CodecStore) Walk(ctx context.Context, bucket string, fn func(key string) error) error {
+	return s.store.Walk(ctx, bucket, fn)
+}
+
+// Recompress rewrites an object in place with the store's codec, keeping its
+// content type. It reports false without rewriting when the object is
+// already stored that way, or when it is replaced while being rewritten: the
+// rewrite is a conditional Put on the ETag that was read, so a concurrent
+// writer's version is never overwritten with older content. Objects are
+// rewritten from memory, so it refuses ones that decompress to more than
+// maxObjectSize.
+func (s *CodecStore) Recompress(ctx context.Context, bucket, key string) (bool, error) {
+	info, err := s.store.Stat(ctx, bucket, key)
+	if err != nil {
+		return false, err
+	}
+	if s.matchesCodec(info) {
+		return false, nil
+	}
+
+	r, info, err := s.store.Get(ctx, bucket, key)
+	if err != nil {
+		return false, err
+	}
+	defer r.Close()
+	if s.matchesCodec(info) {
+		return false, nil
+	}
+	zr, err := decompress(r, objectCompression(info))
+	if err != nil {
+		return false, fmt.Errorf("decoding object %s/%s: %w", bucket, key, err)
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(io.LimitReader(zr, maxObjectSize+1))
+	if err != nil {
+		return false, fmt.Errorf("reading object %s/%s: %w", bucket, key, err)
+	}
+	if len(data) > maxObjectSize {
+		return false, fmt.Errorf("object %s/%s exceeds %d bytes, not recompressing", bucket, key, maxObjectSize)
+	}
+
+	opts := PutOptions{ContentType: info.ContentType, Metadata: maps.Clone(info.Metadata), IfMatch: info.ETag}
+	delete(opts.Metadata, compressionMetaKey)
+	delete(opts.Metadata, compressionLevelMetaKey)
+	err = s.Put(ctx, bucket, key, bytes.NewReader(data), int64(len(data)), opts)
+	if errors.Is(err, ErrChanged) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// matchesCodec reports whether an object is already stored with the store's
+// codec and level.
+func (s *CodecStore) matchesCodec(info ObjectInfo) bool {
+	current := objectCompression(info)
+	return current == s.codec.Compression &&
+		(current == CompressionNone || info.Metadata[compressionLevelMetaKey] == strconv.Itoa(s.codec.Level))
+}
+
+// objectCompression returns how an object was stored. Objects written before
+// compression was configurable carry neither marker and are uncompressed.
+func objectCompression(info ObjectInfo) Compression {
+	if c := info.Metadata[compressionMetaKey]; c != "" {
+		return Compression(c)
+	}
+	if info.ContentEncoding != "" {
+		return Compression(info.ContentEncoding)
+	}
+	return CompressionNone
+}
+
+func decodedInfo(info ObjectInfo) ObjectInfo {
+	if objectCompression(info) != CompressionNone {
+		info.Size = -1
+	}
+	info.ContentEncoding = ""
+	return info
+}
+
+// decodedReader reads a decompressed object and closes both layers.
+type decodedReader struct {
+	io.Reader
+	zr io.ReadCloser
+	r  io.ReadCloser
+}
+
+func (d *decodedReader) Close() error {
+	d.zr.Close()
+	return d.r.Close()
+}
+
+var _ ObjectStore = (*CodecStore)(nil)
@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParseCompression(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in      string
+		want    Compression
+		wantErr bool
+	}{
+		{in: "", want: CompressionNone},
+		{in: "none", want: CompressionNone},
+		{in: "gzip", want: CompressionGzip},
+		{in: "ZSTD", want: CompressionZstd},
+		{in: "brotli", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseCompression(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseCompression(%q) = %q, %v; want %q, err=%v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestCodecStore(t *testing.T) {
+	t.Parallel()
+	testObjectStore(t, NewCodecStore(NewMemoryStore(), Codec{Compression: CompressionNone}))
+}
+
+func TestCodecStore_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	data := []byte(strings.Repeat("<p>hello compressed world</p>\n", 1000))
+	tests := []struct {
+		name  string
+		codec Codec
+	}{
+		{name: "none", codec: Codec{Compression: CompressionNone}},
+		{name: "gzip default level", codec: Codec{Compression: CompressionGzip}},
+		{name: "gzip best", codec: Codec{Compression: CompressionGzip, Level: 9}},
+		{name: "zstd default level", codec: Codec{Compression: CompressionZstd}},
+		{name: "zstd level 19", codec: Codec{Compression: CompressionZstd, Level: 19}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			raw := NewMemoryStore()
+			store := NewCodecStore(raw, tt.codec)
+
+			if err := store.Put(ctx, "b", "k", bytes.NewReader(data), int64(len(data)), PutOptions{ContentType: "text/html"}); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+
+			stored, err := raw.Stat(ctx, "b", "k")
+			if err != nil {
+				t.Fatalf("Stat: %v", err)
+			}
+			if tt.codec.Compression != CompressionNone {
+				if stored.Size >= int64(len(data)) {
+					t.Errorf("stored size %d, want less than %d", stored.Size, len(data))
+				}
+				if stored.ContentEncoding != string(tt.codec.Compression) || stored.Metadata[compressionMetaKey] != string(tt.codec.Compression) {
+					t.Errorf("stored encoding = %q, metadata %v; want %q", stored.ContentEncoding, stored.Metadata, tt.codec.Compression)
+				}
+			}
+
+			got, err := ReadObject(ctx, store, "b", "k")
+			if err != nil {
+				t.Fatalf("ReadObject: %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Error("round trip changed the data")
+			}
+		})
+	}
+}
+
+func TestCodecStore_ReadsObjectsByRecordedCompression(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	raw := NewMemoryStore()
+
+	// Written uncompressed before compression was configured.
+	raw.Put(ctx, "b", "old", strings.NewReader("old"), 3, PutOptions{})
+	gz := NewCodecStore(raw, Codec{Compression: CompressionGzip})
+	if err := gz.Put(ctx, "b", "gz", strings.NewReader("gzipped"), 7, PutOptions{}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	store := NewCodecStore(raw, Codec{Compression: CompressionZstd})
+	for key, want := range map[string]string{"old": "old", "gz": "gzipped"} {
+		got, err := ReadObject(ctx, store, "b", key)
+		if err != nil {
+			t.Fatalf("ReadObject(%s): %v", key, err)
+		}
+		if string(got) != want {
+			t.Errorf("ReadObject(%s) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestCodecStore_Recompress(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	raw := NewMemoryStore()
+	raw.Put(ctx, "b", "k", strings.NewReader("<html>page</html>"), -1, PutOptions{ContentType: "text/html"})
+
+	store := NewCodecStore(raw, Codec{Compression: CompressionZstd, Level: 3})
+	changed, err := store.Recompress(ctx, "b", "k")
+	if err != nil || !changed {
+		t.Fatalf("Recompress = %v, %v; want true, nil", changed, err)
+	}
+	info, _ := raw.Stat(ctx, "b", "k")
+	if info.ContentEncoding != "zstd" || info.ContentType != "text/html" {
+		t.Errorf("after recompress: encoding %q, type %q; want zstd, text/html", info.ContentEncoding, info.ContentType)
+	}
+	if got, _ := ReadObject(ctx, store, "b", "k"); string(got) != "<html>page</html>" {
+		t.Errorf("content = %q after recompress", got)
+	}
+
+	changed, err = store.Recompress(ctx, "b", "k")
+	if err != nil || changed {
+		t.Errorf("second Recompress = %v, %v; want false, nil", changed, err)
+	}
+
+	releveled := NewCodecStore(raw, Codec{Compression: CompressionZstd, Level: 19})
+	if changed, err := releveled.Recompress(ctx, "b", "k"); err != nil || !changed {
+		t.Errorf("Recompress at new level = %v, %v; want true, nil", changed, err)
+	}
+}
+
+// replacingStore replaces an object with newer content right after it is
+// read, as a crawler re-fetching the page would.
+type replacingStore struct {
+	ObjectStore
+}
+
+func (s replacingStore) Get(ctx context.Context, bucket, key string) (io.ReadCloser, ObjectInfo, error) {
+	r, info, err := s.ObjectStore.Get(ctx, bucket, key)
+	if err == nil {
+		s.ObjectStore.Put(ctx, bucket, key, strings.NewReader("<html>new</html>"), -1, PutOptions{ContentType: "text/html"})
+	}
+	return r, info, err
+}
+
+func TestCodecStore_RecompressSkipsReplacedObjects(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	raw := NewMemoryStore()
+	raw.Put(ctx, "b", "k", strings.NewReader("<html>old</html>"), -1, PutOptions{ContentType: "text/html"})
+
+	store := NewCodecStore(replacingStore{raw}, Codec{Compression: CompressionGzip})
+	changed, err := store.Recompress(ctx, "b", "k")
+	if err != nil || changed {
+		t.Fatalf("Recompress = %v, %v; want false, nil", changed, err)
+	}
+	if got, _ := ReadObject(ctx, raw, "b", "k"); string(got) != "<html>new</html>" {
+		t.Errorf("content = %q, want the concurrent write kept", got)
+	}
+}
+
+func TestDecompress_UnknownCompression(t *testing.T) {
+	t.Parallel()
+	if _, err := decompress(strings.NewReader("x"), "lz4"); err == nil {
+		t.Error("expected error for unknown compression")
+	}
+}
@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// fsTempPrefix marks files still being written; Walk skips them.
+const fsTempPrefix = ".tmp-"
+
+// FSStore stores objects as files under a root directory, one directory per
+// bucket, for laptops and tests. Each file starts with a line of JSON holding
+// the object's PutOptions, followed by its content, so an object and its
+// metadata are replaced together by a single rename. ETags are derived from
+// a file's size and modification time; conditional Puts are only checked
+// against writers in the same process.
+type FSStore struct {
+	root string
+	// mu orders renames with the ETag checks of conditional Puts.
+	mu sync.Mutex
+}
+
+// NewFSStore returns a store rooted at dir, creating it if needed.
+func NewFSStore(dir string) (*FSStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating storage dir %s: %w", dir, err)
+	}
+	return &FSStore{root: dir}, nil
+}
+
+// path returns the file holding bucket/key. It rejects names that would
+// escape the bucket directory.
+func (s *FSStore) path(bucket, key string) (string, error) {
+	if !validPathName(bucket) || strings.Contains(bucket, "/") {
+		return "", fmt.Errorf("invalid bucket name %q", bucket)
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if !validPathName(seg) || strings.HasPrefix(seg, fsTempPrefix) {
+			return "", fmt.Errorf("invalid object key %q", key)
+		}
+	}
+	return filepath.Join(s.root, bucket, filepath.FromSlash(key)), nil
+}
+
+func validPathName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsRune(name, filepath.Separator)
+}
+
+func (s *FSStore) Put(ctx context.Context, bucket, key string, r io.Reader, size int64, opts PutOptions) error {
+	path, err := s.path(bucket, key)
+	if err != nil {
+		return err
+	}
+	header, err := json.Marshal(opts)
+	if err != nil {
+		return fmt.Errorf("encoding object metadata: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("putting object %s/%s: %w", bucket, key, err)
+	}
+	tmp, err := os.CreateTemp(dir, fsTempPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("putting object %s/%s: %w", bucket, key, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	w.Write(header)
+	w.WriteByte('\n')
+	if _, err := io.Copy(w, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("putting object %s/%s: %w", bucket, key, err)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("putting object %s/%s: %w", bucket, key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("putting object %s/%s: %w", bucket, key, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if opts.IfMatch != "" {
+		st, err := os.Stat(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("putting object %s/%s: %w", bucket, key, err)
+		}
+		if err != nil || fileETag(st) != opts.IfMatch {
+			return fmt.Errorf("putting object %s/%s: %w", bucket, key, ErrChanged)
+		}
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("putting object %s/%s: %w", bucket, key, err)
+	}
+	return nil
+}
+
+// fileETag derives an object's ETag from its file.
+func fileETag(st fs.FileInfo) string {
+	return fmt.Sprintf("%x-%x", st.ModTime().UnixNano(), st.Size())
+}
+
+// open opens the file for bucket/key and reads its header, leaving the file
+// positioned at the content.
+func (s *FSStore) open(bucket, key string) (*os.File, *bufio.Reader, ObjectInfo, error) {
+	path, err := s.path(bucket, key)
+	if err != nil {
+		return nil, nil, ObjectInfo{}, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, ObjectInfo{}, fmt.Errorf("getting object %s/%s: %w", bucket, key, ErrNotFound)
+	}
+	if err != nil {
+		return nil, nil, ObjectInfo{}, fmt.Errorf("getting object %s/%s: %w", bucket, key, err)
+	}
+
+	br := bufio.NewReader(f)
+	header, err := br.ReadBytes('\n')
+	var opts PutOptions
+	if err == nil {
+		err = json.Unmarshal(header, &opts)
+	}
+	if err != nil {
+		f.Close()
+		return nil, nil, ObjectInfo{}, fmt.Errorf("reading object header %s/%s: %w", bucket, key, err)
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, ObjectInfo{}, fmt.Errorf("getting object %s/%s: %w", bucket, key, err)
+	}
+
+	return f, br, ObjectInfo{
+		Size:            st.Size() - int64(len(header)),
+		ContentType:     opts.ContentType,
+		ContentEncoding: opts.ContentEncoding,
+		Metadata:        opts.Metadata,
+		ETag:            fileETag(st),
+	}, nil
+}
+
+func (s *FSStore) Get(ctx context.Context, bucket, key string) (io.ReadCloser, ObjectInfo, error) {
+	f, br, info, err := s.open(bucket, key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{br, f}, info, nil
+}
+
+func (s *FSStore) Stat(ctx context.Context, bucket, key string) (ObjectInfo, error) {
+	f, _, info, err := s.open(bucket, key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	f.Close()
+	return info, nil
+}
+
+// Walk visits keys in lexical order of their file paths.
+func (s *FSStore) Walk(ctx context.Context, bucket string, fn func(key string) error) error {
+	if !validPathName(bucket) {
+		return fmt.Errorf("invalid bucket name %q", bucket)
+	}
+	dir := filepath.Join(s.root, bucket)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == dir {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), fsTempPrefix) {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(rel))
+	})
+	if err != nil {
+		return fmt.Errorf("listing bucket %s: %w", bucket, err)
+	}
+	return nil
+}
+
+var _ ObjectStore = (*FSStore)(nil)
@@ -7,11 +7,6 @@ import (
 	"strings"
 )
 
-const (
-	HTMLBucket = "nimbus-html"
-	TextBucket = "nimbus-text"
-)
-
 // HTMLKey generates an S3 key for raw HTML content.
 func HTMLKey(rawURL string) string {
 	return objectKey(rawURL, "html")
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// MemoryStore keeps objects in process memory, for tests and single-process
+// runs. Nothing survives a restart.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string]memoryObject
+	version uint64
+}
+
+type memoryObject struct {
+	data []byte
+	opts PutOptions
+	etag string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]map[string]memoryObject)}
+}
+
+func (m *MemoryStore) Put(ctx context.Context, bucket, key string, r io.Reader, size int64, opts PutOptions) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("putting object %s/%s: %w", bucket, key, err)
+	}
+	opts.Metadata = maps.Clone(opts.Metadata)
+	ifMatch := opts.IfMatch
+	opts.IfMatch = ""
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.buckets[bucket]
+	if !ok {
+		b = make(map[string]memoryObject)
+		m.buckets[bucket] = b
+	}
+	if ifMatch != "" && b[key].etag != ifMatch {
+		return fmt.Errorf("putting object %s/%s: %w", bucket, key, ErrChanged)
+	}
+	m.version++
+	b[key] = memoryObject{data: data, opts: opts, etag: strconv.FormatUint(m.version, 10)}
+	return nil
+}
+
+func (m *MemoryStore) object(bucket, key string) (memoryObject, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	obj, ok := m.buckets[bucket][key]
+	if !ok {
+		return memoryObject{}, fmt.Errorf("getting object %s/%s: %w", bucket, key, ErrNotFound)
+	}
+	return obj, nil
+}
+
+func (m *MemoryStore) Get(ctx context.Context, bucket, key string) (io.ReadCloser, ObjectInfo, error) {
+	obj, err := m.object(bucket, key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	return io.NopCloser(bytes.NewReader(obj.data)), obj.info(), nil
+}
+
+func (m *MemoryStore) Stat(ctx context.Context, bucket, key string) (ObjectInfo, error) {
+	obj, err := m.object(bucket, key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return obj.info(), nil
+}
+
+// Walk visits keys in sorted order. It sees the keys present when it
+// starts.
+func (m *MemoryStore) Walk(ctx context.Context, bucket string, fn func(key string) error) error {
+	m.mu.RLock()
+	keys := slices.Sorted(maps.Keys(m.buckets[bucket]))
+	m.mu.RUnlock()
+
+	for _, key := range keys {
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o memoryObject) info() ObjectInfo {
+	return ObjectInfo{
+		Size:            int64(len(o.data)),
+		ContentType:     o.opts.ContentType,
+		ContentEncoding: o.opts.ContentEncoding,
+		Metadata:        maps.Clone(o.opts.Metadata),
+		ETag:            o.etag,
+	}
+}
+
+var _ ObjectStore = (*MemoryStore)(nil)
@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+)
+
+// uploadPartSize is the multipart part size for streamed uploads of unknown
+// length. 5MiB is the S3 minimum, so it is also what such an upload buffers:
+// a pooled buffer of up to one part, plus the client's part buffer when the
+// body is longer.
+const uploadPartSize = 5 * 1024 * 1024
+
+// uploadBuffers holds the buffers that uploads of unknown length are read
+// into, so concurrent small uploads do not each allocate a part.
+var uploadBuffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// S3 bucket addressing styles for config.MinIOConfig.Addressing.
+const (
+	AddressingAuto    = "auto"
+	AddressingPath    = "path"
+	AddressingVirtual = "virtual"
+)
+
+// S3Store stores objects in MinIO or any other S3-compatible service. Keys
+// are stored under an optional prefix, so several deployments can share
+// buckets.
+type S3Store struct {
+	client *minio.Client
+	prefix string
+}
+
+// NewS3Store connects to the service at cfg.Endpoint and, unless
+// cfg.SkipBucketCreation is set, creates any of buckets that are missing.
+func NewS3Store(ctx context.Context, cfg config.MinIOConfig, buckets []string) (*S3Store, error) {
+	var lookup minio.BucketLookupType
+	switch strings.ToLower(cfg.Addressing) {
+	case "", AddressingAuto:
+		lookup = minio.BucketLookupAuto
+	case AddressingPath:
+		lookup = minio.BucketLookupPath
+	case AddressingVirtual:
+		lookup = minio.BucketLookupDNS
+	default:
+		return nil, fmt.Errorf("unknown s3 addressing %q", cfg.Addressing)
+	}
+
+	transport := &http.Transport{
+		// Objects are decoded by their recorded compression, so the transport
+		// must not transparently gunzip them.
+		DisableCompression:  true,
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 20,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure:       cfg.UseSSL,
+		Region:       cfg.Region,
+		BucketLookup: lookup,
+		Transport:    transport,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating s3 client: %w", err)
+	}
+
+	s := &S3Store{client: client, prefix: strings.Trim(cfg.KeyPrefix, "/")}
+	if !cfg.SkipBucketCreation {
+		if err := s.ensureBuckets(ctx, cfg.Region, buckets); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (s *S3Store) ensureBuckets(ctx context.Context, region string, buckets []string) error {
+	for _, bucket := range buckets {
+		exists, err := s.client.BucketExists(ctx, bucket)
+		if err != nil {
+			return fmt.Errorf("checking bucket %s: %w", bucket, err)
+		}
+		if !exists {
+			if err := s.client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{Region: region}); err != nil {
+				return fmt.Errorf("creating bucket %s: %w", bucket, err)
+			}
+		}
+	}
+	return nil
+}
+
+// objectName returns the S3 object name for key.
+func (s *S3Store) objectName(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return s.prefix + "/" + key
+}
+
+// Put streams r to S3. When size is known and small the body is sent in a
+// single PUT; otherwise it is uploaded in multipart parts of uploadPartSize.
+// A body of unknown size that ends within one part is read into a pooled
+// buffer and sent with a single PUT as well. Conditional puts send If-Match,
+// which the service must support (MinIO and AWS S3 do).
+func (s *S3Store) Put(ctx context.Context, bucket, key string, r io.Reader, size int64, opts PutOptions) error {
+	if size < 0 {
+		buf := uploadBuffers.Get().(*bytes.Buffer)
+		buf.Reset()
+		defer uploadBuffers.Put(buf)
+		n, err := io.CopyN(buf, r, uploadPartSize)
+		switch {
+		case err == io.EOF:
+			r, size = bytes.NewReader(buf.Bytes()), n
+		case err != nil:
+			return fmt.Errorf("reading object %s/%s: %w", bucket, key, err)
+		default:
+			r = io.MultiReader(bytes.NewReader(buf.Bytes()), r)
+		}
+	}
+	popts := minio.PutObjectOptions{
+		ContentType:     opts.ContentType,
+		ContentEncoding: opts.ContentEncoding,
+		UserMetadata:    opts.Metadata,
+		PartSize:        uploadPartSize,
+	}
+	if opts.IfMatch != "" {
+		popts.SetMatchETag(opts.IfMatch)
+	}
+	_, err := s.client.PutObject(ctx, bucket, s.objectName(key), r, size, popts)
+	if err != nil {
+		if code := minio.ToErrorResponse(err).Code; code == "PreconditionFailed" || code == minio.NoSuchKey {
+			return fmt.Errorf("putting object %s/%s: %w: %w", bucket, key, ErrChanged, err)
+		}
+		return fmt.Errorf("putting object %s/%s: %w", bucket, key, err)
+	}
+	return nil
+}
+
+func (s *S3Store) Get(ctx context.Context, bucket, key string) (io.ReadCloser, ObjectInfo, error) {
+	obj, err := s.client.GetObject(ctx, bucket, s.objectName(key), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, ObjectInfo{}, fmt.Errorf("getting object %s/%s: %w", bucket, key, err)
+	}
+	// Stat reads the response headers of the GET; it is not another request.
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, ObjectInfo{}, s3Error(bucket, key, err)
+	}
+	return obj, objectInfo(info), nil
+}
+
+func (s *S3Store) Stat(ctx context.Context, bucket, key string) (ObjectInfo, error) {
+	info, err := s.client.StatObject(ctx, bucket, s.objectName(key), minio.StatObjectOptions{})
+	if err != nil {
+		return ObjectInfo{}, s3Error(bucket, key, err)
+	}
+	return objectInfo(info), nil
+}
+
+func (s *S3Store) Walk(ctx context.Context, bucket string, fn func(key string) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	opts := minio.ListObjectsOptions{Recursive: true}
+	if s.prefix != "" {
+		opts.Prefix = s.prefix + "/"
+	}
+	for obj := range s.client.ListObjects(ctx, bucket, opts) {
+		if obj.Err != nil {
+			return fmt.Errorf("listing bucket %s: %w", bucket, obj.Err)
+		}
+		if err := fn(strings.TrimPrefix(obj.Key, opts.Prefix)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func s3Error(bucket, key string, err error) error {
+	if minio.ToErrorResponse(err).Code == minio.NoSuchKey {
+		return fmt.Errorf("getting object %s/%s: %w: %w", bucket, key, ErrNotFound, err)
+	}
+	return fmt.Errorf("getting object %s/%s: %w", bucket, key, err)
+}
+
+func objectInfo(info minio.ObjectInfo) ObjectInfo {
+	return ObjectInfo{
+		Size:            info.Size,
+		ContentType:     info.ContentType,
+		ContentEncoding: info.Metadata.Get("Content-Encoding"),
+		Metadata:        info.UserMetadata,
+		ETag:            info.ETag,
+	}
+}
+
+var _ ObjectStore = (*S3Store)(nil)
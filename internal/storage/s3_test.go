@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+)
+
+// TestS3Store_PutUnknownSize checks that a small body of unknown size is sent
+// with a single PUT rather than a multipart upload.
+func TestS3Store_PutUnknownSize(t *testing.T) {
+	t.Parallel()
+	var (
+		mu       sync.Mutex
+		requests []string
+		body     string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery)
+		body = string(b)
+		mu.Unlock()
+		w.Header().Set("ETag", `"etag"`)
+	}))
+	defer srv.Close()
+
+	store, err := NewS3Store(context.Background(), config.MinIOConfig{
+		Endpoint:           strings.TrimPrefix(srv.URL, "http://"),
+		AccessKey:          "key",
+		SecretKey:          "secret",
+		Region:             "us-east-1",
+		Addressing:         AddressingPath,
+		SkipBucketCreation: true,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Hide the reader's type so its size cannot be discovered.
+	r := struct{ io.Reader }{strings.NewReader("hello")}
+	if err := store.Put(context.Background(), "bucket", "a.txt", r, -1, PutOptions{ContentType: "text/plain"}); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requests) != 1 || requests[0] != "PUT /bucket/a.txt?" {
+		t.Errorf("requests = %q, want a single PUT", requests)
+	}
+	if !strings.Contains(body, "hello") {
+		t.Errorf("body = %q, want it to carry hello", body)
+	}
+}
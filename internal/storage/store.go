@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+)
+
+// Storage backends selectable with config.StorageConfig.Backend.
+const (
+	BackendS3     = "s3"
+	BackendFS     = "fs"
+	BackendMemory = "memory"
+)
+
+// maxObjectSize is the read limit for objects.
+// Matches crawler.maxBodyBytes to avoid reading more than was stored.
+const maxObjectSize = 10 * 1024 * 1024 // 10MB
+
+// ErrNotFound is returned when an object does not exist.
+var ErrNotFound = errors.New("object not found")
+
+// ErrChanged is returned by a conditional Put when the object is missing or
+// no longer has the expected ETag.
+var ErrChanged = errors.New("object changed")
+
+// PutOptions describe an object being stored.
+type PutOptions struct {
+	ContentType     string
+	ContentEncoding string
+	// Metadata is stored with the object and returned in its ObjectInfo.
+	Metadata map[string]string
+	// IfMatch, when set, makes Put replace the object only if its current
+	// ETag is IfMatch, and fail with ErrChanged otherwise.
+	IfMatch string `json:"-"`
+}
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Size            int64
+	ContentType     string
+	ContentEncoding string
+	Metadata        map[string]string
+	// ETag identifies the stored version of the object; every Put changes it.
+	ETag string
+}
+
+// ObjectStore stores objects by bucket and key.
+type ObjectStore interface {
+	// Put stores r under bucket/key, replacing any existing object. size is
+	// the length of r, or -1 if unknown.
+	Put(ctx context.Context, bucket, key string, r io.Reader, size int64, opts PutOptions) error
+	// Get opens an object for reading. The caller must close it.
+	Get(ctx context.Context, bucket, key string) (io.ReadCloser, ObjectInfo, error)
+	// Stat returns an object's info without reading it.
+	Stat(ctx context.Context, bucket, key string) (ObjectInfo, error)
+	// Walk calls fn with every key in bucket, stopping at the first error.
+	Walk(ctx context.Context, bucket string, fn func(key string) error) error
+}
+
+// Open returns the object store selected by cfg.Storage.Backend, wrapped in
+// a CodecStore that compresses new objects as configured. Buckets are
+// created if the backend needs them.
+func Open(ctx context.Context, cfg *config.Config, logger *slog.Logger) (*CodecStore, error) {
+	codec, err := NewCodec(cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	buckets := []string{cfg.Storage.HTMLBucket, cfg.Storage.TextBucket}
+	if cfg.WARC.Enabled {
+		buckets = append(buckets, cfg.WARC.Bucket)
+	}
+	if cfg.Export.Bucket != "" {
+		buckets = append(buckets, cfg.Export.Bucket)
+	}
+
+	var store ObjectStore
+	switch strings.ToLower(cfg.Storage.Backend) {
+	case "", BackendS3:
+		store, err = NewS3Store(ctx, cfg.MinIO, buckets)
+		if err != nil {
+			return nil, err
+		}
+	case BackendFS:
+		store, err = NewFSStore(cfg.Storage.Dir)
+		if err != nil {
+			return nil, err
+		}
+	case BackendMemory:
+		logger.Warn("using in-memory object store; objects are lost on exit and not shared between processes")
+		store = NewMemoryStore()
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Storage.Backend)
+	}
+	return NewCodecStore(store, codec), nil
+}
+
+// ReadObject reads an object's content into memory.
+func ReadObject(ctx context.Context, store ObjectStore, bucket, key string) ([]byte, error) {
+	r, _, err := store.Get(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(io.LimitReader(r, maxObjectSize))
+	if err != nil {
+		return nil, fmt.Errorf("reading object %s/%s: %w", bucket, key, err)
+	}
+	return data, nil
+}
+
+// SplitLink splits a stored "bucket/key" link into its bucket and key.
+func SplitLink(link string) (bucket, key string, ok bool) {
+	bucket, key, ok = strings.Cut(link, "/")
+	return bucket, key, ok && bucket != "" && key != ""
+}
@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+)
+
+// testObjectStore checks the ObjectStore contract against store.
+func testObjectStore(t *testing.T, store ObjectStore) {
+	t.Helper()
+	ctx := context.Background()
+
+	put := func(key, content string, opts PutOptions) {
+		t.Helper()
+		if err := store.Put(ctx, "bucket", key, strings.NewReader(content), -1, opts); err != nil {
+			t.Fatalf("Put(%s): %v", key, err)
+		}
+	}
+	put("example.com/a_1.html", "first", PutOptions{ContentType: "text/html", Metadata: map[string]string{"Source": "test"}})
+	put("example.com/a_1.html", "replaced", PutOptions{ContentType: "text/html", Metadata: map[string]string{"Source": "test"}})
+	put("example.com/sub/b_2.html", "second", PutOptions{ContentType: "text/html"})
+
+	r, info, err := store.Get(ctx, "bucket", "example.com/a_1.html")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatalf("reading: %v", err)
+	}
+	if string(data) != "replaced" {
+		t.Errorf("content = %q, want replaced", data)
+	}
+	if info.ContentType != "text/html" || info.Metadata["Source"] != "test" {
+		t.Errorf("info = %+v, want text/html with Source metadata", info)
+	}
+
+	st, err := store.Stat(ctx, "bucket", "example.com/sub/b_2.html")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if st.Size != int64(len("second")) {
+		t.Errorf("Stat size = %d, want %d", st.Size, len("second"))
+	}
+
+	stale := st.ETag
+	put("example.com/sub/b_2.html", "third", PutOptions{ContentType: "text/html"})
+	st, err = store.Stat(ctx, "bucket", "example.com/sub/b_2.html")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if st.ETag == "" || st.ETag == stale {
+		t.Errorf("ETag = %q after a Put, want a new one (was %q)", st.ETag, stale)
+	}
+	err = store.Put(ctx, "bucket", "example.com/sub/b_2.html", strings.NewReader("lost"), -1, PutOptions{IfMatch: stale})
+	if !errors.Is(err, ErrChanged) {
+		t.Errorf("Put(IfMatch stale) error = %v, want ErrChanged", err)
+	}
+	err = store.Put(ctx, "bucket", "missing.html", strings.NewReader("lost"), -1, PutOptions{IfMatch: stale})
+	if !errors.Is(err, ErrChanged) {
+		t.Errorf("Put(IfMatch missing) error = %v, want ErrChanged", err)
+	}
+	put("example.com/sub/b_2.html", "second", PutOptions{ContentType: "text/html", IfMatch: st.ETag})
+
+	if _, _, err := store.Get(ctx, "bucket", "missing.html"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+	if _, err := store.Stat(ctx, "bucket", "missing.html"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat(missing) error = %v, want ErrNotFound", err)
+	}
+
+	var keys []string
+	if err := store.Walk(ctx, "bucket", func(key string) error {
+		keys = append(keys, key)
+		return nil
+	}); err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+	slices.Sort(keys)
+	want := []string{"example.com/a_1.html", "example.com/sub/b_2.html"}
+	if !slices.Equal(keys, want) {
+		t.Errorf("Walk keys = %v, want %v", keys, want)
+	}
+
+	if err := store.Walk(ctx, "empty", func(string) error {
+		t.Error("Walk visited a key in an empty bucket")
+		return nil
+	}); err != nil {
+		t.Errorf("Walk(empty) = %v", err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	t.Parallel()
+	testObjectStore(t, NewMemoryStore())
+}
+
+func TestFSStore(t *testing.T) {
+	t.Parallel()
+	store, err := NewFSStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFSStore: %v", err)
+	}
+	testObjectStore(t, store)
+}
+
+func TestFSStore_RejectsEscapingKeys(t *testing.T) {
+	t.Parallel()
+	store, err := NewFSStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFSStore: %v", err)
+	}
+
+	tests := []struct {
+		bucket, key string
+	}{
+		{"bucket", "../outside"},
+		{"bucket", "host/../../outside"},
+		{"bucket", "/absolute"},
+		{"..", "key"},
+		{"a/b", "key"},
+	}
+	for _, tt := range tests {
+		if err := store.Put(context.Background(), tt.bucket, tt.key, strings.NewReader("x"), 1, PutOptions{}); err == nil {
+			t.Errorf("Put(%q, %q) succeeded, want error", tt.bucket, tt.key)
+		}
+	}
+}
+
+func TestSplitLink(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		link        string
+		bucket, key string
+		ok          bool
+	}{
+		{link: "nimbus-html/example.com/index_ab.html", bucket: "nimbus-html", key: "example.com/index_ab.html", ok: true},
+		{link: "no-slash"},
+		{link: "/key"},
+		{link: "bucket/"},
+	}
+	for _, tt := range tests {
+		bucket, key, ok := SplitLink(tt.link)
+		if bucket != tt.bucket && tt.ok || key != tt.key && tt.ok || ok != tt.ok {
+			t.Errorf("SplitLink(%q) = %q, %q, %v; want %q, %q, %v", tt.link, bucket, key, ok, tt.bucket, tt.key, tt.ok)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	t.Parallel()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		backend     string
+		compression string
+		wantErr     bool
+	}{
+		{backend: BackendMemory},
+		{backend: BackendFS, compression: "zstd"},
+		{backend: "floppy", wantErr: true},
+		{backend: BackendMemory, compression: "lz4", wantErr: true},
+	}
+	for _, tt := range tests {
+		cfg := config.LoadFromEnv()
+		cfg.Storage.Backend = tt.backend
+		cfg.Storage.Dir = t.TempDir()
+		cfg.Storage.Compression = tt.compression
+		store, err := Open(context.Background(), cfg, logger)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Open(%s, %q) error = %v, wantErr %v", tt.backend, tt.compression, err, tt.wantErr)
+		}
+		if err == nil && store == nil {
+			t.Errorf("Open(%s) returned a nil store", tt.backend)
+		}
+	}
+}
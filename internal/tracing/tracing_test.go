@@ -0,0 +1,31 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		exporter string
+		wantErr  bool
+	}{
+		{"none", false},
+		{"", false},
+		{"stdout", false},
+		{"zipkin", true},
+	}
+	for _, tt := range tests {
+		shutdown, err := Setup(context.Background(), config.TracingConfig{Exporter: tt.exporter}, "test")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Setup(%q) error = %v, wantErr %v", tt.exporter, err, tt.wantErr)
+		}
+		if err == nil {
+			if err := shutdown(context.Background()); err != nil {
+				t.Errorf("shutdown(%q): %v", tt.exporter, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxReplayBlock caps the record blocks an Archive reads into memory.
+const maxReplayBlock = 32 << 20 // 32MB
+
+// Archive serves captures from local .warc.gz files, found through their
+// CDXJ indexes. It is an http.RoundTripper: an http.Client over it replays
+// the archived status, headers and body of each URL, following archived
+// redirects, without touching the network. URLs that were not archived get
+// a synthetic 404.
+type Archive struct {
+	// files maps the filenames in the indexes to local paths.
+	files map[string]string
+	// captures holds the index entries of each SURT key, oldest first.
+	captures map[string][]CDXEntry
+}
+
+// OpenArchive indexes the .warc.gz files at paths, each with its CDXJ index
+// next to it (see CDXJKey). Directories are searched for .warc.gz files.
+func OpenArchive(paths ...string) (*Archive, error) {
+	a := &Archive{files: make(map[string]string), captures: make(map[string][]CDXEntry)}
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !strings.HasSuffix(p, ".warc.gz") {
+				return nil
+			}
+			return a.addFile(p)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("opening warc archive %s: %w", path, err)
+		}
+	}
+	for key, entries := range a.captures {
+		slices.SortStableFunc(entries, func(x, y CDXEntry) int { return x.Time.Compare(y.Time) })
+		a.captures[key] = entries
+	}
+	return a, nil
+}
+
+func (a *Archive) addFile(path string) error {
+	name := filepath.Base(path)
+	index, err := os.ReadFile(CDXJKey(path))
+	if err != nil {
+		return fmt.Errorf("reading index of %s: %w", name, err)
+	}
+	a.files[name] = path
+	for line := range strings.Lines(string(index)) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		e, err := ParseCDXJ(line)
+		if err != nil {
+			return fmt.Errorf("reading index of %s: %w", name, err)
+		}
+		if e.Filename == "" {
+			e.Filename = name
+		}
+		key := SURT(e.URL)
+		a.captures[key] = append(a.captures[key], e)
+	}
+	return nil
+}
+
+// Len returns the number of indexed captures.
+func (a *Archive) Len() int {
+	n := 0
+	for _, entries := range a.captures {
+		n += len(entries)
+	}
+	return n
+}
+
+// ParseCDXJ parses a line written by CDXEntry.String.
+func ParseCDXJ(line string) (CDXEntry, error) {
+	_, rest, ok := strings.Cut(line, " ")
+	ts, js, ok2 := strings.Cut(rest, " ")
+	if !ok || !ok2 {
+		return CDXEntry{}, fmt.Errorf("malformed cdxj line %q", line)
+	}
+	t, err := time.Parse(cdxTimestampLayout, ts)
+	if err != nil {
+		return CDXEntry{}, fmt.Errorf("malformed cdxj timestamp %q", ts)
+	}
+	var f cdxjFields
+	if err := json.Unmarshal([]byte(js), &f); err != nil {
+		return CDXEntry{}, fmt.Errorf("malformed cdxj fields: %w", err)
+	}
+	e := CDXEntry{URL: f.URL, Time: t, MIME: f.MIME, Digest: f.Digest, Filename: f.Filename}
+	if f.Status != "" {
+		e.Status, _ = strconv.Atoi(f.Status)
+	}
+	if e.Length, err = strconv.ParseInt(f.Length, 10, 64); err != nil {
+		return CDXEntry{}, fmt.Errorf("malformed cdxj length %q", f.Length)
+	}
+	if e.Offset, err = strconv.ParseInt(f.Offset, 10, 64); err != nil {
+		return CDXEntry{}, fmt.Errorf("malformed cdxj offset %q", f.Offset)
+	}
+	return e, nil
+}
+
+// Lookup returns the latest capture of rawURL, preferring captures of that
+// exact URL over others with the same SURT key.
+func (a *Archive) Lookup(rawURL string) (CDXEntry, bool) {
+	entries := a.captures[SURT(rawURL)]
+	if len(entries) == 0 {
+		return CDXEntry{}, false
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].URL == rawURL {
+			return entries[i], true
+		}
+	}
+	return entries[len(entries)-1], true
+}
+
+// RoundTrip returns the archived response for req's URL.
+func (a *Archive) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	e, ok := a.Lookup(req.URL.String())
+	if !ok || req.Method != http.MethodGet {
+		return notArchived(req), nil
+	}
+	resp, err := a.response(e, req)
+	if err != nil {
+		return nil, fmt.Errorf("replaying %s: %w", req.URL, err)
+	}
+	return resp, nil
+}
+
+// response rebuilds the response recorded by e. A revisit takes its payload
+// from the capture it refers to.
+func (a *Archive) response(e CDXEntry, req *http.Request) (*http.Response, error) {
+	rec, block, err := a.readRecord(e)
+	if err != nil {
+		return nil, err
+	}
+	header, payload := splitHTTP(block)
+	if rec.Type == TypeRevisit {
+		orig, err := a.revisited(rec)
+		if err != nil {
+			return nil, err
+		}
+		_, origBlock, err := a.readRecord(orig)
+		if err != nil {
+			return nil, err
+		}
+		_, payload = splitHTTP(origBlock)
+	} else if rec.Type != TypeResponse {
+		return nil, fmt.Errorf("index points at a %s record", rec.Type)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(header)), req)
+	if err != nil {
+		return nil, fmt.Errorf("parsing archived response: %w", err)
+	}
+	// The payload was archived decoded, so its framing headers no longer
+	// apply.
+	resp.TransferEncoding = nil
+	resp.ContentLength = int64(len(payload))
+	resp.Body = io.NopCloser(bytes.NewReader(payload))
+	return resp, nil
+}
+
+// revisited finds the capture a revisit record refers to.
+func (a *Archive) revisited(rec *Record) (CDXEntry, error) {
+	target := rec.Header.Get("WARC-Refers-To-Target-URI")
+	date, err := time.Parse(time.RFC3339, rec.Header.Get("WARC-Refers-To-Date"))
+	if err != nil {
+		return CDXEntry{}, errors.New("revisit record has no valid WARC-Refers-To-Date")
+	}
+	for _, e := range a.captures[SURT(target)] {
+		if e.URL == target && e.Time.Equal(date) && e.MIME != mimeRevisit {
+			return e, nil
+		}
+	}
+	return CDXEntry{}, fmt.Errorf("revisited capture of %s at %s is not archived", target, FormatDate(date))
+}
+
+// readRecord reads the record located by e and its whole block.
+func (a *Archive) readRecord(e CDXEntry) (*Record, []byte, error) {
+	path, ok := a.files[e.Filename]
+	if !ok {
+		return nil, nil, fmt.Errorf("warc file %s is not in the archive", e.Filename)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening warc file: %w", err)
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(io.NewSectionReader(f, e.Offset, e.Length))
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading %s at %d: %w", e.Filename, e.Offset, err)
+	}
+	rec, err := ReadRecord(bufio.NewReader(zr))
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading %s at %d: %w", e.Filename, e.Offset, err)
+	}
+	if rec.Length > maxReplayBlock {
+		return nil, nil, fmt.Errorf("record in %s at %d exceeds %d bytes", e.Filename, e.Offset, maxReplayBlock)
+	}
+	block, err := io.ReadAll(rec.Block)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading %s at %d: %w", e.Filename, e.Offset, err)
+	}
+	return rec, block, nil
+}
+
+// splitHTTP splits an HTTP message into its header, up to and including the
+// blank line, and its payload.
+func splitHTTP(block []byte) (header, payload []byte) {
+	i := bytes.Index(block, []byte("\r\n\r\n"))
+	if i < 0 {
+		return block, nil
+	}
+	return block[:i+4], block[i+4:]
+}
+
+// notArchived is the synthetic response for URLs missing from the archive.
+func notArchived(req *http.Request) *http.Response {
+	const body = "not archived\n"
+	return &http.Response{
+		Status:        "404 Not Found",
+		StatusCode:    http.StatusNotFound,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+var _ http.RoundTripper = (*Archive)(nil)
@@ -0,0 +1,125 @@
+package warc
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/theognis1002/nimbus-crawler/internal/storage"
+)
+
+// exportArchive copies a writer's uploads to a directory and opens it.
+func exportArchive(t *testing.T, store storage.ObjectStore) *Archive {
+	t.Helper()
+	dir := t.TempDir()
+	err := store.Walk(context.Background(), testBucket, func(key string) error {
+		data, err := storage.ReadObject(context.Background(), store, testBucket, key)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dir, key), data, 0o644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := OpenArchive(dir)
+	if err != nil {
+		t.Fatalf("OpenArchive: %v", err)
+	}
+	return a
+}
+
+func TestArchiveReplay(t *testing.T) {
+	t.Parallel()
+
+	store := storage.NewMemoryStore()
+	w := newTestWriter(store, 1<<20)
+	capture(t, w, "https://example.com/a", "<html>a</html>")
+	capture(t, w, "https://example.com/copy", "<html>a</html>")
+
+	// http://example.com/old redirected to https://example.com/new.
+	final := testResponse(t, "https://example.com/new", http.StatusOK)
+	redirect := testResponse(t, "http://example.com/old", http.StatusMovedPermanently)
+	redirect.Header.Set("Location", "https://example.com/new")
+	final.Request.Response = redirect
+	c, err := NewCapture(final, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(c, "<html>new</html>")
+	if err := w.Commit(context.Background(), c); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	a := exportArchive(t, store)
+	client := &http.Client{Transport: a}
+
+	tests := []struct {
+		name       string
+		url        string
+		wantStatus int
+		wantBody   string
+		wantURL    string
+	}{
+		{name: "response", url: "https://example.com/a", wantStatus: 200, wantBody: "<html>a</html>"},
+		{name: "revisit", url: "https://example.com/copy", wantStatus: 200, wantBody: "<html>a</html>"},
+		{name: "redirect", url: "http://example.com/old", wantStatus: 200, wantBody: "<html>new</html>", wantURL: "https://example.com/new"},
+		{name: "not archived", url: "https://example.com/missing", wantStatus: 404, wantBody: "not archived\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			resp, err := client.Get(tt.url)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if string(body) != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+			if tt.wantStatus == 200 && resp.Header.Get("Content-Type") != "text/html; charset=utf-8" {
+				t.Errorf("content-type = %q, want archived header", resp.Header.Get("Content-Type"))
+			}
+			if tt.wantURL != "" && resp.Request.URL.String() != tt.wantURL {
+				t.Errorf("final url = %s, want %s", resp.Request.URL, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestParseCDXJRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	want := CDXEntry{
+		URL:      "https://example.com/page?q=1",
+		Time:     time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC),
+		MIME:     "text/html",
+		Status:   301,
+		Digest:   "sha1:ABC",
+		Length:   12,
+		Offset:   34,
+		Filename: "f.warc.gz",
+	}
+	got, err := ParseCDXJ(want.String())
+	if err != nil {
+		t.Fatalf("ParseCDXJ: %v", err)
+	}
+	if got != want {
+		t.Errorf("ParseCDXJ = %+v, want %+v", got, want)
+	}
+
+	if _, err := ParseCDXJ("com,example)/ notatime {}"); err == nil {
+		t.Error("expected error for malformed timestamp")
+	}
+}
@@ -0,0 +1,94 @@
+package warc
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cdxTimestampLayout is the 14-digit timestamp used in CDX indexes.
+const cdxTimestampLayout = "20060102150405"
+
+// SURT returns the Sort-friendly URI Reordering Transform of rawURL as used
+// in CDX indexes: the host reversed and comma-separated without a leading
+// "www", then ")" and the path and sorted query, all lowercased, with the
+// scheme, port and fragment dropped. For example
+// "https://www.Example.com/a?b=1&a=2" becomes "com,example)/a?a=2&b=1".
+// Unparseable URLs are returned lowercased.
+func SURT(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return strings.ToLower(rawURL)
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	parts := strings.Split(host, ".")
+	slices.Reverse(parts)
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	var b strings.Builder
+	b.WriteString(strings.Join(parts, ","))
+	b.WriteString(")")
+	b.WriteString(strings.ToLower(path))
+	if u.RawQuery != "" {
+		params := strings.Split(u.RawQuery, "&")
+		slices.Sort(params)
+		b.WriteString("?")
+		b.WriteString(strings.ToLower(strings.Join(params, "&")))
+	}
+	return b.String()
+}
+
+// CDXEntry is one line of a CDXJ index, locating a response or revisit
+// record in a WARC file.
+type CDXEntry struct {
+	URL      string
+	Time     time.Time
+	MIME     string
+	Status   int
+	Digest   string
+	Length   int64
+	Offset   int64
+	Filename string
+}
+
+// cdxjFields is the JSON block of a CDXJ line, in the pywb field names.
+type cdxjFields struct {
+	URL      string `json:"url"`
+	MIME     string `json:"mime,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Digest   string `json:"digest,omitempty"`
+	Length   string `json:"length"`
+	Offset   string `json:"offset"`
+	Filename string `json:"filename"`
+}
+
+// String formats e as a CDXJ line without the trailing newline.
+func (e CDXEntry) String() string {
+	f := cdxjFields{
+		URL:      e.URL,
+		MIME:     e.MIME,
+		Digest:   e.Digest,
+		Length:   strconv.FormatInt(e.Length, 10),
+		Offset:   strconv.FormatInt(e.Offset, 10),
+		Filename: e.Filename,
+	}
+	if e.Status != 0 {
+		f.Status = strconv.Itoa(e.Status)
+	}
+	js, _ := json.Marshal(f)
+	return SURT(e.URL) + " " + e.Time.UTC().Format(cdxTimestampLayout) + " " + string(js)
+}
+
+// compareCDX orders index entries by SURT key, time and offset, as CDXJ
+// files are sorted.
+func compareCDX(a, b CDXEntry) int {
+	return cmp.Or(strings.Compare(SURT(a.URL), SURT(b.URL)), a.Time.Compare(b.Time), cmp.Compare(a.Offset, b.Offset))
+}
@@ -0,0 +1,47 @@
+package warc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSURT(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://www.Example.com/a?b=1&a=2", "com,example)/a?a=2&b=1"},
+		{"http://example.com", "com,example)/"},
+		{"http://sub.example.co.uk:8080/Path/#frag", "uk,co,example,sub)/path/"},
+		{"not a url", "not a url"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			t.Parallel()
+			if got := SURT(tt.url); got != tt.want {
+				t.Errorf("SURT(%q) = %q, want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCDXEntryString(t *testing.T) {
+	t.Parallel()
+
+	e := CDXEntry{
+		URL:      "https://example.com/page",
+		Time:     time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC),
+		MIME:     "text/html",
+		Status:   200,
+		Digest:   "sha1:ABC",
+		Length:   123,
+		Offset:   456,
+		Filename: "nimbus.warc.gz",
+	}
+	want := `com,example)/page 20260304050607 {"url":"https://example.com/page","mime":"text/html","status":"200","digest":"sha1:ABC","length":"123","offset":"456","filename":"nimbus.warc.gz"}`
+	if got := e.String(); got != want {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
+	}
+}
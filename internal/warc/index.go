@@ -0,0 +1,58 @@
+package warc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CaptureRef identifies the record that first captured a payload, which
+// later revisit records refer to.
+type CaptureRef struct {
+	RecordID  string
+	TargetURI string
+	Date      time.Time
+}
+
+// CaptureIndex remembers the first capture of each payload digest so that
+// unchanged or duplicate payloads are written as revisit records.
+type CaptureIndex interface {
+	// Claim records ref as the capture of payloads with digest. If another
+	// capture was recorded first, it returns that one and true instead.
+	Claim(ctx context.Context, digest string, ref CaptureRef) (CaptureRef, bool, error)
+	// Release forgets the capture of digest if it is still the record
+	// recordID, whose record never reached storage, so the payload's next
+	// capture is written in full.
+	Release(ctx context.Context, digest, recordID string) error
+}
+
+// MemoryIndex is a CaptureIndex held in memory, for a single process.
+type MemoryIndex struct {
+	mu       sync.Mutex
+	captures map[string]CaptureRef
+}
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{captures: make(map[string]CaptureRef)}
+}
+
+func (m *MemoryIndex) Claim(ctx context.Context, digest string, ref CaptureRef) (CaptureRef, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if first, ok := m.captures[digest]; ok {
+		return first, true, nil
+	}
+	m.captures[digest] = ref
+	return ref, false, nil
+}
+
+func (m *MemoryIndex) Release(ctx context.Context, digest, recordID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if first, ok := m.captures[digest]; ok && first.RecordID == recordID {
+		delete(m.captures, digest)
+	}
+	return nil
+}
+
+var _ CaptureIndex = (*MemoryIndex)(nil)
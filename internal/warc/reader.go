@@ -0,0 +1,65 @@
+package warc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// ReadRecord reads the next record from r, which holds uncompressed WARC
+// data. The record's Block reads its Content-Length bytes from r and must be
+// consumed before reading the next record. Header holds every field but
+// WARC-Type and Content-Length. It returns io.EOF at the end of r.
+func ReadRecord(r *bufio.Reader) (*Record, error) {
+	tp := textproto.NewReader(r)
+	version, err := tp.ReadLine()
+	// Records are separated by two blank lines, which may be left over.
+	for err == nil && version == "" {
+		version, err = tp.ReadLine()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(version, "WARC/1.") {
+		return nil, fmt.Errorf("unsupported warc version %q", version)
+	}
+
+	rec := &Record{Length: -1}
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("reading warc header: %w", err)
+		}
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("malformed warc header line %q", line)
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case strings.EqualFold(name, "WARC-Type"):
+			rec.Type = value
+		case strings.EqualFold(name, "Content-Length"):
+			rec.Length, err = strconv.ParseInt(value, 10, 64)
+			if err != nil || rec.Length < 0 {
+				return nil, fmt.Errorf("invalid warc content length %q", value)
+			}
+		default:
+			rec.Header = append(rec.Header, Field{Name: name, Value: value})
+		}
+	}
+	if rec.Length < 0 {
+		return nil, errors.New("warc record has no content length")
+	}
+	rec.Block = io.LimitReader(r, rec.Length)
+	return rec, nil
+}
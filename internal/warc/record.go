@@ -0,0 +1,124 @@
+// Package warc writes crawl captures as WARC/1.1 files with CDXJ indexes.
+package warc
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Version is the WARC version written in every record.
+const Version = "WARC/1.1"
+
+// Record types.
+const (
+	TypeWarcinfo = "warcinfo"
+	TypeRequest  = "request"
+	TypeResponse = "response"
+	TypeMetadata = "metadata"
+	TypeRevisit  = "revisit"
+)
+
+// Content types of record blocks.
+const (
+	ContentTypeHTTPRequest  = "application/http;msgtype=request"
+	ContentTypeHTTPResponse = "application/http;msgtype=response"
+	ContentTypeFields       = "application/warc-fields"
+)
+
+// ProfileIdenticalPayload is the WARC-Profile of revisit records for a
+// payload identical to an earlier capture.
+const ProfileIdenticalPayload = "http://netpreserve.org/warc/1.1/revisit/identical-payload-digest"
+
+// Field is one named WARC header field or warc-fields entry. Order is kept.
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Fields is an ordered list of header fields.
+type Fields []Field
+
+// Get returns the first value of name, or "".
+func (f Fields) Get(name string) string {
+	for _, field := range f {
+		if field.Name == name {
+			return field.Value
+		}
+	}
+	return ""
+}
+
+// Record is a WARC record. Block is read Length bytes; the writer computes
+// WARC-Block-Digest, Content-Length and the version line itself.
+type Record struct {
+	Type   string
+	Header Fields
+	Block  io.Reader
+	Length int64
+}
+
+// NewRecordID returns a fresh WARC-Record-ID.
+func NewRecordID() string {
+	return "<urn:uuid:" + uuid.NewString() + ">"
+}
+
+// FormatDate formats t as a WARC-Date.
+func FormatDate(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// Digest returns a new hash for WARC digests, which are SHA-1 in base32 as
+// most WARC tooling expects.
+func Digest() hash.Hash {
+	return sha1.New()
+}
+
+// FormatDigest formats a digest sum as a WARC digest field value.
+func FormatDigest(sum []byte) string {
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum)
+}
+
+// writeTo writes r's header and block to w, which is usually a gzip member
+// of its own. blockDigest is included when not empty.
+func (r *Record) writeTo(w io.Writer, blockDigest string) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s\r\nWARC-Type: %s\r\n", Version, r.Type)
+	for _, f := range r.Header {
+		fmt.Fprintf(bw, "%s: %s\r\n", f.Name, f.Value)
+	}
+	if blockDigest != "" {
+		fmt.Fprintf(bw, "WARC-Block-Digest: %s\r\n", blockDigest)
+	}
+	fmt.Fprintf(bw, "Content-Length: %s\r\n\r\n", strconv.FormatInt(r.Length, 10))
+	if r.Block != nil {
+		n, err := io.Copy(bw, io.LimitReader(r.Block, r.Length))
+		if err != nil {
+			return fmt.Errorf("writing record block: %w", err)
+		}
+		if n != r.Length {
+			return fmt.Errorf("record block is %d bytes, expected %d", n, r.Length)
+		}
+	}
+	bw.WriteString("\r\n\r\n")
+	return bw.Flush()
+}
+
+// FormatFields encodes fields as an application/warc-fields block.
+func FormatFields(fields Fields) []byte {
+	var b []byte
+	for _, f := range fields {
+		b = append(b, f.Name...)
+		b = append(b, ": "...)
+		b = append(b, f.Value...)
+		b = append(b, "\r\n"...)
+	}
+	return b
+}
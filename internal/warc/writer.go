@@ -0,0 +1,543 @@
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+	"github.com/theognis1002/nimbus-crawler/internal/storage"
+)
+
+// Content types of uploaded files.
+const (
+	contentTypeWARC = "application/warc"
+	contentTypeCDXJ = "text/x-cdxj"
+)
+
+// mimeRevisit is the CDXJ mime of revisit records, as pywb writes it.
+const mimeRevisit = "warc/revisit"
+
+// Capture is one HTTP fetch being recorded: the final exchange and any
+// redirects that led to it. The response payload is written to it as it is
+// read, then it is committed with Writer.Commit or dropped with Discard.
+// Payloads are spooled to a temp file.
+type Capture struct {
+	// Metadata is written in a metadata record after the response.
+	Metadata Fields
+
+	exchange
+	redirects []exchange
+	date      time.Time
+
+	spool       *os.File
+	length      int64
+	payloadHash hash.Hash
+	blockHash   hash.Hash
+}
+
+// exchange is one HTTP request and the header of its response.
+type exchange struct {
+	targetURI string
+	status    int
+	mime      string
+	request   []byte
+	header    []byte
+}
+
+func newExchange(resp *http.Response) exchange {
+	e := exchange{
+		targetURI: resp.Request.URL.String(),
+		status:    resp.StatusCode,
+		request:   requestBlock(resp),
+		header:    responseHeader(resp),
+	}
+	if mt, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil {
+		e.mime = mt
+	}
+	return e
+}
+
+// NewCapture starts recording resp, received at date. Redirects followed on
+// the way are recorded too, from resp.Request.Response, without the bodies
+// the client discarded. The payload is recorded as written, which for Go
+// clients is after transfer and content decoding.
+func NewCapture(resp *http.Response, date time.Time) (*Capture, error) {
+	spool, err := os.CreateTemp("", "nimbus-capture-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating capture spool: %w", err)
+	}
+	c := &Capture{
+		exchange:    newExchange(resp),
+		date:        date,
+		spool:       spool,
+		payloadHash: Digest(),
+		blockHash:   Digest(),
+	}
+	for hop := resp.Request.Response; hop != nil; hop = hop.Request.Response {
+		c.redirects = append(c.redirects, newExchange(hop))
+	}
+	slices.Reverse(c.redirects)
+	c.blockHash.Write(c.header)
+	return c, nil
+}
+
+// TargetURI returns the URI the capture was finally fetched from.
+func (c *Capture) TargetURI() string {
+	return c.targetURI
+}
+
+// Write appends p to the response payload.
+func (c *Capture) Write(p []byte) (int, error) {
+	n, err := c.spool.Write(p)
+	c.payloadHash.Write(p[:n])
+	c.blockHash.Write(p[:n])
+	c.length += int64(n)
+	return n, err
+}
+
+// Discard removes the capture's spool. It is safe to call more than once.
+func (c *Capture) Discard() {
+	if c.spool == nil {
+		return
+	}
+	c.spool.Close()
+	os.Remove(c.spool.Name())
+	c.spool = nil
+}
+
+// requestBlock reconstructs the HTTP request that produced resp. Headers
+// the transport adds itself, such as Accept-Encoding, are not known here.
+func requestBlock(resp *http.Response) []byte {
+	req := resp.Request
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s %s\r\n", req.Method, req.URL.RequestURI(), protocol(resp))
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	fmt.Fprintf(&b, "Host: %s\r\n", host)
+	req.Header.Write(&b)
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+// responseHeader formats resp's status line and headers.
+func responseHeader(resp *http.Response) []byte {
+	status := resp.Status
+	if status == "" {
+		status = strconv.Itoa(resp.StatusCode) + " " + http.StatusText(resp.StatusCode)
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s\r\n", protocol(resp), status)
+	resp.Header.Write(&b)
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+func protocol(resp *http.Response) string {
+	if resp.Proto == "" {
+		return "HTTP/1.1"
+	}
+	return resp.Proto
+}
+
+// Writer appends captures to size-bounded .warc.gz files, compressing each
+// record as its own gzip member so any record can be read from its offset.
+// A file is uploaded with its sorted CDXJ index once it reaches
+// MaxFileBytes, and on Close. It is safe for concurrent use.
+type Writer struct {
+	store  storage.ObjectStore
+	cfg    config.WARCConfig
+	index  CaptureIndex
+	logger *slog.Logger
+	host   string
+
+	mu      sync.Mutex
+	file    *warcFile
+	serial  int
+	uploads sync.WaitGroup
+}
+
+// NewWriter returns a Writer uploading to store, which should not compress
+// objects again. index, if not nil, turns payloads already captured into
+// revisit records.
+func NewWriter(store storage.ObjectStore, cfg config.WARCConfig, index CaptureIndex, logger *slog.Logger) *Writer {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+	// Replicas may share a hostname, so add a random suffix to file names.
+	host += "-" + uuid.NewString()[:8]
+	return &Writer{store: store, cfg: cfg, index: index, logger: logger, host: host}
+}
+
+// warcFile is a WARC file being written locally.
+type warcFile struct {
+	name string
+	f    *os.File
+	gz   *gzip.Writer
+	size int64
+	cdx  []CDXEntry
+	// claims are the payloads whose first capture is in the file.
+	claims []claimedPayload
+}
+
+// claimedPayload is a payload digest claimed in the capture index by the
+// response record recordID.
+type claimedPayload struct {
+	digest, recordID string
+}
+
+// write appends rec to the file as one gzip member and returns its offset
+// and compressed length.
+func (wf *warcFile) write(rec *Record, blockDigest string) (offset, length int64, err error) {
+	start := wf.size
+	wf.gz.Reset(wf.f)
+	err = rec.writeTo(wf.gz, blockDigest)
+	if err == nil {
+		err = wf.gz.Close()
+	}
+	var end int64
+	if err == nil {
+		end, err = wf.f.Seek(0, io.SeekCurrent)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("writing %s record to %s: %w", rec.Type, wf.name, err)
+	}
+	wf.size = end
+	return start, end - start, nil
+}
+
+// truncate cuts the file back to size, dropping a partly written capture.
+func (wf *warcFile) truncate(size int64) {
+	wf.f.Truncate(size)
+	wf.f.Seek(size, io.SeekStart)
+	wf.size = size
+}
+
+// pendingRecord is a record to write, with the CDXJ entry locating it if it
+// is a response or revisit.
+type pendingRecord struct {
+	rec    *Record
+	digest string
+	cdx    *CDXEntry
+	// claim is set on a response that holds the first capture of its payload.
+	claim *claimedPayload
+}
+
+// Commit writes c's redirects as request and response records, then the
+// final exchange as request, response or revisit, and metadata records, and
+// discards c. The final response is written as a revisit if its payload was
+// captured before.
+func (w *Writer) Commit(ctx context.Context, c *Capture) error {
+	defer c.Discard()
+	if _, err := c.spool.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("rewinding capture spool: %w", err)
+	}
+
+	date := FormatDate(c.date)
+	var records []pendingRecord
+	for _, hop := range c.redirects {
+		responseID := NewRecordID()
+		records = append(records, requestRecord(hop, date, responseID), pendingRecord{
+			rec: &Record{
+				Type: TypeResponse,
+				Header: Fields{
+					{"WARC-Record-ID", responseID},
+					{"WARC-Date", date},
+					{"WARC-Target-URI", hop.targetURI},
+					{"Content-Type", ContentTypeHTTPResponse},
+					// The client discarded the body when following the redirect.
+					{"WARC-Truncated", "unspecified"},
+				},
+				Block:  bytes.NewReader(hop.header),
+				Length: int64(len(hop.header)),
+			},
+			digest: blockDigest(hop.header),
+			cdx:    &CDXEntry{URL: hop.targetURI, Time: c.date, MIME: hop.mime, Status: hop.status},
+		})
+	}
+
+	payloadDigest := FormatDigest(c.payloadHash.Sum(nil))
+	responseID := NewRecordID()
+	response := pendingRecord{
+		rec: &Record{
+			Type: TypeResponse,
+			Header: Fields{
+				{"WARC-Record-ID", responseID},
+				{"WARC-Date", date},
+				{"WARC-Target-URI", c.targetURI},
+				{"Content-Type", ContentTypeHTTPResponse},
+				{"WARC-Payload-Digest", payloadDigest},
+			},
+			Block:  io.MultiReader(bytes.NewReader(c.header), c.spool),
+			Length: int64(len(c.header)) + c.length,
+		},
+		digest: FormatDigest(c.blockHash.Sum(nil)),
+		cdx:    &CDXEntry{URL: c.targetURI, Time: c.date, MIME: c.mime, Status: c.status, Digest: payloadDigest},
+	}
+	first, revisit, claimed := w.claim(ctx, c, payloadDigest, responseID)
+	if claimed {
+		response.claim = &claimedPayload{digest: payloadDigest, recordID: responseID}
+	}
+	if revisit {
+		response.rec.Type = TypeRevisit
+		response.rec.Header = append(response.rec.Header,
+			Field{"WARC-Profile", ProfileIdenticalPayload},
+			Field{"WARC-Refers-To", first.RecordID},
+			Field{"WARC-Refers-To-Target-URI", first.TargetURI},
+			Field{"WARC-Refers-To-Date", FormatDate(first.Date)},
+		)
+		response.rec.Block = bytes.NewReader(c.header)
+		response.rec.Length = int64(len(c.header))
+		response.digest = blockDigest(c.header)
+		response.cdx.MIME = mimeRevisit
+	}
+	records = append(records, requestRecord(c.exchange, date, responseID), response)
+
+	if block := FormatFields(c.Metadata); len(block) > 0 {
+		records = append(records, pendingRecord{
+			rec: &Record{
+				Type: TypeMetadata,
+				Header: Fields{
+					{"WARC-Record-ID", NewRecordID()},
+					{"WARC-Date", date},
+					{"WARC-Target-URI", c.targetURI},
+					{"WARC-Concurrent-To", responseID},
+					{"Content-Type", ContentTypeFields},
+				},
+				Block:  bytes.NewReader(block),
+				Length: int64(len(block)),
+			},
+			digest: blockDigest(block),
+		})
+	}
+
+	if err := w.write(ctx, c.date, records); err != nil {
+		if response.claim != nil {
+			w.release(ctx, []claimedPayload{*response.claim})
+		}
+		return err
+	}
+	return nil
+}
+
+// CommitFailure writes a metadata record for a fetch of targetURI, begun at
+// date, that got no response, with metadata describing the failure.
+func (w *Writer) CommitFailure(ctx context.Context, targetURI string, date time.Time, metadata Fields) error {
+	block := FormatFields(metadata)
+	return w.write(ctx, date, []pendingRecord{{
+		rec: &Record{
+			Type: TypeMetadata,
+			Header: Fields{
+				{"WARC-Record-ID", NewRecordID()},
+				{"WARC-Date", FormatDate(date)},
+				{"WARC-Target-URI", targetURI},
+				{"Content-Type", ContentTypeFields},
+			},
+			Block:  bytes.NewReader(block),
+			Length: int64(len(block)),
+		},
+		digest: blockDigest(block),
+	}})
+}
+
+// write appends records to the current file, all or none, starting a file
+// dated date if there is none, and uploads the file once it is full.
+func (w *Writer) write(ctx context.Context, date time.Time, records []pendingRecord) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		wf, err := w.newFile(date)
+		if err != nil {
+			return err
+		}
+		w.file = wf
+	}
+	wf := w.file
+
+	start, entries := wf.size, len(wf.cdx)
+	for _, p := range records {
+		offset, length, err := wf.write(p.rec, p.digest)
+		if err != nil {
+			wf.truncate(start)
+			wf.cdx = wf.cdx[:entries]
+			return err
+		}
+		if p.cdx != nil {
+			p.cdx.Offset, p.cdx.Length, p.cdx.Filename = offset, length, wf.name
+			wf.cdx = append(wf.cdx, *p.cdx)
+		}
+	}
+	for _, p := range records {
+		if p.claim != nil {
+			wf.claims = append(wf.claims, *p.claim)
+		}
+	}
+
+	if wf.size >= w.cfg.MaxFileBytes {
+		w.file = nil
+		w.uploads.Add(1)
+		go func() {
+			defer w.uploads.Done()
+			if err := w.upload(context.WithoutCancel(ctx), wf); err != nil {
+				w.logger.Error("failed to upload warc file", "file", wf.name, "error", err)
+			}
+		}()
+	}
+	return nil
+}
+
+// requestRecord returns the request record of e, concurrent to its response.
+func requestRecord(e exchange, date, responseID string) pendingRecord {
+	return pendingRecord{
+		rec: &Record{
+			Type: TypeRequest,
+			Header: Fields{
+				{"WARC-Record-ID", NewRecordID()},
+				{"WARC-Date", date},
+				{"WARC-Target-URI", e.targetURI},
+				{"WARC-Concurrent-To", responseID},
+				{"Content-Type", ContentTypeHTTPRequest},
+			},
+			Block:  bytes.NewReader(e.request),
+			Length: int64(len(e.request)),
+		},
+		digest: blockDigest(e.request),
+	}
+}
+
+// claim checks whether c's payload was captured before, and reports whether
+// it claimed the payload for recordID otherwise. Empty payloads are always
+// written in full. If the index fails the response is written in full too;
+// a duplicate payload costs space, not correctness.
+func (w *Writer) claim(ctx context.Context, c *Capture, digest, recordID string) (first CaptureRef, revisit, claimed bool) {
+	if w.index == nil || c.length == 0 {
+		return CaptureRef{}, false, false
+	}
+	ref := CaptureRef{RecordID: recordID, TargetURI: c.targetURI, Date: c.date}
+	first, seen, err := w.index.Claim(ctx, digest, ref)
+	if err != nil {
+		w.logger.Warn("failed to check warc payload digest, writing full response", "url", c.targetURI, "error", err)
+		return CaptureRef{}, false, false
+	}
+	return first, seen, !seen
+}
+
+// release gives up claims whose records did not reach storage, so that
+// later captures of their payloads are not revisits of missing records.
+func (w *Writer) release(ctx context.Context, claims []claimedPayload) {
+	ctx = context.WithoutCancel(ctx)
+	for _, c := range claims {
+		if err := w.index.Release(ctx, c.digest, c.recordID); err != nil {
+			w.logger.Error("failed to release warc payload digest", "digest", c.digest, "record_id", c.recordID, "error", err)
+		}
+	}
+}
+
+// blockDigest returns the WARC digest of a block held in memory.
+func blockDigest(block []byte) string {
+	h := Digest()
+	h.Write(block)
+	return FormatDigest(h.Sum(nil))
+}
+
+// newFile starts a local WARC file with its warcinfo record.
+func (w *Writer) newFile(date time.Time) (*warcFile, error) {
+	w.serial++
+	name := fmt.Sprintf("%s-%s-%05d-%s.warc.gz", w.cfg.Prefix, date.UTC().Format(cdxTimestampLayout), w.serial, w.host)
+	f, err := os.CreateTemp("", "nimbus-*.warc.gz")
+	if err != nil {
+		return nil, fmt.Errorf("creating warc file: %w", err)
+	}
+	wf := &warcFile{name: name, f: f, gz: gzip.NewWriter(f)}
+
+	info := FormatFields(Fields{
+		{"software", "nimbus-crawler"},
+		{"format", "WARC File Format 1.1"},
+		{"conformsTo", "https://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/"},
+		{"hostname", w.host},
+		{"isPartOf", w.cfg.Prefix},
+	})
+	rec := &Record{
+		Type: TypeWarcinfo,
+		Header: Fields{
+			{"WARC-Record-ID", NewRecordID()},
+			{"WARC-Date", FormatDate(date)},
+			{"WARC-Filename", name},
+			{"Content-Type", ContentTypeFields},
+		},
+		Block:  bytes.NewReader(info),
+		Length: int64(len(info)),
+	}
+	if _, _, err := wf.write(rec, blockDigest(info)); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return wf, nil
+}
+
+// upload stores a finished WARC file and its CDXJ index. The local file is
+// kept if the upload fails so it can be recovered by hand, and the payloads
+// first captured in it are released from the index.
+func (w *Writer) upload(ctx context.Context, wf *warcFile) error {
+	defer wf.f.Close()
+	if _, err := wf.f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("rewinding warc file: %w", err)
+	}
+	err := w.store.Put(ctx, w.cfg.Bucket, wf.name, wf.f, wf.size, storage.PutOptions{ContentType: contentTypeWARC})
+	if err != nil {
+		w.release(ctx, wf.claims)
+		return fmt.Errorf("uploading %s, kept at %s: %w", wf.name, wf.f.Name(), err)
+	}
+	os.Remove(wf.f.Name())
+
+	slices.SortFunc(wf.cdx, compareCDX)
+	var index bytes.Buffer
+	for _, e := range wf.cdx {
+		index.WriteString(e.String())
+		index.WriteByte('\n')
+	}
+	key := CDXJKey(wf.name)
+	if err := w.store.Put(ctx, w.cfg.Bucket, key, &index, int64(index.Len()), storage.PutOptions{ContentType: contentTypeCDXJ}); err != nil {
+		return fmt.Errorf("uploading %s: %w", key, err)
+	}
+	w.logger.Info("uploaded warc file", "file", wf.name, "bytes", wf.size, "captures", len(wf.cdx))
+	return nil
+}
+
+// CDXJKey returns the key of the CDXJ index of the WARC file at key.
+func CDXJKey(key string) string {
+	return strings.TrimSuffix(key, ".warc.gz") + ".cdxj"
+}
+
+// Close uploads the current file, if any, and waits for pending uploads.
+func (w *Writer) Close(ctx context.Context) error {
+	w.mu.Lock()
+	wf := w.file
+	w.file = nil
+	w.mu.Unlock()
+
+	var err error
+	if wf != nil {
+		err = w.upload(ctx, wf)
+	}
+	w.uploads.Wait()
+	return err
+}
@@ -0,0 +1,259 @@
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theognis1002/nimbus-crawler/internal/config"
+	"github.com/theognis1002/nimbus-crawler/internal/storage"
+)
+
+const testBucket = "warc"
+
+func testResponse(t *testing.T, rawURL string, status int) *http.Response {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &http.Request{Method: http.MethodGet, URL: u, Header: http.Header{"User-Agent": {"test"}}}
+	return &http.Response{
+		StatusCode: status,
+		Proto:      "HTTP/1.1",
+		Header:     http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+		Request:    req,
+	}
+}
+
+func capture(t *testing.T, w *Writer, rawURL, body string) {
+	t.Helper()
+	c, err := NewCapture(testResponse(t, rawURL, http.StatusOK), time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Metadata = Fields{{"hopsFromSeed", "1"}}
+	io.WriteString(c, body)
+	if err := w.Commit(context.Background(), c); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+}
+
+func newTestWriter(store storage.ObjectStore, maxBytes int64) *Writer {
+	cfg := config.WARCConfig{Bucket: testBucket, Prefix: "test", MaxFileBytes: maxBytes}
+	return NewWriter(store, cfg, NewMemoryIndex(), slog.New(slog.DiscardHandler))
+}
+
+// warcFiles returns the uploaded WARC files by key.
+func warcFiles(t *testing.T, store storage.ObjectStore) map[string][]byte {
+	t.Helper()
+	files := make(map[string][]byte)
+	err := store.Walk(context.Background(), testBucket, func(key string) error {
+		if strings.HasSuffix(key, ".warc.gz") {
+			data, err := storage.ReadObject(context.Background(), store, testBucket, key)
+			files[key] = data
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+var warcTypeRe = regexp.MustCompile(`(?m)^WARC-Type: (\w+)\r$`)
+
+func recordTypes(t *testing.T, data []byte) []string {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, m := range warcTypeRe.FindAllSubmatch(plain, -1) {
+		types = append(types, string(m[1]))
+	}
+	return types
+}
+
+func TestWriterRecordsAndRevisits(t *testing.T) {
+	t.Parallel()
+
+	store := storage.NewMemoryStore()
+	w := newTestWriter(store, 1<<20)
+	capture(t, w, "https://example.com/a", "<html>same</html>")
+	capture(t, w, "https://example.com/b", "<html>same</html>")
+	if err := w.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	files := warcFiles(t, store)
+	if len(files) != 1 {
+		t.Fatalf("got %d warc files, want 1", len(files))
+	}
+	for key, data := range files {
+		got := strings.Join(recordTypes(t, data), ",")
+		want := "warcinfo,request,response,metadata,request,revisit,metadata"
+		if got != want {
+			t.Errorf("record types = %s, want %s", got, want)
+		}
+
+		index, err := storage.ReadObject(context.Background(), store, testBucket, CDXJKey(key))
+		if err != nil {
+			t.Fatalf("reading cdxj: %v", err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(index)), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("got %d cdxj lines, want 2", len(lines))
+		}
+		for i, wantType := range []string{"response", "revisit"} {
+			checkCDXJLine(t, data, lines[i], wantType)
+		}
+	}
+}
+
+// checkCDXJLine checks that a CDXJ line locates a single gzip member holding
+// a record of wantType.
+func checkCDXJLine(t *testing.T, data []byte, line, wantType string) {
+	t.Helper()
+	_, js, ok := strings.Cut(line, " {")
+	if !ok {
+		t.Fatalf("malformed cdxj line %q", line)
+	}
+	var f cdxjFields
+	if err := json.Unmarshal([]byte("{"+js), &f); err != nil {
+		t.Fatal(err)
+	}
+	offset, _ := strconv.ParseInt(f.Offset, 10, 64)
+	length, _ := strconv.ParseInt(f.Length, 10, 64)
+	zr, err := gzip.NewReader(bytes.NewReader(data[offset : offset+length]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr.Multistream(false)
+	rec, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(rec, []byte(Version+"\r\nWARC-Type: "+wantType+"\r\n")) {
+		t.Errorf("record at offset %d starts %q, want a %s record", offset, rec[:min(len(rec), 40)], wantType)
+	}
+	if !bytes.HasSuffix(rec, []byte("\r\n\r\n")) {
+		t.Errorf("record at offset %d is not terminated", offset)
+	}
+	if wantType == TypeResponse && !bytes.Contains(rec, []byte("HTTP/1.1 200 OK\r\nContent-Type: text/html; charset=utf-8\r\n\r\n<html>same</html>")) {
+		t.Errorf("response record missing http status line, headers or payload:\n%s", rec)
+	}
+}
+
+func TestWriterCommitFailure(t *testing.T) {
+	t.Parallel()
+
+	store := storage.NewMemoryStore()
+	w := newTestWriter(store, 1<<20)
+	date := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := w.CommitFailure(context.Background(), "https://example.com/down", date, Fields{{"fetchError", "connection refused"}}); err != nil {
+		t.Fatalf("CommitFailure: %v", err)
+	}
+	if err := w.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	for key, data := range warcFiles(t, store) {
+		if got := strings.Join(recordTypes(t, data), ","); got != "warcinfo,metadata" {
+			t.Errorf("record types = %s, want warcinfo,metadata", got)
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, _ := io.ReadAll(zr)
+		if !bytes.Contains(plain, []byte("WARC-Target-URI: https://example.com/down\r\n")) || !bytes.Contains(plain, []byte("fetchError: connection refused\r\n")) {
+			t.Errorf("metadata record missing target or error:\n%s", plain)
+		}
+		// Failures have no response to replay, so nothing is indexed.
+		index, err := storage.ReadObject(context.Background(), store, testBucket, CDXJKey(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(index) != 0 {
+			t.Errorf("cdxj = %q, want empty", index)
+		}
+	}
+}
+
+func TestWriterRollsFilesBySize(t *testing.T) {
+	t.Parallel()
+
+	store := storage.NewMemoryStore()
+	w := newTestWriter(store, 1)
+	capture(t, w, "https://example.com/a", "one")
+	capture(t, w, "https://example.com/b", "two")
+	if err := w.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	files := warcFiles(t, store)
+	if len(files) != 2 {
+		t.Fatalf("got %d warc files, want 2", len(files))
+	}
+	for key, data := range files {
+		if got := recordTypes(t, data); got[0] != TypeWarcinfo || len(got) != 4 {
+			t.Errorf("%s record types = %v, want warcinfo and one capture", key, got)
+		}
+	}
+}
+
+// failingStore fails the upload of WARC files.
+type failingStore struct {
+	storage.ObjectStore
+}
+
+func (s failingStore) Put(ctx context.Context, bucket, key string, r io.Reader, size int64, opts storage.PutOptions) error {
+	if strings.HasSuffix(key, ".warc.gz") {
+		return errors.New("upload failed")
+	}
+	return s.ObjectStore.Put(ctx, bucket, key, r, size, opts)
+}
+
+func TestWriterReleasesClaimsOfFailedUpload(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.WARCConfig{Bucket: testBucket, Prefix: "test", MaxFileBytes: 1 << 20}
+	index := NewMemoryIndex()
+	logger := slog.New(slog.DiscardHandler)
+
+	failed := NewWriter(failingStore{storage.NewMemoryStore()}, cfg, index, logger)
+	capture(t, failed, "https://example.com/a", "<html>same</html>")
+	if err := failed.Close(context.Background()); err == nil {
+		t.Fatal("Close succeeded, want upload error")
+	}
+
+	store := storage.NewMemoryStore()
+	w := NewWriter(store, cfg, index, logger)
+	capture(t, w, "https://example.com/b", "<html>same</html>")
+	if err := w.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	for _, data := range warcFiles(t, store) {
+		if got := strings.Join(recordTypes(t, data), ","); got != "warcinfo,request,response,metadata" {
+			t.Errorf("record types = %s, want the payload written in full", got)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package wayback
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/theognis1002/nimbus-crawler/internal/database/models"
+)
+
+// Capture is one stored version of a page.
+type Capture struct {
+	Time time.Time
+	// Link is the stored HTML's "bucket/key".
+	Link string
+}
+
+// Index looks up what the archive holds.
+type Index interface {
+	// Captures returns the captures of rawURL, oldest first.
+	Captures(ctx context.Context, rawURL string) ([]Capture, error)
+	// Domains pages through domains with stored pages.
+	Domains(ctx context.Context, after string, limit int) ([]models.DomainSummary, error)
+	// DomainURLs pages through the stored URLs of a domain.
+	DomainURLs(ctx context.Context, domain, after string, limit int) ([]models.ArchivedURL, error)
+}
+
+// PostgresIndex finds captures in the urls and url_snapshots tables.
+type PostgresIndex struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresIndex(pool *pgxpool.Pool) *PostgresIndex {
+	return &PostgresIndex{pool: pool}
+}
+
+// Captures returns every snapshot of rawURL. URLs crawled without
+// content-addressed storage have no snapshots, only the page last stored
+// for them in urls.
+func (i *PostgresIndex) Captures(ctx context.Context, rawURL string) ([]Capture, error) {
+	snapshots, err := models.ListSnapshots(ctx, i.pool, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) > 0 {
+		captures := make([]Capture, len(snapshots))
+		for j, s := range snapshots {
+			captures[j] = Capture{Time: s.FetchedAt, Link: s.S3HTMLLink}
+		}
+		slices.Reverse(captures)
+		return captures, nil
+	}
+
+	rec, err := models.GetURLByURL(ctx, i.pool, rawURL)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if rec.S3HTMLLink == nil {
+		return nil, nil
+	}
+	t := rec.UpdatedAt
+	if rec.LastCrawlTime != nil {
+		t = *rec.LastCrawlTime
+	}
+	return []Capture{{Time: t, Link: *rec.S3HTMLLink}}, nil
+}
+
+func (i *PostgresIndex) Domains(ctx context.Context, after string, limit int) ([]models.DomainSummary, error) {
+	return models.ListArchivedDomains(ctx, i.pool, after, limit)
+}
+
+func (i *PostgresIndex) DomainURLs(ctx context.Context, domain, after string, limit int) ([]models.ArchivedURL, error) {
+	return models.ListArchivedURLs(ctx, i.pool, domain, after, limit)
+}
+
+var _ Index = (*PostgresIndex)(nil)
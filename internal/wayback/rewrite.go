@@ -0,0 +1,174 @@
+package wayback
+
+import (
+	"bytes"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// urlAttrs are the attributes holding a single URL.
+var urlAttrs = map[string]bool{
+	"href":       true,
+	"src":        true,
+	"action":     true,
+	"formaction": true,
+	"poster":     true,
+	"background": true,
+	"cite":       true,
+	"data":       true,
+}
+
+var (
+	cssURLRe    = regexp.MustCompile(`(?i)url\(\s*(['"]?)([^'")]*?)(['"]?)\s*\)`)
+	cssImportRe = regexp.MustCompile(`(?i)(@import\s+)(['"])([^'"]+)(['"])`)
+	refreshRe   = regexp.MustCompile(`(?i)^(\s*\d+\s*;\s*url\s*=\s*)(['"]?)(.*?)(['"]?\s*)$`)
+)
+
+// rewriter points the URLs of an archived page at the archive.
+type rewriter struct {
+	base *url.URL
+	// archived returns the archive URL serving an absolute URL.
+	archived func(target string) string
+}
+
+// rewriteHTML copies an archived page from r to w with every link, image,
+// stylesheet, form and CSS reference rewritten by archived, so navigation
+// stays inside the archive. banner is inserted at the start of the body.
+// Markup that needs no rewriting is copied byte for byte.
+func rewriteHTML(w io.Writer, r io.Reader, pageURL *url.URL, archived func(string) string, banner []byte) error {
+	rw := &rewriter{base: pageURL, archived: archived}
+	z := html.NewTokenizer(r)
+	inStyle := false
+	for {
+		tt := z.Next()
+		var err error
+		switch tt {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return nil
+			}
+			return z.Err()
+		case html.StartTagToken, html.SelfClosingTagToken:
+			raw := bytes.Clone(z.Raw())
+			tok := z.Token()
+			if tok.DataAtom == atom.Base {
+				rw.setBase(tok)
+			}
+			if rw.rewriteAttrs(&tok) {
+				_, err = io.WriteString(w, tok.String())
+			} else {
+				_, err = w.Write(raw)
+			}
+			if err == nil && tok.DataAtom == atom.Body && banner != nil {
+				_, err = w.Write(banner)
+			}
+			inStyle = tok.DataAtom == atom.Style && tt == html.StartTagToken
+		case html.TextToken:
+			if inStyle {
+				_, err = io.WriteString(w, rw.rewriteCSS(string(z.Raw())))
+			} else {
+				_, err = w.Write(z.Raw())
+			}
+		default:
+			inStyle = false
+			_, err = w.Write(z.Raw())
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// setBase applies a <base href> to the URLs that follow it.
+func (rw *rewriter) setBase(tok html.Token) {
+	for _, a := range tok.Attr {
+		if a.Key == "href" {
+			if u, err := rw.base.Parse(strings.TrimSpace(a.Val)); err == nil {
+				rw.base = u
+			}
+		}
+	}
+}
+
+// rewriteAttrs rewrites the URL-bearing attributes of tok and reports
+// whether any changed.
+func (rw *rewriter) rewriteAttrs(tok *html.Token) bool {
+	changed := false
+	for i, a := range tok.Attr {
+		var val string
+		switch {
+		case urlAttrs[a.Key]:
+			val = rw.rewriteURL(a.Val)
+		case a.Key == "srcset":
+			val = rw.rewriteSrcset(a.Val)
+		case a.Key == "style":
+			val = rw.rewriteCSS(a.Val)
+		case a.Key == "content" && tok.DataAtom == atom.Meta && isRefresh(tok):
+			val = refreshRe.ReplaceAllStringFunc(a.Val, func(m string) string {
+				p := refreshRe.FindStringSubmatch(m)
+				return p[1] + p[2] + rw.rewriteURL(p[3]) + p[4]
+			})
+		default:
+			continue
+		}
+		if val != a.Val {
+			tok.Attr[i].Val = val
+			changed = true
+		}
+	}
+	return changed
+}
+
+func isRefresh(tok *html.Token) bool {
+	for _, a := range tok.Attr {
+		if a.Key == "http-equiv" && strings.EqualFold(a.Val, "refresh") {
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteURL returns the archive URL for ref. Fragments, scripts, data and
+// other non-HTTP URLs are left alone.
+func (rw *rewriter) rewriteURL(ref string) string {
+	trimmed := strings.TrimSpace(ref)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return ref
+	}
+	u, err := rw.base.Parse(trimmed)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ref
+	}
+	return rw.archived(u.String())
+}
+
+// rewriteSrcset rewrites each candidate of a srcset attribute.
+func (rw *rewriter) rewriteSrcset(srcset string) string {
+	candidates := strings.Split(srcset, ",")
+	for i, c := range candidates {
+		fields := strings.Fields(c)
+		if len(fields) == 0 {
+			continue
+		}
+		fields[0] = rw.rewriteURL(fields[0])
+		candidates[i] = strings.Join(fields, " ")
+	}
+	return strings.Join(candidates, ", ")
+}
+
+// rewriteCSS rewrites url() and @import references in a stylesheet.
+func (rw *rewriter) rewriteCSS(css string) string {
+	css = cssURLRe.ReplaceAllStringFunc(css, func(m string) string {
+		p := cssURLRe.FindStringSubmatch(m)
+		return "url(" + p[1] + rw.rewriteURL(p[2]) + p[3] + ")"
+	})
+	return cssImportRe.ReplaceAllStringFunc(css, func(m string) string {
+		p := cssImportRe.FindStringSubmatch(m)
+		return p[1] + p[2] + rw.rewriteURL(p[3]) + p[4]
+	})
+}
@@ -0,0 +1,78 @@
+package wayback
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRewriteHTML(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "relative link",
+			in:   `<a href="/about">About</a>`,
+			want: `<a href="/web/T/https://example.com/about">About</a>`,
+		},
+		{
+			name: "absolute image",
+			in:   `<img src="https://cdn.example.com/a.png" alt="x"/>`,
+			want: `<img src="/web/T/https://cdn.example.com/a.png" alt="x"/>`,
+		},
+		{
+			name: "srcset",
+			in:   `<img srcset="a.png 1x, b.png 2x">`,
+			want: `<img srcset="/web/T/https://example.com/dir/a.png 1x, /web/T/https://example.com/dir/b.png 2x">`,
+		},
+		{
+			name: "stylesheet and style block",
+			in:   `<link rel="stylesheet" href="s.css"><style>@import "x.css"; body { background: url('bg.png') }</style>`,
+			want: `<link rel="stylesheet" href="/web/T/https://example.com/dir/s.css"><style>@import "/web/T/https://example.com/dir/x.css"; body { background: url('/web/T/https://example.com/dir/bg.png') }</style>`,
+		},
+		{
+			name: "inline style",
+			in:   `<div style="background:url(bg.png)">x</div>`,
+			want: `<div style="background:url(/web/T/https://example.com/dir/bg.png)">x</div>`,
+		},
+		{
+			name: "base href",
+			in:   `<base href="https://other.example/root/"><a href="p">p</a>`,
+			want: `<base href="/web/T/https://other.example/root/"><a href="/web/T/https://other.example/root/p">p</a>`,
+		},
+		{
+			name: "meta refresh",
+			in:   `<meta http-equiv="refresh" content="0; url=/next">`,
+			want: `<meta http-equiv="refresh" content="0; url=/web/T/https://example.com/next">`,
+		},
+		{
+			name: "left alone",
+			in:   `<a href="#top">top</a><a href="mailto:a@b.c">m</a><a href="javascript:void(0)">j</a><p>text &amp; more</p>`,
+			want: `<a href="#top">top</a><a href="mailto:a@b.c">m</a><a href="javascript:void(0)">j</a><p>text &amp; more</p>`,
+		},
+		{
+			name: "banner after body",
+			in:   `<html><body class="x"><p>hi</p></body></html>`,
+			want: `<html><body class="x">[banner]<p>hi</p></body></html>`,
+		},
+	}
+
+	page, _ := url.Parse("https://example.com/dir/page.html")
+	archived := func(u string) string { return "/web/T/" + u }
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var out strings.Builder
+			if err := rewriteHTML(&out, strings.NewReader(tt.in), page, archived, []byte("[banner]")); err != nil {
+				t.Fatalf("rewriteHTML: %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
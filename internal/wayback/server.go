@@ -0,0 +1,310 @@
+// Package wayback serves stored pages for browsing, Wayback Machine style.
+package wayback
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/theognis1002/nimbus-crawler/internal/database/models"
+	"github.com/theognis1002/nimbus-crawler/internal/storage"
+)
+
+const (
+	// pageSize is the number of domains or URLs listed per page.
+	pageSize = 100
+	// rawModifier after a timestamp serves a capture without rewriting.
+	rawModifier = "id_"
+	// latestTimestamp selects the latest capture.
+	latestTimestamp = "2"
+)
+
+// Server serves the archive:
+//
+//	/web/<timestamp>/<url>  the capture of url current at timestamp, rewritten
+//	/web/<timestamp>id_/<url>  the same capture as stored
+//	/web/*/<url>  a calendar of url's captures
+//	/domains  the archived domains
+//	/domains/<domain>  the archived URLs of a domain
+//
+// Timestamps may be truncated ("2026" for the last capture of 2026 or
+// before); requests are redirected to the exact timestamp of the capture
+// served.
+type Server struct {
+	index  Index
+	store  storage.ObjectStore
+	logger *slog.Logger
+	mux    *http.ServeMux
+}
+
+func NewServer(index Index, store storage.ObjectStore, logger *slog.Logger) *Server {
+	s := &Server{index: index, store: store, logger: logger, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /{$}", s.handleIndex)
+	s.mux.HandleFunc("GET /domains", s.handleDomains)
+	s.mux.HandleFunc("GET /domains/{domain}", s.handleDomain)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Archived URLs contain "//", which ServeMux would clean away.
+	if strings.HasPrefix(r.URL.Path, "/web/") {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleWeb(w, r)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// webPath returns the archive path of target at timestamp ts.
+func webPath(ts, target string) string {
+	return "/web/" + ts + "/" + target
+}
+
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if target := strings.TrimSpace(r.URL.Query().Get("url")); target != "" {
+		redirect(w, webPath("*", target))
+		return
+	}
+	s.render(w, http.StatusOK, "index", nil)
+}
+
+// parseWebPath splits a /web/ request into its timestamp, whether it asks
+// for the raw capture, and the archived URL, which keeps the request's query.
+func parseWebPath(r *http.Request) (ts string, raw bool, target string) {
+	ts, target, _ = strings.Cut(strings.TrimPrefix(r.URL.EscapedPath(), "/web/"), "/")
+	ts, raw = strings.CutSuffix(ts, rawModifier)
+	if unescaped, err := url.PathUnescape(target); err == nil {
+		target = unescaped
+	}
+	// Clients and proxies may collapse "https://" to "https:/".
+	for _, scheme := range []string{"http:/", "https:/"} {
+		if strings.HasPrefix(target, scheme) && !strings.HasPrefix(target, scheme+"/") {
+			target = scheme + "/" + strings.TrimPrefix(target, scheme)
+		}
+	}
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	return ts, raw, target
+}
+
+func (s *Server) handleWeb(w http.ResponseWriter, r *http.Request) {
+	ts, raw, target := parseWebPath(r)
+	if target == "" {
+		redirect(w, "/")
+		return
+	}
+
+	target, captures, err := s.lookup(r, target)
+	if err != nil {
+		s.logger.Error("failed to look up captures", "url", target, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if len(captures) == 0 {
+		s.render(w, http.StatusNotFound, "notArchived", target)
+		return
+	}
+	if ts == "*" || ts == "" {
+		s.render(w, http.StatusOK, "captures", newCalendar(target, captures))
+		return
+	}
+
+	at := time.Now()
+	if ts != latestTimestamp {
+		if at, err = parseTimestamp(ts); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	c := captureAt(captures, at)
+	if exact := formatTimestamp(c.Time); exact != ts {
+		if raw {
+			exact += rawModifier
+		}
+		redirect(w, webPath(exact, target))
+		return
+	}
+	s.serveCapture(w, r, target, c, raw)
+}
+
+// lookup finds the captures of target. URLs given without a scheme are
+// looked up as https, then http.
+func (s *Server) lookup(r *http.Request, target string) (string, []Capture, error) {
+	candidates := []string{target}
+	if !strings.Contains(target, "://") {
+		candidates = []string{"https://" + target, "http://" + target}
+	}
+	for _, c := range candidates {
+		captures, err := s.index.Captures(r.Context(), c)
+		if err != nil || len(captures) > 0 {
+			return c, captures, err
+		}
+	}
+	return candidates[0], nil, nil
+}
+
+// captureAt returns the capture current at t: the latest made at or before
+// t, or the first if all were made after it.
+func captureAt(captures []Capture, t time.Time) Capture {
+	best := captures[0]
+	for _, c := range captures[1:] {
+		if !c.Time.After(t) {
+			best = c
+		}
+	}
+	return best
+}
+
+// redirect redirects to an archive path. http.Redirect would clean the "//"
+// out of the archived URL.
+func redirect(w http.ResponseWriter, path string) {
+	w.Header().Set("Location", path)
+	w.WriteHeader(http.StatusFound)
+}
+
+func (s *Server) serveCapture(w http.ResponseWriter, r *http.Request, target string, c Capture, raw bool) {
+	bucket, key, ok := storage.SplitLink(c.Link)
+	if !ok {
+		s.logger.Error("invalid capture link", "url", target, "link", c.Link)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	body, info, err := s.store.Get(r.Context(), bucket, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		s.render(w, http.StatusNotFound, "notArchived", target)
+		return
+	}
+	if err != nil {
+		s.logger.Error("failed to get capture", "url", target, "link", c.Link, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = "text/html"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Memento-Datetime", c.Time.UTC().Format(http.TimeFormat))
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	if raw {
+		if _, err := io.Copy(w, body); err != nil {
+			s.logger.Warn("failed to serve capture", "url", target, "error", err)
+		}
+		return
+	}
+
+	pageURL, err := url.Parse(target)
+	if err != nil {
+		http.Error(w, "invalid url", http.StatusBadRequest)
+		return
+	}
+	var banner bytes.Buffer
+	if err := templates.ExecuteTemplate(&banner, "banner", struct {
+		URL  string
+		Time time.Time
+	}{target, c.Time}); err != nil {
+		s.logger.Error("failed to render banner", "error", err)
+	}
+	ts := formatTimestamp(c.Time)
+	archived := func(u string) string { return webPath(ts, u) }
+	if err := rewriteHTML(w, body, pageURL, archived, banner.Bytes()); err != nil {
+		s.logger.Warn("failed to serve capture", "url", target, "error", err)
+	}
+}
+
+func (s *Server) handleDomains(w http.ResponseWriter, r *http.Request) {
+	domains, err := s.index.Domains(r.Context(), r.URL.Query().Get("after"), pageSize)
+	if err != nil {
+		s.logger.Error("failed to list domains", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	var next string
+	if len(domains) == pageSize {
+		next = domains[len(domains)-1].Domain
+	}
+	s.render(w, http.StatusOK, "domains", struct {
+		Domains []models.DomainSummary
+		Next    string
+	}{domains, next})
+}
+
+func (s *Server) handleDomain(w http.ResponseWriter, r *http.Request) {
+	domain := r.PathValue("domain")
+	urls, err := s.index.DomainURLs(r.Context(), domain, r.URL.Query().Get("after"), pageSize)
+	if err != nil {
+		s.logger.Error("failed to list domain urls", "domain", domain, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	var next string
+	if len(urls) == pageSize {
+		next = urls[len(urls)-1].URL
+	}
+	s.render(w, http.StatusOK, "domain", struct {
+		Domain string
+		URLs   []models.ArchivedURL
+		Next   string
+	}{domain, urls, next})
+}
+
+func (s *Server) render(w http.ResponseWriter, status int, name string, data any) {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		s.logger.Error("failed to render page", "page", name, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+// calendar groups a URL's captures by year and month for display.
+type calendar struct {
+	URL         string
+	Count       int
+	First, Last time.Time
+	Years       []calendarYear
+}
+
+type calendarYear struct {
+	Year   int
+	Months []calendarMonth
+}
+
+type calendarMonth struct {
+	Month    time.Month
+	Captures []Capture
+}
+
+func newCalendar(target string, captures []Capture) calendar {
+	cal := calendar{URL: target, Count: len(captures), First: captures[0].Time, Last: captures[len(captures)-1].Time}
+	for _, c := range captures {
+		t := c.Time.UTC()
+		if n := len(cal.Years); n == 0 || cal.Years[n-1].Year != t.Year() {
+			cal.Years = append(cal.Years, calendarYear{Year: t.Year()})
+		}
+		y := &cal.Years[len(cal.Years)-1]
+		if n := len(y.Months); n == 0 || y.Months[n-1].Month != t.Month() {
+			y.Months = append(y.Months, calendarMonth{Month: t.Month()})
+		}
+		m := &y.Months[len(y.Months)-1]
+		m.Captures = append(m.Captures, c)
+	}
+	return cal
+}
@@ -0,0 +1,92 @@
+package wayback
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theognis1002/nimbus-crawler/internal/database/models"
+	"github.com/theognis1002/nimbus-crawler/internal/storage"
+)
+
+type fakeIndex map[string][]Capture
+
+func (f fakeIndex) Captures(ctx context.Context, rawURL string) ([]Capture, error) {
+	return f[rawURL], nil
+}
+
+func (f fakeIndex) Domains(ctx context.Context, after string, limit int) ([]models.DomainSummary, error) {
+	return []models.DomainSummary{{Domain: "example.com", ArchivedURLs: 1}}, nil
+}
+
+func (f fakeIndex) DomainURLs(ctx context.Context, domain, after string, limit int) ([]models.ArchivedURL, error) {
+	return []models.ArchivedURL{{URL: "https://example.com/"}}, nil
+}
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	store := storage.NewMemoryStore()
+	ctx := context.Background()
+	for key, body := range map[string]string{
+		"old": `<html><body><a href="/next">next</a></body></html>`,
+		"new": `<html><body>new</body></html>`,
+	} {
+		if err := store.Put(ctx, "html", key, strings.NewReader(body), int64(len(body)), storage.PutOptions{ContentType: "text/html"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	index := fakeIndex{"https://example.com/": {
+		{Time: time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC), Link: "html/old"},
+		{Time: time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC), Link: "html/new"},
+	}}
+	return NewServer(index, store, slog.New(slog.DiscardHandler))
+}
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		path         string
+		wantStatus   int
+		wantLocation string
+		wantBody     string
+	}{
+		{name: "exact capture", path: "/web/20250501120000/https://example.com/", wantStatus: 200, wantBody: `<a href="/web/20250501120000/https://example.com/next">`},
+		{name: "raw capture", path: "/web/20250501120000id_/https://example.com/", wantStatus: 200, wantBody: `<html><body><a href="/next">next</a></body></html>`},
+		{name: "truncated timestamp", path: "/web/2025/https://example.com/", wantStatus: 302, wantLocation: "/web/20250501120000/https://example.com/"},
+		{name: "latest", path: "/web/2/https://example.com/", wantStatus: 302, wantLocation: "/web/20260201120000/https://example.com/"},
+		{name: "collapsed scheme", path: "/web/20260201120000/https:/example.com/", wantStatus: 200, wantBody: "new"},
+		{name: "no scheme", path: "/web/*/example.com/", wantStatus: 200, wantBody: "2 capture(s)"},
+		{name: "calendar", path: "/web/*/https://example.com/", wantStatus: 200, wantBody: `href="/web/20260201120000/https://example.com/"`},
+		{name: "not archived", path: "/web/2026/https://missing.example/", wantStatus: 404, wantBody: "has not been archived"},
+		{name: "bad timestamp", path: "/web/20x6/https://example.com/", wantStatus: 400},
+		{name: "search", path: "/?url=https://example.com/", wantStatus: 302, wantLocation: "/web/*/https://example.com/"},
+		{name: "domains", path: "/domains", wantStatus: 200, wantBody: `href="/domains/example.com"`},
+		{name: "domain", path: "/domains/example.com", wantStatus: 200, wantBody: `href="/web/*/https://example.com/"`},
+	}
+
+	srv := newTestServer(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if loc := rec.Header().Get("Location"); loc != tt.wantLocation {
+				t.Errorf("location = %q, want %q", loc, tt.wantLocation)
+			}
+			body, _ := io.ReadAll(rec.Body)
+			if !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("body does not contain %q:\n%s", tt.wantBody, body)
+			}
+		})
+	}
+}
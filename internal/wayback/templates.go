@@ -0,0 +1,62 @@
+package wayback
+
+import "html/template"
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"web":       webPath,
+	"timestamp": formatTimestamp,
+}).Parse(`
+{{define "header"}}<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.}} - nimbus archive</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: 2em auto; padding: 0 1em; }
+form input[type=text] { width: 70%; }
+ul.captures { columns: 3; }
+</style></head><body>
+<p><a href="/">nimbus archive</a> · <a href="/domains">domains</a></p>
+<form action="/" method="get"><input type="text" name="url" placeholder="https://example.com/"> <button>Browse</button></form>
+{{end}}
+
+{{define "footer"}}</body></html>
+{{end}}
+
+{{define "index"}}{{template "header" "Search"}}
+<p>Enter a URL to list its captures, or browse the archive by domain.</p>
+{{template "footer"}}{{end}}
+
+{{define "captures"}}{{template "header" .URL}}
+<h1>{{.URL}}</h1>
+<p>{{.Count}} capture(s) between {{.First.Format "Jan 2, 2006"}} and {{.Last.Format "Jan 2, 2006"}}.</p>
+{{range .Years}}<h2>{{.Year}}</h2>
+{{range .Months}}<h3>{{.Month}}</h3>
+<ul class="captures">{{range .Captures}}
+<li><a href="{{web (timestamp .Time) $.URL}}">{{.Time.Format "Jan 2 15:04:05"}}</a></li>{{end}}
+</ul>{{end}}{{end}}
+{{template "footer"}}{{end}}
+
+{{define "notArchived"}}{{template "header" "Not archived"}}
+<h1>Not archived</h1>
+<p>{{.}} has not been archived.</p>
+{{template "footer"}}{{end}}
+
+{{define "domains"}}{{template "header" "Domains"}}
+<h1>Domains</h1>
+<table><tr><th>Domain</th><th>Pages</th><th>Last crawled</th></tr>
+{{range .Domains}}<tr><td><a href="/domains/{{.Domain}}">{{.Domain}}</a></td><td>{{.ArchivedURLs}}</td><td>{{with .LastCrawlTime}}{{.Format "2006-01-02 15:04"}}{{end}}</td></tr>
+{{end}}</table>
+{{with .Next}}<p><a href="?after={{.}}">Next page</a></p>{{end}}
+{{template "footer"}}{{end}}
+
+{{define "domain"}}{{template "header" .Domain}}
+<h1>{{.Domain}}</h1>
+<ul>{{range .URLs}}
+<li><a href="{{web "*" .URL}}">{{.URL}}</a>{{with .LastCrawlTime}} · {{.Format "2006-01-02"}}{{end}}</li>{{end}}
+</ul>
+{{with .Next}}<p><a href="?after={{.}}">Next page</a></p>{{end}}
+{{template "footer"}}{{end}}
+
+{{define "banner"}}<div style="all:initial;display:block;font:13px sans-serif;background:#ffd;border-bottom:1px solid #cc9;padding:4px 8px;color:#000">
+Archived copy of <a style="color:#00c" href="{{.URL}}">{{.URL}}</a> captured {{.Time.Format "Jan 2, 2006 15:04:05 MST"}} ·
+<a style="color:#00c" href="{{web "*" .URL}}">all captures</a></div>
+{{end}}
+`))
@@ -0,0 +1,42 @@
+package wayback
+
+import (
+	"fmt"
+	"time"
+)
+
+// timestampLayout is the 14-digit capture timestamp used in archive URLs.
+const timestampLayout = "20060102150405"
+
+// formatTimestamp formats t for archive URLs.
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(timestampLayout)
+}
+
+// parseTimestamp parses a full or truncated archive timestamp such as
+// "2026", "202603" or "20260304150405". A truncated timestamp means the end
+// of its period, so "2026" selects the last capture made in 2026 or before.
+func parseTimestamp(ts string) (time.Time, error) {
+	periods := []struct {
+		layout string
+		next   func(time.Time) time.Time
+	}{
+		{"2006", func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }},
+		{"200601", func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }},
+		{"20060102", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }},
+		{"2006010215", func(t time.Time) time.Time { return t.Add(time.Hour) }},
+		{"200601021504", func(t time.Time) time.Time { return t.Add(time.Minute) }},
+		{timestampLayout, func(t time.Time) time.Time { return t.Add(time.Second) }},
+	}
+	for _, p := range periods {
+		if len(ts) != len(p.layout) {
+			continue
+		}
+		t, err := time.Parse(p.layout, ts)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid timestamp %q", ts)
+		}
+		return p.next(t).Add(-time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", ts)
+}
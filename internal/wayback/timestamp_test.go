@@ -0,0 +1,35 @@
+package wayback
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		ts      string
+		want    time.Time
+		wantErr bool
+	}{
+		{ts: "20260304050607", want: time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)},
+		{ts: "2026", want: time.Date(2026, 12, 31, 23, 59, 59, 0, time.UTC)},
+		{ts: "202602", want: time.Date(2026, 2, 28, 23, 59, 59, 0, time.UTC)},
+		{ts: "20260304", want: time.Date(2026, 3, 4, 23, 59, 59, 0, time.UTC)},
+		{ts: "20261", wantErr: true},
+		{ts: "2026x304", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ts, func(t *testing.T) {
+			t.Parallel()
+			got, err := parseTimestamp(tt.ts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTimestamp(%q) error = %v, wantErr %v", tt.ts, err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseTimestamp(%q) = %v, want %v", tt.ts, got, tt.want)
+			}
+		})
+	}
+}